@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/icholy/kilo/editor"
+)
+
+// configDir is where the user's kilorc lives.
+func configDir() string {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "kilo")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config", "kilo")
+}
+
+// socketPath is where --remote finds the editor to open files in, or
+// makes a new one listen for RPC clients. Whoever can reach the socket
+// can run commands, so it's only put in XDG_RUNTIME_DIR when that's a
+// directory of the user's own which nobody else can enter.
+func socketPath() (string, error) {
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		return "", errors.New("XDG_RUNTIME_DIR isn't set, give the socket with --listen")
+	}
+	fi, err := os.Stat(dir)
+	if err != nil {
+		return "", err
+	}
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !fi.IsDir() || fi.Mode().Perm() != 0700 || !ok || int(st.Uid) != os.Getuid() {
+		return "", fmt.Errorf("%s isn't a directory of your own with permissions 0700, give the socket with --listen", dir)
+	}
+	return filepath.Join(dir, fmt.Sprintf("kilo-%d.sock", os.Getuid())), nil
+}
+
+func configFile() string {
+	return filepath.Join(configDir(), "kilorc")
+}
+
+// loadConfig applies the settings in a kilorc file. Every command line
+// flag can be set, one per line:
+//
+//	# comment
+//	expandtab = true
+//	statusline = %f %m%=%l:%c
+func loadConfig(name string) error {
+	f, err := os.Open(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	var lineno int
+	for sc.Scan() {
+		lineno++
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			key, value = line, "true"
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if err := flag.Set(key, value); err != nil {
+			return fmt.Errorf("%s:%d: %v", name, lineno, err)
+		}
+	}
+	return sc.Err()
+}
+
+// loadInitScript runs the user's init.ked and init.lua, which can define
+// commands, hooks, and key bindings.
+func loadInitScript(e *editor.Editor) error {
+	for _, name := range []string{"init.ked", "init.lua"} {
+		name = filepath.Join(configDir(), name)
+		f, err := os.Open(name)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if filepath.Ext(name) == ".lua" {
+			err = e.RunLua(name, f)
+		} else {
+			err = e.RunScript(name, f)
+		}
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
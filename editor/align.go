@@ -0,0 +1,94 @@
+package editor
+
+import (
+	"bytes"
+	"strings"
+	"unicode/utf8"
+)
+
+// alignFields splits a line into the fields to align. Without a
+// delimiter the fields are separated by blanks. Assignments and
+// key: value pairs are only split at the first delimiter, so the values
+// can contain it, e.g. a == b.
+func alignFields(line []byte, delim string) [][]byte {
+	if delim == "" {
+		return bytes.Fields(line)
+	}
+	n := -1
+	if delim == "=" || delim == ":" {
+		n = 2
+	}
+	fields := bytes.SplitN(line, []byte(delim), n)
+	for i, f := range fields {
+		fields[i] = bytes.TrimSpace(f)
+	}
+	return fields
+}
+
+// align lines up the delimiters in the selected lines, like column -t,
+// padding the fields before them with spaces. The delimiter defaults to
+// blanks. Commas and colons stay attached to the field before them, and
+// other delimiters get a space on either side. Lines without the
+// delimiter are left alone.
+func (e *Editor) align(args string) {
+	delim := strings.TrimSpace(args)
+	start, end := e.selectedRows()
+	end = clamp(end, 0, e.buf.NumRows()-1)
+	if start > end {
+		return
+	}
+	rows := make([][][]byte, end-start+1)
+	var widths []int
+	for y := start; y <= end; y++ {
+		fields := alignFields(e.buf.Rows[y].Chars, delim)
+		if len(fields) < 2 {
+			continue
+		}
+		rows[y-start] = fields
+		for i, f := range fields {
+			if i == len(widths) {
+				widths = append(widths, 0)
+			}
+			if n := utf8.RuneCount(f); n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+	var lines [][]byte
+	for y := start; y <= end; y++ {
+		chars := e.buf.Rows[y].Chars
+		fields := rows[y-start]
+		if fields == nil {
+			lines = append(lines, chars)
+			continue
+		}
+		indent := chars[:len(chars)-len(bytes.TrimLeft(chars, " \t"))]
+		line := append([]byte(nil), indent...)
+		for i, f := range fields {
+			line = append(line, f...)
+			if i == len(fields)-1 {
+				break
+			}
+			pad := bytes.Repeat([]byte(" "), widths[i]-utf8.RuneCount(f))
+			switch {
+			case delim == "":
+				line = append(append(line, pad...), ' ')
+			case delim == "," || delim == ":":
+				line = append(append(append(line, delim...), pad...), ' ')
+			case i == 0 && widths[0] == 0:
+				// a table row which starts with the delimiter
+				line = append(append(line, delim...), ' ')
+			default:
+				line = append(append(line, pad...), " "+delim+" "...)
+			}
+		}
+		lines = append(lines, bytes.TrimRight(line, " "))
+	}
+	text := bytes.Join(lines, []byte("\n"))
+	if bytes.Equal(text, e.getRange(start, 0, end, e.buf.Rows[end].Len())) {
+		return
+	}
+	cx, cy := e.cx, e.cy
+	e.replaceRange(start, 0, end, e.buf.Rows[end].Len(), text)
+	e.moveTo(cx, cy)
+}
@@ -0,0 +1,31 @@
+package editor
+
+import (
+	"bufio"
+	"io"
+)
+
+// RunScript runs the commands in a script, one per line. Blank lines
+// and lines starting with # are skipped. Commands which would prompt
+// for input are cancelled when there's no terminal. The script stops at
+// the first command which fails, and its error is returned. For example:
+//
+//	# bump the version
+//	goto 1
+//	replace /0.0.1/0.0.2/
+//	insert // Code generated by hand.\n
+//	save
+//
+// Scripts can also define commands, hooks, and key bindings, see
+// runScript, and Lua scripts can do more, see RunLua.
+func (e *Editor) RunScript(name string, r io.Reader) error {
+	var lines []string
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		lines = append(lines, sc.Text())
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	return e.runScript(name, lines)
+}
@@ -0,0 +1,219 @@
+package editor
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// exprNumber matches the numbers in expressions: decimals with an
+// optional fraction and exponent, and integers with a 0x, 0o, or 0b
+// prefix.
+var exprNumber = regexp.MustCompile(`^(0[xX][0-9a-fA-F_]+|0[oO][0-7_]+|0[bB][01_]+|([0-9][0-9_]*(\.[0-9_]*)?|\.[0-9_]+)([eE][-+]?[0-9]+)?)`)
+
+// exprParser evaluates arithmetic expressions by recursive descent.
+type exprParser struct {
+	s string
+	i int
+}
+
+// evaluate computes an arithmetic expression with + - * / %, ^ or **
+// for powers, and parentheses.
+func evaluate(expr string) (float64, error) {
+	p := &exprParser{s: expr}
+	v, err := p.sum()
+	if err != nil {
+		return 0, err
+	}
+	if p.skip(); p.i < len(p.s) {
+		return 0, fmt.Errorf("unexpected %q", p.s[p.i:])
+	}
+	return v, nil
+}
+
+// skip skips blanks.
+func (p *exprParser) skip() {
+	for p.i < len(p.s) && (p.s[p.i] == ' ' || p.s[p.i] == '\t') {
+		p.i++
+	}
+}
+
+// accept consumes op if it's next.
+func (p *exprParser) accept(op string) bool {
+	p.skip()
+	if strings.HasPrefix(p.s[p.i:], op) {
+		p.i += len(op)
+		return true
+	}
+	return false
+}
+
+func (p *exprParser) sum() (float64, error) {
+	v, err := p.product()
+	for err == nil {
+		var w float64
+		switch {
+		case p.accept("+"):
+			w, err = p.product()
+			v += w
+		case p.accept("-"):
+			w, err = p.product()
+			v -= w
+		default:
+			return v, nil
+		}
+	}
+	return 0, err
+}
+
+func (p *exprParser) product() (float64, error) {
+	v, err := p.unary()
+	for err == nil {
+		var w float64
+		switch {
+		case p.accept("*"):
+			w, err = p.unary()
+			v *= w
+		case p.accept("/"):
+			if w, err = p.unary(); err == nil && w == 0 {
+				err = errors.New("division by zero")
+			}
+			v /= w
+		case p.accept("%"):
+			if w, err = p.unary(); err == nil && w == 0 {
+				err = errors.New("division by zero")
+			}
+			v = math.Mod(v, w)
+		default:
+			return v, nil
+		}
+	}
+	return 0, err
+}
+
+// unary binds less tightly than powers, so -2^2 is -4.
+func (p *exprParser) unary() (float64, error) {
+	switch {
+	case p.accept("-"):
+		v, err := p.unary()
+		return -v, err
+	case p.accept("+"):
+		return p.unary()
+	}
+	return p.power()
+}
+
+// power is right associative, so 2^3^2 is 2^9.
+func (p *exprParser) power() (float64, error) {
+	v, err := p.primary()
+	if err != nil {
+		return 0, err
+	}
+	if p.accept("^") || p.accept("**") {
+		w, err := p.unary()
+		if err != nil {
+			return 0, err
+		}
+		return math.Pow(v, w), nil
+	}
+	return v, nil
+}
+
+func (p *exprParser) primary() (float64, error) {
+	if p.accept("(") {
+		v, err := p.sum()
+		if err != nil {
+			return 0, err
+		}
+		if !p.accept(")") {
+			return 0, errors.New("missing )")
+		}
+		return v, nil
+	}
+	m := exprNumber.FindString(p.s[p.i:])
+	if m == "" {
+		if p.i == len(p.s) {
+			return 0, errors.New("unexpected end of expression")
+		}
+		return 0, fmt.Errorf("unexpected %q", p.s[p.i:])
+	}
+	p.i += len(m)
+	if len(m) > 1 && strings.ContainsAny(m[1:2], "xXoObB") {
+		n, err := strconv.ParseInt(m, 0, 64)
+		return float64(n), err
+	}
+	return strconv.ParseFloat(strings.ReplaceAll(m, "_", ""), 64)
+}
+
+// formatNumber formats the result of an expression, without an exponent
+// for integers, and rounded to 12 significant digits otherwise so that
+// 0.1+0.2 is 0.3.
+func formatNumber(v float64) string {
+	if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+		return strconv.FormatInt(int64(v), 10)
+	}
+	return strconv.FormatFloat(v, 'g', 12, 64)
+}
+
+// exprAt returns the bounds of the expression around the cursor, which
+// is the run of digits, operators, parentheses, and blanks it's in.
+func (e *Editor) exprAt() (x0, x1 int, ok bool) {
+	if e.cy >= e.buf.NumRows() {
+		return 0, 0, false
+	}
+	chars := e.buf.Rows[e.cy].Chars
+	isExpr := func(c byte) bool {
+		return '0' <= c && c <= '9' || strings.IndexByte(".+-*/%^() \t", c) >= 0
+	}
+	x0, x1 = e.cx, e.cx
+	for x0 > 0 && isExpr(chars[x0-1]) {
+		x0--
+	}
+	for x1 < len(chars) && isExpr(chars[x1]) {
+		x1++
+	}
+	// leave out the blanks around it
+	for x0 < x1 && (chars[x0] == ' ' || chars[x0] == '\t') {
+		x0++
+	}
+	for x1 > x0 && (chars[x1-1] == ' ' || chars[x1-1] == '\t') {
+		x1--
+	}
+	return x0, x1, x0 < x1
+}
+
+// calc implements the calc command. It evaluates the expression given
+// as the argument, the selection, or the expression around the cursor.
+// A selected expression is replaced with its result, and otherwise the
+// result is shown in the status bar.
+func (e *Editor) calc(args string) {
+	expr := strings.TrimSpace(args)
+	y0, x0, y1, x1, selected := e.selectionBounds()
+	switch {
+	case expr != "":
+		selected = false
+	case selected:
+		expr = string(e.getRange(y0, x0, y1, x1))
+	default:
+		var ok bool
+		if x0, x1, ok = e.exprAt(); !ok {
+			e.fail("calc: no expression at the cursor")
+			return
+		}
+		expr = string(e.buf.Rows[e.cy].Chars[x0:x1])
+	}
+	v, err := evaluate(strings.Join(strings.Fields(expr), " "))
+	if err != nil {
+		e.fail("calc: %v", err)
+		return
+	}
+	result := formatNumber(v)
+	if selected {
+		e.cy, e.cx = e.replaceRange(y0, x0, y1, x1, []byte(result))
+		e.selection.active = false
+	}
+	e.setStatus("%s = %s", strings.TrimSpace(expr), result)
+}
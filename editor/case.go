@@ -0,0 +1,84 @@
+package editor
+
+import (
+	"bytes"
+	"unicode"
+	"unicode/utf8"
+)
+
+// mapRunes applies fn to every rune in text. The position argument
+// is true for the first letter of a word.
+func mapRunes(text []byte, fn func(r rune, first bool) rune) []byte {
+	var out []byte
+	inword := false
+	for len(text) > 0 {
+		r, size := utf8.DecodeRune(text)
+		if r == utf8.RuneError && size == 1 {
+			// keep invalid bytes as they are
+			out = append(out, text[0])
+			text = text[1:]
+			continue
+		}
+		text = text[size:]
+		isword := unicode.IsLetter(r) || unicode.IsDigit(r) || r == '\'' && inword
+		out = utf8.AppendRune(out, fn(r, isword && !inword))
+		inword = isword
+	}
+	return out
+}
+
+var caseTransforms = map[string]func([]byte) []byte{
+	"upper": bytes.ToUpper,
+	"lower": bytes.ToLower,
+	"toggle": func(b []byte) []byte {
+		return mapRunes(b, func(r rune, _ bool) rune {
+			if unicode.IsUpper(r) {
+				return unicode.ToLower(r)
+			}
+			return unicode.ToUpper(r)
+		})
+	},
+	"title": func(b []byte) []byte {
+		return mapRunes(b, func(r rune, first bool) rune {
+			if first {
+				return unicode.ToTitle(r)
+			}
+			return unicode.ToLower(r)
+		})
+	},
+}
+
+// wordRange returns the bounds of the word at the cursor.
+func (e *Editor) wordRange() (y, x0, x1 int, ok bool) {
+	if e.cy >= e.buf.NumRows() {
+		return 0, 0, 0, false
+	}
+	chars := e.buf.Rows[e.cy].Chars
+	x0, x1 = e.cx, e.cx
+	for x0 > 0 && e.isWordByte(chars[x0-1]) {
+		x0--
+	}
+	for x1 < len(chars) && e.isWordByte(chars[x1]) {
+		x1++
+	}
+	return e.cy, x0, x1, x0 != x1
+}
+
+// changeCase transforms the selection, or the word under the cursor.
+func (e *Editor) changeCase(name string) {
+	fn, ok := caseTransforms[name]
+	if !ok {
+		e.fail("unknown case: %s", name)
+		return
+	}
+	y0, x0, y1, x1, ok := e.selectionBounds()
+	if !ok {
+		var y int
+		if y, x0, x1, ok = e.wordRange(); !ok {
+			return
+		}
+		y0, y1 = y, y
+	}
+	text := fn(e.getRange(y0, x0, y1, x1))
+	e.cy, e.cx = e.replaceRange(y0, x0, y1, x1, text)
+}
@@ -0,0 +1,98 @@
+package editor
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/icholy/kilo/internal/buffer"
+	"github.com/icholy/kilo/internal/ui"
+	"golang.org/x/exp/slices"
+)
+
+// defaultColorPreview is the filetypes whose color literals are shown
+// in their color.
+const defaultColorPreview = "css,html"
+
+var (
+	// hexColor matches #rgb, #rgba, #rrggbb and #rrggbbaa, but not an
+	// html character reference like &#123;
+	hexColor = regexp.MustCompile(`(?:^|[^\w&])(#(?:[0-9a-fA-F]{8}|[0-9a-fA-F]{6}|[0-9a-fA-F]{3,4}))\b`)
+	// rgbColor matches rgb() and rgba() with the components separated
+	// by commas or blanks, and an optional alpha.
+	rgbColor = regexp.MustCompile(`\brgba?\(\s*(\d{1,3})(?:\s*,\s*|\s+)(\d{1,3})(?:\s*,\s*|\s+)(\d{1,3})\s*(?:[,/]\s*[\d.]+%?\s*)?\)`)
+)
+
+// colorPreview reports whether the color literals of the buffer's
+// filetype are previewed.
+func (e *Editor) colorPreview() bool {
+	if e.buf.Syntax == nil {
+		return false
+	}
+	return slices.Contains(strings.Split(e.colorpreview, ","), e.buf.Syntax.Filetype)
+}
+
+// colorSwatches finds the color literals in a row.
+func (e *Editor) colorSwatches(row *buffer.Row) []ui.Swatch {
+	var swatches []ui.Swatch
+	add := func(start, end int, r, g, b uint8) {
+		swatches = append(swatches, ui.Swatch{Start: row.CxToRx(start), End: row.CxToRx(end), R: r, G: g, B: b})
+	}
+	for _, m := range hexColor.FindAllSubmatchIndex(row.Chars, -1) {
+		start, end := m[2], m[3]
+		if r, g, b, ok := parseHexColor(string(row.Chars[start+1 : end])); ok {
+			add(start, end, r, g, b)
+		}
+	}
+	for _, m := range rgbColor.FindAllSubmatchIndex(row.Chars, -1) {
+		var c [3]uint8
+		ok := true
+		for i := range c {
+			n, err := strconv.Atoi(string(row.Chars[m[2+2*i]:m[3+2*i]]))
+			if err != nil || n > 255 {
+				ok = false
+			}
+			c[i] = uint8(n)
+		}
+		if ok {
+			add(m[0], m[1], c[0], c[1], c[2])
+		}
+	}
+	return swatches
+}
+
+// parseHexColor parses the digits of a hex color, ignoring its alpha.
+func parseHexColor(hex string) (r, g, b uint8, ok bool) {
+	if len(hex) == 3 || len(hex) == 4 {
+		// each digit is repeated: #f80 is #ff8800
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	n, err := strconv.ParseUint(hex[:6], 16, 32)
+	if err != nil {
+		return 0, 0, 0, false
+	}
+	return uint8(n >> 16), uint8(n >> 8), uint8(n), true
+}
+
+// toggleColorPreview implements the color-preview command, which turns
+// showing color literals in their color on or off for the filetype.
+func (e *Editor) toggleColorPreview() {
+	if e.buf.Syntax == nil {
+		e.fail("color-preview: the buffer has no filetype")
+		return
+	}
+	ft := e.buf.Syntax.Filetype
+	var filetypes []string
+	for _, f := range strings.Split(e.colorpreview, ",") {
+		if f != "" && f != ft {
+			filetypes = append(filetypes, f)
+		}
+	}
+	if e.colorPreview() {
+		e.setStatus("hiding %s colors", ft)
+	} else {
+		filetypes = append(filetypes, ft)
+		e.setStatus("showing %s colors", ft)
+	}
+	e.colorpreview = strings.Join(filetypes, ",")
+}
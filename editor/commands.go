@@ -0,0 +1,188 @@
+package editor
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/icholy/kilo/internal/fuzzy"
+	"golang.org/x/exp/slices"
+)
+
+type Command struct {
+	name string
+	help string
+	fn   func(e *Editor, args string)
+}
+
+var commands []Command
+
+func init() {
+	commands = []Command{
+		{"save", "save the current file", func(e *Editor, _ string) { e.save() }},
+		{"set", "change an option: set tabstop=4, set list, set nolist, set list!, set tabstop?", (*Editor).set},
+		{"reload-config", "apply the kilorc again", func(e *Editor, _ string) { e.reloadConfig() }},
+		{"open", "open a file, Tab completes its name", (*Editor).openFile},
+		{"find-file", "fuzzy find a file under the working directory by its path", (*Editor).findFile},
+		{"recent", "fuzzy find a recently opened file and switch to it", (*Editor).pickRecent},
+		{"terminal", "run a command, or a shell, in a pane below the buffer, Ctrl-\\ moves between them", (*Editor).openTerminal},
+		{"run", "save and run the file in the terminal pane with the command given or the filetype's, %f is the file name", (*Editor).runFile},
+		{"close-terminal", "close the terminal pane, killing its command", func(e *Editor, _ string) { e.closeTerminal() }},
+		{"suspend", "stop the editor and go back to the shell, fg resumes it", func(e *Editor, _ string) { e.suspend() }},
+		{"find-next", "jump to the next match of the last search", func(e *Editor, _ string) { e.findNext(1) }},
+		{"find-prev", "jump to the previous match of the last search", func(e *Editor, _ string) { e.findNext(-1) }},
+		{"find", "search forward from the cursor, or jump to the next match of the argument", func(e *Editor, args string) {
+			if args == "" {
+				e.find(1)
+				return
+			}
+			e.searchquery = args
+			e.findNext(1)
+		}},
+		{"find-backward", "search backward from the cursor, or jump to the previous match of the argument", func(e *Editor, args string) {
+			if args == "" {
+				e.find(-1)
+				return
+			}
+			e.searchquery = args
+			e.findNext(-1)
+		}},
+		{"replace", "replace every occurrence in the buffer or selection: /old/new/", (*Editor).replaceAll},
+		{"replace-regex", "replace every match of a regular expression in the buffer or selection: /(\\w+)_id/\\u$1ID/", (*Editor).replaceRegex},
+		{"bind", "bind a key to a command: bind ctrl-t header", (*Editor).bind},
+		{"on", "run a command on an event: on save *.go format", (*Editor).addHook},
+		{"plugin", "start a plugin which speaks JSON-RPC over stdio", (*Editor).loadPlugin},
+		{"lua", "run a Lua script, which can edit the buffer and add commands, key bindings, and hooks", (*Editor).runLuaFile},
+		{"stats", "count the lines, words, characters, and bytes of the buffer and selection", func(e *Editor, _ string) { e.stats() }},
+		{"export", "write the highlighted buffer to a file: html|ansi <file>", (*Editor).exportFile},
+		{"write", "write the selection or lines to another file: [start,end] [>>] <file>", (*Editor).writeRange},
+		{"diff", "show the unsaved changes as a unified diff, Enter jumps to a line", func(e *Editor, _ string) { e.diffSaved() }},
+		{"revert", "discard the unsaved changes by loading the file again", func(e *Editor, _ string) { e.revert() }},
+		{"insert", "insert text at the cursor, \\n starts a new line, and ${date}, ${filename}, ${user} and the like are expanded", (*Editor).insertText},
+		{"timestamp", "insert the date and time in the strftime format given, or the timestamp-format setting", (*Editor).insertTimestamp},
+		{"digraph", "insert the character two others stand for, Ctrl-D reads them, a: for ä or -> for →, or define one: digraph :) U+263A", (*Editor).digraph},
+		{"digraphs", "list the digraphs and insert the one chosen", func(e *Editor, _ string) { e.listDigraphs() }},
+		{"complete", "show completions at the cursor", func(e *Editor, _ string) { e.completion() }},
+		{"complete-word", "complete the word before the cursor with the words in the edited files", func(e *Editor, _ string) { e.completeWord() }},
+		{"definition", "jump to the definition of the symbol under the cursor", func(e *Editor, _ string) { e.definition() }},
+		{"references", "list references to the symbol under the cursor", func(e *Editor, _ string) { e.references() }},
+		{"rename", "rename the symbol under the cursor", func(e *Editor, _ string) { e.rename() }},
+		{"hover", "show documentation for the symbol under the cursor", func(e *Editor, _ string) { e.hover() }},
+		{"outline", "toggle a sidebar listing the functions, types or headings of the buffer, Enter jumps to one, or focus it with: focus", (*Editor).toggleOutline},
+		{"tag", "jump to the tag under the cursor", func(e *Editor, _ string) { e.tagJump() }},
+		{"jump-back", "return to the previous location in the jump list", func(e *Editor, _ string) { e.jumpOlder() }},
+		{"jump-forward", "go to the next location in the jump list", func(e *Editor, _ string) { e.jumpNewer() }},
+		{"goto", "go to a line number, or line:column where the column counts tabs as their width", (*Editor).gotoLine},
+		{"comment", "toggle comment on the current line or selection", func(e *Editor, _ string) { e.toggleComment(e.selectedRows()) }},
+		{"build", "run the build command and collect its errors", (*Editor).build},
+		{"task", "run a task from the project's .kilo/tasks.toml, or choose one, and list its errors", (*Editor).runTask},
+		{"test", "run the tests, go test ./... by default, and mark the failed ones", (*Editor).runTests},
+		{"next-error", "jump to the next build error", func(e *Editor, _ string) { e.nextError(1) }},
+		{"prev-error", "jump to the previous build error", func(e *Editor, _ string) { e.nextError(-1) }},
+		{"errors", "list the build errors", func(e *Editor, _ string) { e.listErrors() }},
+		{"diagnostics", "toggle showing the language server's diagnostics and the build errors after their lines", func(e *Editor, _ string) { e.toggleDiagnostics() }},
+		{"todos", "list the TODO, FIXME and other todo-keywords in comments, in the project's files too with: all", (*Editor).listTodos},
+		{"messages", "show the message log", func(e *Editor, _ string) { e.showMessages() }},
+		{"move-up", "move the current line or selection up", func(e *Editor, _ string) { e.moveLines(-1) }},
+		{"move-down", "move the current line or selection down", func(e *Editor, _ string) { e.moveLines(1) }},
+		{"duplicate", "duplicate the current line or selection", func(e *Editor, _ string) { e.duplicateLines() }},
+		{"case", "change the case of the selection or word: upper, lower, toggle, title", (*Editor).changeCase},
+		{"encode", "encode the selection as base64, url, or a json string", func(e *Editor, args string) { e.transcode(args, false) }},
+		{"decode", "decode the selection from base64, url, or a json string", func(e *Editor, args string) { e.transcode(args, true) }},
+		{"sort", "sort the selected lines, options: reverse, numeric", (*Editor).sort},
+		{"uniq", "remove adjacent duplicate lines from the selection, or all duplicates with: all", (*Editor).uniq},
+		{"retab", "convert the indentation of the selection or buffer to spaces or tabs at the tab stop: [spaces|tabs] [width]", (*Editor).retab},
+		{"line-endings", "convert the buffer to lf or crlf line endings, or show which it has", (*Editor).setLineEndings},
+		{"indent", "indent the current line or selection", func(e *Editor, _ string) { e.indentLines(1) }},
+		{"dedent", "dedent the current line or selection", func(e *Editor, _ string) { e.indentLines(-1) }},
+		{"checkbox", "check or uncheck the markdown task list checkboxes on the current line or selection", func(e *Editor, _ string) { e.toggleCheckbox() }},
+		{"align", "line up the selected lines on a delimiter, e.g. =, :, |, or a comma, by default on blanks", (*Editor).align},
+		{"reflow", "rewrap the paragraph or selection to textwidth, or the width given, keeping indentation and comment leaders", (*Editor).reflow},
+		{"kill-line", "delete the current line into the kill ring", func(e *Editor, _ string) { e.killLine() }},
+		{"yank", "insert the last killed text", func(e *Editor, _ string) { e.yank() }},
+		{"copy", "copy the selection or line into a register: a-z, A-Z to append, \" or +, the kill ring by default", (*Editor).copyText},
+		{"cut", "move the selection or line into a register", (*Editor).cutText},
+		{"paste", "insert the text in a register, the last kill by default", (*Editor).pasteText},
+		{"increment", "add to the number under or after the cursor, 1 by default", func(e *Editor, args string) { e.incrementCommand(args, 1) }},
+		{"decrement", "subtract from the number under or after the cursor, 1 by default", func(e *Editor, args string) { e.incrementCommand(args, -1) }},
+		{"calc", "evaluate an arithmetic expression, the selection which is replaced by the result, or the expression at the cursor", (*Editor).calc},
+		{"sequence", "insert incrementing numbers down the selected lines: [start [step [format]]], e.g. 1 1 \"%d. \"", (*Editor).insertSequence},
+		{"registers", "list the registers and paste the one chosen", func(e *Editor, _ string) { e.listRegisters() }},
+		{"undo", "undo the last change", func(e *Editor, _ string) { e.undoChange() }},
+		{"redo", "redo the last undone change", func(e *Editor, _ string) { e.redoChange() }},
+		{"undo-tree", "list every state of the buffer, including undone branches, and go back to one", func(e *Editor, _ string) { e.undoTree() }},
+		{"format", "run the filetype's formatter over the buffer", func(e *Editor, _ string) { e.format() }},
+		{"json-format", "pretty-print the selection or buffer as JSON", func(e *Editor, _ string) { e.formatData("json") }},
+		{"yaml-format", "normalize the selection or buffer as YAML with yq", func(e *Editor, _ string) { e.formatData("yaml") }},
+		{"validate", "check the selection or buffer is valid json or yaml, by default the filetype, and jump to the error", (*Editor).validateData},
+		{"scrollbar", "toggle the scrollbar", func(e *Editor, _ string) { e.toggleScrollbar() }},
+		{"spell", "toggle spell checking", func(e *Editor, _ string) { e.toggleSpell() }},
+		{"center", "scroll the cursor line to the middle of the screen", func(e *Editor, _ string) { e.centerCursor() }},
+		{"cursorline", "toggle highlighting the cursor line, or the cursor column with: column", (*Editor).toggleCursorLine},
+		{"highlight-word", "toggle highlighting occurrences of the word under the cursor", func(e *Editor, _ string) { e.toggleOccurrences() }},
+		{"whitespace", "toggle showing tabs and trailing whitespace", func(e *Editor, _ string) { e.toggleWhitespace() }},
+		{"color-preview", "toggle showing the color literals of the filetype, like #ff8800, in their color", func(e *Editor, _ string) { e.toggleColorPreview() }},
+		{"spell-suggest", "suggest corrections for the word under the cursor", func(e *Editor, _ string) { e.spellSuggest() }},
+	}
+	sort.Slice(commands, func(i, j int) bool {
+		return commands[i].name < commands[j].name
+	})
+}
+
+// allCommands returns the built in commands followed by the ones
+// defined by scripts.
+func (e *Editor) allCommands() []Command {
+	return append(slices.Clip(commands), e.usercmds...)
+}
+
+// findCommand looks up a command by name or unique prefix.
+func (e *Editor) findCommand(name string) (Command, bool) {
+	var found []Command
+	for _, c := range e.allCommands() {
+		if c.name == name {
+			return c, true
+		}
+		if strings.HasPrefix(c.name, name) {
+			found = append(found, c)
+		}
+	}
+	if len(found) == 1 {
+		return found[0], true
+	}
+	return Command{}, false
+}
+
+// commandPrompt reads a command line and runs it. An empty or
+// ambiguous name shows a menu of the matching commands.
+func (e *Editor) commandPrompt() {
+	line, ok := e.prompt("Command:", nil)
+	if !ok {
+		return
+	}
+	e.runCommand(line)
+}
+
+func (e *Editor) runCommand(line string) {
+	name, args, _ := strings.Cut(strings.TrimSpace(line), " ")
+	if c, ok := e.findCommand(name); ok {
+		c.fn(e, strings.TrimSpace(args))
+		return
+	}
+	all := e.allCommands()
+	names := make([]string, len(all))
+	for i, c := range all {
+		names[i] = c.name
+	}
+	var matches []Command
+	var items []string
+	for _, i := range fuzzy.Rank(name, names) {
+		matches = append(matches, all[i])
+		items = append(items, all[i].name+" - "+all[i].help)
+	}
+	if len(matches) == 0 {
+		e.fail("unknown command: %s", name)
+		return
+	}
+	if idx := e.menu(items); idx >= 0 {
+		matches[idx].fn(e, strings.TrimSpace(args))
+	}
+}
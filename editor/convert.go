@@ -0,0 +1,137 @@
+package editor
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+
+	"github.com/icholy/kilo/internal/buffer"
+)
+
+// detectCRLF reports whether a file's lines end with CRLF, going by
+// the first line.
+func detectCRLF(data []byte) bool {
+	i := bytes.IndexByte(data, '\n')
+	return i > 0 && data[i-1] == '\r'
+}
+
+// newline returns the line ending the buffer is saved with.
+func (e *Editor) newline() string {
+	if e.crlf {
+		return "\r\n"
+	}
+	return "\n"
+}
+
+// lineEndingName returns the name of the buffer's line endings.
+func (e *Editor) lineEndingName() string {
+	if e.crlf {
+		return "crlf"
+	}
+	return "lf"
+}
+
+// setLineEndings implements the line-endings command, which converts
+// the buffer to lf or crlf line endings when it's saved, or shows which
+// it has. The conversion can be undone.
+func (e *Editor) setLineEndings(args string) {
+	var crlf bool
+	switch args {
+	case "":
+		e.setStatus("line endings: %s", e.lineEndingName())
+		return
+	case "lf", "unix":
+	case "crlf", "dos":
+		crlf = true
+	default:
+		e.fail("line-endings: expected lf or crlf: %s", args)
+		return
+	}
+	if crlf == e.crlf {
+		e.setStatus("the line endings are already %s", e.lineEndingName())
+		return
+	}
+	e.crlf = crlf
+	// the rows are the same, but the change is recorded for undo
+	e.buf.Changes++
+	e.dirty = true
+	e.setStatus("converted %d lines to %s", e.buf.NumRows(), e.lineEndingName())
+}
+
+// tabWidth returns the number of columns between the buffer's tab
+// stops.
+func (e *Editor) tabWidth() int {
+	switch {
+	case e.buf.TabStop > 0:
+		return e.buf.TabStop
+	case e.opts.TabStop > 0:
+		return e.opts.TabStop
+	}
+	return buffer.TabStop
+}
+
+// retab implements the retab command, which converts the indentation
+// of the selected lines, or of the buffer, to spaces or tabs. By default
+// the expandtab setting decides which, and tabs are as wide as the tab
+// stop. The arguments are spaces or tabs, and the width.
+func (e *Editor) retab(args string) {
+	spaces, width := e.expandtab, e.tabWidth()
+	for _, arg := range strings.Fields(args) {
+		switch arg {
+		case "spaces":
+			spaces = true
+		case "tabs":
+			spaces = false
+		default:
+			n, err := strconv.Atoi(arg)
+			if err != nil || n <= 0 {
+				e.fail("retab: expected spaces, tabs, or a width: %s", arg)
+				return
+			}
+			width = n
+		}
+	}
+	start, end := 0, e.buf.NumRows()-1
+	if _, _, _, _, ok := e.selectionBounds(); ok {
+		start, end = e.selectedRows()
+	}
+	var changed int
+	for y := start; y <= end && y < e.buf.NumRows(); y++ {
+		row := e.buf.Rows[y]
+		n, col := 0, 0
+		for ; n < len(row.Chars) && (row.Chars[n] == ' ' || row.Chars[n] == '\t'); n++ {
+			if row.Chars[n] == '\t' {
+				col += width - col%width
+			} else {
+				col++
+			}
+		}
+		var indent []byte
+		if spaces {
+			indent = bytes.Repeat([]byte(" "), col)
+		} else {
+			indent = append(bytes.Repeat([]byte("\t"), col/width), bytes.Repeat([]byte(" "), col%width)...)
+		}
+		if bytes.Equal(indent, row.Chars[:n]) {
+			continue
+		}
+		row.Chars = append(indent, row.Chars[n:]...)
+		row.Update()
+		if y == e.cy {
+			if e.cx >= n {
+				e.cx += len(indent) - n
+			} else if e.cx > len(indent) {
+				e.cx = len(indent)
+			}
+		}
+		changed++
+	}
+	if changed > 0 {
+		e.dirty = true
+	}
+	to := "tabs"
+	if spaces {
+		to = "spaces"
+	}
+	e.setStatus("retabbed %d lines to %s", changed, to)
+}
@@ -0,0 +1,56 @@
+package editor
+
+import "github.com/icholy/kilo/internal/term"
+
+// maxCount limits how many times a key can be repeated.
+const maxCount = 9999
+
+// uncounted are the keys which ignore the count because they prompt,
+// open a menu, or quit.
+var uncounted = map[int]bool{
+	term.ControlKey('q'):          true,
+	term.ControlKey('s'):          true,
+	term.ControlKey('f'):          true,
+	term.ControlKey('p'):          true,
+	term.ControlKey(' '):          true,
+	term.ControlKey('r'):          true,
+	term.ControlKey('n'):          true,
+	term.ControlKey('e'):          true,
+	term.AltKey('x'):              true,
+	term.F2:                       true,
+	term.F5:                       true,
+	term.F12:                      true,
+	term.ShiftModifier | term.F12: true,
+	term.MouseEvent:               true,
+	term.PasteEvent:               true,
+}
+
+// countKey handles Alt and a digit, which add the digit to the count
+// of times the next key is repeated, e.g. Alt-1 Alt-0 Down moves down
+// ten rows.
+func (e *Editor) countKey(c int) bool {
+	if c < term.AltKey('0') || c > term.AltKey('9') {
+		return false
+	}
+	e.count = e.count*10 + c - term.AltKey('0')
+	if e.count > maxCount {
+		e.count = maxCount
+	}
+	e.showStatus("count: %d", e.count)
+	return true
+}
+
+// takeCount returns how many times to repeat the key and resets the
+// count.
+func (e *Editor) takeCount(c int) int {
+	n := e.count
+	if n == 0 {
+		return 1
+	}
+	e.count = 0
+	e.showStatus("")
+	if uncounted[c] {
+		return 1
+	}
+	return n
+}
@@ -0,0 +1,129 @@
+package editor
+
+import (
+	"strings"
+
+	"golang.org/x/exp/slices"
+)
+
+// Severities of diagnostics, numbered like the language server
+// protocol does.
+const (
+	severityError = 1 + iota
+	severityWarning
+	severityInfo
+	severityHint
+)
+
+// diagnostic is a problem on a line of the current file, reported by
+// the language server, or by a build or lint command in the quickfix
+// list.
+type diagnostic struct {
+	cy       int
+	severity int
+	message  string
+}
+
+// fileDiagnostics returns the diagnostics of the current file ordered
+// by line, the most severe of a line first. There are none when they
+// aren't shown.
+func (e *Editor) fileDiagnostics() []diagnostic {
+	if !e.diagnostics {
+		return nil
+	}
+	var diags []diagnostic
+	if e.lsp != nil {
+		for _, d := range e.lsp.diagnostics {
+			msg, _, _ := strings.Cut(d.Message, "\n")
+			if d.Source != "" {
+				msg = d.Source + ": " + msg
+			}
+			severity := d.Severity
+			if severity == 0 {
+				severity = severityError
+			}
+			diags = append(diags, diagnostic{cy: d.Range.Start.Line, severity: severity, message: msg})
+		}
+	}
+	if e.filename != "" {
+		for _, qf := range e.quickfix {
+			// the todos are hints, whose messages are on the line already
+			if qf.severity == severityHint || !sameFile(qf.loc.filename, e.filename) {
+				continue
+			}
+			severity := qf.severity
+			if severity == 0 {
+				severity = quickfixSeverity(qf.message)
+			}
+			diags = append(diags, diagnostic{cy: qf.loc.cy, severity: severity, message: qf.message})
+		}
+	}
+	slices.SortStableFunc(diags, func(a, b diagnostic) bool {
+		if a.cy != b.cy {
+			return a.cy < b.cy
+		}
+		return a.severity < b.severity
+	})
+	return diags
+}
+
+// quickfixSeverity returns the severity of an error message from a
+// compiler or linter, which is an error unless it says otherwise.
+func quickfixSeverity(message string) int {
+	switch m := strings.ToLower(message); {
+	case strings.HasPrefix(m, "warning"):
+		return severityWarning
+	case strings.HasPrefix(m, "note"), strings.HasPrefix(m, "info"):
+		return severityInfo
+	}
+	return severityError
+}
+
+// diagnosticColor returns the foreground color parameter diagnostics
+// of a severity are drawn in.
+func diagnosticColor(severity int) int {
+	switch severity {
+	case severityError:
+		return 31
+	case severityWarning:
+		return 33
+	case severityInfo:
+		return 34
+	default:
+		return 36
+	}
+}
+
+// lineDiagnostics groups the diagnostics of the current file by line.
+func (e *Editor) lineDiagnostics() map[int][]diagnostic {
+	diags := e.fileDiagnostics()
+	if len(diags) == 0 {
+		return nil
+	}
+	lines := map[int][]diagnostic{}
+	for _, d := range diags {
+		lines[d.cy] = append(lines[d.cy], d)
+	}
+	return lines
+}
+
+// pollLSP sends the changes to the buffer to the language server, which
+// checks them, and handles what the server sent since it was last
+// polled. It reports whether the diagnostics changed.
+func (e *Editor) pollLSP() bool {
+	if e.lsp == nil {
+		return false
+	}
+	if e.diagnostics {
+		if err := e.lsp.Sync(); err != nil {
+			e.log(LogError, "lsp", "error", err)
+		}
+	}
+	return e.lsp.Poll()
+}
+
+// toggleDiagnostics shows or hides the diagnostics after the lines and
+// their counts in the gutter.
+func (e *Editor) toggleDiagnostics() {
+	e.diagnostics = !e.diagnostics
+}
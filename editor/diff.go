@@ -0,0 +1,230 @@
+package editor
+
+import (
+	"bytes"
+	"fmt"
+)
+
+const (
+	// diffContext is the number of unchanged lines shown around changes.
+	diffContext = 3
+	// diffLimit bounds the size of the table used to compare the changed
+	// part of two files. Bigger changes are shown as replacing it all.
+	diffLimit = 1 << 24
+)
+
+// diffOp is a line of a diff: ' ' for a line in both, '-' for a line
+// only in the old version, and '+' for a line only in the new version.
+// a and b are the indexes of the line in either version.
+type diffOp struct {
+	kind byte
+	a, b int
+}
+
+// diffLines compares two versions of a file line by line, by finding
+// their longest common subsequence.
+func diffLines(a, b [][]byte) []diffOp {
+	var ops []diffOp
+	// the common prefix and suffix are skipped to keep the table small
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && bytes.Equal(a[prefix], b[prefix]) {
+		ops = append(ops, diffOp{' ', prefix, prefix})
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && bytes.Equal(a[len(a)-1-suffix], b[len(b)-1-suffix]) {
+		suffix++
+	}
+	n, m := len(a)-prefix-suffix, len(b)-prefix-suffix
+	if n*m > diffLimit {
+		for i := 0; i < n; i++ {
+			ops = append(ops, diffOp{'-', prefix + i, prefix})
+		}
+		for j := 0; j < m; j++ {
+			ops = append(ops, diffOp{'+', prefix + n, prefix + j})
+		}
+	} else {
+		// lcs[i*(m+1)+j] is the length of the common subsequence of
+		// the changed lines from a[i] and b[j] on
+		lcs := make([]int32, (n+1)*(m+1))
+		for i := n - 1; i >= 0; i-- {
+			for j := m - 1; j >= 0; j-- {
+				k := i*(m+1) + j
+				switch {
+				case bytes.Equal(a[prefix+i], b[prefix+j]):
+					lcs[k] = lcs[k+m+2] + 1
+				case lcs[k+m+1] >= lcs[k+1]:
+					lcs[k] = lcs[k+m+1]
+				default:
+					lcs[k] = lcs[k+1]
+				}
+			}
+		}
+		i, j := 0, 0
+		for i < n || j < m {
+			k := i*(m+1) + j
+			switch {
+			case i < n && j < m && bytes.Equal(a[prefix+i], b[prefix+j]):
+				ops = append(ops, diffOp{' ', prefix + i, prefix + j})
+				i++
+				j++
+			case j == m || i < n && lcs[k+m+1] >= lcs[k+1]:
+				ops = append(ops, diffOp{'-', prefix + i, prefix + j})
+				i++
+			default:
+				ops = append(ops, diffOp{'+', prefix + i, prefix + j})
+				j++
+			}
+		}
+	}
+	for k := suffix; k > 0; k-- {
+		ops = append(ops, diffOp{' ', len(a) - k, len(b) - k})
+	}
+	return ops
+}
+
+// unifiedDiff formats the differences between two versions of a file
+// as a unified diff. It also returns the line of the new version each
+// line of the diff refers to.
+func unifiedDiff(name string, a, b [][]byte) (lines []string, targets []int) {
+	ops := diffLines(a, b)
+	add := func(line string, target int) {
+		lines = append(lines, line)
+		targets = append(targets, target)
+	}
+	for start := 0; start < len(ops); {
+		// find the next change, and the end of the hunk around it
+		for start < len(ops) && ops[start].kind == ' ' {
+			start++
+		}
+		if start == len(ops) {
+			break
+		}
+		end := start
+		for same := 0; end < len(ops) && same <= 2*diffContext; end++ {
+			if ops[end].kind == ' ' {
+				same++
+			} else {
+				same = 0
+			}
+		}
+		// trim the unchanged lines after the last change to the context
+		for end > start && ops[end-1].kind == ' ' {
+			end--
+		}
+		first := start - diffContext
+		if first < 0 {
+			first = 0
+		}
+		last := end + diffContext
+		if last > len(ops) {
+			last = len(ops)
+		}
+		if len(lines) == 0 {
+			add("--- "+name+" (saved)", 0)
+			add("+++ "+name+" (buffer)", 0)
+		}
+		var na, nb int
+		for _, op := range ops[first:last] {
+			if op.kind != '+' {
+				na++
+			}
+			if op.kind != '-' {
+				nb++
+			}
+		}
+		add(fmt.Sprintf("@@ -%s +%s @@", hunkRange(ops[first].a, na), hunkRange(ops[first].b, nb)), ops[first].b)
+		for _, op := range ops[first:last] {
+			switch op.kind {
+			case '-':
+				add("-"+string(a[op.a]), op.b)
+			default:
+				add(string(op.kind)+string(b[op.b]), op.b)
+			}
+		}
+		start = last
+	}
+	return lines, targets
+}
+
+// hunkRange formats the start and length of a hunk, which is the line
+// before the hunk when it's empty.
+func hunkRange(start, n int) string {
+	if n == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if n == 1 {
+		return fmt.Sprint(start + 1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, n)
+}
+
+// savedLines reads the file being edited, split into lines the way it's
+// loaded into the buffer.
+func (e *Editor) savedLines() ([][]byte, error) {
+	data, err := readFile(e.filename)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimSuffix(data, []byte("\n"))
+	if len(data) == 0 {
+		return nil, nil
+	}
+	lines := bytes.Split(data, []byte("\n"))
+	for i, line := range lines {
+		lines[i] = bytes.TrimSuffix(line, []byte("\r"))
+	}
+	return lines, nil
+}
+
+// diffSaved implements the diff command, which shows the unsaved
+// changes as a unified diff. Enter jumps to the line under the cursor.
+func (e *Editor) diffSaved() {
+	if e.filename == "" {
+		e.fail("diff: the buffer has no file")
+		return
+	}
+	saved, err := e.savedLines()
+	if err != nil {
+		e.fail("diff: %v", err)
+		return
+	}
+	rows := make([][]byte, e.buf.NumRows())
+	for i, r := range e.buf.Rows {
+		rows[i] = r.Chars
+	}
+	lines, targets := unifiedDiff(e.filename, saved, rows)
+	if len(lines) == 0 {
+		e.setStatus("no changes since %s was saved", e.filename)
+		return
+	}
+	e.view("diff "+e.filename, lines, func(i int) {
+		e.moveTo(0, targets[i])
+		e.recenter = true
+	})
+}
+
+// revert implements the revert command, which discards the unsaved
+// changes by loading the file again. It can be undone.
+func (e *Editor) revert() {
+	if e.filename == "" {
+		e.fail("revert: the buffer has no file")
+		return
+	}
+	if e.dirty && !e.confirm("Discard the changes to %s?", e.filename) {
+		return
+	}
+	data, err := readFile(e.filename)
+	if err != nil {
+		e.fail("revert: %v", err)
+		return
+	}
+	cx, cy := e.cx, e.cy
+	e.buf.Clear()
+	e.loadRows(data)
+	e.crlf = detectCRLF(data)
+	e.selection.active = false
+	e.moveTo(cx, cy)
+	e.markSaved()
+	e.setStatus("reverted %s", e.filename)
+}
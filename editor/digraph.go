@@ -0,0 +1,162 @@
+package editor
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/icholy/kilo/internal/term"
+)
+
+// digraphKey starts entering a digraph.
+var digraphKey = term.ControlKey('d')
+
+// digraphs are two characters which stand for a character which is
+// hard to type, mostly the ones of RFC 1345 which vim uses too.
+var digraphs = map[string]rune{
+	// letters with diacritics
+	"a:": 'ä', "e:": 'ë', "i:": 'ï', "o:": 'ö', "u:": 'ü', "y:": 'ÿ',
+	"A:": 'Ä', "E:": 'Ë', "I:": 'Ï', "O:": 'Ö', "U:": 'Ü',
+	"a'": 'á', "e'": 'é', "i'": 'í', "o'": 'ó', "u'": 'ú', "y'": 'ý',
+	"A'": 'Á', "E'": 'É', "I'": 'Í', "O'": 'Ó', "U'": 'Ú', "Y'": 'Ý',
+	"a!": 'à', "e!": 'è', "i!": 'ì', "o!": 'ò', "u!": 'ù',
+	"A!": 'À', "E!": 'È', "I!": 'Ì', "O!": 'Ò', "U!": 'Ù',
+	"a>": 'â', "e>": 'ê', "i>": 'î', "o>": 'ô', "u>": 'û',
+	"A>": 'Â', "E>": 'Ê', "I>": 'Î', "O>": 'Ô', "U>": 'Û',
+	"a?": 'ã', "n?": 'ñ', "o?": 'õ', "A?": 'Ã', "N?": 'Ñ', "O?": 'Õ',
+	"c,": 'ç', "C,": 'Ç', "aa": 'å', "AA": 'Å', "ae": 'æ', "AE": 'Æ',
+	"o/": 'ø', "O/": 'Ø', "ss": 'ß', "oe": 'œ', "OE": 'Œ',
+	// punctuation
+	"<<": '«', ">>": '»', "!I": '¡', "?I": '¿', "SE": '§', "PI": '¶',
+	"-N": '–', "-M": '—', "'6": '‘', "'9": '’', "\"6": '“', "\"9": '”',
+	".M": '·', "Sb": '∙', ",.": '…',
+	// symbols
+	"Eu": '€', "Pd": '£', "Ye": '¥', "Ct": '¢', "Co": '©', "Rg": '®',
+	"TM": '™', "DG": '°', "My": 'µ', "OK": '✓', "XX": '✗',
+	// arrows
+	"->": '→', "<-": '←', "-!": '↑', "-v": '↓', "<>": '↔', "=>": '⇒',
+	// math
+	"+-": '±', "*X": '×', "-:": '÷', "!=": '≠', "=<": '≤', ">=": '≥',
+	"?2": '≈', "00": '∞', "RT": '√', "FA": '∀', "TE": '∃', "(-": '∈',
+	"12": '½', "14": '¼', "34": '¾', "1S": '¹', "2S": '²', "3S": '³',
+	// greek
+	"a*": 'α', "b*": 'β', "g*": 'γ', "d*": 'δ', "e*": 'ε', "z*": 'ζ',
+	"y*": 'η', "h*": 'θ', "i*": 'ι', "k*": 'κ', "l*": 'λ', "m*": 'μ',
+	"n*": 'ν', "c*": 'ξ', "o*": 'ο', "p*": 'π', "r*": 'ρ', "s*": 'σ',
+	"t*": 'τ', "u*": 'υ', "f*": 'φ', "x*": 'χ', "q*": 'ψ', "w*": 'ω',
+	"D*": 'Δ', "G*": 'Γ', "L*": 'Λ', "P*": 'Π', "S*": 'Σ', "W*": 'Ω',
+}
+
+// lookupDigraph returns the character a digraph stands for. The user's
+// digraphs come first, and the characters can be typed in either order.
+func (e *Editor) lookupDigraph(d string) (rune, bool) {
+	reversed := string([]byte{d[1], d[0]})
+	for _, table := range []map[string]rune{e.digraphs, digraphs} {
+		if r, ok := table[d]; ok {
+			return r, true
+		}
+		if r, ok := table[reversed]; ok {
+			return r, true
+		}
+	}
+	return 0, false
+}
+
+// insertDigraph inserts the character a digraph stands for.
+func (e *Editor) insertDigraph(d string) {
+	r, ok := e.lookupDigraph(d)
+	if !ok {
+		e.fail("unknown digraph %s", d)
+		return
+	}
+	e.cy, e.cx = e.replaceRange(e.cy, e.cx, e.cy, e.cx, []byte(string(r)))
+}
+
+// enterDigraph reads the two characters of a digraph and inserts the
+// character they stand for.
+func (e *Editor) enterDigraph() {
+	var d []byte
+	for len(d) < 2 {
+		e.showStatus("digraph: %s", d)
+		e.refreshScreen()
+		c := e.readKey()
+		if c < ' ' || c >= utf8.RuneSelf {
+			e.showStatus("")
+			return
+		}
+		d = append(d, byte(c))
+	}
+	e.showStatus("")
+	e.insertDigraph(string(d))
+}
+
+// digraph implements the digraph command. Without arguments it reads
+// a digraph to insert, with two characters it inserts their digraph, and
+// with two characters and another character it defines a digraph, e.g.
+// digraph :) ☺ or digraph :) U+263A.
+func (e *Editor) digraph(args string) {
+	fields := strings.Fields(args)
+	switch {
+	case len(fields) == 0:
+		e.enterDigraph()
+	case len(fields) == 1 && len(fields[0]) == 2:
+		e.insertDigraph(fields[0])
+	case len(fields) == 2 && len(fields[0]) == 2:
+		r, ok := parseDigraphChar(fields[1])
+		if !ok {
+			e.fail("digraph: %s is not a character", fields[1])
+			return
+		}
+		if e.digraphs == nil {
+			e.digraphs = map[string]rune{}
+		}
+		e.digraphs[fields[0]] = r
+	default:
+		e.fail("digraph: expected two characters, and the character they stand for to define one")
+	}
+}
+
+// parseDigraphChar parses the character of a digraph definition, which
+// is the character itself or its code point, which the prompt can take.
+func parseDigraphChar(s string) (rune, bool) {
+	if utf8.RuneCountInString(s) == 1 {
+		r, _ := utf8.DecodeRuneInString(s)
+		return r, r != utf8.RuneError
+	}
+	if !strings.HasPrefix(s, "U+") && !strings.HasPrefix(s, "u+") {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(s[2:], 16, 32)
+	if err != nil || !utf8.ValidRune(rune(n)) {
+		return 0, false
+	}
+	return rune(n), true
+}
+
+// listDigraphs shows the digraphs, and inserts the one chosen.
+func (e *Editor) listDigraphs() {
+	var keys []string
+	for d := range digraphs {
+		keys = append(keys, d)
+	}
+	for d := range e.digraphs {
+		if _, ok := digraphs[d]; !ok {
+			keys = append(keys, d)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, _ := e.lookupDigraph(keys[i])
+		b, _ := e.lookupDigraph(keys[j])
+		return a < b
+	})
+	lines := make([]string, len(keys))
+	for i, d := range keys {
+		r, _ := e.lookupDigraph(d)
+		lines[i] = fmt.Sprintf("%s  %c  U+%04X", d, r, r)
+	}
+	e.view("[Digraphs]", lines, func(i int) {
+		e.insertDigraph(keys[i])
+	})
+}
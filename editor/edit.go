@@ -0,0 +1,279 @@
+package editor
+
+import (
+	"bytes"
+	"strings"
+	"unicode"
+
+	"github.com/icholy/kilo/internal/buffer"
+	"golang.org/x/exp/slices"
+)
+
+func (e *Editor) insertRow(at int, chars []byte) {
+	e.buf.InsertRow(at, chars)
+	e.dirty = true
+}
+
+func (e *Editor) deleteRow(at int) {
+	if at < 0 || at >= e.buf.NumRows() {
+		return
+	}
+	if e.cx == 0 && e.cy == 0 {
+		return
+	}
+	e.buf.DeleteRows(at, at+1)
+	e.dirty = true
+}
+
+func (e *Editor) insertChar(c int) {
+	if e.cy == e.buf.NumRows() {
+		e.insertRow(e.buf.NumRows(), nil)
+		// the cursor may have been left past the start of the new row
+		e.cx = 0
+	}
+	e.buf.Rows[e.cy].InsertChar(e.cx, c)
+	e.cx++
+	e.dirty = true
+}
+
+var pairs = map[byte]byte{'(': ')', '[': ']', '{': '}', '"': '"', '\'': '\''}
+
+// typeChar inserts a typed character. When autopairs is enabled,
+// opening brackets and quotes get a matching closer which is skipped over
+// if it's typed next.
+func (e *Editor) typeChar(c int) {
+	if e.cy != e.autorow {
+		e.autoclosed = e.autoclosed[:0]
+	}
+	if !e.autopairs || c >= 128 || e.cy >= e.buf.NumRows() {
+		e.insertChar(c)
+		return
+	}
+	chars := e.buf.Rows[e.cy].Chars
+	var next, prev byte
+	if e.cx < len(chars) {
+		next = chars[e.cx]
+	}
+	if e.cx > 0 {
+		prev = chars[e.cx-1]
+	}
+	// skip over an auto-inserted closer
+	if n := len(e.autoclosed); n > 0 && e.autoclosed[n-1] == byte(c) && next == byte(c) {
+		e.autoclosed = e.autoclosed[:n-1]
+		e.cx++
+		return
+	}
+	closer, ok := pairs[byte(c)]
+	if !ok || (next != 0 && !unicode.IsSpace(rune(next)) && !strings.ContainsRune(")]}", rune(next))) {
+		e.insertChar(c)
+		return
+	}
+	// don't pair the apostrophe in "don't"
+	if closer == byte(c) && prev != 0 && !buffer.IsDelim(prev) {
+		e.insertChar(c)
+		return
+	}
+	e.insertChar(c)
+	e.buf.Rows[e.cy].InsertChar(e.cx, int(closer))
+	e.autoclosed = append(e.autoclosed, closer)
+	e.autorow = e.cy
+}
+
+func (e *Editor) deleteChar() {
+	if e.cy == e.buf.NumRows() {
+		return
+	}
+	// delete both halves of an empty pair
+	if e.autopairs && e.cx > 0 && e.cx < e.buf.Rows[e.cy].Len() {
+		chars := e.buf.Rows[e.cy].Chars
+		if closer, ok := pairs[chars[e.cx-1]]; ok && chars[e.cx] == closer {
+			e.buf.Rows[e.cy].DeleteChar(e.cx)
+			if n := len(e.autoclosed); n > 0 && e.autoclosed[n-1] == closer {
+				e.autoclosed = e.autoclosed[:n-1]
+			}
+		}
+	}
+	if e.cx == 0 && e.cy == 0 {
+		return
+	}
+	row := e.buf.Rows[e.cy]
+	if e.cx > 0 {
+		// all the bytes of the character
+		for start := prevChar(row.Chars, e.cx); e.cx > start; e.cx-- {
+			row.DeleteChar(e.cx - 1)
+		}
+	} else {
+		e.cx = e.buf.Rows[e.cy-1].Len()
+		e.buf.Rows[e.cy-1].Append(row.Chars)
+		e.deleteRow(e.cy)
+		e.cy--
+	}
+}
+
+// getRange returns the text between (x0, y0) and (x1, y1).
+func (e *Editor) getRange(y0, x0, y1, x1 int) []byte {
+	var b []byte
+	for y := y0; y <= y1 && y < e.buf.NumRows(); y++ {
+		chars := e.buf.Rows[y].Chars
+		start, end := 0, len(chars)
+		if y == y0 {
+			start = clamp(x0, 0, end)
+		}
+		if y == y1 {
+			end = clamp(x1, start, end)
+		}
+		b = append(b, chars[start:end]...)
+		if y < y1 {
+			b = append(b, '\n')
+		}
+	}
+	return b
+}
+
+// replaceRange replaces the text between (x0, y0) and (x1, y1) with
+// text, which may span multiple lines. It returns the position of the
+// end of the inserted text. Positions outside the buffer are clamped to
+// it, and a range which ends before it starts is taken the other way
+// around, as language servers aren't to be trusted with them.
+func (e *Editor) replaceRange(y0, x0, y1, x1 int, text []byte) (y, x int) {
+	y0, y1 = clamp(y0, 0, e.buf.NumRows()), clamp(y1, 0, e.buf.NumRows())
+	if y1 < y0 || y1 == y0 && x1 < x0 {
+		y0, x0, y1, x1 = y1, x1, y0, x0
+	}
+	if y0 >= e.buf.NumRows() {
+		e.insertRow(e.buf.NumRows(), nil)
+		y0, x0 = e.buf.NumRows()-1, 0
+	}
+	if y1 >= e.buf.NumRows() {
+		y1, x1 = e.buf.NumRows()-1, e.buf.Rows[e.buf.NumRows()-1].Len()
+	}
+	x0 = clamp(x0, 0, e.buf.Rows[y0].Len())
+	x1 = clamp(x1, 0, e.buf.Rows[y1].Len())
+	prefix := slices.Clone(e.buf.Rows[y0].Chars[:x0])
+	suffix := slices.Clone(e.buf.Rows[y1].Chars[x1:])
+	e.buf.DeleteRows(y0+1, y1+1)
+	lines := bytes.Split(text, []byte("\n"))
+	last := len(lines) - 1
+	y = y0 + last
+	x = len(lines[last])
+	if last == 0 {
+		x += len(prefix)
+	}
+	lines[0] = append(prefix, lines[0]...)
+	lines[last] = append(slices.Clip(lines[last]), suffix...)
+	e.buf.Rows[y0].Chars = lines[0]
+	e.buf.Rows[y0].Update()
+	for i, line := range lines[1:] {
+		e.insertRow(y0+1+i, slices.Clone(line))
+	}
+	e.dirty = true
+	return y, x
+}
+
+func clamp(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
+
+// toggleComment comments or uncomments the rows from start to end
+// inclusive. If any of the rows isn't commented, they are all commented.
+func (e *Editor) toggleComment(start, end int) {
+	if e.buf.Syntax == nil {
+		e.fail("no comment syntax for this file type")
+		return
+	}
+	end = clamp(end, 0, e.buf.NumRows()-1)
+	prefix, suffix := e.buf.Syntax.LineComment, ""
+	if prefix == "" {
+		prefix, suffix = e.buf.Syntax.BlockComment[0], e.buf.Syntax.BlockComment[1]
+	}
+	if prefix == "" {
+		e.fail("no comment syntax for this file type")
+		return
+	}
+	commented := true
+	indent := -1
+	for y := start; y <= end; y++ {
+		chars := e.buf.Rows[y].Chars
+		text := bytes.TrimLeft(chars, " \t")
+		if len(text) == 0 {
+			continue
+		}
+		if n := len(chars) - len(text); indent < 0 || n < indent {
+			indent = n
+		}
+		if !bytes.HasPrefix(text, []byte(prefix)) || !bytes.HasSuffix(text, []byte(suffix)) {
+			commented = false
+		}
+	}
+	if indent < 0 {
+		return
+	}
+	for y := start; y <= end; y++ {
+		row := e.buf.Rows[y]
+		text := bytes.TrimLeft(row.Chars, " \t")
+		if len(text) == 0 {
+			continue
+		}
+		n := len(row.Chars) - len(text)
+		if commented {
+			text = bytes.TrimPrefix(text, []byte(prefix))
+			text = bytes.TrimPrefix(text, []byte(" "))
+			text = bytes.TrimSuffix(text, []byte(suffix))
+			if suffix != "" {
+				text = bytes.TrimSuffix(text, []byte(" "))
+			}
+			row.Chars = append(row.Chars[:n:n], text...)
+		} else {
+			var b []byte
+			b = append(b, row.Chars[:indent]...)
+			b = append(b, prefix...)
+			b = append(b, ' ')
+			b = append(b, row.Chars[indent:]...)
+			if suffix != "" {
+				b = append(b, ' ')
+				b = append(b, suffix...)
+			}
+			row.Chars = b
+		}
+		row.Update()
+	}
+	e.dirty = true
+	e.moveTo(e.cx, e.cy)
+}
+
+func (e *Editor) insertNewline() {
+	if e.cx == 0 {
+		e.insertRow(e.cy, nil)
+	} else {
+		// the rows don't share an array, which editing one would change
+		// the other through
+		e.insertRow(e.cy+1, slices.Clone(e.buf.Rows[e.cy].Chars[e.cx:]))
+		e.buf.Rows[e.cy].Truncate(e.cx)
+	}
+	e.cy++
+	e.cx = 0
+}
+
+// paste inserts pasted text as a single edit.
+func (e *Editor) paste() {
+	if e.term == nil {
+		return
+	}
+	text := bytes.ReplaceAll(e.term.Paste, []byte("\r\n"), []byte("\n"))
+	text = bytes.ReplaceAll(text, []byte("\r"), []byte("\n"))
+	e.cy, e.cx = e.replaceRange(e.cy, e.cx, e.cy, e.cx, text)
+	e.term.Paste = nil
+}
+
+// insertText inserts text at the cursor. The escapes \n, \t, and \\,
+// and the template variables like ${date} are expanded.
+func (e *Editor) insertText(args string) {
+	text := strings.NewReplacer(`\n`, "\n", `\t`, "\t", `\\`, `\`).Replace(args)
+	e.cy, e.cx = e.replaceRange(e.cy, e.cx, e.cy, e.cx, e.expandVariables([]byte(text), e.filename))
+}
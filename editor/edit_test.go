@@ -0,0 +1,27 @@
+package editor
+
+import (
+	"strings"
+	"testing"
+)
+
+// bufferText returns the lines of the buffer joined by newlines.
+func bufferText(e *Editor) string {
+	lines := make([]string, e.buf.NumRows())
+	for i, r := range e.buf.Rows {
+		lines[i] = string(r.Chars)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestTypePastEnd(t *testing.T) {
+	e := newTestEditor(t, "x")
+	e.autopairs = true
+	// page down leaves the cursor's column on the line past the end
+	for _, k := range []int{'a', KeyPageDown, '#', '('} {
+		e.HandleKey(k)
+	}
+	if got, want := bufferText(e), "ax\n#()"; got != want {
+		t.Errorf("buffer = %q, want %q", got, want)
+	}
+}
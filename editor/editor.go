@@ -0,0 +1,412 @@
+// Package editor implements the kilo text editor. An Editor can either
+// run in the terminal, or be embedded in another program which feeds
+// it keys and draws its output.
+package editor
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/icholy/kilo/internal/buffer"
+	"github.com/icholy/kilo/internal/i18n"
+	"github.com/icholy/kilo/internal/term"
+	"github.com/icholy/kilo/internal/ui"
+	"github.com/icholy/kilo/internal/vt"
+	lua "github.com/yuin/gopher-lua"
+	"golang.org/x/sys/unix"
+)
+
+const version = "0.0.1"
+
+// Editor is the state of the editor: the buffer, the cursor, the screen,
+// and its settings.
+type Editor struct {
+	term         *term.Terminal
+	buf          *buffer.Buffer
+	opts         buffer.Options
+	screenrows   int
+	screencols   int
+	cx           int
+	cy           int
+	rx           int
+	rowoff       int
+	coloff       int
+	status       string
+	statustime   time.Time
+	filename     string
+	dirty        bool
+	jumps        []Location
+	jumpidx      int
+	welcome      bool
+	recent       []string
+	recentidx    int
+	statusline   string
+	gitbranch    string
+	messages     []string
+	autopairs    bool
+	autoclosed   []byte
+	autorow      int
+	wordchars    string
+	killring     [][]byte
+	registers    map[byte][]byte
+	register     byte
+	killed       bool
+	killappend   bool
+	expandtab    bool
+	shiftwidth   int
+	textwidth    int
+	timefmt      string
+	modelines    bool
+	undoroot     *undoNode
+	undocur      *undoNode
+	undosaved    *undoNode // the state saved to the file
+	undoseq      int
+	undochanges  int
+	undolines    []undoLine // the rows in the state of undocur
+	undotext     [][]byte   // the text of undolines, which isn't modified
+	lasttyped    bool
+	selection    Selection
+	cursorline   bool
+	occurrences  bool
+	searchquery  string
+	scrolloff    int
+	scrollbar    bool
+	promptinfo   string
+	prompting    bool
+	promptcol    int
+	count        int
+	templates    string
+	configfile   string
+	configcheck  time.Time
+	configtime   time.Time
+	formatter    string
+	projectcfg   string
+	projectprev  map[string]string
+	distrusted   map[string]bool
+	trustpending bool
+	loglevel     LogLevel
+	logfile      *os.File
+	timing       timing
+	showtiming   bool
+	occword      string
+	keytime      time.Time
+	cursorcolumn bool
+	dictpath     string
+	formatonsave bool
+	buildcmd     string
+	quickfix     []QuickfixEntry
+	qfidx        int
+	qfrun        *quickfixRun // the command filling the quickfix list, while it runs
+	popup        *ui.Popup
+	lsp          *LSPClient
+	esctimeout   time.Duration
+	pending      []int
+	closed       bool
+	input        chan int
+	ready        chan struct{}
+	usercmds     []Command
+	hooks        []Hook
+	keymap       map[int]binding
+	plugins      []*Plugin
+	listener     net.Listener
+	calls        chan rpcCall
+	waker        atomic.Pointer[term.Terminal]
+	idletimer    *time.Timer
+	scriptdepth  int
+	lua          *lua.LState // the Lua interpreter, nil until a script runs
+	recenter     bool
+	terminal     *terminalPane
+	termfocus    bool
+	totalrows    int
+	testmarks    map[string][]int
+	searchhl     []SearchMatch
+	searchlen    int
+	words        *wordIndex
+	colorpreview string
+	todokeywords string
+	crlf         bool
+	digraphs     map[string]rune
+	filelock     *os.File // the advisory lock on the file being edited
+	readonly     bool     // another editor has the file open
+	recording    *os.File // the key file the typed keys are written to
+	screenreader bool
+	announcer    string
+	lastscreen   *vt.Screen // the frame last drawn in the screen reader mode
+	speech       *exec.Cmd  // the command speaking the last announcement
+	lang         string
+	localedir    string
+	outline      *outline // the outline sidebar, nil when it's closed
+	outlinefocus bool
+	diagnostics  bool
+	failure      error // the error of the command last run by a script
+	catalog      i18n.Catalog
+	config       Options
+}
+
+// Options configures an Editor.
+type Options struct {
+	AutoPairs     bool          // automatically close brackets and quotes
+	Dict          string        // spell checking dictionary, empty to search the usual places
+	FormatOnSave  bool          // run the filetype's formatter when saving
+	Build         string        // command used to build the project, the filetype's or make when empty
+	EscTimeout    time.Duration // maximum delay between the bytes of an escape sequence
+	WordChars     string        // characters other than letters and digits which are part of words
+	ExpandTab     bool          // indent with spaces instead of tabs
+	ShiftWidth    int           // number of spaces per indent level when ExpandTab is set
+	List          bool          // show tabs and trailing whitespace
+	ListSpaces    bool          // show spaces as middle dots when whitespace is shown
+	CursorLine    bool          // highlight the line the cursor is on
+	CursorColumn  bool          // highlight the column the cursor is on
+	HighlightWord bool          // highlight occurrences of the word under the cursor
+	ScrollOff     int           // minimum number of rows kept visible above and below the cursor
+	Scrollbar     bool          // show a scrollbar in the rightmost column
+	Diagnostics   bool          // show the diagnostics of the language server and build errors after the lines
+	StatusLine    string        // status bar format, e.g. "%f %m%=%l:%c"
+	Modelines     bool          // apply the tab stop, indentation, and filetype set by vim and emacs modelines
+	Templates     string        // directory of templates for new files, e.g. skeleton.go
+	TabStop       int           // number of columns between tab stops
+	TextWidth     int           // column past which prose is wrapped while typing, 0 to turn it off
+	Timestamp     string        // strftime format of the timestamp command, e.g. %Y-%m-%d %H:%M
+	Config        string        // kilorc file which is reloaded when it changes
+	Formatter     string        // command which formats the buffer instead of the filetype's formatter, %f is the file name
+	LogLevel      LogLevel      // how much is written to ~/.local/state/kilo/kilo.log
+	Timing        bool          // show how long drawing the screen and handling keys take
+	ScreenReader  bool          // draw only what changed, without decorations, and announce the cursor's moves
+	Announce      string        // where announcements go: status for the message bar, >file, or a command such as espeak
+	Language      string        // language of the messages, e.g. de_DE, empty for English
+	Locales       string        // directory of message catalogs, like de.po, which take precedence over the builtin ones
+}
+
+// DefaultOptions returns the options used by the kilo command when no
+// flags are given.
+func DefaultOptions() Options {
+	return Options{
+		AutoPairs:     true,
+		FormatOnSave:  true,
+		EscTimeout:    term.DefaultEscTimeout,
+		WordChars:     "_",
+		ShiftWidth:    4,
+		HighlightWord: true,
+		Diagnostics:   true,
+		StatusLine:    ui.DefaultStatusLine,
+		Modelines:     true,
+		TabStop:       buffer.TabStop,
+		Timestamp:     defaultTimestampFormat,
+		Announce:      defaultAnnounce,
+		Language:      i18n.Language(os.Getenv),
+	}
+}
+
+// New returns an empty editor. The screen defaults to 24x80 until
+// Resize is called.
+func New(opts Options) *Editor {
+	if opts.ShiftWidth <= 0 {
+		opts.ShiftWidth = 4
+	}
+	if opts.Timestamp == "" {
+		opts.Timestamp = defaultTimestampFormat
+	}
+	e := &Editor{
+		config:       opts,
+		autopairs:    opts.AutoPairs,
+		dictpath:     opts.Dict,
+		formatonsave: opts.FormatOnSave,
+		buildcmd:     opts.Build,
+		esctimeout:   opts.EscTimeout,
+		wordchars:    opts.WordChars,
+		expandtab:    opts.ExpandTab,
+		shiftwidth:   opts.ShiftWidth,
+		textwidth:    opts.TextWidth,
+		timefmt:      opts.Timestamp,
+		cursorline:   opts.CursorLine,
+		cursorcolumn: opts.CursorColumn,
+		occurrences:  opts.HighlightWord,
+		scrolloff:    opts.ScrollOff,
+		scrollbar:    opts.Scrollbar,
+		diagnostics:  opts.Diagnostics,
+		statusline:   opts.StatusLine,
+		modelines:    opts.Modelines,
+		templates:    opts.Templates,
+		configfile:   opts.Config,
+		formatter:    opts.Formatter,
+		loglevel:     opts.LogLevel,
+		showtiming:   opts.Timing,
+		screenreader: opts.ScreenReader,
+		announcer:    opts.Announce,
+		lang:         opts.Language,
+		localedir:    opts.Locales,
+		colorpreview: defaultColorPreview,
+		todokeywords: strings.Join(buffer.DefaultTodo, ","),
+	}
+	e.openLog()
+	e.loadCatalog()
+	if fi, err := os.Stat(opts.Config); err == nil {
+		e.configtime = fi.ModTime()
+	}
+	e.opts.List = opts.List
+	e.opts.ListSpaces = opts.ListSpaces
+	e.opts.TabStop = opts.TabStop
+	e.opts.Todo = buffer.DefaultTodo
+	e.opts.Warn = true
+	e.buf = buffer.New(&e.opts)
+	e.words = newWordIndex()
+	e.Resize(24, 80)
+	e.setScreenReader()
+	return e
+}
+
+// Open loads the named file into the editor.
+func (e *Editor) Open(path string) error {
+	return e.open(path)
+}
+
+// Goto moves the cursor to a 1 based line and column. The cursor is
+// scrolled to the middle of the screen the next time it's drawn.
+func (e *Editor) Goto(line, col int) {
+	e.moveTo(col-1, line-1)
+	e.recenter = true
+}
+
+// Resize sets the size of the screen, including the status and
+// message bars.
+func (e *Editor) Resize(rows, cols int) {
+	e.totalrows = rows
+	e.screenrows, e.screencols = rows-2, cols // room for status bar & message
+	e.lastscreen = nil
+	if e.terminal != nil {
+		n := terminalRows(rows)
+		e.screenrows -= n + 1
+		e.terminal.resize(n, cols)
+	}
+}
+
+// Render writes the escape sequences which draw the whole screen to w.
+func (e *Editor) Render(w io.Writer) error {
+	var b bytes.Buffer
+	e.render(&b)
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// HandleKey processes a single key. Commands which prompt for input
+// receive the following keys, so the state between calls is kept on
+// a separate goroutine. HandleKey must not be called concurrently.
+func (e *Editor) HandleKey(k int) {
+	if e.closed {
+		return
+	}
+	if e.input == nil {
+		e.input = make(chan int)
+		e.ready = make(chan struct{})
+		go e.loop()
+		<-e.ready
+	}
+	e.input <- k
+	<-e.ready
+}
+
+// loop processes the keys sent by HandleKey until the editor is closed.
+func (e *Editor) loop() {
+	e.askTrust()
+	for !e.closed {
+		e.processKeypress()
+	}
+	close(e.ready)
+}
+
+// Closed reports whether the user has quit the editor.
+func (e *Editor) Closed() bool {
+	return e.closed
+}
+
+// Close saves the cursor position, shuts down the language server,
+// plugins, and Lua, and stops listening for RPC clients. Closing it
+// again, after the user quit, does nothing.
+func (e *Editor) Close() {
+	if e.closed {
+		return
+	}
+	e.savePosition()
+	e.closeTerminal()
+	e.unlock()
+	if e.recording != nil {
+		e.recording.Close()
+	}
+	e.words.close()
+	if e.lsp != nil {
+		e.lsp.Close()
+	}
+	for _, p := range e.plugins {
+		p.Close()
+	}
+	if e.lua != nil {
+		e.lua.Close()
+	}
+	if e.listener != nil {
+		e.listener.Close()
+	}
+	if e.logfile != nil {
+		e.log(LogInfo, "exit")
+		e.logfile.Close()
+	}
+	e.closed = true
+}
+
+// Run takes over the terminal and runs the editor until the user quits.
+// The welcome screen is shown if no file was opened.
+func (e *Editor) Run() error {
+	if e.input != nil {
+		return errors.New("editor is already driven by HandleKey")
+	}
+	e.term = term.New()
+	e.term.EscTimeout = e.esctimeout
+	e.term.Trace = e.traceEscape
+	e.term.Idle = e.idle
+	if err := e.term.EnableRawMode(); err != nil {
+		return err
+	}
+	defer e.term.Restore()
+	rows, cols, err := e.term.Size()
+	if err != nil {
+		return err
+	}
+	e.Resize(rows, cols)
+	e.log(LogInfo, "terminal", "rows", rows, "cols", cols)
+	e.waker.Store(e.term)
+	defer e.waker.Store(nil)
+	if e.configfile != "" {
+		stop := e.tick(configCheckInterval)
+		defer stop()
+	}
+	if e.filename == "" && e.buf.NumRows() == 0 {
+		e.initWelcome()
+	}
+	e.askTrust()
+	// show help message, unless opening the file had something to say
+	if e.status == "" {
+		e.setStatus("HELP: Ctrl-S = save | Ctrl-Q = quit | Ctrl-F = find | Ctrl-P = command")
+	}
+	for !e.closed {
+		if !e.skipFrame() {
+			e.refreshScreen()
+		}
+		e.processKeypress()
+	}
+	return nil
+}
+
+func (e *Editor) die(format string, args ...any) {
+	// the message would be lost with the alternate screen
+	e.term.Restore()
+	msg := fmt.Sprintf(format, args...)
+	unix.Write(unix.Stdout, []byte(msg+"\n"))
+	unix.Exit(1)
+}
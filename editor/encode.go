@@ -0,0 +1,95 @@
+package editor
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+// encodings are the transforms of the encode and decode commands. The
+// first function encodes, and the second decodes.
+var encodings = map[string][2]func([]byte) ([]byte, error){
+	"base64": {
+		func(b []byte) ([]byte, error) {
+			return []byte(base64.StdEncoding.EncodeToString(b)), nil
+		},
+		decodeBase64,
+	},
+	"url": {
+		func(b []byte) ([]byte, error) {
+			return []byte(url.QueryEscape(string(b))), nil
+		},
+		func(b []byte) ([]byte, error) {
+			s, err := url.QueryUnescape(string(b))
+			return []byte(s), err
+		},
+	},
+	"json": {
+		func(b []byte) ([]byte, error) {
+			var out bytes.Buffer
+			enc := json.NewEncoder(&out)
+			enc.SetEscapeHTML(false)
+			if err := enc.Encode(string(b)); err != nil {
+				return nil, err
+			}
+			// without the quotes and newline
+			return out.Bytes()[1 : out.Len()-2], nil
+		},
+		func(b []byte) ([]byte, error) {
+			if len(b) < 2 || b[0] != '"' || b[len(b)-1] != '"' {
+				b = append(append([]byte{'"'}, b...), '"')
+			}
+			var s string
+			err := json.Unmarshal(b, &s)
+			return []byte(s), err
+		},
+	},
+}
+
+// decodeBase64 decodes base64 with or without padding, in either the
+// standard or the URL alphabet. Line breaks are ignored.
+func decodeBase64(b []byte) ([]byte, error) {
+	s := strings.Join(strings.Fields(string(b)), "")
+	var err error
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		var out []byte
+		if out, err = enc.DecodeString(s); err == nil {
+			if !utf8.Valid(out) {
+				return nil, errors.New("the decoded data isn't text")
+			}
+			return out, nil
+		}
+	}
+	return nil, err
+}
+
+// transcode implements the encode and decode commands, which replace
+// the selection with it encoded or decoded as base64, url, or json.
+func (e *Editor) transcode(name string, decode bool) {
+	name = strings.TrimSpace(name)
+	fns, ok := encodings[name]
+	if !ok {
+		e.fail("unknown encoding: %q, expected base64, url, or json", name)
+		return
+	}
+	y0, x0, y1, x1, ok := e.selectionBounds()
+	if !ok {
+		e.fail("select the text to transform first")
+		return
+	}
+	fn := fns[0]
+	if decode {
+		fn = fns[1]
+	}
+	text, err := fn(e.getRange(y0, x0, y1, x1))
+	if err != nil {
+		e.fail("%s: %v", name, err)
+		return
+	}
+	e.cy, e.cx = e.replaceRange(y0, x0, y1, x1, text)
+	e.selection.active = false
+}
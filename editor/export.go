@@ -0,0 +1,51 @@
+package editor
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/icholy/kilo/internal/ui"
+)
+
+// Export writes the buffer with its syntax highlighting to w. The
+// format is either "html" or "ansi".
+func (e *Editor) Export(w io.Writer, format string) error {
+	for y := range e.buf.Rows {
+		e.buf.Highlight(y)
+	}
+	switch format {
+	case "html":
+		return ui.ExportHTML(w, e.filename, e.buf.Rows)
+	case "ansi":
+		return ui.ExportANSI(w, e.buf.Rows)
+	default:
+		return fmt.Errorf("unknown export format: %q", format)
+	}
+}
+
+// exportFile parses "html|ansi <file>" and exports the buffer to the
+// file.
+func (e *Editor) exportFile(args string) {
+	format, name, _ := strings.Cut(args, " ")
+	name = strings.TrimSpace(name)
+	if name == "" {
+		e.fail("export: expected html|ansi <file>")
+		return
+	}
+	f, err := os.Create(name)
+	if err != nil {
+		e.fail("export: %v", err)
+		return
+	}
+	err = e.Export(f, format)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		e.fail("export: %v", err)
+		return
+	}
+	e.setStatus("exported %s", name)
+}
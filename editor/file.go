@@ -0,0 +1,164 @@
+package editor
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+
+	"github.com/icholy/kilo/internal/buffer"
+)
+
+func (e *Editor) open(filename string) error {
+	data, err := readFile(filename)
+	// a file which doesn't exist yet starts from its template
+	created := errors.Is(err, fs.ErrNotExist)
+	if err != nil && !created {
+		e.log(LogError, "open", "file", filename, "error", err)
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	e.log(LogInfo, "open", "file", filename, "bytes", len(data), "new", created)
+	var cx, cy int
+	if created {
+		data, cx, cy = cutTemplateCursor(e.expandVariables(e.findTemplate(filename), filename))
+	}
+	e.unlock()
+	e.filename = filename
+	e.loadRows(data)
+	e.crlf = detectCRLF(data)
+	e.dirty = false
+	addRecentFile(filename)
+	e.gitbranch = gitBranch(filename)
+	e.resetUndo()
+	if created {
+		e.moveTo(cx, cy)
+		e.setStatus("new file")
+	} else {
+		e.restorePosition()
+	}
+	e.buf.Syntax = buffer.SyntaxFor(filename)
+	if e.buf.Syntax == nil && e.buf.NumRows() > 0 {
+		e.buf.Syntax = buffer.DetectSyntax(e.buf.Rows[0].Chars)
+	}
+	e.loadProjectConfig()
+	e.detectIndent()
+	e.applyModelines()
+	e.buf.Rehighlight()
+	e.startLSP()
+	e.lock()
+	e.runHooks("open")
+	e.pluginEvent("open", map[string]any{})
+	return nil
+}
+
+// switchFile replaces the current buffer with the contents of filename.
+// It refuses to discard unsaved changes.
+func (e *Editor) switchFile(filename string) bool {
+	if sameFile(filename, e.filename) {
+		return true
+	}
+	if e.dirty {
+		e.fail("%s has unsaved changes", e.filename)
+		return false
+	}
+	if _, err := os.Stat(filename); err != nil && !isRemote(filename) {
+		e.fail("%v", err)
+		return false
+	}
+	e.savePosition()
+	if e.lsp != nil {
+		e.lsp.Close()
+		e.lsp = nil
+	}
+	e.buf = buffer.New(&e.opts)
+	e.cx, e.cy = 0, 0
+	e.rowoff, e.coloff = 0, 0
+	if err := e.open(filename); err != nil {
+		e.fail("%v", err)
+		return false
+	}
+	return true
+}
+
+func (e *Editor) save() {
+	if e.filename == "" {
+		name, ok := e.promptFile("Save as:")
+		if !ok {
+			return
+		}
+		e.filename = name
+		e.buf.Syntax = buffer.SyntaxFor(name)
+		e.buf.Rehighlight()
+	}
+	if e.readonly {
+		e.fail("%s is read-only, set noreadonly to save it anyway", e.filename)
+		return
+	}
+	_, err := os.Stat(e.filename)
+	created := errors.Is(err, fs.ErrNotExist)
+	e.runHooks("save")
+	fmterr := e.formatOnSave()
+	if err := e.writeFile(e.filename); err != nil {
+		e.log(LogError, "save", "file", e.filename, "error", err)
+		e.fail("save failed: %v", err)
+		return
+	}
+	e.log(LogInfo, "save", "file", e.filename)
+	e.markSaved()
+	if created {
+		// a new file is locked once it exists
+		e.lock()
+	}
+	e.pluginEvent("save", map[string]any{})
+	if fmterr != nil {
+		e.setStatus("saved %s unformatted: %v", e.filename, fmterr)
+	} else {
+		e.setStatus("saved %s", e.filename)
+	}
+	if e.lsp != nil {
+		e.lsp.Sync()
+		e.lsp.DidSave()
+	}
+}
+
+// writeFile writes the buffer to the named file.
+func (e *Editor) writeFile(name string) error {
+	if rf, ok := parseRemote(name); ok {
+		var b bytes.Buffer
+		e.writeRowsTo(&b, e.newline())
+		return rf.Write(b.Bytes())
+	}
+	f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	if err := e.writeRowsTo(f, e.newline()); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func (e *Editor) rowsToBytes() []byte {
+	var b bytes.Buffer
+	e.writeRowsTo(&b, "\n")
+	return b.Bytes()
+}
+
+// writeRowsTo writes the rows to w, each followed by newline.
+func (e *Editor) writeRowsTo(w io.Writer, newline string) error {
+	for _, r := range e.buf.Rows {
+		if _, err := w.Write(r.Chars); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, newline); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package editor
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"strings"
+
+	"github.com/icholy/kilo/internal/fuzzy"
+)
+
+const (
+	// maxFinderFiles bounds the files find-file looks through.
+	maxFinderFiles = 50000
+	// maxFinderMatches is how many of the best matches are listed.
+	maxFinderMatches = 100
+)
+
+// errTooManyFiles stops listing the project's files.
+var errTooManyFiles = errors.New("too many files")
+
+// skipDir reports whether the files in a directory are left out of
+// project wide searches: hidden directories, and the ones dependencies
+// and test data live in.
+func skipDir(path string, d fs.DirEntry) bool {
+	if path == "." {
+		return false
+	}
+	name := d.Name()
+	return strings.HasPrefix(name, ".") || name == "vendor" || name == "testdata" || name == "node_modules"
+}
+
+// projectFiles lists the files under the working directory.
+func projectFiles() []string {
+	var files []string
+	filepath.WalkDir(".", func(path string, d fs.DirEntry, err error) error {
+		switch {
+		case err != nil:
+			return nil
+		case d.IsDir() && skipDir(path, d):
+			return filepath.SkipDir
+		case d.IsDir():
+			return nil
+		case len(files) == maxFinderFiles:
+			return errTooManyFiles
+		}
+		files = append(files, path)
+		return nil
+	})
+	return files
+}
+
+// pickFile lets the user choose one of the files whose names pattern
+// matches best, which is opened. The best matches are shown as the
+// pattern is typed when it isn't given.
+func (e *Editor) pickFile(prompt, pattern string, files, names []string) {
+	if pattern == "" {
+		var ok bool
+		pattern, ok = e.prompt(prompt, func(input string, _ int) {
+			var best []string
+			for i, idx := range fuzzy.Rank(input, names) {
+				if i == maxCompletions {
+					best = append(best, "...")
+					break
+				}
+				best = append(best, names[idx])
+			}
+			e.promptinfo = strings.Join(best, " ")
+			if len(best) == 0 {
+				e.promptinfo = "no matches"
+			}
+		})
+		if !ok {
+			return
+		}
+	}
+	ranked := fuzzy.Rank(pattern, names)
+	if len(ranked) > maxFinderMatches {
+		ranked = ranked[:maxFinderMatches]
+	}
+	var items []string
+	for _, idx := range ranked {
+		items = append(items, names[idx])
+	}
+	switch len(ranked) {
+	case 0:
+		e.fail("no files match %s", pattern)
+	case 1:
+		e.switchFile(files[ranked[0]])
+	default:
+		if i := e.menu(items); i >= 0 {
+			e.switchFile(files[ranked[i]])
+		}
+	}
+}
+
+// findFile implements the find-file command, which fuzzy finds a file
+// under the working directory by its path.
+func (e *Editor) findFile(args string) {
+	files := projectFiles()
+	e.pickFile("Find file:", args, files, files)
+}
+
+// pickRecent implements the recent command, which switches to one of
+// the recently opened files, fuzzy found by its path.
+func (e *Editor) pickRecent(args string) {
+	files := readRecentFiles()
+	names := make([]string, len(files))
+	for i, f := range files {
+		names[i] = displayPath(f)
+	}
+	e.pickFile("Recent file:", args, files, names)
+}
@@ -0,0 +1,83 @@
+package editor
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// setText replaces the contents of the buffer, keeping the
+// cursor on the same line and column where possible.
+func (e *Editor) setText(text []byte) {
+	text = bytes.TrimSuffix(text, []byte("\n"))
+	e.buf.Clear()
+	for _, line := range bytes.Split(text, []byte("\n")) {
+		e.insertRow(e.buf.NumRows(), line)
+	}
+	e.moveTo(e.cx, e.cy)
+}
+
+// formatterCommand returns the formatter option split into arguments,
+// or the filetype's formatter.
+func (e *Editor) formatterCommand() []string {
+	if e.formatter != "" {
+		return strings.Fields(e.formatter)
+	}
+	if e.buf.Syntax == nil {
+		return nil
+	}
+	return e.buf.Syntax.Formatter
+}
+
+// formatBuffer pipes the buffer through the formatter. On failure the
+// buffer is left untouched.
+func (e *Editor) formatBuffer() error {
+	formatter := e.formatterCommand()
+	if len(formatter) == 0 {
+		return errors.New("no formatter for this file type")
+	}
+	args := make([]string, len(formatter))
+	for i, arg := range formatter {
+		args[i] = strings.ReplaceAll(arg, "%f", e.filename)
+	}
+	input := e.rowsToBytes()
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg, _, _ := strings.Cut(strings.TrimSpace(stderr.String()), "\n")
+		if msg == "" {
+			msg = err.Error()
+		}
+		return fmt.Errorf("%s: %s", args[0], msg)
+	}
+	if !bytes.Equal(stdout.Bytes(), input) {
+		e.setText(stdout.Bytes())
+	}
+	return nil
+}
+
+func (e *Editor) format() {
+	if err := e.formatBuffer(); err != nil {
+		e.fail("%v", err)
+		return
+	}
+	e.setStatus("formatted")
+}
+
+// formatOnSave runs the formatter before saving if one is
+// configured and installed.
+func (e *Editor) formatOnSave() error {
+	formatter := e.formatterCommand()
+	if !e.formatonsave || len(formatter) == 0 {
+		return nil
+	}
+	if _, err := exec.LookPath(formatter[0]); err != nil {
+		return nil
+	}
+	return e.formatBuffer()
+}
@@ -0,0 +1,48 @@
+package editor
+
+import (
+	"bytes"
+	"fmt"
+
+	"golang.org/x/exp/slices"
+)
+
+// gutterWidth is the number of columns of the gutter, which is only shown
+// when lines of the current file are marked or have diagnostics.
+const gutterWidth = 2
+
+// gutterMarks returns the marked lines of the current file.
+func (e *Editor) gutterMarks() []int {
+	if e.filename == "" || len(e.testmarks) == 0 {
+		return nil
+	}
+	path, err := absPath(e.filename)
+	if err != nil {
+		return nil
+	}
+	return e.testmarks[path]
+}
+
+// gutterCols returns the number of columns taken by the gutter.
+func (e *Editor) gutterCols() int {
+	if len(e.gutterMarks()) == 0 && len(e.fileDiagnostics()) == 0 {
+		return 0
+	}
+	return gutterWidth
+}
+
+// drawGutter draws the gutter of a row, which has a red mark on lines
+// where a test failed, and otherwise the number of diagnostics of the
+// line in the color of the most severe one.
+func drawGutter(b *bytes.Buffer, marks []int, diags []diagnostic, filerow int) {
+	switch {
+	case slices.Contains(marks, filerow):
+		b.WriteString("\x1b[31m✗\x1b[m ")
+	case len(diags) > 9:
+		fmt.Fprintf(b, "\x1b[%dm+\x1b[m ", diagnosticColor(diags[0].severity))
+	case len(diags) > 0:
+		fmt.Fprintf(b, "\x1b[%dm%d\x1b[m ", diagnosticColor(diags[0].severity), len(diags))
+	default:
+		b.WriteString("  ")
+	}
+}
@@ -0,0 +1,82 @@
+package editor
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+)
+
+const maxHistory = 100
+
+func historyFile() string {
+	return filepath.Join(stateDir(), "history")
+}
+
+// historyName is the name the prompt's history is kept under, e.g.
+// "save as" for the "Save as:" prompt.
+func historyName(prompt string) string {
+	return strings.ToLower(strings.TrimSuffix(prompt, ":"))
+}
+
+// readHistory returns the inputs of every prompt, oldest first. Each
+// line has the format: name<TAB>input
+func readHistory() map[string][]string {
+	f, err := os.Open(historyFile())
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	history := map[string][]string{}
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		name, input, ok := strings.Cut(sc.Text(), "\t")
+		if ok && input != "" {
+			history[name] = append(history[name], input)
+		}
+	}
+	return history
+}
+
+// promptHistory returns the previous inputs of the prompt, oldest first.
+func promptHistory(prompt string) []string {
+	return readHistory()[historyName(prompt)]
+}
+
+// addHistory adds the input to the end of the prompt's history,
+// removing any earlier copy of it. The history is private, it's written
+// like the other state files, and not at all without a state directory
+// rather than to the current one.
+func addHistory(prompt, input string) {
+	if stateDir() == "" {
+		return
+	}
+	history := readHistory()
+	if history == nil {
+		history = map[string][]string{}
+	}
+	name := historyName(prompt)
+	var inputs []string
+	for _, s := range history[name] {
+		if s != input {
+			inputs = append(inputs, s)
+		}
+	}
+	inputs = append(inputs, input)
+	if len(inputs) > maxHistory {
+		inputs = inputs[len(inputs)-maxHistory:]
+	}
+	history[name] = inputs
+	names := maps.Keys(history)
+	slices.Sort(names)
+	var b strings.Builder
+	for _, name := range names {
+		for _, s := range history[name] {
+			b.WriteString(name + "\t" + s + "\n")
+		}
+	}
+	writeFileAtomic(historyFile(), []byte(b.String()))
+}
@@ -0,0 +1,66 @@
+package editor
+
+import (
+	"bytes"
+	"unicode/utf8"
+
+	"github.com/icholy/kilo/internal/ui"
+)
+
+// typeText inserts the text of a text event: characters other than
+// ascii, typed or committed by an input method. It's inserted at once,
+// and undone at once, instead of byte by byte.
+func (e *Editor) typeText() {
+	if e.term == nil || len(e.term.Text) == 0 {
+		return
+	}
+	text := bytes.ReplaceAll(e.term.Text, []byte("\r"), []byte("\n"))
+	e.term.Text = nil
+	e.cy, e.cx = e.replaceRange(e.cy, e.cx, e.cy, e.cx, text)
+	e.autoWrap()
+}
+
+// prevChar returns the index of the character before index cx of
+// chars, stepping over all the bytes of a utf-8 character.
+func prevChar(chars []byte, cx int) int {
+	if cx <= 0 {
+		return 0
+	}
+	_, size := utf8.DecodeLastRune(chars[:cx])
+	return cx - size
+}
+
+// nextChar returns the index of the character after the one at index cx
+// of chars.
+func nextChar(chars []byte, cx int) int {
+	if cx >= len(chars) {
+		return len(chars)
+	}
+	_, size := utf8.DecodeRune(chars[cx:])
+	return cx + size
+}
+
+// charStart moves index cx of chars back to the start of the character
+// it's in the middle of, if it is.
+func charStart(chars []byte, cx int) int {
+	for n := 0; cx > 0 && cx < len(chars) && n < utf8.UTFMax-1 && !utf8.RuneStart(chars[cx]); n++ {
+		cx--
+	}
+	return cx
+}
+
+// cursorCol returns the screen column of the cursor. Characters other
+// than ascii take up more bytes of the rendered row than columns of the
+// screen, and CJK characters take up two columns. Input methods show
+// the text being composed at the cursor, so it has to be where the
+// terminal draws the character under it.
+func (e *Editor) cursorCol() int {
+	col := e.rx - e.coloff
+	if e.cy < e.buf.NumRows() {
+		render := e.buf.Rows[e.cy].Render()
+		if e.coloff <= e.rx && e.rx <= len(render) {
+			col = ui.Width(render[e.coloff:e.rx])
+		}
+	}
+	return e.gutterCols() + col
+}
@@ -0,0 +1,102 @@
+package editor
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// numberPattern matches the numbers which can be incremented: hex,
+// octal, and binary with their 0x, 0o, and 0b prefixes, and decimal
+// with an optional minus sign.
+var numberPattern = regexp.MustCompile(`0[xX][0-9a-fA-F]+|0[oO][0-7]+|0[bB][01]+|-?[0-9]+`)
+
+// increment adds delta to the number under or after the cursor on the
+// current line, keeping its base, case, and width, and leaves the
+// cursor on its last digit. Numbers with a prefix are unsigned and wrap
+// around.
+func (e *Editor) increment(delta int64) {
+	if e.cy >= e.buf.NumRows() {
+		return
+	}
+	chars := e.buf.Rows[e.cy].Chars
+	var loc []int
+	for _, m := range numberPattern.FindAllIndex(chars, -1) {
+		if m[1] > e.cx {
+			loc = m
+			break
+		}
+	}
+	if loc == nil {
+		e.fail("no number under or after the cursor")
+		return
+	}
+	number := string(chars[loc[0]:loc[1]])
+	// a minus after a word character is subtraction, e.g. x-1
+	if strings.HasPrefix(number, "-") && loc[0] > 0 && e.isWordByte(chars[loc[0]-1]) {
+		number = number[1:]
+		loc[0]++
+	}
+	text, err := incrementNumber(number, delta)
+	if err != nil {
+		e.fail("%v", err)
+		return
+	}
+	e.replaceRange(e.cy, loc[0], e.cy, loc[1], []byte(text))
+	e.cx = loc[0] + len(text) - 1
+}
+
+// incrementNumber adds delta to the number in text.
+func incrementNumber(text string, delta int64) (string, error) {
+	if len(text) > 2 && text[0] == '0' && strings.ContainsRune("xXoObB", rune(text[1])) {
+		base := map[byte]int{'x': 16, 'o': 8, 'b': 2}[text[1]|0x20]
+		digits := text[2:]
+		n, err := strconv.ParseUint(digits, base, 64)
+		if err != nil {
+			return "", err
+		}
+		s := strconv.FormatUint(n+uint64(delta), base)
+		if strings.ToUpper(digits) == digits && strings.ToLower(digits) != digits {
+			s = strings.ToUpper(s)
+		}
+		return text[:2] + zeroPad(s, len(digits)), nil
+	}
+	n, err := strconv.ParseInt(text, 10, 64)
+	if err != nil {
+		return "", err
+	}
+	s := strconv.FormatInt(n+delta, 10)
+	// keep the width of numbers with leading zeros, e.g. 007
+	digits := strings.TrimPrefix(text, "-")
+	if len(digits) > 1 && digits[0] == '0' {
+		sign := ""
+		if strings.HasPrefix(s, "-") {
+			sign, s = "-", s[1:]
+		}
+		s = sign + zeroPad(s, len(digits))
+	}
+	return s, nil
+}
+
+// zeroPad pads s with leading zeros to width.
+func zeroPad(s string, width int) string {
+	if len(s) >= width {
+		return s
+	}
+	return strings.Repeat("0", width-len(s)) + s
+}
+
+// incrementCommand implements the increment and decrement commands,
+// which take the amount as an optional argument.
+func (e *Editor) incrementCommand(args string, sign int64) {
+	delta := int64(1)
+	if args = strings.TrimSpace(args); args != "" {
+		n, err := strconv.ParseInt(args, 10, 64)
+		if err != nil {
+			e.fail("invalid amount: %s", args)
+			return
+		}
+		delta = n
+	}
+	e.increment(sign * delta)
+}
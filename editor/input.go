@@ -0,0 +1,353 @@
+package editor
+
+import (
+	"strings"
+	"time"
+
+	"github.com/icholy/kilo/internal/buffer"
+	"github.com/icholy/kilo/internal/term"
+)
+
+// mouse translates a mouse event into cursor movement, scrolling,
+// or selection.
+func (e *Editor) mouse() {
+	if e.term == nil {
+		return
+	}
+	m := e.term.Mouse
+	if e.outlineClick(m) {
+		return
+	}
+	switch {
+	case m.Button == term.MouseWheelUp:
+		e.scrollBy(-3)
+	case m.Button == term.MouseWheelDown:
+		e.scrollBy(3)
+	case m.Release:
+	case m.Button == term.MouseLeft || m.Button == term.MouseDrag:
+		if m.Y >= e.screenrows {
+			return
+		}
+		if m.Button == term.MouseLeft {
+			e.selection.active = false
+			e.outlinefocus = false
+		}
+		cy := clamp(m.Y+e.rowoff, 0, e.buf.NumRows())
+		cx := 0
+		if cy < e.buf.NumRows() {
+			rx := m.X - e.gutterCols()
+			if rx < 0 {
+				rx = 0
+			}
+			cx = e.buf.Rows[cy].RxToCx(rx + e.coloff)
+		}
+		if m.Button == term.MouseLeft {
+			e.selection = Selection{cx: cx, cy: cy}
+		} else {
+			e.selection.active = true
+		}
+		e.cx, e.cy = cx, cy
+	}
+}
+
+// scrollBy moves the viewport by n rows, dragging the cursor
+// along if it would go off screen.
+func (e *Editor) scrollBy(n int) {
+	e.rowoff = clamp(e.rowoff+n, 0, e.buf.NumRows())
+	if e.cy < e.rowoff {
+		e.moveTo(e.cx, e.rowoff)
+	}
+	if e.cy >= e.rowoff+e.screenrows {
+		e.moveTo(e.cx, e.rowoff+e.screenrows-1)
+	}
+}
+
+// moveTo puts the cursor at the given position, clamped to the buffer.
+func (e *Editor) moveTo(cx, cy int) {
+	e.cy = clamp(cy, 0, e.buf.NumRows())
+	e.cx = 0
+	if e.cy < e.buf.NumRows() {
+		e.cx = clamp(cx, 0, e.buf.Rows[e.cy].Len())
+	}
+}
+
+// readKey waits for the next key. Without a terminal, the keys come
+// from HandleKey, or prompts are cancelled when running a script.
+func (e *Editor) readKey() int {
+	if len(e.pending) > 0 {
+		c := e.pending[0]
+		e.pending = e.pending[1:]
+		return c
+	}
+	var c int
+	if e.term != nil {
+		var err error
+		for {
+			c, err = e.term.ReadKey()
+			if err != nil {
+				e.die("%v", err)
+			}
+			if c != term.ResizeEvent {
+				break
+			}
+			e.resizeTerminal()
+		}
+		e.scheduleIdle()
+		e.recordKey(c)
+	} else if e.input != nil {
+		e.ready <- struct{}{}
+		c = <-e.input
+	} else {
+		// headless, cancel any prompts
+		c = '\x1b'
+	}
+	e.keytime = time.Now()
+	e.log(LogDebug, "key", "code", c)
+	return c
+}
+
+// unreadKey pushes c back so that it's returned by the next readKey.
+func (e *Editor) unreadKey(c int) {
+	e.pending = append(e.pending, c)
+}
+
+func (e *Editor) processKeypress() {
+	e.pollRequests()
+	c := e.readKey()
+	e.pluginEvent("key", map[string]any{"key": c})
+	if e.termfocus {
+		e.terminalKey(c)
+		return
+	}
+	if e.outlinefocus && e.outlineCols() > 0 {
+		e.outlineKey(c)
+		return
+	}
+	if e.welcome && e.welcomeKey(c) {
+		return
+	}
+	if e.countKey(c) || e.registerKey(c) {
+		return
+	}
+	if e.register != 0 {
+		// the register is only used by this key
+		e.showStatus("")
+		defer func() { e.register = 0 }()
+	}
+	n := e.takeCount(c)
+	defer e.commitUndo(c)
+	defer e.announceMove(c, e.cx, e.cy, e.buf.Changes, e.statustime)
+	start := time.Now()
+	for i := 0; i < n && !e.closed; i++ {
+		e.dispatchKey(c)
+	}
+	e.timing.keyed = true
+	e.log(LogDebug, "dispatch", "code", c, "count", n, "time", time.Since(start))
+}
+
+// dispatchKey runs the command bound to a key.
+func (e *Editor) dispatchKey(c int) {
+	e.killappend = e.killed
+	e.killed = false
+	if b, ok := e.keymap[c]; ok {
+		e.runBinding(b)
+		return
+	}
+	switch c {
+	case term.ControlKey('q'):
+		e.Close()
+	case term.ControlKey('s'):
+		e.save()
+	case term.ControlKey('f'):
+		e.find(1)
+	case term.ControlKey(' '):
+		e.completion()
+	case term.ControlKey('g'):
+		e.definition()
+	case term.ControlKey('r'):
+		e.references()
+	case term.ControlKey('p'):
+		e.commandPrompt()
+	case term.ControlKey('_'):
+		e.toggleComment(e.selectedRows())
+	case terminalFocusKey:
+		e.focusTerminal()
+	case digraphKey:
+		e.enterDigraph()
+	case term.MouseEvent:
+		e.mouse()
+		return
+	case term.PasteEvent:
+		e.paste()
+	case term.TextEvent:
+		e.typeText()
+	case term.AltKey('x'):
+		e.commandPrompt()
+	case term.ShiftModifier | term.ArrowUp, term.ShiftModifier | term.ArrowDown, term.ShiftModifier | term.ArrowLeft, term.ShiftModifier | term.ArrowRight:
+		e.extendSelection(c &^ term.ShiftModifier)
+		return
+	case term.CtrlModifier | term.ArrowRight, term.AltKey('f'):
+		e.moveWordForward()
+	case term.CtrlModifier | term.ArrowLeft, term.AltKey('b'):
+		e.moveWordBackward()
+	case term.ControlKey('w'):
+		e.deleteWordBackward()
+	case term.AltKey('d'):
+		e.deleteWordForward()
+	case term.AltModifier | term.ArrowUp:
+		e.moveLines(-1)
+		return
+	case term.AltModifier | term.ArrowDown:
+		e.moveLines(1)
+		return
+	case term.ShiftModifier | term.AltModifier | term.ArrowDown:
+		e.duplicateLines()
+		return
+	case term.AltKey('u'):
+		e.changeCase("upper")
+	case term.AltKey('l'):
+		e.changeCase("lower")
+	case term.AltKey('c'):
+		e.changeCase("title")
+	case term.AltKey('/'):
+		e.completeWord()
+	case '\t':
+		e.tab()
+		if e.selection.active {
+			return
+		}
+	case term.ShiftModifier | '\t':
+		e.indentLines(-1)
+		return
+	case term.ControlKey('k'):
+		e.killToEnd()
+	case term.AltKey('k'):
+		e.killLine()
+	case term.ControlKey('y'):
+		e.yank()
+	case term.AltKey('w'):
+		e.copyText("")
+	case term.ControlKey('a'):
+		e.increment(1)
+	case term.ControlKey('x'):
+		e.increment(-1)
+	case term.F2:
+		e.rename()
+	case term.F5:
+		e.build("")
+	case term.ShiftModifier | term.F5:
+		e.runTests("")
+	case term.CtrlModifier | term.F5:
+		e.runFile("")
+	case term.F8:
+		e.nextError(1)
+	case term.F3:
+		e.findNext(1)
+	case term.ShiftModifier | term.F3:
+		e.findNext(-1)
+	case term.ShiftModifier | term.F8:
+		e.nextError(-1)
+	case term.F12:
+		e.definition()
+	case term.ShiftModifier | term.F12:
+		e.references()
+	case term.ControlKey('z'):
+		e.undoChange()
+	case term.ControlKey(']'):
+		e.tagJump()
+	case term.ControlKey('t'), term.ControlKey('o'):
+		e.jumpOlder()
+	case term.CtrlModifier | 'i', term.AltKey('i'):
+		e.jumpNewer()
+	case term.ControlKey('n'):
+		e.rename()
+	case term.ControlKey('e'):
+		e.hover()
+	case term.ArrowUp, term.ArrowDown, term.ArrowLeft, term.ArrowRight:
+		e.moveCursor(c)
+	case term.PageUp:
+		e.pushJump(e.location())
+		e.cy = e.rowoff
+		for i := 0; i < e.screenrows; i++ {
+			e.moveCursor(term.ArrowUp)
+		}
+	case term.PageDown:
+		e.pushJump(e.location())
+		e.cy = e.rowoff + e.screenrows - 1
+		if e.cy > e.buf.NumRows() {
+			e.cy = e.buf.NumRows()
+		}
+		for i := 0; i < e.screenrows; i++ {
+			e.moveCursor(term.ArrowDown)
+		}
+	case term.HomeKey:
+		e.cx = 0
+	case term.EndKey:
+		if e.cy < e.buf.NumRows() {
+			e.cx = e.buf.Rows[e.cy].Len()
+		}
+	case '\r':
+		if !e.markdownNewline() {
+			e.insertNewline()
+		}
+	case term.DeleteKey:
+		e.moveCursor(term.ArrowRight)
+		e.deleteChar()
+	case term.ControlKey('h'), term.BackspaceKey:
+		e.deleteChar()
+	case term.ControlKey('l'):
+		e.centerCursor()
+	case '\x1b', term.UnknownKey:
+		// ignore
+	default:
+		if c >= term.AltModifier || c >= term.ArrowLeft {
+			e.setStatus("key not bound")
+			break
+		}
+		e.typeChar(c)
+		e.autoWrap()
+		if e.lsp != nil && c < 128 && strings.ContainsRune(e.lsp.triggers, rune(c)) {
+			e.completion()
+		}
+	}
+	e.selection.active = false
+}
+
+func (e *Editor) moveCursor(c int) {
+	var row *buffer.Row
+	if e.cy < e.buf.NumRows() {
+		row = e.buf.Rows[e.cy]
+	}
+	switch c {
+	case term.ArrowUp:
+		if e.cy > 0 {
+			e.cy--
+		}
+	case term.ArrowDown:
+		if e.cy < e.buf.NumRows() {
+			e.cy++
+		}
+	case term.ArrowLeft:
+		if e.cx > 0 {
+			e.cx = prevChar(row.Chars, e.cx)
+		} else if e.cy > 0 {
+			e.cy--
+			e.cx = e.buf.Rows[e.cy].Len()
+		}
+	case term.ArrowRight:
+		if row.Chars != nil && e.cx < row.Len() {
+			e.cx = nextChar(row.Chars, e.cx)
+		} else if row.Chars != nil && e.cx == row.Len() {
+			e.cy++
+			e.cx = 0
+		}
+	}
+
+	if e.cy < e.buf.NumRows() {
+		row := e.buf.Rows[e.cy]
+		if e.cx > row.Len() {
+			e.cx = row.Len()
+		}
+		e.cx = charStart(row.Chars, e.cx)
+	}
+}
@@ -0,0 +1,103 @@
+package editor
+
+import (
+	"strconv"
+	"strings"
+)
+
+type Location struct {
+	filename string
+	cx, cy   int
+}
+
+// jump moves the cursor to loc, opening its file if necessary.
+// The previous location is recorded in the jump list.
+func (e *Editor) jump(loc Location) {
+	prev := e.location()
+	if !e.switchFile(loc.filename) {
+		return
+	}
+	e.pushJump(prev)
+	e.moveTo(loc.cx, loc.cy)
+}
+
+func (e *Editor) location() Location {
+	return Location{filename: e.filename, cx: e.cx, cy: e.cy}
+}
+
+// pushJump records loc in the jump list. Like in vim, jumping
+// somewhere new discards the locations ahead of the current position.
+func (e *Editor) pushJump(loc Location) {
+	e.jumps = append(e.jumps[:e.jumpidx], loc)
+	if len(e.jumps) > jumpListSize {
+		e.jumps = e.jumps[1:]
+	}
+	e.jumpidx = len(e.jumps)
+}
+
+const jumpListSize = 100
+
+// jumpOlder goes back to the previous location in the jump list.
+func (e *Editor) jumpOlder() {
+	if e.jumpidx == 0 {
+		e.setStatus("at start of jump list")
+		return
+	}
+	// remember where we are so we can come back with jumpNewer
+	if e.jumpidx == len(e.jumps) {
+		e.jumps = append(e.jumps, e.location())
+	}
+	if e.gotoJump(e.jumps[e.jumpidx-1]) {
+		e.jumpidx--
+	}
+}
+
+// jumpNewer undoes a jumpOlder.
+func (e *Editor) jumpNewer() {
+	if e.jumpidx >= len(e.jumps)-1 {
+		e.setStatus("at end of jump list")
+		return
+	}
+	if e.gotoJump(e.jumps[e.jumpidx+1]) {
+		e.jumpidx++
+	}
+}
+
+func (e *Editor) gotoJump(loc Location) bool {
+	if !e.switchFile(loc.filename) {
+		return false
+	}
+	e.moveTo(loc.cx, loc.cy)
+	return true
+}
+
+// gotoLine prompts for a line number, optionally followed by :column,
+// and moves the cursor there.
+func (e *Editor) gotoLine(args string) {
+	if args == "" {
+		var ok bool
+		if args, ok = e.prompt("Go to line:", nil); !ok {
+			return
+		}
+	}
+	line, col, hascol := strings.Cut(strings.TrimSpace(args), ":")
+	n, err := strconv.Atoi(line)
+	if err != nil {
+		e.fail("invalid line number: %s", args)
+		return
+	}
+	var rx int
+	if hascol {
+		if rx, err = strconv.Atoi(col); err != nil || rx < 1 {
+			e.fail("invalid column: %s", col)
+			return
+		}
+		rx--
+	}
+	e.pushJump(e.location())
+	e.moveTo(0, n-1)
+	if e.cy < e.buf.NumRows() {
+		// the column is where it's displayed, counting tabs as their width
+		e.moveTo(e.buf.Rows[e.cy].RxToCx(rx), e.cy)
+	}
+}
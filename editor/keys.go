@@ -0,0 +1,156 @@
+package editor
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/icholy/kilo/internal/term"
+)
+
+// Keys which can be passed to HandleKey. Printable characters are
+// passed as themselves.
+const (
+	KeyEnter     = '\r'
+	KeyTab       = '\t'
+	KeyEscape    = '\x1b'
+	KeyBackspace = term.BackspaceKey
+	KeyLeft      = term.ArrowLeft
+	KeyRight     = term.ArrowRight
+	KeyUp        = term.ArrowUp
+	KeyDown      = term.ArrowDown
+	KeyPageUp    = term.PageUp
+	KeyPageDown  = term.PageDown
+	KeyHome      = term.HomeKey
+	KeyEnd       = term.EndKey
+	KeyDelete    = term.DeleteKey
+	KeyInsert    = term.InsertKey
+	KeyF1        = term.F1
+	KeyF2        = term.F2
+	KeyF3        = term.F3
+	KeyF4        = term.F4
+	KeyF5        = term.F5
+	KeyF6        = term.F6
+	KeyF7        = term.F7
+	KeyF8        = term.F8
+	KeyF9        = term.F9
+	KeyF10       = term.F10
+	KeyF11       = term.F11
+	KeyF12       = term.F12
+)
+
+// Modifiers which can be combined with the keys above.
+const (
+	ModAlt   = term.AltModifier
+	ModShift = term.ShiftModifier
+	ModCtrl  = term.CtrlModifier
+)
+
+// ControlKey returns the key produced by pressing Ctrl with c.
+func ControlKey(c byte) int {
+	return term.ControlKey(c)
+}
+
+// AltKey returns the key produced by pressing Alt with c.
+func AltKey(c byte) int {
+	return term.AltKey(c)
+}
+
+// keyNames are the names of the keys which aren't typed as a character,
+// in the notation of bind and of key files.
+var keyNames = map[string]int{
+	"enter":    KeyEnter,
+	"tab":      KeyTab,
+	"esc":      KeyEscape,
+	"bs":       KeyBackspace,
+	"space":    ' ',
+	"lt":       '<',
+	"left":     KeyLeft,
+	"right":    KeyRight,
+	"up":       KeyUp,
+	"down":     KeyDown,
+	"pageup":   KeyPageUp,
+	"pagedown": KeyPageDown,
+	"home":     KeyHome,
+	"end":      KeyEnd,
+	"del":      KeyDelete,
+	"insert":   KeyInsert,
+}
+
+// parseKey parses key names like ctrl-t, alt-x, f6, enter, and
+// shift-up. A single character stands for itself.
+func parseKey(name string) (int, bool) {
+	var mods int
+	for {
+		prefix, rest, ok := strings.Cut(name, "-")
+		if !ok || rest == "" {
+			break
+		}
+		switch strings.ToLower(prefix) {
+		case "ctrl":
+			mods |= ModCtrl
+		case "alt":
+			mods |= ModAlt
+		case "shift":
+			mods |= ModShift
+		default:
+			return 0, false
+		}
+		name = rest
+	}
+	var k int
+	if name == "" {
+		return 0, false
+	} else if len(name) == 1 {
+		k = int(name[0])
+	} else if code, ok := keyNames[strings.ToLower(name)]; ok {
+		k = code
+	} else if n, err := strconv.Atoi(name[1:]); (name[0] == 'f' || name[0] == 'F') && err == nil && n >= 1 && n <= 12 {
+		k = KeyF1 + n - 1
+	} else {
+		return 0, false
+	}
+	// control characters and shifted characters are characters too
+	if k < 128 {
+		if mods&ModCtrl != 0 {
+			k = ControlKey(byte(k))
+		}
+		if mods&ModShift != 0 {
+			k = int(strings.ToUpper(string(rune(k)))[0])
+		}
+		mods &^= ModCtrl | ModShift
+	}
+	return k | mods, true
+}
+
+// keyName returns the name parseKey parses as the key, or the character
+// itself for the ones which are typed.
+func keyName(k int) string {
+	var prefix string
+	for _, m := range []struct {
+		mod  int
+		name string
+	}{{ModCtrl, "ctrl-"}, {ModAlt, "alt-"}, {ModShift, "shift-"}} {
+		if k&m.mod != 0 {
+			prefix += m.name
+			k &^= m.mod
+		}
+	}
+	for name, code := range keyNames {
+		if code == k && name != "space" {
+			return prefix + name
+		}
+	}
+	switch {
+	case k >= KeyF1 && k <= KeyF12:
+		return fmt.Sprintf("%sf%d", prefix, k-KeyF1+1)
+	case k == 0:
+		return prefix + "ctrl-space"
+	case k < ' ':
+		return prefix + "ctrl-" + strings.ToLower(string(rune(k+'@')))
+	case k < 256:
+		// the bytes of utf-8 characters are keys of their own
+		return prefix + string([]byte{byte(k)})
+	}
+	return fmt.Sprintf("%s%d", prefix, k)
+}
@@ -0,0 +1,84 @@
+package editor
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseKey(t *testing.T) {
+	tests := []struct {
+		name string
+		key  int
+		ok   bool
+	}{
+		{"a", 'a', true},
+		{"-", '-', true},
+		{"ctrl-t", ControlKey('t'), true},
+		{"Ctrl-T", ControlKey('T'), true},
+		{"ctrl-space", ControlKey(' '), true},
+		{"alt-x", AltKey('x'), true},
+		{"shift-a", 'A', true},
+		{"enter", KeyEnter, true},
+		{"f6", KeyF6, true},
+		{"F12", KeyF12, true},
+		{"shift-up", KeyUp | ModShift, true},
+		{"ctrl-alt-left", KeyLeft | ModCtrl | ModAlt, true},
+		{"f13", 0, false},
+		{"f0", 0, false},
+		{"hyper-x", 0, false},
+		{"nosuchkey", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		key, ok := parseKey(tt.name)
+		if ok != tt.ok || ok && key != tt.key {
+			t.Errorf("parseKey(%q) = %d, %v, want %d, %v", tt.name, key, ok, tt.key, tt.ok)
+		}
+	}
+}
+
+func TestKeyName(t *testing.T) {
+	keys := []int{
+		'a', '<', ControlKey('t'), ControlKey(' '), AltKey('x'), KeyEnter, KeyEscape,
+		KeyBackspace, KeyF1, KeyF12, KeyUp | ModShift, KeyLeft | ModCtrl | ModAlt, KeyDelete,
+	}
+	for _, k := range keys {
+		name := keyName(k)
+		if got, ok := parseKey(name); !ok || got != k {
+			t.Errorf("parseKey(keyName(%d) = %q) = %d, %v", k, name, got, ok)
+		}
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	tests := []struct {
+		s    string
+		keys []int
+		ok   bool
+	}{
+		{"ab", []int{'a', 'b'}, true},
+		{"i<enter>\nx<ctrl-s>", []int{'i', KeyEnter, 'x', ControlKey('s')}, true},
+		{"<lt>b>", []int{'<', 'b', '>'}, true},
+		{"<shift-down><space>", []int{KeyDown | ModShift, ' '}, true},
+		{"<ctrl-s", nil, false},
+		{"<nosuchkey>", nil, false},
+	}
+	for _, tt := range tests {
+		keys, err := ParseKeys(tt.s)
+		if (err == nil) != tt.ok || tt.ok && !reflect.DeepEqual(keys, tt.keys) {
+			t.Errorf("ParseKeys(%q) = %v, %v, want %v", tt.s, keys, err, tt.keys)
+		}
+	}
+}
+
+func TestFormatKey(t *testing.T) {
+	keys := []int{'h', 'i', '<', KeyEnter, ControlKey('s'), KeyBackspace, KeyDown | ModShift, ' ', AltKey('.')}
+	var s string
+	for _, k := range keys {
+		s += formatKey(k)
+	}
+	got, err := ParseKeys(s)
+	if err != nil || !reflect.DeepEqual(got, keys) {
+		t.Errorf("ParseKeys(%q) = %v, %v, want %v", s, got, err, keys)
+	}
+}
@@ -0,0 +1,85 @@
+package editor
+
+import "golang.org/x/exp/slices"
+
+const killRingSize = 16
+
+// kill saves killed text in the kill ring. Consecutive kills are
+// merged into a single entry so they can be yanked back together.
+func (e *Editor) kill(text []byte, backward bool) {
+	if e.killappend && len(e.killring) > 0 {
+		last := e.killring[len(e.killring)-1]
+		if backward {
+			e.killring[len(e.killring)-1] = append(slices.Clone(text), last...)
+		} else {
+			e.killring[len(e.killring)-1] = append(last, text...)
+		}
+	} else {
+		e.killring = append(e.killring, slices.Clone(text))
+		if len(e.killring) > killRingSize {
+			e.killring = e.killring[1:]
+		}
+	}
+	// a named register gets the merged kill, unless it's being appended to
+	switch r := e.register; {
+	case 'A' <= r && r <= 'Z':
+		e.setRegister(r, text)
+	case r != 0 && r != defaultRegister:
+		e.setRegister(r, e.killring[len(e.killring)-1])
+	}
+	e.killed = true
+}
+
+// killRange deletes the range and saves it in the kill ring.
+func (e *Editor) killRange(y0, x0, y1, x1 int, backward bool) {
+	e.kill(e.getRange(y0, x0, y1, x1), backward)
+	e.cy, e.cx = e.replaceRange(y0, x0, y1, x1, nil)
+}
+
+// killToEnd kills from the cursor to the end of the line. At the
+// end of a line, the line break is killed instead.
+func (e *Editor) killToEnd() {
+	if e.cy >= e.buf.NumRows() {
+		return
+	}
+	row := e.buf.Rows[e.cy]
+	if e.cx < row.Len() {
+		e.killRange(e.cy, e.cx, e.cy, row.Len(), false)
+	} else if e.cy < e.buf.NumRows()-1 {
+		e.killRange(e.cy, e.cx, e.cy+1, 0, false)
+	}
+}
+
+// killLine kills the whole current line including its line break.
+func (e *Editor) killLine() {
+	if e.cy >= e.buf.NumRows() {
+		return
+	}
+	cx := e.cx
+	if e.cy < e.buf.NumRows()-1 {
+		e.killRange(e.cy, 0, e.cy+1, 0, false)
+	} else {
+		e.kill(append(slices.Clone(e.buf.Rows[e.cy].Chars), '\n'), false)
+		if e.cy > 0 {
+			e.replaceRange(e.cy-1, e.buf.Rows[e.cy-1].Len(), e.cy, e.buf.Rows[e.cy].Len(), nil)
+			e.cy--
+		} else {
+			e.replaceRange(0, 0, 0, e.buf.Rows[0].Len(), nil)
+		}
+	}
+	e.moveTo(cx, e.cy)
+}
+
+// yank inserts the most recently killed text at the cursor, or the
+// text in the register chosen with Alt-".
+func (e *Editor) yank() {
+	if e.register != 0 {
+		e.pasteText("")
+		return
+	}
+	if len(e.killring) == 0 {
+		e.fail("kill ring is empty")
+		return
+	}
+	e.cy, e.cx = e.replaceRange(e.cy, e.cx, e.cy, e.cx, e.killring[len(e.killring)-1])
+}
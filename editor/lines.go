@@ -0,0 +1,269 @@
+package editor
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/exp/slices"
+)
+
+// moveLines moves the current line, or the selected lines, up
+// (dir < 0) or down (dir > 0) by one row. The cursor and selection
+// move along with the text.
+func (e *Editor) moveLines(dir int) {
+	start, end := e.selectedRows()
+	if end >= e.buf.NumRows() {
+		return
+	}
+	if dir < 0 && start == 0 || dir > 0 && end >= e.buf.NumRows()-1 {
+		return
+	}
+	if dir < 0 {
+		row := e.buf.Rows[start-1]
+		e.buf.Rows = slices.Delete(e.buf.Rows, start-1, start)
+		e.buf.Rows = slices.Insert(e.buf.Rows, end, row)
+	} else {
+		row := e.buf.Rows[end+1]
+		e.buf.Rows = slices.Delete(e.buf.Rows, end+1, end+2)
+		e.buf.Rows = slices.Insert(e.buf.Rows, start, row)
+	}
+	e.cy += dir
+	if e.selection.active {
+		e.selection.cy += dir
+	}
+	e.dirty = true
+	e.buf.Changes++
+}
+
+// duplicateLines inserts a copy of the current line, or the
+// selected lines, below them and moves the cursor onto the copy.
+func (e *Editor) duplicateLines() {
+	start, end := e.selectedRows()
+	if end >= e.buf.NumRows() {
+		return
+	}
+	n := end - start + 1
+	for y := start; y <= end; y++ {
+		e.insertRow(end+1+y-start, slices.Clone(e.buf.Rows[y].Chars))
+	}
+	e.cy += n
+	if e.selection.active {
+		e.selection.cy += n
+	}
+}
+
+// sortableRows returns the selected rows, or the whole buffer if
+// nothing is selected.
+func (e *Editor) sortableRows() (start, end int) {
+	if _, _, _, _, ok := e.selectionBounds(); ok {
+		start, end = e.selectedRows()
+		return start, clamp(end, 0, e.buf.NumRows()-1)
+	}
+	return 0, e.buf.NumRows() - 1
+}
+
+// setLines replaces the rows from start onwards with lines.
+func (e *Editor) setLines(start int, lines [][]byte) {
+	for i, line := range lines {
+		e.buf.Rows[start+i].Chars = line
+		e.buf.Rows[start+i].Update()
+	}
+	e.dirty = true
+}
+
+// leadingNumber parses the number at the start of the line.
+func leadingNumber(line []byte) (float64, bool) {
+	s := strings.TrimSpace(string(line))
+	end := 0
+	for end < len(s) && (unicode.IsDigit(rune(s[end])) || s[end] == '.' || (end == 0 && (s[end] == '-' || s[end] == '+'))) {
+		end++
+	}
+	n, err := strconv.ParseFloat(s[:end], 64)
+	return n, err == nil
+}
+
+// sort sorts the selected lines. The args may contain "reverse"
+// and "numeric". Lines without a number sort before numeric ones.
+func (e *Editor) sort(args string) {
+	start, end := e.sortableRows()
+	if start >= end {
+		return
+	}
+	var reverse, numeric bool
+	for _, arg := range strings.Fields(args) {
+		switch arg {
+		case "reverse", "desc":
+			reverse = true
+		case "numeric", "n":
+			numeric = true
+		default:
+			e.fail("sort: unknown option %q", arg)
+			return
+		}
+	}
+	lines := make([][]byte, 0, end-start+1)
+	for y := start; y <= end; y++ {
+		lines = append(lines, e.buf.Rows[y].Chars)
+	}
+	less := func(a, b []byte) bool { return bytes.Compare(a, b) < 0 }
+	if numeric {
+		less = func(a, b []byte) bool {
+			na, oka := leadingNumber(a)
+			nb, okb := leadingNumber(b)
+			if oka != okb {
+				return okb
+			}
+			return na < nb
+		}
+	}
+	slices.SortStableFunc(lines, func(a, b []byte) bool {
+		if reverse {
+			return less(b, a)
+		}
+		return less(a, b)
+	})
+	e.setLines(start, lines)
+	e.setStatus("sorted %d lines", len(lines))
+}
+
+// uniq removes duplicate lines from the selection. By default only
+// adjacent duplicates are removed, with "all" every repeated line is.
+func (e *Editor) uniq(args string) {
+	start, end := e.sortableRows()
+	if start >= end {
+		return
+	}
+	global := args == "all"
+	seen := map[string]bool{}
+	var removed int
+	for y := start; y <= end; {
+		line := string(e.buf.Rows[y].Chars)
+		dup := seen[line]
+		if !global {
+			dup = y > start && line == string(e.buf.Rows[y-1].Chars)
+		}
+		if dup {
+			e.buf.DeleteRows(y, y+1)
+			end--
+			removed++
+			continue
+		}
+		seen[line] = true
+		y++
+	}
+	if removed > 0 {
+		e.dirty = true
+		e.moveTo(e.cx, e.cy)
+	}
+	e.setStatus("removed %d duplicate lines", removed)
+}
+
+// detectIndent guesses whether the buffer is indented with tabs or
+// spaces, and how many spaces make up one level. Buffers without
+// indentation use the configured settings.
+func (e *Editor) detectIndent() {
+	e.expandtab, e.shiftwidth = e.config.ExpandTab, e.config.ShiftWidth
+	var tabs, spaces int
+	width := 0
+	for _, row := range e.buf.Rows {
+		chars := row.Chars
+		n := 0
+		for n < len(chars) && chars[n] == ' ' {
+			n++
+		}
+		if n == len(chars) {
+			continue
+		}
+		switch {
+		case chars[0] == '\t':
+			tabs++
+		case n >= 2:
+			// ignore the single space before block comment stars
+			spaces++
+			if width == 0 || n < width {
+				width = n
+			}
+		}
+	}
+	switch {
+	case tabs > spaces:
+		e.expandtab = false
+	case spaces > tabs:
+		e.expandtab = true
+		if width <= 8 {
+			e.shiftwidth = width
+		}
+	}
+}
+
+// indentString is one level of indentation.
+func (e *Editor) indentString() []byte {
+	if e.expandtab {
+		return bytes.Repeat([]byte(" "), e.shiftwidth)
+	}
+	return []byte("\t")
+}
+
+// indentLines shifts the selected rows right (dir > 0) or left
+// (dir < 0) by one level of indentation. Empty rows are left alone.
+func (e *Editor) indentLines(dir int) {
+	start, end := e.selectedRows()
+	end = clamp(end, 0, e.buf.NumRows()-1)
+	indent := e.indentString()
+	for y := start; y <= end; y++ {
+		row := e.buf.Rows[y]
+		var delta int
+		if dir > 0 {
+			if row.Len() == 0 {
+				continue
+			}
+			row.Chars = append(slices.Clone(indent), row.Chars...)
+			delta = len(indent)
+		} else {
+			switch {
+			case bytes.HasPrefix(row.Chars, []byte("\t")):
+				delta = -1
+			default:
+				n := 0
+				for n < e.shiftwidth && n < row.Len() && row.Chars[n] == ' ' {
+					n++
+				}
+				delta = -n
+			}
+			if delta == 0 {
+				continue
+			}
+			row.Chars = row.Chars[-delta:]
+		}
+		row.Update()
+		e.dirty = true
+		if y == e.cy {
+			e.cx = clamp(e.cx+delta, 0, row.Len())
+		}
+		if e.selection.active && y == e.selection.cy {
+			e.selection.cx = clamp(e.selection.cx+delta, 0, row.Len())
+		}
+	}
+}
+
+// tab indents the selection, or inserts indentation at the cursor.
+func (e *Editor) tab() {
+	if _, _, _, _, ok := e.selectionBounds(); ok {
+		e.indentLines(1)
+		return
+	}
+	if !e.expandtab {
+		e.typeChar('\t')
+		return
+	}
+	// pad to the next multiple of shiftwidth
+	rx := 0
+	if e.cy < e.buf.NumRows() {
+		rx = e.buf.Rows[e.cy].CxToRx(e.cx)
+	}
+	for n := e.shiftwidth - rx%e.shiftwidth; n > 0; n-- {
+		e.insertChar(' ')
+	}
+}
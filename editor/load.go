@@ -0,0 +1,51 @@
+package editor
+
+import (
+	"bytes"
+	"time"
+)
+
+const (
+	// loadChunk is how many rows are added to the buffer at a time
+	// while a file is loaded.
+	loadChunk = 16384
+	// loadProgressDelay is how long loading a file takes before its
+	// progress is shown.
+	loadProgressDelay = 200 * time.Millisecond
+)
+
+// loadRows adds the lines of a file to the end of the buffer. The rows
+// share data instead of copying each line. Trailing carriage returns
+// are dropped, as is the newline at the end of the file.
+func (e *Editor) loadRows(data []byte) {
+	start := time.Now()
+	total := len(data)
+	var progress bool
+	lines := make([][]byte, 0, loadChunk)
+	for len(data) > 0 {
+		line := data
+		if i := bytes.IndexByte(data, '\n'); i >= 0 {
+			line, data = data[:i], data[i+1:]
+		} else {
+			data = nil
+		}
+		if n := len(line); n > 0 && line[n-1] == '\r' {
+			line = line[:n-1]
+		}
+		// limit the capacity, so appending to a row doesn't overwrite the next
+		lines = append(lines, line[:len(line):len(line)])
+		if len(lines) == loadChunk {
+			e.buf.AppendRows(lines)
+			lines = lines[:0]
+			if e.term != nil && time.Since(start) > loadProgressDelay {
+				progress = true
+				e.showStatus("loading %s: %d%%", e.filename, (total-len(data))*100/total)
+				e.refreshScreen()
+			}
+		}
+	}
+	e.buf.AppendRows(lines)
+	if progress {
+		e.showStatus("")
+	}
+}
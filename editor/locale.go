@@ -0,0 +1,19 @@
+package editor
+
+import "github.com/icholy/kilo/internal/i18n"
+
+// loadCatalog loads the message catalog of the lang setting.
+func (e *Editor) loadCatalog() {
+	c, err := i18n.Load(e.lang, e.localedir)
+	e.catalog = c
+	if err != nil {
+		e.log(LogError, "locale", "lang", e.lang, "error", err)
+		e.fail("lang: %v", err)
+	}
+}
+
+// tr translates a message of the user interface to the language of the
+// messages. Formats are translated before their arguments are filled in.
+func (e *Editor) tr(msg string) string {
+	return e.catalog.Translate(msg)
+}
@@ -0,0 +1,107 @@
+package editor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/sys/unix"
+)
+
+// errLocked is returned by lockFile when another process holds the lock.
+var errLocked = errors.New("locked")
+
+// lockFile takes an advisory lock on the named file, which other kilo
+// instances take too. The lock is held until the returned file is closed,
+// or the process exits.
+func lockFile(name string) (*os.File, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := unix.Flock(int(f.Fd()), unix.LOCK_EX|unix.LOCK_NB); err != nil {
+		f.Close()
+		if errors.Is(err, unix.EWOULDBLOCK) {
+			return nil, errLocked
+		}
+		return nil, err
+	}
+	return f, nil
+}
+
+// vimSwapPID returns the process id of the vim editing the named file, by
+// reading the header of its swap file. Swap files left behind by a vim
+// which is no longer running are ignored.
+func vimSwapPID(name string) (int, bool) {
+	swap := filepath.Join(filepath.Dir(name), "."+filepath.Base(name)+".swp")
+	f, err := os.Open(swap)
+	if err != nil {
+		return 0, false
+	}
+	defer f.Close()
+	// the header starts with b0, the version, the page size, the mtime and
+	// the inode, followed by the pid, the user name and the host name
+	header := make([]byte, 108)
+	if _, err := f.Read(header); err != nil || !bytes.HasPrefix(header, []byte("b0VIM ")) {
+		return 0, false
+	}
+	pid := int(binary.LittleEndian.Uint32(header[24:28]))
+	host := string(bytes.TrimRight(header[68:108], "\x00"))
+	if hostname, err := os.Hostname(); err != nil || host != hostname {
+		// the process is on another machine
+		return pid, true
+	}
+	if pid <= 0 {
+		return 0, false
+	}
+	if err := unix.Kill(pid, 0); err != nil && err != unix.EPERM {
+		return 0, false
+	}
+	return pid, true
+}
+
+// lock takes the lock on the current file. When another kilo holds it,
+// or vim has the file open, the buffer is made read-only so that saving
+// doesn't clobber the other editor's changes.
+func (e *Editor) lock() {
+	if e.filelock != nil || e.filename == "" || isRemote(e.filename) {
+		return
+	}
+	f, err := lockFile(e.filename)
+	switch {
+	case errors.Is(err, errLocked):
+		e.readonly = true
+		e.log(LogInfo, "lock", "file", e.filename, "error", err)
+		e.setStatus("%s is open in another kilo, opened read-only", e.filename)
+		return
+	case err != nil:
+		// files which can't be read can't be locked, and aren't edited
+		e.log(LogError, "lock", "file", e.filename, "error", err)
+		return
+	}
+	e.filelock = f
+	if pid, ok := vimSwapPID(e.filename); ok {
+		e.readonly = true
+		e.setStatus("%s is open in vim%s, opened read-only", e.filename, pidSuffix(pid))
+	}
+}
+
+// pidSuffix describes the process id of another editor, if it's known.
+func pidSuffix(pid int) string {
+	if pid <= 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (pid %d)", pid)
+}
+
+// unlock releases the lock on the current file.
+func (e *Editor) unlock() {
+	if e.filelock != nil {
+		e.filelock.Close()
+		e.filelock = nil
+	}
+	e.readonly = false
+}
@@ -0,0 +1,102 @@
+package editor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LogLevel is how much the editor writes to its log file.
+type LogLevel int
+
+const (
+	LogOff LogLevel = iota
+	LogError
+	LogInfo
+	LogDebug // keys, escape sequences, and timing
+)
+
+var logLevels = []string{"off", "error", "info", "debug"}
+
+func (l LogLevel) String() string {
+	if l < 0 || int(l) >= len(logLevels) {
+		return strconv.Itoa(int(l))
+	}
+	return logLevels[l]
+}
+
+// Set parses a level name, so that LogLevel can be used as a flag.
+func (l *LogLevel) Set(s string) error {
+	for i, name := range logLevels {
+		if s == name {
+			*l = LogLevel(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("expected one of %s", strings.Join(logLevels, ", "))
+}
+
+func logFile() string {
+	return filepath.Join(stateDir(), "kilo.log")
+}
+
+// openLog opens the log file for appending.
+func (e *Editor) openLog() {
+	if e.loglevel == LogOff {
+		return
+	}
+	if err := os.MkdirAll(stateDir(), 0755); err != nil {
+		return
+	}
+	f, err := os.OpenFile(logFile(), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return
+	}
+	e.logfile = f
+	e.log(LogInfo, "start", "version", version, "term", os.Getenv("TERM"), "pid", os.Getpid())
+}
+
+// log writes a line of key=value pairs to the log file if the level is
+// enabled:
+//
+//	2006-01-02T15:04:05.000 level=debug msg=key code=1000 seq="\x1b[A"
+func (e *Editor) log(level LogLevel, msg string, kv ...any) {
+	if e.logfile == nil || level > e.loglevel {
+		return
+	}
+	var b strings.Builder
+	b.WriteString(time.Now().Format("2006-01-02T15:04:05.000"))
+	fmt.Fprintf(&b, " level=%s msg=%s", level, logValue(msg))
+	for i := 0; i+1 < len(kv); i += 2 {
+		fmt.Fprintf(&b, " %v=%s", kv[i], logValue(kv[i+1]))
+	}
+	b.WriteByte('\n')
+	e.logfile.Write([]byte(b.String()))
+}
+
+// logValue formats a value, quoting it when it isn't a single word.
+func logValue(v any) string {
+	var s string
+	switch v := v.(type) {
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	case time.Duration:
+		s = v.String()
+	default:
+		s = fmt.Sprint(v)
+	}
+	if s == "" || strings.ContainsAny(s, " \"=") || strings.IndexFunc(s, func(r rune) bool { return r < ' ' || r == 0x7f }) >= 0 {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// traceEscape logs the escape sequences read from the terminal.
+func (e *Editor) traceEscape(seq []byte, key int) {
+	e.log(LogDebug, "escape", "seq", seq, "key", key)
+}
@@ -0,0 +1,1096 @@
+package editor
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/icholy/kilo/internal/buffer"
+	"github.com/icholy/kilo/internal/fuzzy"
+	"golang.org/x/exp/slices"
+)
+
+const lspTimeout = 3 * time.Second
+
+type LSPServer struct {
+	LanguageID string
+	Extensions []string
+	Command    []string
+}
+
+var lspServers = []LSPServer{
+	{LanguageID: "go", Extensions: []string{".go"}, Command: []string{"gopls"}},
+	{LanguageID: "python", Extensions: []string{".py"}, Command: []string{"pylsp"}},
+	{LanguageID: "rust", Extensions: []string{".rs"}, Command: []string{"rust-analyzer"}},
+	{LanguageID: "c", Extensions: []string{".c", ".h"}, Command: []string{"clangd"}},
+	{LanguageID: "cpp", Extensions: []string{".cc", ".cpp", ".hpp"}, Command: []string{"clangd"}},
+	{LanguageID: "typescript", Extensions: []string{".ts", ".js"}, Command: []string{"typescript-language-server", "--stdio"}},
+}
+
+func lspServerFor(filename string) (LSPServer, bool) {
+	ext := filepath.Ext(filename)
+	for _, s := range lspServers {
+		if slices.Contains(s.Extensions, ext) {
+			return s, true
+		}
+	}
+	return LSPServer{}, false
+}
+
+type lspMessage struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+	Result  json.RawMessage  `json:"result,omitempty"`
+	Error   *lspError        `json:"error,omitempty"`
+}
+
+type lspError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *lspError) Error() string {
+	return fmt.Sprintf("%s (%d)", e.Message, e.Code)
+}
+
+type lspPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type lspRange struct {
+	Start lspPosition `json:"start"`
+	End   lspPosition `json:"end"`
+}
+
+type lspTextEdit struct {
+	Range   lspRange `json:"range"`
+	NewText string   `json:"newText"`
+}
+
+type lspDiagnostic struct {
+	Range    lspRange `json:"range"`
+	Severity int      `json:"severity"`
+	Source   string   `json:"source"`
+	Message  string   `json:"message"`
+}
+
+type lspCompletionItem struct {
+	Label      string       `json:"label"`
+	Detail     string       `json:"detail"`
+	SortText   string       `json:"sortText"`
+	InsertText string       `json:"insertText"`
+	TextEdit   *lspTextEdit `json:"textEdit"`
+}
+
+// LSPClient is a minimal language server client. Requests are synchronous:
+// the editor blocks until the response arrives or lspTimeout elapses.
+// Positions are byte offsets, converted from and to the position encoding
+// the server chose when it doesn't use utf-8.
+type LSPClient struct {
+	cmd      *exec.Cmd
+	w        io.WriteCloser
+	msgs     chan *lspMessage
+	seq      int
+	version  int
+	uri      string
+	langid   string
+	text     []byte
+	triggers string
+	// encoding is the position encoding of the server: utf-8, utf-16 or
+	// utf-32
+	encoding string
+	// content returns the current buffer contents
+	content func() []byte
+	// wake is called when a notification or request from the server
+	// is waiting to be handled by Poll
+	wake func()
+	// diagnostics are the ones last published for the document, and
+	// published is set when they change
+	diagnostics []lspDiagnostic
+	published   bool
+}
+
+func lspURI(filename string) string {
+	if abs, err := filepath.Abs(filename); err == nil {
+		filename = abs
+	}
+	u := url.URL{Scheme: "file", Path: filename}
+	return u.String()
+}
+
+func lspStart(server LSPServer, filename string, content func() []byte, wake func()) (*LSPClient, error) {
+	cmd := exec.Command(server.Command[0], server.Command[1:]...)
+	w, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	r, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	c := &LSPClient{
+		cmd:     cmd,
+		w:       w,
+		msgs:    make(chan *lspMessage, 16),
+		uri:     lspURI(filename),
+		langid:  server.LanguageID,
+		content: content,
+		wake:    wake,
+	}
+	go c.readLoop(bufio.NewReader(r))
+	root, _ := os.Getwd()
+	params := map[string]any{
+		"processId": os.Getpid(),
+		"rootUri":   lspURI(root),
+		"capabilities": map[string]any{
+			"general": map[string]any{
+				"positionEncodings": []string{"utf-8", "utf-16"},
+			},
+			"textDocument": map[string]any{
+				"completion": map[string]any{
+					"completionItem": map[string]any{"snippetSupport": false},
+				},
+				"documentSymbol": map[string]any{
+					"hierarchicalDocumentSymbolSupport": true,
+				},
+				"publishDiagnostics": map[string]any{},
+			},
+		},
+	}
+	var result struct {
+		Capabilities struct {
+			PositionEncoding   string `json:"positionEncoding"`
+			CompletionProvider *struct {
+				TriggerCharacters []string `json:"triggerCharacters"`
+			} `json:"completionProvider"`
+		} `json:"capabilities"`
+	}
+	if err := c.call("initialize", params, &result); err != nil {
+		c.Close()
+		return nil, err
+	}
+	if p := result.Capabilities.CompletionProvider; p != nil {
+		c.triggers = strings.Join(p.TriggerCharacters, "")
+	}
+	// servers which don't say use utf-16, the protocol's default
+	c.encoding = result.Capabilities.PositionEncoding
+	if c.encoding == "" {
+		c.encoding = "utf-16"
+	}
+	if err := c.notify("initialized", struct{}{}); err != nil {
+		c.Close()
+		return nil, err
+	}
+	c.text = content()
+	err = c.notify("textDocument/didOpen", map[string]any{
+		"textDocument": map[string]any{
+			"uri":        c.uri,
+			"languageId": c.langid,
+			"version":    c.version,
+			"text":       string(c.text),
+		},
+	})
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *LSPClient) readLoop(r *bufio.Reader) {
+	defer close(c.msgs)
+	for {
+		var length int
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimSpace(line)
+			if line == "" {
+				break
+			}
+			if name, value, ok := strings.Cut(line, ":"); ok && strings.EqualFold(name, "Content-Length") {
+				length, _ = strconv.Atoi(strings.TrimSpace(value))
+			}
+		}
+		body := make([]byte, length)
+		if _, err := io.ReadFull(r, body); err != nil {
+			return
+		}
+		var msg lspMessage
+		if err := json.Unmarshal(body, &msg); err != nil {
+			continue
+		}
+		c.msgs <- &msg
+		if msg.ID == nil || msg.Method != "" {
+			c.wake()
+		}
+	}
+}
+
+func (c *LSPClient) write(msg any) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(c.w, "Content-Length: %d\r\n\r\n%s", len(data), data)
+	return err
+}
+
+func (c *LSPClient) notify(method string, params any) error {
+	return c.write(map[string]any{
+		"jsonrpc": "2.0",
+		"method":  method,
+		"params":  params,
+	})
+}
+
+func (c *LSPClient) call(method string, params, result any) error {
+	c.seq++
+	id := c.seq
+	err := c.write(map[string]any{
+		"jsonrpc": "2.0",
+		"id":      id,
+		"method":  method,
+		"params":  params,
+	})
+	if err != nil {
+		return err
+	}
+	timeout := time.After(lspTimeout)
+	for {
+		select {
+		case msg, ok := <-c.msgs:
+			if !ok {
+				return errors.New("language server exited")
+			}
+			if msg.ID == nil {
+				c.handleNotification(msg)
+				continue
+			}
+			if msg.Method != "" {
+				c.handleRequest(msg)
+				continue
+			}
+			if string(*msg.ID) != strconv.Itoa(id) {
+				continue
+			}
+			if msg.Error != nil {
+				return msg.Error
+			}
+			if result == nil || len(msg.Result) == 0 {
+				return nil
+			}
+			return json.Unmarshal(msg.Result, result)
+		case <-timeout:
+			return fmt.Errorf("%s: timed out", method)
+		}
+	}
+}
+
+func (c *LSPClient) handleNotification(msg *lspMessage) {
+	if msg.Method != "textDocument/publishDiagnostics" {
+		return
+	}
+	var params struct {
+		URI         string          `json:"uri"`
+		Diagnostics []lspDiagnostic `json:"diagnostics"`
+	}
+	if err := json.Unmarshal(msg.Params, &params); err != nil || params.URI != c.uri {
+		return
+	}
+	c.diagnostics = params.Diagnostics
+	c.published = true
+}
+
+// Poll handles the notifications and requests the server sent while
+// no request was waiting for a response. It reports whether the
+// diagnostics of the document changed.
+func (c *LSPClient) Poll() bool {
+	for {
+		select {
+		case msg, ok := <-c.msgs:
+			if !ok {
+				return false
+			}
+			switch {
+			case msg.ID == nil:
+				c.handleNotification(msg)
+			case msg.Method != "":
+				c.handleRequest(msg)
+			}
+			// the responses left are to requests which timed out
+		default:
+			published := c.published
+			c.published = false
+			return published
+		}
+	}
+}
+
+// handleRequest replies to requests initiated by the server. None of them
+// are supported, but servers may block until they receive an answer.
+func (c *LSPClient) handleRequest(msg *lspMessage) {
+	var result any
+	if msg.Method == "workspace/configuration" {
+		var params struct {
+			Items []any `json:"items"`
+		}
+		json.Unmarshal(msg.Params, &params)
+		result = make([]any, len(params.Items))
+	}
+	c.write(map[string]any{
+		"jsonrpc": "2.0",
+		"id":      msg.ID,
+		"result":  result,
+	})
+}
+
+// Sync sends the buffer contents to the server if they changed since
+// the last time they were sent.
+func (c *LSPClient) Sync() error {
+	text := c.content()
+	if bytes.Equal(text, c.text) {
+		return nil
+	}
+	c.text = text
+	c.version++
+	return c.notify("textDocument/didChange", map[string]any{
+		"textDocument": map[string]any{
+			"uri":     c.uri,
+			"version": c.version,
+		},
+		"contentChanges": []any{
+			map[string]any{"text": string(text)},
+		},
+	})
+}
+
+// position returns the parameters of a request about a position of the
+// document, which is synced first so that it's where the server expects.
+func (c *LSPClient) position(cx, cy int) (map[string]any, error) {
+	if err := c.Sync(); err != nil {
+		return nil, err
+	}
+	return map[string]any{
+		"textDocument": map[string]any{"uri": c.uri},
+		"position":     c.fromBytes(c.text, lspPosition{Line: cy, Character: cx}),
+	}, nil
+}
+
+// lspLine returns the line of text, without its newline.
+func lspLine(text []byte, line int) []byte {
+	for ; line > 0; line-- {
+		i := bytes.IndexByte(text, '\n')
+		if i < 0 {
+			return nil
+		}
+		text = text[i+1:]
+	}
+	if i := bytes.IndexByte(text, '\n'); i >= 0 {
+		text = text[:i]
+	}
+	return text
+}
+
+// fromBytes converts a position in text from bytes to the position
+// encoding of the server.
+func (c *LSPClient) fromBytes(text []byte, p lspPosition) lspPosition {
+	if c.encoding == "utf-8" {
+		return p
+	}
+	line := lspLine(text, p.Line)
+	chars := 0
+	for i := 0; i < p.Character && i < len(line); {
+		r, size := utf8.DecodeRune(line[i:])
+		chars += c.runeLen(r)
+		i += size
+	}
+	p.Character = chars
+	return p
+}
+
+// toBytes converts the positions in text from the position encoding of
+// the server to bytes, in place.
+func (c *LSPClient) toBytes(text []byte, positions ...*lspPosition) {
+	if c.encoding == "utf-8" {
+		return
+	}
+	for _, p := range positions {
+		line := lspLine(text, p.Line)
+		i := 0
+		for chars := 0; chars < p.Character && i < len(line); {
+			r, size := utf8.DecodeRune(line[i:])
+			chars += c.runeLen(r)
+			i += size
+		}
+		p.Character = i
+	}
+}
+
+// runeLen returns the number of characters of the position encoding a
+// rune takes.
+func (c *LSPClient) runeLen(r rune) int {
+	if c.encoding == "utf-16" && r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
+
+// editsToBytes converts the positions of the edits to text to bytes.
+func (c *LSPClient) editsToBytes(text []byte, edits []lspTextEdit) {
+	for i := range edits {
+		c.toBytes(text, &edits[i].Range.Start, &edits[i].Range.End)
+	}
+}
+
+func (c *LSPClient) Completion(cx, cy int) ([]lspCompletionItem, error) {
+	params, err := c.position(cx, cy)
+	if err != nil {
+		return nil, err
+	}
+	var raw json.RawMessage
+	if err := c.call("textDocument/completion", params, &raw); err != nil {
+		return nil, err
+	}
+	var items []lspCompletionItem
+	if bytes.HasPrefix(bytes.TrimSpace(raw), []byte("[")) {
+		if err := json.Unmarshal(raw, &items); err != nil {
+			return nil, err
+		}
+	} else if len(raw) > 0 && string(raw) != "null" {
+		var list struct {
+			Items []lspCompletionItem `json:"items"`
+		}
+		if err := json.Unmarshal(raw, &list); err != nil {
+			return nil, err
+		}
+		items = list.Items
+	}
+	for _, item := range items {
+		if te := item.TextEdit; te != nil {
+			c.toBytes(c.text, &te.Range.Start, &te.Range.End)
+		}
+	}
+	slices.SortStableFunc(items, func(a, b lspCompletionItem) bool {
+		return a.SortText < b.SortText
+	})
+	return items, nil
+}
+
+func (c *LSPClient) DidSave() error {
+	return c.notify("textDocument/didSave", map[string]any{
+		"textDocument": map[string]any{"uri": c.uri},
+	})
+}
+
+// DidRewrite tells the server that a file other than the document was
+// rewritten on disk from old to text. Servers only track the documents
+// which are open, so it's opened with the old contents, changed, saved
+// and closed again.
+func (c *LSPClient) DidRewrite(filename string, old, text []byte) error {
+	langid := c.langid
+	if server, ok := lspServerFor(filename); ok {
+		langid = server.LanguageID
+	}
+	doc := map[string]any{"uri": lspURI(filename)}
+	notes := []struct {
+		method string
+		params map[string]any
+	}{
+		{"textDocument/didOpen", map[string]any{
+			"textDocument": map[string]any{"uri": doc["uri"], "languageId": langid, "version": 0, "text": string(old)},
+		}},
+		{"textDocument/didChange", map[string]any{
+			"textDocument":   map[string]any{"uri": doc["uri"], "version": 1},
+			"contentChanges": []any{map[string]any{"text": string(text)}},
+		}},
+		{"textDocument/didSave", map[string]any{"textDocument": doc}},
+		{"textDocument/didClose", map[string]any{"textDocument": doc}},
+	}
+	for _, n := range notes {
+		if err := c.notify(n.method, n.params); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *LSPClient) Close() {
+	if c.cmd.ProcessState == nil {
+		c.call("shutdown", nil, nil)
+		c.notify("exit", nil)
+	}
+	c.w.Close()
+	c.cmd.Process.Kill()
+	c.cmd.Wait()
+}
+
+func (e *Editor) startLSP() {
+	server, ok := lspServerFor(e.filename)
+	if !ok || isRemote(e.filename) {
+		return
+	}
+	if _, err := exec.LookPath(server.Command[0]); err != nil {
+		return
+	}
+	c, err := lspStart(server, e.filename, e.rowsToBytes, e.wake)
+	if err != nil {
+		e.fail("lsp: %v", err)
+		return
+	}
+	e.lsp = c
+}
+
+// completion requests completions at the cursor and lets the user
+// pick one from a popup menu. Without a language server, the word before
+// the cursor is completed with complete-word.
+func (e *Editor) completion() {
+	if e.lsp == nil {
+		e.completeWord()
+		return
+	}
+	items, err := e.lsp.Completion(e.cx, e.cy)
+	if err != nil {
+		e.fail("lsp: %v", err)
+		return
+	}
+	if len(items) == 0 {
+		e.setStatus("no completions")
+		return
+	}
+	// the partially typed identifier, none on the line past the end
+	start := e.cx
+	var partial []byte
+	if e.cy < e.buf.NumRows() {
+		chars := e.buf.Rows[e.cy].Chars
+		for start > 0 && !buffer.IsDelim(chars[start-1]) {
+			start--
+		}
+		partial = chars[start:e.cx]
+	}
+	items = rankCompletions(string(partial), items)
+	labels := make([]string, len(items))
+	for i, item := range items {
+		labels[i] = item.Label
+		if item.Detail != "" {
+			labels[i] += "  " + item.Detail
+		}
+	}
+	idx := e.menu(labels)
+	if idx < 0 {
+		return
+	}
+	item := items[idx]
+	if te := item.TextEdit; te != nil {
+		r := te.Range
+		e.cy, e.cx = e.replaceRange(r.Start.Line, r.Start.Character, r.End.Line, r.End.Character, []byte(te.NewText))
+		return
+	}
+	text := item.InsertText
+	if text == "" {
+		text = item.Label
+	}
+	// replace the partially typed identifier
+	e.cy, e.cx = e.replaceRange(e.cy, start, e.cy, e.cx, []byte(text))
+}
+
+// rankCompletions orders the items by how well their labels fuzzy match
+// the partially typed identifier. The ones which don't match follow in
+// the server's order.
+func rankCompletions(partial string, items []lspCompletionItem) []lspCompletionItem {
+	labels := make([]string, len(items))
+	for i, item := range items {
+		labels[i] = item.Label
+	}
+	ranked := make([]lspCompletionItem, 0, len(items))
+	matched := make([]bool, len(items))
+	for _, i := range fuzzy.Rank(partial, labels) {
+		ranked = append(ranked, items[i])
+		matched[i] = true
+	}
+	for i, item := range items {
+		if !matched[i] {
+			ranked = append(ranked, item)
+		}
+	}
+	return ranked
+}
+
+type lspLocation struct {
+	URI   string   `json:"uri"`
+	Range lspRange `json:"range"`
+	// LocationLink fields
+	TargetURI            string    `json:"targetUri"`
+	TargetSelectionRange *lspRange `json:"targetSelectionRange"`
+}
+
+// start returns the document and the position a location starts at.
+func (l lspLocation) start() (string, lspPosition) {
+	uri, r := l.URI, l.Range
+	if l.TargetURI != "" {
+		uri = l.TargetURI
+		if l.TargetSelectionRange != nil {
+			r = *l.TargetSelectionRange
+		}
+	}
+	return uri, r.Start
+}
+
+func lspFilename(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return uri
+	}
+	name := u.Path
+	if wd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(wd, name); err == nil && !strings.HasPrefix(rel, "..") {
+			return rel
+		}
+	}
+	return name
+}
+
+// locations sends a request which returns Location | Location[] | LocationLink[].
+func (c *LSPClient) locations(method string, params any) ([]Location, error) {
+	var raw json.RawMessage
+	if err := c.call(method, params, &raw); err != nil {
+		return nil, err
+	}
+	var locs []lspLocation
+	raw = bytes.TrimSpace(raw)
+	if bytes.HasPrefix(raw, []byte("[")) {
+		if err := json.Unmarshal(raw, &locs); err != nil {
+			return nil, err
+		}
+	} else if len(raw) > 0 && string(raw) != "null" {
+		var loc lspLocation
+		if err := json.Unmarshal(raw, &loc); err != nil {
+			return nil, err
+		}
+		locs = append(locs, loc)
+	}
+	result := make([]Location, len(locs))
+	texts := map[string][]byte{}
+	for i, l := range locs {
+		uri, pos := l.start()
+		text, ok := texts[uri]
+		if !ok {
+			text = c.document(uri)
+			texts[uri] = text
+		}
+		c.toBytes(text, &pos)
+		result[i] = Location{filename: lspFilename(uri), cx: pos.Character, cy: pos.Line}
+	}
+	return result, nil
+}
+
+// document returns the text of the document with the URI: the one last
+// sent to the server for the open document, and the file for the others.
+func (c *LSPClient) document(uri string) []byte {
+	if uri == c.uri {
+		return c.text
+	}
+	data, _ := os.ReadFile(lspFilename(uri))
+	return data
+}
+
+func (c *LSPClient) Definition(cx, cy int) ([]Location, error) {
+	params, err := c.position(cx, cy)
+	if err != nil {
+		return nil, err
+	}
+	return c.locations("textDocument/definition", params)
+}
+
+func (c *LSPClient) References(cx, cy int) ([]Location, error) {
+	params, err := c.position(cx, cy)
+	if err != nil {
+		return nil, err
+	}
+	params["context"] = map[string]any{"includeDeclaration": true}
+	return c.locations("textDocument/references", params)
+}
+
+// definition jumps to the definition of the symbol under the cursor.
+func (e *Editor) definition() {
+	if e.lsp == nil {
+		e.fail("no language server")
+		return
+	}
+	locs, err := e.lsp.Definition(e.cx, e.cy)
+	if err != nil {
+		e.fail("lsp: %v", err)
+		return
+	}
+	if len(locs) == 0 {
+		e.fail("no definition found")
+		return
+	}
+	e.jump(locs[0])
+}
+
+// references lists the references to the symbol under the cursor
+// and jumps to the chosen one.
+func (e *Editor) references() {
+	if e.lsp == nil {
+		e.fail("no language server")
+		return
+	}
+	locs, err := e.lsp.References(e.cx, e.cy)
+	if err != nil {
+		e.fail("lsp: %v", err)
+		return
+	}
+	if len(locs) == 0 {
+		e.fail("no references found")
+		return
+	}
+	items := make([]string, len(locs))
+	for i, loc := range locs {
+		items[i] = fmt.Sprintf("%s:%d: %s", loc.filename, loc.cy+1, e.locationLine(loc))
+	}
+	if idx := e.menu(items); idx >= 0 {
+		e.jump(locs[idx])
+	}
+}
+
+// locationLine returns the trimmed text of the line loc points to.
+func (e *Editor) locationLine(loc Location) string {
+	if loc.filename == e.filename {
+		if loc.cy < e.buf.NumRows() {
+			return strings.TrimSpace(string(e.buf.Rows[loc.cy].Chars))
+		}
+		return ""
+	}
+	data, err := os.ReadFile(loc.filename)
+	if err != nil {
+		return ""
+	}
+	lines := strings.Split(string(data), "\n")
+	if loc.cy < len(lines) {
+		return strings.TrimSpace(lines[loc.cy])
+	}
+	return ""
+}
+
+type lspWorkspaceEdit struct {
+	Changes         map[string][]lspTextEdit `json:"changes"`
+	DocumentChanges []struct {
+		TextDocument struct {
+			URI string `json:"uri"`
+		} `json:"textDocument"`
+		Edits []lspTextEdit `json:"edits"`
+	} `json:"documentChanges"`
+}
+
+// Files groups the edits by the file they apply to.
+func (we lspWorkspaceEdit) Files() map[string][]lspTextEdit {
+	files := map[string][]lspTextEdit{}
+	for uri, edits := range we.Changes {
+		name := lspFilename(uri)
+		files[name] = append(files[name], edits...)
+	}
+	for _, dc := range we.DocumentChanges {
+		name := lspFilename(dc.TextDocument.URI)
+		files[name] = append(files[name], dc.Edits...)
+	}
+	return files
+}
+
+func (c *LSPClient) Rename(cx, cy int, name string) (lspWorkspaceEdit, error) {
+	var edit lspWorkspaceEdit
+	params, err := c.position(cx, cy)
+	if err != nil {
+		return edit, err
+	}
+	params["newName"] = name
+	err = c.call("textDocument/rename", params, &edit)
+	return edit, err
+}
+
+// sortEditsReverse orders edits so that applying them one after another
+// doesn't invalidate the positions of the remaining ones.
+func sortEditsReverse(edits []lspTextEdit) {
+	slices.SortStableFunc(edits, func(a, b lspTextEdit) bool {
+		if a.Range.Start.Line != b.Range.Start.Line {
+			return a.Range.Start.Line > b.Range.Start.Line
+		}
+		return a.Range.Start.Character > b.Range.Start.Character
+	})
+}
+
+// lspApplyEdits applies the edits to text and returns the result.
+func lspApplyEdits(text []byte, edits []lspTextEdit) []byte {
+	// byte offset of the start of each line
+	lines := []int{0}
+	for i, b := range text {
+		if b == '\n' {
+			lines = append(lines, i+1)
+		}
+	}
+	offset := func(p lspPosition) int {
+		if p.Line >= len(lines) {
+			return len(text)
+		}
+		return clamp(lines[p.Line]+p.Character, 0, len(text))
+	}
+	sortEditsReverse(edits)
+	for _, e := range edits {
+		start, end := offset(e.Range.Start), offset(e.Range.End)
+		text = append(text[:start:start], append([]byte(e.NewText), text[end:]...)...)
+	}
+	return text
+}
+
+// applyEdits applies the edits to the current buffer.
+func (e *Editor) applyEdits(edits []lspTextEdit) {
+	sortEditsReverse(edits)
+	for _, edit := range edits {
+		r := edit.Range
+		e.replaceRange(r.Start.Line, r.Start.Character, r.End.Line, r.End.Character, []byte(edit.NewText))
+	}
+	e.moveTo(e.cx, e.cy)
+}
+
+// rename renames the symbol under the cursor. Edits to the current
+// buffer are applied in place, other files are rewritten on disk, and
+// the language server is told about both.
+func (e *Editor) rename() {
+	if e.lsp == nil {
+		e.fail("no language server")
+		return
+	}
+	name, ok := e.prompt("Rename to:", nil)
+	if !ok {
+		return
+	}
+	we, err := e.lsp.Rename(e.cx, e.cy, name)
+	if err != nil {
+		e.fail("lsp: %v", err)
+		return
+	}
+	// the other files are rewritten into temporary files first, which
+	// replace them once they're all written, so that a failure doesn't
+	// leave the rename half done
+	type rewrite struct {
+		tmp       string
+		old, text []byte
+	}
+	var current []lspTextEdit
+	rewrites := map[string]*rewrite{}
+	defer func() {
+		for _, rw := range rewrites {
+			if rw.tmp != "" {
+				os.Remove(rw.tmp)
+			}
+		}
+	}()
+	var nedits, nfiles int
+	for filename, edits := range we.Files() {
+		nedits += len(edits)
+		nfiles++
+		if e.filename != "" && sameFile(filename, e.filename) {
+			e.lsp.editsToBytes(e.lsp.text, edits)
+			current = edits
+			continue
+		}
+		fi, err := os.Stat(filename)
+		if err != nil {
+			e.fail("rename: %v", err)
+			return
+		}
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			e.fail("rename: %v", err)
+			return
+		}
+		e.lsp.editsToBytes(data, edits)
+		rw := &rewrite{old: data, text: lspApplyEdits(data, edits)}
+		rewrites[filename] = rw
+		rw.tmp, err = writeTemp(filename, rw.text, fi.Mode().Perm())
+		if err != nil {
+			e.fail("rename: %v", err)
+			return
+		}
+	}
+	for filename, rw := range rewrites {
+		if err := os.Rename(rw.tmp, filename); err != nil {
+			e.fail("rename: %v", err)
+			return
+		}
+		rw.tmp = ""
+	}
+	if current != nil {
+		e.applyEdits(current)
+	}
+	// the server is told about the rewritten files and the changes to
+	// the buffer, so that its view of them doesn't go stale
+	for filename, rw := range rewrites {
+		if err := e.lsp.DidRewrite(filename, rw.old, rw.text); err != nil {
+			e.fail("lsp: %v", err)
+			return
+		}
+	}
+	if err := e.lsp.Sync(); err != nil {
+		e.fail("lsp: %v", err)
+		return
+	}
+	e.setStatus("renamed to %s: %d edits in %d files", name, nedits, nfiles)
+}
+
+// lspMarkup holds the contents of a hover response, which can be
+// MarkupContent, a MarkedString, or a list of MarkedStrings.
+type lspMarkup struct {
+	Value string
+}
+
+func (m *lspMarkup) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	switch {
+	case bytes.HasPrefix(data, []byte(`"`)):
+		return json.Unmarshal(data, &m.Value)
+	case bytes.HasPrefix(data, []byte("[")):
+		var parts []lspMarkup
+		if err := json.Unmarshal(data, &parts); err != nil {
+			return err
+		}
+		values := make([]string, len(parts))
+		for i, p := range parts {
+			values[i] = p.Value
+		}
+		m.Value = strings.Join(values, "\n\n")
+		return nil
+	default:
+		var v struct {
+			Language string `json:"language"`
+			Value    string `json:"value"`
+		}
+		if err := json.Unmarshal(data, &v); err != nil {
+			return err
+		}
+		m.Value = v.Value
+		return nil
+	}
+}
+
+func (c *LSPClient) Hover(cx, cy int) (string, error) {
+	params, err := c.position(cx, cy)
+	if err != nil {
+		return "", err
+	}
+	var result *struct {
+		Contents lspMarkup `json:"contents"`
+	}
+	if err := c.call("textDocument/hover", params, &result); err != nil {
+		return "", err
+	}
+	if result == nil {
+		return "", nil
+	}
+	return result.Contents.Value, nil
+}
+
+// hover shows the documentation for the symbol under the cursor.
+func (e *Editor) hover() {
+	if e.lsp == nil {
+		e.fail("no language server")
+		return
+	}
+	text, err := e.lsp.Hover(e.cx, e.cy)
+	if err != nil {
+		e.fail("lsp: %v", err)
+		return
+	}
+	var lines []string
+	for _, line := range strings.Split(strings.TrimSpace(text), "\n") {
+		// drop the markdown code fences
+		if strings.HasPrefix(line, "```") {
+			continue
+		}
+		lines = append(lines, strings.ReplaceAll(line, "\t", "    "))
+	}
+	if len(lines) == 0 || text == "" {
+		e.setStatus("no documentation")
+		return
+	}
+	e.showPopup(lines)
+}
+
+// lspSymbol is a DocumentSymbol, or a SymbolInformation which has a
+// location instead of a selection range and children.
+type lspSymbol struct {
+	Name           string      `json:"name"`
+	Kind           int         `json:"kind"`
+	SelectionRange *lspRange   `json:"selectionRange"`
+	Children       []lspSymbol `json:"children"`
+	// SymbolInformation fields
+	Location *struct {
+		Range lspRange `json:"range"`
+	} `json:"location"`
+}
+
+// start returns the position of the symbol's name.
+func (s lspSymbol) start() lspPosition {
+	switch {
+	case s.SelectionRange != nil:
+		return s.SelectionRange.Start
+	case s.Location != nil:
+		return s.Location.Range.Start
+	}
+	return lspPosition{}
+}
+
+// lspFunctionKinds are the symbol kinds of methods, constructors and
+// functions.
+var lspFunctionKinds = []int{6, 9, 12}
+
+// DocumentSymbols returns the symbols of the document in the order they
+// appear in it, each followed by the ones nested in it.
+func (c *LSPClient) DocumentSymbols() ([]outlineSymbol, error) {
+	if err := c.Sync(); err != nil {
+		return nil, err
+	}
+	var result []lspSymbol
+	params := map[string]any{"textDocument": map[string]any{"uri": c.uri}}
+	if err := c.call("textDocument/documentSymbol", params, &result); err != nil {
+		return nil, err
+	}
+	var symbols []outlineSymbol
+	var add func(syms []lspSymbol, depth int)
+	add = func(syms []lspSymbol, depth int) {
+		slices.SortStableFunc(syms, func(a, b lspSymbol) bool {
+			return a.start().Line < b.start().Line
+		})
+		for _, s := range syms {
+			pos := s.start()
+			c.toBytes(c.text, &pos)
+			sym := outlineSymbol{name: s.Name, depth: depth, cx: pos.Character, cy: pos.Line}
+			if slices.Contains(lspFunctionKinds, s.Kind) {
+				sym.name += "()"
+			}
+			symbols = append(symbols, sym)
+			add(s.Children, depth+1)
+		}
+	}
+	add(result, 0)
+	return symbols, nil
+}
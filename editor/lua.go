@@ -0,0 +1,317 @@
+package editor
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// RunLua runs a Lua script, which uses the kilo module to edit the
+// buffer and add commands, key bindings, and hooks. Lines and columns
+// are 1 based, columns count bytes:
+//
+//	kilo.define("header", function(args)
+//	  kilo.set_cursor(1, 1)
+//	  kilo.insert("// " .. args .. "\n")
+//	end, "insert a header comment")
+//	kilo.bind("ctrl-t", "header Copyright")
+//	kilo.on("save", "*.go", function(filename)
+//	  if kilo.line_count() > 0 and kilo.get_line(1) == "" then
+//	    kilo.status(filename .. " starts with a blank line")
+//	  end
+//	end)
+//
+// The functions are:
+//
+//	command(line)                      runs an editor command, raising its error if it fails
+//	define(name, fn [, help])          adds a command which calls fn(args)
+//	bind(key, command or fn)           runs a command or calls fn() when the key is pressed
+//	on(event, pattern, command or fn)  runs a command or calls fn(filename) on open or save
+//	status(message)                    shows a message
+//	filename()                         returns the name of the file, "" if it has none
+//	filetype()                         returns the filetype, "" if it's unknown
+//	line_count()                       returns the number of lines
+//	get_line(n)                        returns the text of a line
+//	set_line(n, text)                  replaces the text of a line
+//	get_text()                         returns the text of the buffer
+//	set_text(text)                     replaces the text of the buffer
+//	insert(text)                       inserts text at the cursor, which moves after it
+//	replace(line1, col1, line2, col2, text)
+//	cursor()                           returns the line and column of the cursor
+//	set_cursor(line, col)
+//	selection()                        returns the start line and column and the end
+//	                                   line and column of the selection, or nil
+//
+// Like scripts of commands, the script stops at the first command which
+// fails, unless it's called with pcall.
+func (e *Editor) RunLua(name string, r io.Reader) error {
+	L := e.luaState()
+	fn, err := L.Load(r, name)
+	if err != nil {
+		return err
+	}
+	return e.luaCall(fn)
+}
+
+// runLuaFile implements the lua command, which runs a Lua script.
+func (e *Editor) runLuaFile(name string) {
+	if name == "" {
+		e.fail("lua: expected a file name")
+		return
+	}
+	L := e.luaState()
+	fn, err := L.LoadFile(name)
+	if err != nil {
+		e.fail("lua: %v", err)
+		return
+	}
+	if err := e.luaCall(fn); err != nil {
+		e.fail("lua: %v", err)
+	}
+}
+
+// luaState returns the Lua interpreter, starting it the first time.
+func (e *Editor) luaState() *lua.LState {
+	if e.lua != nil {
+		return e.lua
+	}
+	L := lua.NewState()
+	kilo := L.SetFuncs(L.NewTable(), map[string]lua.LGFunction{
+		"command":    e.luaCommand,
+		"define":     e.luaDefine,
+		"bind":       e.luaBind,
+		"on":         e.luaOn,
+		"status":     e.luaStatus,
+		"filename":   e.luaFilename,
+		"filetype":   e.luaFiletype,
+		"line_count": e.luaLineCount,
+		"get_line":   e.luaGetLine,
+		"set_line":   e.luaSetLine,
+		"get_text":   e.luaGetText,
+		"set_text":   e.luaSetText,
+		"insert":     e.luaInsert,
+		"replace":    e.luaReplace,
+		"cursor":     e.luaCursor,
+		"set_cursor": e.luaSetCursor,
+		"selection":  e.luaSelection,
+	})
+	L.SetGlobal("kilo", kilo)
+	e.lua = L
+	return L
+}
+
+// luaCall calls a Lua function, and returns the error it raised.
+func (e *Editor) luaCall(fn *lua.LFunction, args ...lua.LValue) error {
+	if e.scriptdepth >= maxScriptDepth {
+		return errors.New("commands nested too deeply")
+	}
+	e.scriptdepth++
+	defer func() { e.scriptdepth-- }()
+	err := e.lua.CallByParam(lua.P{Fn: fn, Protect: true}, args...)
+	var apierr *lua.ApiError
+	if errors.As(err, &apierr) {
+		// the message without the stack trace
+		return errors.New(apierr.Object.String())
+	}
+	return err
+}
+
+// luaFunc returns a function which calls a Lua function with the
+// arguments, and fails like a command if it raises an error.
+func (e *Editor) luaFunc(fn *lua.LFunction, args ...func() lua.LValue) func() {
+	return func() {
+		values := make([]lua.LValue, len(args))
+		for i, arg := range args {
+			values[i] = arg()
+		}
+		if err := e.luaCall(fn, values...); err != nil {
+			e.fail("%v", err)
+		}
+	}
+}
+
+// luaBinding returns the binding of a command line or a function which
+// is the argument n.
+func (e *Editor) luaBinding(L *lua.LState, n int, args ...func() lua.LValue) binding {
+	switch v := L.CheckAny(n).(type) {
+	case lua.LString:
+		return binding{command: strings.TrimSpace(string(v))}
+	case *lua.LFunction:
+		return binding{fn: e.luaFunc(v, args...)}
+	}
+	L.ArgError(n, "command or function expected")
+	return binding{}
+}
+
+// luaPosition returns the 0 based position of the 1 based line and
+// column which are the arguments n and n+1, raising an error unless it's
+// in the buffer.
+func (e *Editor) luaPosition(L *lua.LState, n int) pluginPosition {
+	p := pluginPosition{Line: L.CheckInt(n) - 1, Col: L.CheckInt(n+1) - 1}
+	if e.checkPosition(p) != nil {
+		L.RaiseError("position %d:%d is outside the buffer", p.Line+1, p.Col+1)
+	}
+	return p
+}
+
+// luaLine returns the 0 based index of the 1 based line number which is
+// the argument n, raising an error unless it's one of the lines.
+func (e *Editor) luaLine(L *lua.LState, n int) int {
+	y := L.CheckInt(n) - 1
+	if y < 0 || y >= e.buf.NumRows() {
+		L.ArgError(n, fmt.Sprintf("line %d out of range [1, %d]", y+1, e.buf.NumRows()))
+	}
+	return y
+}
+
+func (e *Editor) luaCommand(L *lua.LState) int {
+	line := L.CheckString(1)
+	e.failure = nil
+	e.runCommand(line)
+	if err := e.failure; err != nil {
+		e.failure = nil
+		L.RaiseError("%v", err)
+	}
+	return 0
+}
+
+func (e *Editor) luaDefine(L *lua.LState) int {
+	name := L.CheckString(1)
+	fn := L.CheckFunction(2)
+	help := L.OptString(3, "user command")
+	if name == "" || strings.ContainsAny(name, " \t") {
+		L.ArgError(1, fmt.Sprintf("invalid command name: %q", name))
+	}
+	e.addCommand(Command{
+		name: name,
+		help: help,
+		fn: func(e *Editor, args string) {
+			if err := e.luaCall(fn, lua.LString(args)); err != nil {
+				e.fail("%s: %v", name, err)
+			}
+		},
+	})
+	return 0
+}
+
+func (e *Editor) luaBind(L *lua.LState) int {
+	name := L.CheckString(1)
+	k, ok := parseKey(name)
+	if !ok {
+		L.ArgError(1, fmt.Sprintf("unknown key: %s, expected e.g. ctrl-t or alt-x or f6", name))
+	}
+	b := e.luaBinding(L, 2)
+	if e.keymap == nil {
+		e.keymap = map[int]binding{}
+	}
+	e.keymap[k] = b
+	return 0
+}
+
+func (e *Editor) luaOn(L *lua.LState) int {
+	event := L.CheckString(1)
+	if event != "open" && event != "save" {
+		L.ArgError(1, "expected open or save")
+	}
+	pattern := L.CheckString(2)
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		L.ArgError(2, err.Error())
+	}
+	filename := func() lua.LValue { return lua.LString(e.filename) }
+	e.hooks = append(e.hooks, Hook{event: event, pattern: pattern, action: e.luaBinding(L, 3, filename)})
+	return 0
+}
+
+func (e *Editor) luaStatus(L *lua.LState) int {
+	e.setStatus("%s", L.CheckString(1))
+	return 0
+}
+
+func (e *Editor) luaFilename(L *lua.LState) int {
+	L.Push(lua.LString(e.filename))
+	return 1
+}
+
+func (e *Editor) luaFiletype(L *lua.LState) int {
+	var filetype string
+	if e.buf.Syntax != nil {
+		filetype = e.buf.Syntax.Filetype
+	}
+	L.Push(lua.LString(filetype))
+	return 1
+}
+
+func (e *Editor) luaLineCount(L *lua.LState) int {
+	L.Push(lua.LNumber(e.buf.NumRows()))
+	return 1
+}
+
+func (e *Editor) luaGetLine(L *lua.LState) int {
+	y := e.luaLine(L, 1)
+	L.Push(lua.LString(e.buf.Rows[y].Chars))
+	return 1
+}
+
+func (e *Editor) luaSetLine(L *lua.LState) int {
+	y := e.luaLine(L, 1)
+	text := L.CheckString(2)
+	e.replaceRange(y, 0, y, e.buf.Rows[y].Len(), []byte(text))
+	e.moveTo(e.cx, e.cy)
+	return 0
+}
+
+func (e *Editor) luaGetText(L *lua.LState) int {
+	L.Push(lua.LString(e.rowsToBytes()))
+	return 1
+}
+
+func (e *Editor) luaSetText(L *lua.LState) int {
+	text := L.CheckString(1)
+	e.replaceRange(0, 0, e.buf.NumRows(), 0, []byte(strings.TrimSuffix(text, "\n")))
+	e.moveTo(e.cx, e.cy)
+	return 0
+}
+
+func (e *Editor) luaInsert(L *lua.LState) int {
+	text := L.CheckString(1)
+	e.cy, e.cx = e.replaceRange(e.cy, e.cx, e.cy, e.cx, []byte(text))
+	return 0
+}
+
+func (e *Editor) luaReplace(L *lua.LState) int {
+	start, end := e.luaPosition(L, 1), e.luaPosition(L, 3)
+	text := L.CheckString(5)
+	if e.checkRange(start, end) != nil {
+		L.RaiseError("start %d:%d is after end %d:%d", start.Line+1, start.Col+1, end.Line+1, end.Col+1)
+	}
+	e.cy, e.cx = e.replaceRange(start.Line, start.Col, end.Line, end.Col, []byte(text))
+	return 0
+}
+
+func (e *Editor) luaCursor(L *lua.LState) int {
+	L.Push(lua.LNumber(e.cy + 1))
+	L.Push(lua.LNumber(e.cx + 1))
+	return 2
+}
+
+func (e *Editor) luaSetCursor(L *lua.LState) int {
+	p := e.luaPosition(L, 1)
+	e.moveTo(p.Col, p.Line)
+	return 0
+}
+
+func (e *Editor) luaSelection(L *lua.LState) int {
+	y0, x0, y1, x1, ok := e.selectionBounds()
+	if !ok {
+		L.Push(lua.LNil)
+		return 1
+	}
+	for _, v := range []int{y0, x0, y1, x1} {
+		L.Push(lua.LNumber(v + 1))
+	}
+	return 4
+}
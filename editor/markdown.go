@@ -0,0 +1,123 @@
+package editor
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// listItem matches the start of a line of markdown: the indentation
+// and block quote markers, then a bullet or a number, the blanks after
+// it, and a task list checkbox. The marker only counts when it's
+// followed by blanks.
+var listItem = regexp.MustCompile(`^([ \t]*(?:>[ \t]?)*)(?:([-*+])|([0-9]+)([.)]))?([ \t]+)?(\[[ xX]\][ \t]+)?`)
+
+// markdownItem is the start of a line of markdown split into its parts.
+type markdownItem struct {
+	quote    string // indentation and block quote markers
+	bullet   string // -, *, or +
+	number   int    // the number of an ordered list item, if bullet is empty
+	delim    string // . or ) after the number
+	space    string // blanks after the marker
+	checkbox string // [ ] or [x] and the blanks after it
+	end      int    // the length of the prefix
+}
+
+// parseItem splits the start of a line of markdown into its parts.
+func parseItem(chars []byte) (item markdownItem, ok bool) {
+	m := listItem.FindSubmatch(chars)
+	item.quote = string(m[1])
+	item.end = len(m[1])
+	if len(m[5]) == 0 || len(m[2]) == 0 && len(m[3]) == 0 {
+		return item, false
+	}
+	item.bullet, item.delim, item.space, item.checkbox = string(m[2]), string(m[4]), string(m[5]), string(m[6])
+	if len(m[3]) > 0 {
+		item.number, _ = strconv.Atoi(string(m[3]))
+	}
+	item.end = len(m[0])
+	return item, true
+}
+
+// marker returns the list marker of the item, e.g. "- " or "2. ".
+func (item markdownItem) marker() string {
+	if item.bullet != "" {
+		return item.bullet + item.space
+	}
+	return strconv.Itoa(item.number) + item.delim + item.space
+}
+
+// isMarkdown reports whether the buffer is markdown.
+func (e *Editor) isMarkdown() bool {
+	return e.buf.Syntax != nil && e.buf.Syntax.Filetype == "markdown"
+}
+
+// markdownNewline breaks the line like Enter does in a list or block
+// quote, and starts the new line with the next list marker and the
+// same block quote markers. Enter on an empty list item ends the list
+// instead. It reports whether the line was a list item or block quote.
+func (e *Editor) markdownNewline() bool {
+	if !e.isMarkdown() || e.cy >= e.buf.NumRows() {
+		return false
+	}
+	chars := e.buf.Rows[e.cy].Chars
+	item, ok := parseItem(chars)
+	if !ok && !strings.Contains(item.quote, ">") || e.cx < item.end {
+		return false
+	}
+	if item.end == len(chars) {
+		// remove the markers of the empty item or quote line
+		e.cy, e.cx = e.replaceRange(e.cy, 0, e.cy, len(chars), nil)
+		return true
+	}
+	leader := item.quote
+	if ok {
+		if item.bullet == "" {
+			item.number++
+		}
+		leader += item.marker()
+		if item.checkbox != "" {
+			leader += "[ ] "
+		}
+	}
+	e.insertNewline()
+	e.cy, e.cx = e.replaceRange(e.cy, 0, e.cy, 0, []byte(leader))
+	return true
+}
+
+// toggleCheckbox checks or unchecks the task list checkboxes on the
+// selected lines. List items without a checkbox get one, and lines which
+// aren't list items become items with one.
+func (e *Editor) toggleCheckbox() {
+	start, end := e.selectedRows()
+	end = clamp(end, 0, e.buf.NumRows()-1)
+	for y := start; y <= end; y++ {
+		chars := e.buf.Rows[y].Chars
+		if len(chars) == 0 {
+			continue
+		}
+		item, ok := parseItem(chars)
+		switch {
+		case item.checkbox != "":
+			x := item.end - len(item.checkbox) + 1
+			check := "x"
+			if chars[x] != ' ' {
+				check = " "
+			}
+			e.replaceRange(y, x, y, x+1, []byte(check))
+		case ok:
+			e.insertAt(y, item.end, "[ ] ")
+		default:
+			e.insertAt(y, item.end, "- [ ] ")
+		}
+	}
+}
+
+// insertAt inserts text into row y at x, moving the cursor along if it
+// was after x.
+func (e *Editor) insertAt(y, x int, text string) {
+	e.replaceRange(y, x, y, x, []byte(text))
+	if y == e.cy && e.cx >= x {
+		e.cx += len(text)
+	}
+}
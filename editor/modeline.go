@@ -0,0 +1,179 @@
+package editor
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/icholy/kilo/internal/buffer"
+)
+
+// modelineLines is how many lines at the start and the end of a file
+// are searched for modelines.
+const modelineLines = 5
+
+// modeFiletypes maps the vim filetypes and emacs modes which differ from
+// ours.
+var modeFiletypes = map[string]string{
+	"sh":           "shell",
+	"bash":         "shell",
+	"zsh":          "shell",
+	"shell-script": "shell",
+	"cpp":          "c",
+	"c++":          "c",
+	"js":           "javascript",
+	"js2":          "javascript",
+	"typescript":   "javascript",
+	"makefile":     "make",
+	"md":           "markdown",
+	"xml":          "html",
+}
+
+// applyModelines applies the settings in vim and emacs modelines near
+// the start and the end of the file:
+//
+//	# vim: ts=4 sw=4 et
+//	/* vim: set ft=c noet: */
+//	# -*- mode: python; tab-width: 4; indent-tabs-mode: nil -*-
+//
+// Only the tab stop, indentation, and filetype can be set, and other
+// settings are ignored, so a file can't make the editor do anything
+// else. They can be turned off with the modelines option.
+func (e *Editor) applyModelines() {
+	if !e.modelines {
+		return
+	}
+	n := e.buf.NumRows()
+	for i, row := range e.buf.Rows {
+		if i >= modelineLines && i < n-modelineLines {
+			continue
+		}
+		line := string(row.Chars)
+		if opts, ok := vimModeline(line); ok {
+			for _, opt := range opts {
+				e.setModelineOption(opt)
+			}
+		}
+		if vars, ok := emacsModeline(line); ok {
+			for _, v := range vars {
+				e.setEmacsVariable(v[0], v[1])
+			}
+		}
+	}
+}
+
+// vimModeline returns the options of a line containing a vim modeline.
+// Both the "vim: ts=4 sw=4" and "vim: set ts=4 sw=4:" forms are
+// recognized.
+func vimModeline(line string) ([]string, bool) {
+	var rest string
+	for _, marker := range []string{"vim:", "vi:", "ex:"} {
+		i := strings.Index(line, marker)
+		if i < 0 || i > 0 && line[i-1] != ' ' && line[i-1] != '\t' {
+			continue
+		}
+		rest = strings.TrimSpace(line[i+len(marker):])
+		break
+	}
+	if rest == "" {
+		return nil, false
+	}
+	for _, set := range []string{"set ", "se "} {
+		if strings.HasPrefix(rest, set) {
+			// the options end at the next colon, the rest is text like
+			// the end of a comment
+			opts, _, ok := strings.Cut(rest[len(set):], ":")
+			if !ok {
+				return nil, false
+			}
+			return strings.Fields(opts), true
+		}
+	}
+	return strings.FieldsFunc(rest, func(r rune) bool {
+		return r == ' ' || r == '\t' || r == ':'
+	}), true
+}
+
+// setModelineOption applies a single vim option.
+func (e *Editor) setModelineOption(opt string) {
+	name, value, _ := strings.Cut(opt, "=")
+	switch name {
+	case "ts", "tabstop":
+		if n, ok := modelineWidth(value); ok {
+			e.buf.SetTabStop(n)
+		}
+	case "sw", "shiftwidth":
+		if n, ok := modelineWidth(value); ok {
+			e.shiftwidth = n
+		}
+	case "et", "expandtab":
+		e.expandtab = true
+	case "noet", "noexpandtab":
+		e.expandtab = false
+	case "ft", "filetype", "syn", "syntax":
+		e.setModelineFiletype(value)
+	}
+}
+
+// emacsModeline returns the variables of a line containing an emacs
+// -*- ... -*- modeline. A modeline with a single word is a mode.
+func emacsModeline(line string) ([][2]string, bool) {
+	_, rest, ok := strings.Cut(line, "-*-")
+	if !ok {
+		return nil, false
+	}
+	body, _, ok := strings.Cut(rest, "-*-")
+	if !ok {
+		return nil, false
+	}
+	body = strings.TrimSpace(body)
+	if !strings.Contains(body, ":") {
+		return [][2]string{{"mode", body}}, true
+	}
+	var vars [][2]string
+	for _, field := range strings.Split(body, ";") {
+		name, value, ok := strings.Cut(field, ":")
+		if !ok {
+			continue
+		}
+		vars = append(vars, [2]string{strings.ToLower(strings.TrimSpace(name)), strings.TrimSpace(value)})
+	}
+	return vars, true
+}
+
+// setEmacsVariable applies a single emacs variable.
+func (e *Editor) setEmacsVariable(name, value string) {
+	switch {
+	case name == "mode":
+		e.setModelineFiletype(strings.TrimSuffix(value, "-mode"))
+	case name == "tab-width":
+		if n, ok := modelineWidth(value); ok {
+			e.buf.SetTabStop(n)
+		}
+	case name == "indent-tabs-mode":
+		e.expandtab = value == "nil"
+	case name == "c-basic-offset", strings.HasSuffix(name, "-indent-offset"), strings.HasSuffix(name, "-basic-offset"):
+		if n, ok := modelineWidth(value); ok {
+			e.shiftwidth = n
+		}
+	}
+}
+
+func (e *Editor) setModelineFiletype(name string) {
+	name = strings.ToLower(name)
+	if ft, ok := modeFiletypes[name]; ok {
+		name = ft
+	}
+	if s := buffer.SyntaxNamed(name); s != nil {
+		e.buf.Syntax = s
+	}
+}
+
+// modelineWidth parses a tab stop or indent width, rejecting the values
+// which would make the file unreadable.
+func modelineWidth(s string) (int, bool) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 || n > 16 {
+		return 0, false
+	}
+	return n, true
+}
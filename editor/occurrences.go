@@ -0,0 +1,73 @@
+package editor
+
+import (
+	"bytes"
+	"time"
+)
+
+// occurrenceDelay is how long the cursor must rest on a word before
+// its other occurrences are highlighted.
+const occurrenceDelay = 500 * time.Millisecond
+
+// cursorWord returns the word under the cursor.
+func (e *Editor) cursorWord() string {
+	y, x0, x1, ok := e.wordRange()
+	if !ok {
+		return ""
+	}
+	return string(e.buf.Rows[y].Chars[x0:x1])
+}
+
+// idle is called while waiting for input. Requests from plugins
+// and RPC clients are handled, the config file is reloaded if it
+// changed, and once the cursor has rested on a word for
+// occurrenceDelay, every occurrence of it is highlighted.
+func (e *Editor) idle() {
+	e.pollRequests()
+	if e.watchConfig() || e.pollTerminal() || e.pollLSP() || e.pollQuickfix() {
+		e.refreshScreen()
+	}
+	if !e.occurrences || e.occword != "" || time.Since(e.keytime) < occurrenceDelay {
+		return
+	}
+	if word := e.cursorWord(); word != "" {
+		e.occword = word
+		e.refreshScreen()
+	}
+}
+
+// occurrenceMask reports which bytes of render are part of a whole
+// word occurrence of word, or nil if there are none.
+func (e *Editor) occurrenceMask(render []byte, word string) []bool {
+	if word == "" {
+		return nil
+	}
+	var mask []bool
+	for i := 0; i < len(render); {
+		j := bytes.Index(render[i:], []byte(word))
+		if j < 0 {
+			break
+		}
+		start, end := i+j, i+j+len(word)
+		if (start == 0 || !e.isWordByte(render[start-1])) && (end == len(render) || !e.isWordByte(render[end])) {
+			if mask == nil {
+				mask = make([]bool, len(render))
+			}
+			for k := start; k < end; k++ {
+				mask[k] = true
+			}
+		}
+		i = start + 1
+	}
+	return mask
+}
+
+func (e *Editor) toggleOccurrences() {
+	e.occurrences = !e.occurrences
+	e.occword = ""
+	if e.occurrences {
+		e.setStatus("highlighting occurrences on")
+	} else {
+		e.setStatus("highlighting occurrences off")
+	}
+}
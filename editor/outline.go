@@ -0,0 +1,331 @@
+package editor
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/icholy/kilo/internal/buffer"
+	"github.com/icholy/kilo/internal/term"
+	"github.com/icholy/kilo/internal/ui"
+)
+
+// outlineMinCols is the width of the screen below which the outline
+// sidebar isn't shown, as it would leave too little room for the text.
+const outlineMinCols = 40
+
+// outlineSymbol is a function, type, heading or the like in the
+// outline of a buffer.
+type outlineSymbol struct {
+	name   string
+	depth  int // the number of symbols it's nested in
+	cx, cy int
+}
+
+// outline is the sidebar listing the symbols of the current buffer.
+type outline struct {
+	ui.Sidebar
+	symbols []outlineSymbol
+	// buf and changes are the buffer and the version of it the symbols
+	// were found in
+	buf     *buffer.Buffer
+	changes int
+}
+
+// outlinePattern finds symbols in the lines of a filetype. The name
+// group is the name of the symbol, and the optional level group nests
+// it by its length, instead of by the indentation of the line.
+type outlinePattern struct {
+	re *regexp.Regexp
+	fn bool // the symbols are functions
+}
+
+// outlinePatterns are the patterns of the filetypes without a language
+// server, or for when it isn't running.
+var outlinePatterns = map[string][]outlinePattern{
+	"go": {
+		{re: regexp.MustCompile(`^func\s+(?:\([^)]*\)\s*)?(?P<name>\w+)`), fn: true},
+		{re: regexp.MustCompile(`^type\s+(?P<name>\w+)`)},
+	},
+	"c": {
+		{re: regexp.MustCompile(`^(?:typedef\s+)?(?:struct|union|enum)\s+(?P<name>\w+)\s*\{?\s*$`)},
+		{re: regexp.MustCompile(`^[A-Za-z_][\w\s*]*?\b(?P<name>\w+)\s*\([^;]*$`), fn: true},
+	},
+	"rust": {
+		{re: regexp.MustCompile(`^\s*(?:pub(?:\([^)]*\))?\s+)?(?:const\s+)?(?:async\s+)?(?:unsafe\s+)?fn\s+(?P<name>\w+)`), fn: true},
+		{re: regexp.MustCompile(`^\s*(?:pub(?:\([^)]*\))?\s+)?(?:struct|enum|trait|mod|type|union)\s+(?P<name>\w+)`)},
+		{re: regexp.MustCompile(`^\s*(?P<name>impl\b[^{]*?)\s*\{?\s*$`)},
+	},
+	"javascript": {
+		{re: regexp.MustCompile(`^\s*(?:export\s+)?(?:default\s+)?(?:async\s+)?function\s*\*?\s*(?P<name>[\w$]+)`), fn: true},
+		{re: regexp.MustCompile(`^\s*(?:export\s+)?(?:default\s+)?(?:abstract\s+)?(?:class|interface|enum)\s+(?P<name>[\w$]+)`)},
+		{re: regexp.MustCompile(`^\s*(?:export\s+)?(?:const|let|var)\s+(?P<name>[\w$]+)\s*=\s*(?:async\s+)?(?:function\b|\([^)]*\)\s*=>|[\w$]+\s*=>)`), fn: true},
+	},
+	"java": {
+		{re: regexp.MustCompile(`^\s*(?:(?:public|protected|private|static|final|abstract|sealed)\s+)*(?:class|interface|enum|record)\s+(?P<name>\w+)`)},
+		{re: regexp.MustCompile(`^\s+(?:(?:public|protected|private|static|final|abstract|synchronized|native)\s+)+[\w<>\[\],.? ]+\s+(?P<name>\w+)\s*\(`), fn: true},
+	},
+	"python": {
+		{re: regexp.MustCompile(`^\s*(?:async\s+)?def\s+(?P<name>\w+)`), fn: true},
+		{re: regexp.MustCompile(`^\s*class\s+(?P<name>\w+)`)},
+	},
+	"ruby": {
+		{re: regexp.MustCompile(`^\s*def\s+(?P<name>[\w.]+[?!=]?)`), fn: true},
+		{re: regexp.MustCompile(`^\s*(?:class|module)\s+(?P<name>[\w:]+)`)},
+	},
+	"shell": {
+		{re: regexp.MustCompile(`^\s*(?:function\s+)?(?P<name>[\w.:-]+)\s*\(\)`), fn: true},
+		{re: regexp.MustCompile(`^\s*function\s+(?P<name>[\w.:-]+)\s*\{?\s*$`), fn: true},
+	},
+	"lua": {
+		{re: regexp.MustCompile(`^\s*(?:local\s+)?function\s+(?P<name>[\w.:]+)`), fn: true},
+	},
+	"make": {
+		{re: regexp.MustCompile(`^(?P<name>[\w./%-]+(?:\s+[\w./%-]+)*)\s*::?(?:[^=]|$)`)},
+	},
+	"markdown": {
+		{re: regexp.MustCompile(`^(?P<level>#{1,6})\s+(?P<name>.*?)\s*#*\s*$`)},
+	},
+}
+
+// findSymbols returns the symbols of a buffer which match the patterns
+// of its filetype. A symbol is nested in the symbols above it which are
+// less indented, or of a lower heading level.
+func findSymbols(b *buffer.Buffer) []outlineSymbol {
+	if b.Syntax == nil {
+		return nil
+	}
+	patterns := outlinePatterns[b.Syntax.Filetype]
+	if len(patterns) == 0 {
+		return nil
+	}
+	var symbols []outlineSymbol
+	// the levels of the symbols the next one may be nested in
+	var levels []int
+	fence := false
+	for y, row := range b.Rows {
+		chars := row.Chars
+		if b.Syntax.Filetype == "markdown" && bytes.HasPrefix(bytes.TrimSpace(chars), []byte("```")) {
+			// headings aren't in code blocks
+			fence = !fence
+			continue
+		}
+		if fence {
+			continue
+		}
+		for _, p := range patterns {
+			m := p.re.FindSubmatchIndex(chars)
+			if m == nil {
+				continue
+			}
+			name := m[2*p.re.SubexpIndex("name"):]
+			level := len(chars) - len(bytes.TrimLeft(chars, " \t"))
+			if i := p.re.SubexpIndex("level"); i > 0 {
+				level = m[2*i+1] - m[2*i]
+			}
+			for len(levels) > 0 && levels[len(levels)-1] >= level {
+				levels = levels[:len(levels)-1]
+			}
+			sym := outlineSymbol{
+				name:  string(chars[name[0]:name[1]]),
+				depth: len(levels),
+				cx:    name[0],
+				cy:    y,
+			}
+			if p.fn {
+				sym.name += "()"
+			}
+			symbols = append(symbols, sym)
+			levels = append(levels, level)
+			break
+		}
+	}
+	return symbols
+}
+
+// outlineCols returns the number of columns taken by the outline
+// sidebar, a quarter of the screen.
+func (e *Editor) outlineCols() int {
+	if e.outline == nil || e.screencols < outlineMinCols {
+		return 0
+	}
+	return clamp(e.screencols/4, 20, 40)
+}
+
+// toggleOutline implements the outline command, which opens the outline
+// sidebar and focuses it, or closes it. With "focus", the focus moves
+// to the sidebar, opening it if it's closed.
+func (e *Editor) toggleOutline(args string) {
+	switch strings.TrimSpace(args) {
+	case "":
+		if e.outline != nil {
+			e.outline = nil
+			e.outlinefocus = false
+			return
+		}
+	case "focus":
+	default:
+		e.fail("outline: unknown argument %s, expected focus", args)
+		return
+	}
+	if e.screencols < outlineMinCols {
+		e.fail("outline: the screen is too small")
+		return
+	}
+	if e.outline == nil {
+		e.outline = &outline{}
+	}
+	e.updateOutline()
+	if !e.outlinefocus {
+		// start from the symbol the cursor is in
+		e.outline.Selected = e.currentSymbol()
+		if e.outline.Selected < 0 {
+			e.outline.Selected = 0
+		}
+	}
+	e.outlinefocus = true
+	e.showStatus("Enter = jump to the symbol | Esc = back to the buffer")
+}
+
+// updateOutline finds the symbols of the buffer again when it changed
+// since they were found. The language server's symbols are used when
+// there is one, and the patterns of the filetype otherwise.
+func (e *Editor) updateOutline() {
+	o := e.outline
+	if o == nil || o.buf == e.buf && o.changes == e.buf.Changes {
+		return
+	}
+	o.buf, o.changes = e.buf, e.buf.Changes
+	o.symbols = nil
+	if e.lsp != nil {
+		symbols, err := e.lsp.DocumentSymbols()
+		if err != nil {
+			e.log(LogError, "outline", "error", err)
+		}
+		o.symbols = symbols
+	}
+	if len(o.symbols) == 0 {
+		o.symbols = findSymbols(e.buf)
+	}
+	o.Title = "Outline"
+	o.Lines = make([]string, len(o.symbols))
+	for i, sym := range o.symbols {
+		o.Lines[i] = strings.Repeat("  ", sym.depth) + sym.name
+	}
+	if len(o.symbols) == 0 {
+		o.Title = "Outline: no symbols"
+	}
+	o.Selected = -1
+}
+
+// currentSymbol returns the index of the last symbol on or above the
+// cursor line, or -1.
+func (e *Editor) currentSymbol() int {
+	current := -1
+	for i, sym := range e.outline.symbols {
+		if sym.cy > e.cy {
+			break
+		}
+		current = i
+	}
+	return current
+}
+
+// drawOutline draws the outline sidebar at the right of the buffer.
+// Until it's focused, the symbol the cursor is in is highlighted.
+func (e *Editor) drawOutline(b *bytes.Buffer) {
+	cols := e.outlineCols()
+	if cols == 0 {
+		return
+	}
+	e.updateOutline()
+	o := e.outline
+	if !e.outlinefocus {
+		o.Selected = e.currentSymbol()
+	}
+	o.Scroll(e.screenrows)
+	o.Draw(b, e.screencols-cols, e.screenrows, cols, e.outlinefocus)
+}
+
+// outlineKey handles a key while the outline sidebar has the focus.
+// Enter jumps to the selected symbol and moves the focus back to the
+// buffer, as does Esc without jumping.
+func (e *Editor) outlineKey(c int) {
+	o := e.outline
+	e.updateOutline()
+	selected := o.Selected
+	switch c {
+	case '\x1b', 'q', term.ControlKey('q'):
+		e.outlinefocus = false
+	case '\r':
+		e.jumpSymbol(o.Selected)
+	case term.ArrowUp, 'k':
+		o.Selected--
+	case term.ArrowDown, 'j':
+		o.Selected++
+	case term.PageUp:
+		o.Selected -= e.screenrows - 1
+	case term.PageDown:
+		o.Selected += e.screenrows - 1
+	case term.HomeKey:
+		o.Selected = 0
+	case term.EndKey:
+		o.Selected = len(o.symbols) - 1
+	case term.MouseEvent:
+		e.mouse()
+	case term.ResizeEvent:
+	default:
+		if b, ok := e.keymap[c]; ok && strings.HasPrefix(b.command, "outline") {
+			e.runBinding(b)
+		}
+	}
+	if len(o.symbols) > 0 {
+		o.Selected = clamp(o.Selected, 0, len(o.symbols)-1)
+	}
+	if e.outlinefocus && o.Selected != selected && o.Selected >= 0 && o.Selected < len(o.symbols) {
+		e.announceSymbol(o.symbols[o.Selected])
+	}
+}
+
+// announceSymbol announces a symbol selected in the outline in the
+// screen reader mode.
+func (e *Editor) announceSymbol(sym outlineSymbol) {
+	if e.screenreader {
+		e.announce(sym.name + ", line " + strconv.Itoa(sym.cy+1))
+	}
+}
+
+// jumpSymbol moves the cursor to the i-th symbol of the outline and the
+// focus back to the buffer.
+func (e *Editor) jumpSymbol(i int) {
+	o := e.outline
+	if i < 0 || i >= len(o.symbols) {
+		return
+	}
+	sym := o.symbols[i]
+	e.outlinefocus = false
+	e.pushJump(e.location())
+	e.moveTo(sym.cx, sym.cy)
+	e.recenter = true
+}
+
+// outlineClick handles a mouse event on the outline sidebar, where a
+// click jumps to the symbol clicked. It reports whether the event was
+// on the sidebar.
+func (e *Editor) outlineClick(m term.Mouse) bool {
+	cols := e.outlineCols()
+	if cols == 0 || m.X < e.screencols-cols || m.Y >= e.screenrows {
+		return false
+	}
+	if m.Button == term.MouseWheelUp || m.Button == term.MouseWheelDown {
+		// the wheel scrolls the buffer
+		return false
+	}
+	if m.Button == term.MouseLeft && !m.Release && m.Y > 0 {
+		e.updateOutline()
+		i := e.outline.Offset + m.Y - 1
+		e.jumpSymbol(i)
+	}
+	return true
+}
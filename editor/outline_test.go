@@ -0,0 +1,17 @@
+package editor
+
+import "testing"
+
+func TestOutlineKeysWithoutSymbols(t *testing.T) {
+	e := newTestEditor(t, "no symbols here")
+	e.toggleOutline("focus")
+	if !e.outlinefocus {
+		t.Fatalf("outline isn't focused: %s", e.status)
+	}
+	for _, k := range []int{KeyHome, KeyDown, KeyEnd, KeyUp, KeyEscape} {
+		e.HandleKey(k)
+	}
+	if e.outlinefocus {
+		t.Error("Esc didn't move the focus back to the buffer")
+	}
+}
@@ -0,0 +1,287 @@
+package editor
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+const pluginTimeout = 3 * time.Second
+
+// Plugin is an external process which speaks JSON-RPC 2.0 over stdio,
+// one message per line. The editor sends an "initialize" request and
+// the plugin replies with the events it wants and the commands it
+// provides:
+//
+//	{"events": ["open", "save", "key"], "commands": [{"name": "wc", "help": "count words"}]}
+//
+// Events are sent as notifications with the "filename" and, for key
+// events, the "key". Running one of the plugin's commands sends a
+// "command" notification with its "name" and "args". The plugin may
+// send these requests at any time, positions are 0 based {"line", "col"}
+// byte offsets:
+//
+//	getText                            returns {"text"}
+//	getCursor                          returns {"line", "col"}
+//	setCursor {"line", "col"}
+//	insert    {"text"}                 inserts at the cursor
+//	replace   {"start", "end", "text"}
+//	status    {"message"}
+//	command   {"line"}                 runs an editor command
+type Plugin struct {
+	name    string
+	cmd     *exec.Cmd
+	w       io.WriteCloser
+	msgs    chan *lspMessage
+	pending []*lspMessage
+	seq     int
+	events  []string
+	wake    func()
+}
+
+type pluginCommand struct {
+	Name string `json:"name"`
+	Help string `json:"help"`
+}
+
+type pluginPosition struct {
+	Line int `json:"line"`
+	Col  int `json:"col"`
+}
+
+// checkPosition returns an error unless p is in the buffer: on one of
+// its lines, or at the start of the line after the last.
+func (e *Editor) checkPosition(p pluginPosition) error {
+	switch {
+	case p.Line < 0 || p.Line > e.buf.NumRows():
+		return fmt.Errorf("line %d out of range [0, %d]", p.Line, e.buf.NumRows())
+	case p.Line == e.buf.NumRows() && p.Col != 0:
+		return fmt.Errorf("column %d out of range on line %d, past the end", p.Col, p.Line)
+	case p.Line < e.buf.NumRows() && (p.Col < 0 || p.Col > e.buf.Rows[p.Line].Len()):
+		return fmt.Errorf("column %d out of range [0, %d] on line %d", p.Col, e.buf.Rows[p.Line].Len(), p.Line)
+	}
+	return nil
+}
+
+// checkRange returns an error unless start and end are in the buffer,
+// and start isn't after end.
+func (e *Editor) checkRange(start, end pluginPosition) error {
+	if err := e.checkPosition(start); err != nil {
+		return fmt.Errorf("start: %w", err)
+	}
+	if err := e.checkPosition(end); err != nil {
+		return fmt.Errorf("end: %w", err)
+	}
+	if end.Line < start.Line || end.Line == start.Line && end.Col < start.Col {
+		return fmt.Errorf("start %d:%d is after end %d:%d", start.Line, start.Col, end.Line, end.Col)
+	}
+	return nil
+}
+
+func pluginStart(command string, wake func()) (*Plugin, []pluginCommand, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return nil, nil, errors.New("missing plugin command")
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	w, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	r, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, nil, err
+	}
+	p := &Plugin{
+		name: args[0],
+		cmd:  cmd,
+		w:    w,
+		msgs: make(chan *lspMessage, 16),
+		wake: wake,
+	}
+	go p.readLoop(bufio.NewReader(r))
+	var result struct {
+		Events   []string        `json:"events"`
+		Commands []pluginCommand `json:"commands"`
+	}
+	if err := p.call("initialize", map[string]any{"version": version}, &result); err != nil {
+		p.Close()
+		return nil, nil, err
+	}
+	p.events = result.Events
+	return p, result.Commands, nil
+}
+
+func (p *Plugin) readLoop(r *bufio.Reader) {
+	defer close(p.msgs)
+	for {
+		line, err := r.ReadBytes('\n')
+		if err != nil {
+			return
+		}
+		var msg lspMessage
+		if err := json.Unmarshal(line, &msg); err != nil {
+			continue
+		}
+		p.msgs <- &msg
+		p.wake()
+	}
+}
+
+func (p *Plugin) write(msg any) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	_, err = p.w.Write(append(data, '\n'))
+	return err
+}
+
+func (p *Plugin) notify(method string, params any) error {
+	return p.write(map[string]any{
+		"jsonrpc": "2.0",
+		"method":  method,
+		"params":  params,
+	})
+}
+
+// call sends a request and waits for the response. Requests from the
+// plugin which arrive in the meantime are kept for the next poll.
+func (p *Plugin) call(method string, params, result any) error {
+	p.seq++
+	id := p.seq
+	err := p.write(map[string]any{
+		"jsonrpc": "2.0",
+		"id":      id,
+		"method":  method,
+		"params":  params,
+	})
+	if err != nil {
+		return err
+	}
+	timeout := time.After(pluginTimeout)
+	for {
+		select {
+		case msg, ok := <-p.msgs:
+			if !ok {
+				return errors.New("plugin exited")
+			}
+			if msg.Method != "" {
+				p.pending = append(p.pending, msg)
+				continue
+			}
+			if msg.ID == nil || string(*msg.ID) != strconv.Itoa(id) {
+				continue
+			}
+			if msg.Error != nil {
+				return msg.Error
+			}
+			if result == nil || len(msg.Result) == 0 {
+				return nil
+			}
+			return json.Unmarshal(msg.Result, result)
+		case <-timeout:
+			return fmt.Errorf("%s: timed out", method)
+		}
+	}
+}
+
+// Wants reports whether the plugin subscribed to the event.
+func (p *Plugin) Wants(event string) bool {
+	return slices.Contains(p.events, event)
+}
+
+func (p *Plugin) Close() {
+	p.w.Close()
+	p.cmd.Process.Kill()
+	p.cmd.Wait()
+}
+
+// loadPlugin starts a plugin and adds its commands.
+func (e *Editor) loadPlugin(command string) {
+	p, commands, err := pluginStart(command, e.wake)
+	if err != nil {
+		e.fail("plugin: %v", err)
+		return
+	}
+	e.plugins = append(e.plugins, p)
+	for _, pc := range commands {
+		name := pc.Name
+		e.addCommand(Command{
+			name: name,
+			help: pc.Help,
+			fn: func(e *Editor, args string) {
+				p.notify("command", map[string]any{"name": name, "args": args})
+			},
+		})
+	}
+}
+
+// pluginEvent notifies the plugins which subscribed to the event.
+func (e *Editor) pluginEvent(event string, params map[string]any) {
+	params["filename"] = e.filename
+	for _, p := range e.plugins {
+		if p.Wants(event) {
+			p.notify(event, params)
+		}
+	}
+}
+
+// pollPlugins handles the requests the plugins have sent without
+// blocking, and reports whether there were any.
+func (e *Editor) pollPlugins() bool {
+	var handled bool
+	for i := 0; i < len(e.plugins); i++ {
+		p := e.plugins[i]
+		msgs := p.pending
+		p.pending = nil
+		exited := false
+	drain:
+		for {
+			select {
+			case msg, ok := <-p.msgs:
+				if !ok {
+					exited = true
+					break drain
+				}
+				msgs = append(msgs, msg)
+			default:
+				break drain
+			}
+		}
+		for _, msg := range msgs {
+			if msg.Method == "" {
+				continue
+			}
+			result, err := e.handleRequest(msg.Method, msg.Params)
+			handled = true
+			if msg.ID == nil {
+				continue
+			}
+			reply := map[string]any{"jsonrpc": "2.0", "id": msg.ID, "result": result}
+			if err != nil {
+				reply = map[string]any{"jsonrpc": "2.0", "id": msg.ID, "error": lspError{Code: -32602, Message: err.Error()}}
+			}
+			p.write(reply)
+		}
+		if exited {
+			e.setStatus("plugin %s exited", p.name)
+			p.Close()
+			e.plugins = slices.Delete(e.plugins, i, i+1)
+			i--
+			handled = true
+		}
+	}
+	return handled
+}
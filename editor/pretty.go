@@ -0,0 +1,202 @@
+package editor
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// syntaxError is an error at a 1 based line and column of the text
+// being formatted or validated. col is 0 when it's not known.
+type syntaxError struct {
+	line, col int
+	msg       string
+}
+
+func (err *syntaxError) Error() string {
+	if err.col == 0 {
+		return fmt.Sprintf("line %d: %s", err.line, err.msg)
+	}
+	return fmt.Sprintf("line %d, column %d: %s", err.line, err.col, err.msg)
+}
+
+// dataRange returns the bounds of the selection, or of the whole
+// buffer.
+func (e *Editor) dataRange() (y0, x0, y1, x1 int) {
+	if y0, x0, y1, x1, ok := e.selectionBounds(); ok {
+		return y0, x0, y1, x1
+	}
+	last := e.buf.NumRows() - 1
+	return 0, 0, last, e.buf.Rows[last].Len()
+}
+
+// formatJSON pretty-prints JSON, indenting it like the buffer. Nested
+// JSON which is selected keeps the indentation of its first line.
+func (e *Editor) formatJSON(text []byte, prefix string) ([]byte, error) {
+	var b bytes.Buffer
+	if err := json.Indent(&b, text, prefix, string(e.indentString())); err != nil {
+		return nil, jsonError(text, err)
+	}
+	return b.Bytes(), nil
+}
+
+// jsonError converts the offset of a JSON syntax error into a line and
+// column.
+func jsonError(text []byte, err error) error {
+	var serr *json.SyntaxError
+	if !errors.As(err, &serr) {
+		return err
+	}
+	// the offset is after the byte which is wrong
+	offset := int(serr.Offset) - 1
+	if offset < 0 {
+		offset = 0
+	}
+	if offset > len(text) {
+		offset = len(text)
+	}
+	before := text[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	col := offset - bytes.LastIndexByte(before, '\n')
+	return &syntaxError{line: line, col: col, msg: serr.Error()}
+}
+
+// yamlPosition finds the position in an error from yq. The Python
+// version reports the context of the error before the problem, so the
+// last position is used.
+var yamlPosition = regexp.MustCompile(`line (\d+)(?:, column (\d+))?`)
+
+// yamlCommand returns the command which normalizes YAML. There are two
+// programs called yq: the Go one, and a Python wrapper around jq.
+func yamlCommand() ([]string, error) {
+	if _, err := exec.LookPath("yq"); err != nil {
+		return nil, errors.New("yq isn't installed")
+	}
+	out, _ := exec.Command("yq", "--version").CombinedOutput()
+	if bytes.Contains(out, []byte("mikefarah")) {
+		return []string{"yq", "-P", "."}, nil
+	}
+	return []string{"yq", "-y", "."}, nil
+}
+
+// formatYAML normalizes YAML with yq.
+func formatYAML(text []byte) ([]byte, error) {
+	args, err := yamlCommand()
+	if err != nil {
+		return nil, err
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = bytes.NewReader(text)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.Join(strings.Fields(stderr.String()), " ")
+		if msg == "" {
+			msg = err.Error()
+		}
+		msg = strings.TrimPrefix(msg, "yq: ")
+		msg = strings.TrimPrefix(msg, "Error running jq: ")
+		m := yamlPosition.FindAllStringSubmatch(msg, -1)
+		if len(m) == 0 {
+			return nil, errors.New(msg)
+		}
+		last := m[len(m)-1]
+		serr := &syntaxError{msg: msg}
+		serr.line, _ = strconv.Atoi(last[1])
+		serr.col, _ = strconv.Atoi(last[2])
+		return nil, serr
+	}
+	return stdout.Bytes(), nil
+}
+
+// formatData implements the json-format and yaml-format commands,
+// which reformat the selection or the buffer.
+func (e *Editor) formatData(format string) {
+	if e.buf.NumRows() == 0 {
+		return
+	}
+	y0, x0, y1, x1 := e.dataRange()
+	text := e.getRange(y0, x0, y1, x1)
+	var out []byte
+	var err error
+	switch format {
+	case "json":
+		var prefix string
+		if e.selection.active {
+			chars := e.buf.Rows[y0].Chars
+			prefix = string(chars[:len(chars)-len(bytes.TrimLeft(chars, " \t"))])
+		}
+		out, err = e.formatJSON(text, prefix)
+	case "yaml":
+		out, err = formatYAML(text)
+	}
+	if err != nil {
+		e.dataError(format, y0, x0, err)
+		return
+	}
+	out = bytes.TrimSuffix(out, []byte("\n"))
+	if bytes.Equal(out, text) {
+		e.setStatus("%s: already formatted", format)
+		return
+	}
+	cx, cy := e.cx, e.cy
+	e.replaceRange(y0, x0, y1, x1, out)
+	e.moveTo(cx, cy)
+	e.selection.active = false
+	e.setStatus("%s: formatted", format)
+}
+
+// validateData implements the validate command, which checks that the
+// selection or the buffer is valid JSON or YAML, by default according
+// to the filetype.
+func (e *Editor) validateData(args string) {
+	format := strings.TrimSpace(args)
+	if format == "" && e.buf.Syntax != nil {
+		format = e.buf.Syntax.Filetype
+	}
+	if format != "json" && format != "yaml" {
+		e.fail("validate: expected json or yaml")
+		return
+	}
+	if e.buf.NumRows() == 0 {
+		return
+	}
+	y0, x0, y1, x1 := e.dataRange()
+	text := e.getRange(y0, x0, y1, x1)
+	var err error
+	switch format {
+	case "json":
+		if !json.Valid(text) {
+			var v any
+			err = jsonError(text, json.Unmarshal(text, &v))
+		}
+	case "yaml":
+		_, err = formatYAML(text)
+	}
+	if err != nil {
+		e.dataError(format, y0, x0, err)
+		return
+	}
+	e.setStatus("%s: valid", format)
+}
+
+// dataError reports an error in the text which starts at y0, x0, and
+// moves the cursor to where it is.
+func (e *Editor) dataError(format string, y0, x0 int, err error) {
+	var serr *syntaxError
+	if errors.As(err, &serr) {
+		cx := serr.col - 1
+		if serr.line == 1 {
+			cx += x0
+		}
+		e.selection.active = false
+		e.moveTo(cx, y0+serr.line-1)
+	}
+	e.fail("%s: %v", format, err)
+}
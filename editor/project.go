@@ -0,0 +1,131 @@
+package editor
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// projectConfigNames are the names of a project's config file, which
+// has the same format as the kilorc.
+var projectConfigNames = []string{".kilorc", filepath.Join(".kilo", "config")}
+
+// findProjectConfig returns the project config in the directory of the
+// file or the closest parent which has one.
+func findProjectConfig(filename string) string {
+	if filename == "" || isRemote(filename) {
+		return ""
+	}
+	path, err := filepath.Abs(filename)
+	if err != nil {
+		return ""
+	}
+	for dir := filepath.Dir(path); ; dir = filepath.Dir(dir) {
+		for _, name := range projectConfigNames {
+			if fi, err := os.Stat(filepath.Join(dir, name)); err == nil && fi.Mode().IsRegular() {
+				return filepath.Join(dir, name)
+			}
+		}
+		if dir == filepath.Dir(dir) {
+			return ""
+		}
+	}
+}
+
+func trustedFile() string {
+	return filepath.Join(stateDir(), "trusted")
+}
+
+// isTrusted reports whether the user trusted the config with these
+// contents. Each line of the trusted file has the format: sha256 path
+func isTrusted(path, sum string) bool {
+	f, err := os.Open(trustedFile())
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if s, p, ok := strings.Cut(sc.Text(), " "); ok && s == sum && p == path {
+			return true
+		}
+	}
+	return false
+}
+
+// trust remembers that the config with these contents is trusted,
+// replacing the sum of its earlier contents.
+func trust(path, sum string) {
+	var b strings.Builder
+	if data, err := os.ReadFile(trustedFile()); err == nil {
+		for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+			if _, p, ok := strings.Cut(line, " "); ok && p != path {
+				b.WriteString(line + "\n")
+			}
+		}
+	}
+	b.WriteString(sum + " " + path + "\n")
+	writeFileAtomic(trustedFile(), []byte(b.String()))
+}
+
+// loadProjectConfig overlays the settings of the project config of the
+// current file on the global ones. The settings it changed are put
+// back when a file in another project is opened. A config which is new
+// or modified is only used once the user trusts it, since it can set
+// the build and formatter commands.
+func (e *Editor) loadProjectConfig() {
+	path := findProjectConfig(e.filename)
+	if path == e.projectcfg {
+		return
+	}
+	for name, value := range e.projectprev {
+		e.Set(name, value)
+	}
+	e.projectcfg, e.projectprev = "", nil
+	if path == "" || e.distrusted[path] {
+		return
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		e.fail("%v", err)
+		return
+	}
+	hash := sha256.Sum256(data)
+	sum := hex.EncodeToString(hash[:])
+	if !isTrusted(path, sum) {
+		if e.term == nil && e.input == nil {
+			// ask once the editor is running
+			e.trustpending = true
+			return
+		}
+		if !e.confirm("Use the settings in %s?", path) {
+			if e.distrusted == nil {
+				e.distrusted = map[string]bool{}
+			}
+			e.distrusted[path] = true
+			e.setStatus("ignoring %s", path)
+			return
+		}
+		trust(path, sum)
+	}
+	e.projectcfg, e.projectprev = path, map[string]string{}
+	if err := e.loadConfig(path, e.projectprev); err != nil {
+		e.fail("%v", err)
+	}
+}
+
+// askTrust asks whether to use the project config of a file which was
+// opened before the editor could prompt, and applies it.
+func (e *Editor) askTrust() {
+	if !e.trustpending {
+		return
+	}
+	e.trustpending = false
+	e.loadProjectConfig()
+	e.detectIndent()
+	e.applyModelines()
+	e.buf.Rerender()
+}
@@ -0,0 +1,275 @@
+package editor
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/icholy/kilo/internal/term"
+	"github.com/icholy/kilo/internal/ui"
+	"golang.org/x/exp/slices"
+)
+
+// maxCompletions is how many completions are listed in the prompt.
+const maxCompletions = 8
+
+// promptFile prompts for a filename, which can be completed with Tab.
+func (e *Editor) promptFile(prompt string) (string, bool) {
+	return e.promptComplete(prompt, completeFilename, nil)
+}
+
+// completeInput completes the text before the prompt cursor to the
+// longest prefix shared by the candidates, and lists them when there's
+// more than one.
+func (e *Editor) completeInput(before string, complete func(string) []string) string {
+	candidates := complete(before)
+	e.promptinfo = ""
+	switch len(candidates) {
+	case 0:
+		e.promptinfo = "no matches"
+		return before
+	case 1:
+		return candidates[0]
+	}
+	prefix := candidates[0]
+	for _, c := range candidates[1:] {
+		n := 0
+		for n < len(prefix) && n < len(c) && prefix[n] == c[n] {
+			n++
+		}
+		prefix = prefix[:n]
+	}
+	// list the names without the directory they share
+	dir, _ := filepath.Split(prefix)
+	var names []string
+	for i, c := range candidates {
+		if i == maxCompletions {
+			names = append(names, "...")
+			break
+		}
+		names = append(names, strings.TrimPrefix(c, dir))
+	}
+	e.promptinfo = strings.Join(names, " ")
+	return prefix
+}
+
+// completeFilename returns the paths which start with prefix. The
+// names of directories end with a slash, and hidden files are only
+// included when the name being completed starts with a dot.
+func completeFilename(prefix string) []string {
+	dir, base := filepath.Split(prefix)
+	path := dir
+	if path == "" {
+		path = "."
+	}
+	if strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, path[2:])
+		}
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, base) || strings.HasPrefix(name, ".") && !strings.HasPrefix(base, ".") {
+			continue
+		}
+		if fi, err := os.Stat(filepath.Join(path, name)); err == nil && fi.IsDir() {
+			name += "/"
+		}
+		names = append(names, dir+name)
+	}
+	return names
+}
+
+// confirm asks a yes or no question, formatted like setStatus formats
+// the messages once their format is translated.
+func (e *Editor) confirm(format string, args ...any) bool {
+	e.showStatus("%s (y/n)", fmt.Sprintf(e.tr(format), args...))
+	e.refreshScreen()
+	c := e.readKey()
+	e.showStatus("")
+	return c == 'y' || c == 'Y'
+}
+
+// openFile opens the named file, or prompts for one.
+func (e *Editor) openFile(args string) {
+	if args == "" {
+		var ok bool
+		if args, ok = e.promptFile("Open:"); !ok {
+			return
+		}
+	}
+	e.switchFile(args)
+}
+
+func (e *Editor) prompt(prompt string, callback func(input string, key int)) (string, bool) {
+	return e.promptComplete(prompt, nil, callback)
+}
+
+// promptComplete reads a line of input on the message bar. The cursor
+// can be moved with Left, Right, Home, and End, Up and Down recall the
+// previous inputs, and Tab completes the text before the cursor when
+// complete is set. The callback is called after every key.
+func (e *Editor) promptComplete(prompt string, complete func(string) []string, callback func(input string, key int)) (string, bool) {
+	var input []byte
+	var pos int
+	label := e.tr(prompt)
+	if e.screenreader && e.announceChannel() != defaultAnnounce {
+		e.announce(label)
+	}
+	e.prompting = true
+	defer func() {
+		e.promptinfo = ""
+		e.prompting = false
+	}()
+	// the arrow keys move through the previous inputs, and back to the
+	// one being typed
+	history := promptHistory(prompt)
+	histidx := len(history)
+	var typed []byte
+	for {
+		if e.promptinfo != "" {
+			e.showStatus("%s %s (%s, ESC to cancel)", label, input, e.promptinfo)
+		} else {
+			e.showStatus("%s %s (ESC to cancel)", label, input)
+		}
+		e.promptcol = ui.Width([]byte(label)) + 1 + ui.Width(input[:pos])
+		e.refreshScreen()
+		c := e.readKey()
+		switch {
+		case c == term.ControlKey('h') || c == term.BackspaceKey:
+			if pos > 0 {
+				start := prevChar(input, pos)
+				input = slices.Delete(input, start, pos)
+				pos = start
+			}
+		case c == term.DeleteKey:
+			if pos < len(input) {
+				input = slices.Delete(input, pos, nextChar(input, pos))
+			}
+		case c == '\x1b' || c == term.ControlKey('q'):
+			e.showStatus("")
+			return "", false
+		case c == '\r':
+			if len(input) != 0 {
+				e.showStatus("")
+				addHistory(prompt, string(input))
+				if callback != nil {
+					callback(string(input), c)
+				}
+				return string(input), true
+			}
+		case c == term.ArrowLeft:
+			pos = prevChar(input, pos)
+		case c == term.ArrowRight:
+			pos = nextChar(input, pos)
+		case c == term.HomeKey || c == term.ControlKey('a'):
+			pos = 0
+		case c == term.EndKey || c == term.ControlKey('e'):
+			pos = len(input)
+		case c == term.ArrowUp && histidx > 0:
+			if histidx == len(history) {
+				typed = input
+			}
+			histidx--
+			input = []byte(history[histidx])
+			pos = len(input)
+		case c == term.ArrowDown && histidx < len(history):
+			histidx++
+			if histidx == len(history) {
+				input = typed
+			} else {
+				input = []byte(history[histidx])
+			}
+			pos = len(input)
+		case c == '\t' && complete != nil:
+			before := e.completeInput(string(input[:pos]), complete)
+			input = append([]byte(before), input[pos:]...)
+			pos = len(before)
+		case unicode.IsPrint(rune(c)) && c < 128:
+			input = slices.Insert(input, pos, byte(c))
+			pos++
+		case c == term.TextEvent && e.term != nil:
+			text := bytes.ReplaceAll(e.term.Text, []byte("\r"), nil)
+			input = slices.Insert(input, pos, text...)
+			pos += len(text)
+		}
+		if callback != nil {
+			callback(string(input), c)
+		}
+	}
+}
+
+// menu displays the items in a popup below the cursor and lets the
+// user pick one. It returns the index of the chosen item, or -1 if the menu
+// was dismissed. Keys which aren't handled by the menu dismiss it and are
+// processed normally.
+func (e *Editor) menu(items []string) int {
+	p := &ui.Popup{Lines: items}
+	e.popup = p
+	defer func() { e.popup = nil }()
+	for {
+		if p.Selected < p.Offset {
+			p.Offset = p.Selected
+		}
+		if p.Selected >= p.Offset+ui.PopupMaxHeight {
+			p.Offset = p.Selected - ui.PopupMaxHeight + 1
+		}
+		e.refreshScreen()
+		switch c := e.readKey(); c {
+		case term.ArrowUp:
+			if p.Selected > 0 {
+				p.Selected--
+			}
+		case term.ArrowDown:
+			if p.Selected < len(p.Lines)-1 {
+				p.Selected++
+			}
+		case term.PageUp:
+			p.Selected = 0
+		case term.PageDown:
+			p.Selected = len(p.Lines) - 1
+		case '\r', '\t':
+			return p.Selected
+		case '\x1b':
+			return -1
+		default:
+			e.unreadKey(c)
+			return -1
+		}
+	}
+}
+
+// showPopup displays text in a popup below the cursor until a key
+// is pressed. The arrow keys scroll the text, other keys dismiss the popup
+// and are processed normally.
+func (e *Editor) showPopup(lines []string) {
+	p := &ui.Popup{Lines: lines, Selected: -1}
+	e.popup = p
+	defer func() { e.popup = nil }()
+	for {
+		e.refreshScreen()
+		switch c := e.readKey(); c {
+		case term.ArrowUp:
+			if p.Offset > 0 {
+				p.Offset--
+			}
+		case term.ArrowDown:
+			if p.Offset+ui.PopupMaxHeight < len(p.Lines) {
+				p.Offset++
+			}
+		case '\x1b':
+			return
+		default:
+			e.unreadKey(c)
+			return
+		}
+	}
+}
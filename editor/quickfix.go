@@ -0,0 +1,165 @@
+package editor
+
+import (
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type QuickfixEntry struct {
+	loc     Location
+	message string
+	// severity is that of a diagnostic, or 0 when the message says
+	severity int
+}
+
+// matches file:line:col: message and file:line: message
+var quickfixPattern = regexp.MustCompile(`^(?:vet: )?([^:\s]+):(\d+)(?::(\d+))?:\s*(.*)$`)
+
+// parseQuickfix extracts the error locations from compiler output.
+func parseQuickfix(output string) []QuickfixEntry {
+	var entries []QuickfixEntry
+	for _, line := range strings.Split(output, "\n") {
+		m := quickfixPattern.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			continue
+		}
+		e := QuickfixEntry{loc: Location{filename: filepath.Clean(m[1])}, message: m[4]}
+		e.loc.cy, _ = strconv.Atoi(m[2])
+		e.loc.cy--
+		if m[3] != "" {
+			e.loc.cx, _ = strconv.Atoi(m[3])
+			e.loc.cx--
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// build runs the build command and fills the quickfix list with
+// the errors it reports.
+func (e *Editor) build(args string) {
+	command := args
+	if command == "" {
+		command = e.buildCommand()
+	}
+	e.runQuickfix(command, "", parseQuickfix)
+}
+
+// quickfixRun is a command run in the background by runQuickfix. Its
+// output and error are set before done is closed.
+type quickfixRun struct {
+	command string
+	parse   func(output string) []QuickfixEntry
+	done    chan struct{}
+	output  []byte
+	err     error
+}
+
+// runQuickfix runs a shell command in dir, or the working directory,
+// and fills the quickfix list with the errors parse finds in its output.
+// While the editor runs in a terminal the command runs in the background
+// and the list is filled once it finishes, scripts and headless editors
+// wait for it.
+func (e *Editor) runQuickfix(command, dir string, parse func(output string) []QuickfixEntry) {
+	if e.qfrun != nil {
+		e.fail("%s is still running", e.qfrun.command)
+		return
+	}
+	e.setStatus("running %s ...", command)
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Dir = dir
+	run := &quickfixRun{command: command, parse: parse, done: make(chan struct{})}
+	if e.term == nil || e.scriptdepth > 0 {
+		e.refreshScreen()
+		run.output, run.err = cmd.CombinedOutput()
+		e.finishQuickfix(run)
+		return
+	}
+	e.qfrun = run
+	go func() {
+		run.output, run.err = cmd.CombinedOutput()
+		close(run.done)
+		e.wake()
+	}()
+}
+
+// pollQuickfix fills the quickfix list once the command run in the
+// background finishes, and reports whether it did.
+func (e *Editor) pollQuickfix() bool {
+	if e.qfrun == nil {
+		return false
+	}
+	select {
+	case <-e.qfrun.done:
+	default:
+		return false
+	}
+	run := e.qfrun
+	e.qfrun = nil
+	e.finishQuickfix(run)
+	return true
+}
+
+// finishQuickfix fills the quickfix list from the output of a command,
+// and jumps to the first error.
+func (e *Editor) finishQuickfix(run *quickfixRun) {
+	e.quickfix = run.parse(string(run.output))
+	e.qfidx = -1
+	if len(e.quickfix) == 0 {
+		if run.err != nil {
+			msg, _, _ := strings.Cut(strings.TrimSpace(string(run.output)), "\n")
+			e.fail("%s: %v %s", run.command, run.err, msg)
+		} else {
+			e.setStatus("%s: ok", run.command)
+		}
+		return
+	}
+	e.nextError(1)
+}
+
+// buildCommand returns the configured build command, or the default one
+// for the filetype.
+func (e *Editor) buildCommand() string {
+	switch {
+	case e.buildcmd != "":
+		return e.buildcmd
+	case e.buf.Syntax != nil && e.buf.Syntax.Build != "":
+		return e.buf.Syntax.Build
+	}
+	return "make"
+}
+
+// nextError jumps dir entries forward in the quickfix list.
+func (e *Editor) nextError(dir int) {
+	if len(e.quickfix) == 0 {
+		e.setStatus("no errors")
+		return
+	}
+	e.qfidx = clamp(e.qfidx+dir, 0, len(e.quickfix)-1)
+	e.jumpError()
+}
+
+func (e *Editor) jumpError() {
+	qf := e.quickfix[e.qfidx]
+	e.jump(qf.loc)
+	e.setStatus("(%d of %d) %s", e.qfidx+1, len(e.quickfix), qf.message)
+}
+
+// listErrors shows the quickfix list and jumps to the chosen entry.
+func (e *Editor) listErrors() {
+	if len(e.quickfix) == 0 {
+		e.setStatus("no errors")
+		return
+	}
+	items := make([]string, len(e.quickfix))
+	for i, qf := range e.quickfix {
+		items[i] = qf.loc.filename + ":" + strconv.Itoa(qf.loc.cy+1) + ": " + qf.message
+	}
+	if idx := e.menu(items); idx >= 0 {
+		e.qfidx = idx
+		e.jumpError()
+	}
+}
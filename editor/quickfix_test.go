@@ -0,0 +1,32 @@
+package editor
+
+import (
+	"testing"
+
+	"github.com/icholy/kilo/internal/term"
+)
+
+func TestRunQuickfixBackground(t *testing.T) {
+	e := newTestEditor(t)
+	// the command runs in the background while there's a terminal
+	e.term = &term.Terminal{}
+	e.runQuickfix("sleep 0.1; echo x.go:1: oops", "", parseQuickfix)
+	if e.qfrun == nil {
+		t.Fatal("the command didn't run in the background")
+	}
+	e.runQuickfix("true", "", parseQuickfix)
+	if e.status != "sleep 0.1; echo x.go:1: oops is still running" {
+		t.Errorf("status = %q while the command runs", e.status)
+	}
+	<-e.qfrun.done
+	if !e.pollQuickfix() {
+		t.Fatal("pollQuickfix didn't fill the list")
+	}
+	want := QuickfixEntry{loc: Location{filename: "x.go"}, message: "oops"}
+	if len(e.quickfix) != 1 || e.quickfix[0] != want {
+		t.Errorf("quickfix = %+v, want %+v", e.quickfix, want)
+	}
+	if e.qfrun != nil || e.pollQuickfix() {
+		t.Error("the command is still running after it finished")
+	}
+}
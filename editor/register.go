@@ -0,0 +1,252 @@
+package editor
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/icholy/kilo/internal/term"
+	"golang.org/x/exp/slices"
+)
+
+// Registers hold text for copying and pasting. They are named by a
+// byte: a to z are named registers, which are appended to when written
+// as A to Z, " is the most recent entry of the kill ring, and + is the
+// system clipboard.
+const (
+	defaultRegister   = '"'
+	clipboardRegister = '+'
+)
+
+// clipboardCopy and clipboardPaste are the commands tried in order to
+// copy to and paste from the system clipboard.
+var (
+	clipboardCopy = [][]string{
+		{"wl-copy"},
+		{"xclip", "-selection", "clipboard"},
+		{"xsel", "--clipboard", "--input"},
+		{"pbcopy"},
+	}
+	clipboardPaste = [][]string{
+		{"wl-paste", "--no-newline"},
+		{"xclip", "-selection", "clipboard", "-o"},
+		{"xsel", "--clipboard", "--output"},
+		{"pbpaste"},
+	}
+)
+
+// validRegister reports whether r names a register.
+func validRegister(r byte) bool {
+	return 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || r == defaultRegister || r == clipboardRegister
+}
+
+// registerKey handles Alt-" followed by the name of a register, which
+// is used by the next copy, cut, kill, or yank instead of the kill ring,
+// e.g. Alt-" a Alt-w copies the selection into register a.
+func (e *Editor) registerKey(c int) bool {
+	if c != term.AltKey('"') {
+		return false
+	}
+	e.showStatus("register: (a-z, A-Z to append, \" or +)")
+	e.refreshScreen()
+	r := e.readKey()
+	if r > 0xff || !validRegister(byte(r)) {
+		e.register = 0
+		e.showStatus("")
+		return true
+	}
+	e.register = byte(r)
+	e.showStatus("register: %c", r)
+	return true
+}
+
+// setRegister stores text in a register. The kill ring is used when r
+// is 0.
+func (e *Editor) setRegister(r byte, text []byte) {
+	if e.registers == nil {
+		e.registers = map[byte][]byte{}
+	}
+	switch {
+	case r == 0 || r == defaultRegister:
+		e.killring = append(e.killring, slices.Clone(text))
+		if len(e.killring) > killRingSize {
+			e.killring = e.killring[1:]
+		}
+	case r == clipboardRegister:
+		e.registers[r] = slices.Clone(text)
+		e.copyClipboard(text)
+	case 'A' <= r && r <= 'Z':
+		r += 'a' - 'A'
+		e.registers[r] = append(e.registers[r], text...)
+	default:
+		e.registers[r] = slices.Clone(text)
+	}
+}
+
+// getRegister returns the text in a register, or the last kill when r
+// is 0.
+func (e *Editor) getRegister(r byte) ([]byte, bool) {
+	switch {
+	case r == 0 || r == defaultRegister:
+		if len(e.killring) == 0 {
+			return nil, false
+		}
+		return e.killring[len(e.killring)-1], true
+	case r == clipboardRegister:
+		if text, ok := pasteClipboard(); ok {
+			return text, true
+		}
+	case 'A' <= r && r <= 'Z':
+		r += 'a' - 'A'
+	}
+	text, ok := e.registers[r]
+	return text, ok
+}
+
+// copyClipboard copies text to the system clipboard, using the first
+// clipboard command which is installed. The OSC 52 escape sequence is
+// also sent, which lets terminals set the clipboard over ssh.
+func (e *Editor) copyClipboard(text []byte) {
+	if e.term != nil {
+		fmt.Fprintf(e.term, "\x1b]52;c;%s\x07", base64.StdEncoding.EncodeToString(text))
+	}
+	for _, args := range clipboardCopy {
+		if _, err := exec.LookPath(args[0]); err != nil {
+			continue
+		}
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Stdin = bytes.NewReader(text)
+		if err := cmd.Run(); err == nil {
+			return
+		}
+	}
+}
+
+// pasteClipboard returns the contents of the system clipboard. It fails
+// when there's no clipboard command installed.
+func pasteClipboard() ([]byte, bool) {
+	for _, args := range clipboardPaste {
+		if _, err := exec.LookPath(args[0]); err != nil {
+			continue
+		}
+		if out, err := exec.Command(args[0], args[1:]...).Output(); err == nil {
+			return out, true
+		}
+	}
+	return nil, false
+}
+
+// registerArg returns the register named by a command's argument, or
+// the one chosen with Alt-".
+func (e *Editor) registerArg(args string) (byte, error) {
+	args = strings.TrimSpace(args)
+	switch {
+	case args == "":
+		return e.register, nil
+	case len(args) == 1 && validRegister(args[0]):
+		return args[0], nil
+	}
+	return 0, fmt.Errorf("invalid register: %s", args)
+}
+
+// selectionOrLine returns the bounds of the selection, or of the
+// current line including its line break.
+func (e *Editor) selectionOrLine() (y0, x0, y1, x1 int) {
+	if y0, x0, y1, x1, ok := e.selectionBounds(); ok {
+		return y0, x0, y1, x1
+	}
+	if e.cy < e.buf.NumRows()-1 {
+		return e.cy, 0, e.cy + 1, 0
+	}
+	return e.cy, 0, e.cy, e.buf.Rows[e.cy].Len()
+}
+
+// copyText copies the selection, or the current line, into a register.
+func (e *Editor) copyText(args string) {
+	r, err := e.registerArg(args)
+	if err != nil {
+		e.fail("copy: %v", err)
+		return
+	}
+	if e.cy >= e.buf.NumRows() {
+		return
+	}
+	text := e.getRange(e.selectionOrLine())
+	e.setRegister(r, text)
+	e.selection.active = false
+	e.setStatus("copied %d bytes%s", len(text), registerName(r))
+}
+
+// cutText moves the selection, or the current line, into a register.
+func (e *Editor) cutText(args string) {
+	r, err := e.registerArg(args)
+	if err != nil {
+		e.fail("cut: %v", err)
+		return
+	}
+	if e.cy >= e.buf.NumRows() {
+		return
+	}
+	y0, x0, y1, x1 := e.selectionOrLine()
+	e.setRegister(r, e.getRange(y0, x0, y1, x1))
+	e.cy, e.cx = e.replaceRange(y0, x0, y1, x1, nil)
+	e.selection.active = false
+}
+
+// pasteText inserts the text in a register at the cursor.
+func (e *Editor) pasteText(args string) {
+	r, err := e.registerArg(args)
+	if err != nil {
+		e.fail("paste: %v", err)
+		return
+	}
+	text, ok := e.getRegister(r)
+	if !ok {
+		e.fail("register%s is empty", registerName(r))
+		return
+	}
+	e.cy, e.cx = e.replaceRange(e.cy, e.cx, e.cy, e.cx, text)
+}
+
+// registerName describes a register for a status message.
+func registerName(r byte) string {
+	if r == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" %c", r)
+}
+
+// listRegisters shows the registers which hold text, and pastes the one
+// chosen with Enter.
+func (e *Editor) listRegisters() {
+	var names []byte
+	var lines []string
+	add := func(r byte, text []byte) {
+		preview := strings.ReplaceAll(string(text), "\n", `\n`)
+		if len(preview) > e.screencols {
+			preview = preview[:e.screencols]
+		}
+		names = append(names, r)
+		lines = append(lines, fmt.Sprintf("%c  %s", r, preview))
+	}
+	if text, ok := e.getRegister(defaultRegister); ok {
+		add(defaultRegister, text)
+	}
+	if text, ok := e.registers[clipboardRegister]; ok {
+		add(clipboardRegister, text)
+	}
+	for r := byte('a'); r <= 'z'; r++ {
+		if text, ok := e.registers[r]; ok {
+			add(r, text)
+		}
+	}
+	if len(lines) == 0 {
+		e.fail("the registers are empty")
+		return
+	}
+	e.view("[Registers]", lines, func(i int) {
+		e.pasteText(string(names[i]))
+	})
+}
@@ -0,0 +1,220 @@
+package editor
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// RemoteFile is a file on another machine named by a scp://[user@]host[:port]/path
+// URL. Like in vim, the path is relative to the home directory unless
+// it starts with a second slash. Files are read and written by running
+// commands over an ssh connection, which authenticates with the keys in
+// the ssh agent or the default identity files, and only trusts hosts
+// already in ~/.ssh/known_hosts. The ssh config isn't read.
+type RemoteFile struct {
+	user string
+	host string
+	port string
+	path string
+}
+
+// parseRemote parses a scp:// URL.
+func parseRemote(name string) (RemoteFile, bool) {
+	if !strings.HasPrefix(name, "scp://") {
+		return RemoteFile{}, false
+	}
+	u, err := url.Parse(name)
+	if err != nil || u.Hostname() == "" || strings.HasPrefix(u.Hostname(), "-") || len(u.Path) < 2 {
+		return RemoteFile{}, false
+	}
+	rf := RemoteFile{host: u.Hostname(), port: u.Port(), path: u.Path[1:]}
+	if u.User != nil {
+		rf.user = u.User.Username()
+	}
+	return rf, true
+}
+
+func isRemote(name string) bool {
+	_, ok := parseRemote(name)
+	return ok
+}
+
+// remoteIdentities are the private keys in ~/.ssh tried after the ones
+// in the agent. Keys protected by a passphrase are skipped.
+var remoteIdentities = []string{"id_ed25519", "id_ecdsa", "id_rsa"}
+
+// dial connects to the remote host.
+func (rf RemoteFile) dial() (*ssh.Client, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	known, err := knownhosts.New(filepath.Join(home, ".ssh", "known_hosts"))
+	if err != nil {
+		return nil, err
+	}
+	hostkey := func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		err := known(hostname, remote, key)
+		var keyErr *knownhosts.KeyError
+		if errors.As(err, &keyErr) && len(keyErr.Want) == 0 {
+			return errors.New("host key isn't in ~/.ssh/known_hosts")
+		}
+		return err
+	}
+	name := rf.user
+	if name == "" {
+		u, err := user.Current()
+		if err != nil {
+			return nil, err
+		}
+		name = u.Username
+	}
+	var auth []ssh.AuthMethod
+	if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+		if conn, err := net.Dial("unix", sock); err == nil {
+			defer conn.Close()
+			auth = append(auth, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+		}
+	}
+	var signers []ssh.Signer
+	for _, id := range remoteIdentities {
+		data, err := os.ReadFile(filepath.Join(home, ".ssh", id))
+		if err != nil {
+			continue
+		}
+		if signer, err := ssh.ParsePrivateKey(data); err == nil {
+			signers = append(signers, signer)
+		}
+	}
+	if len(signers) > 0 {
+		auth = append(auth, ssh.PublicKeys(signers...))
+	}
+	port := rf.port
+	if port == "" {
+		port = "22"
+	}
+	addr := net.JoinHostPort(rf.host, port)
+	return ssh.Dial("tcp", addr, &ssh.ClientConfig{
+		User:              name,
+		Auth:              auth,
+		HostKeyCallback:   hostkey,
+		HostKeyAlgorithms: knownKeyAlgorithms(known, addr),
+		Timeout:           10 * time.Second,
+	})
+}
+
+// knownKeyAlgorithms returns the algorithms of the keys known for the
+// host, so that the server is asked for one of them rather than its
+// preferred key, which may not be the one in known_hosts. It returns
+// nil for unknown hosts, which fail the host key check anyway.
+func knownKeyAlgorithms(hostkey ssh.HostKeyCallback, addr string) []string {
+	// the keys known for the host are in the error for a key which
+	// isn't one of them
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil
+	}
+	key, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		return nil
+	}
+	var keyErr *knownhosts.KeyError
+	if !errors.As(hostkey(addr, &net.TCPAddr{}, key), &keyErr) {
+		return nil
+	}
+	var algos []string
+	for _, k := range keyErr.Want {
+		switch t := k.Key.Type(); t {
+		case ssh.KeyAlgoRSA:
+			algos = append(algos, ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA)
+		default:
+			algos = append(algos, t)
+		}
+	}
+	return algos
+}
+
+// run runs a command on the remote host.
+func (rf RemoteFile) run(command string, stdin []byte) ([]byte, error) {
+	client, err := rf.dial()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", rf.host, err)
+	}
+	defer client.Close()
+	session, err := client.NewSession()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", rf.host, err)
+	}
+	defer session.Close()
+	var stdout, stderr bytes.Buffer
+	session.Stdin = bytes.NewReader(stdin)
+	session.Stdout = &stdout
+	session.Stderr = &stderr
+	if err := session.Run(command); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s: %s", rf.host, msg)
+		}
+		return nil, fmt.Errorf("%s: %w", rf.host, err)
+	}
+	return stdout.Bytes(), nil
+}
+
+// shellQuote quotes s for the shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func (rf RemoteFile) Read() ([]byte, error) {
+	return rf.run("cat -- "+shellQuote(rf.path), nil)
+}
+
+// remoteWrite is the script writing the file named by its argument. It
+// writes a temporary file next to it first, with the permissions of the
+// file or new ones, which replaces it only once it's fully written, so
+// that a lost connection doesn't leave it truncated.
+const remoteWrite = `set -e
+t=$(mktemp "$(dirname -- "$1")/.$(basename -- "$1").XXXXXX")
+trap 'rm -f -- "$t"' EXIT
+if [ -e "$1" ]; then
+	cp -p -- "$1" "$t"
+else
+	chmod "$(printf %o $((0666 & ~$(umask))))" "$t"
+fi
+cat > "$t"
+mv -f -- "$t" "$1"`
+
+func (rf RemoteFile) Write(data []byte) error {
+	_, err := rf.run("sh -c "+shellQuote(remoteWrite)+" kilo "+shellQuote(rf.path), data)
+	return err
+}
+
+// readFile reads a local or remote file.
+func readFile(name string) ([]byte, error) {
+	if rf, ok := parseRemote(name); ok {
+		return rf.Read()
+	}
+	return os.ReadFile(name)
+}
+
+// absPath returns the absolute path of a local file, remote files are
+// returned unchanged.
+func absPath(name string) (string, error) {
+	if isRemote(name) {
+		return name, nil
+	}
+	return filepath.Abs(name)
+}
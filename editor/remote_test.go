@@ -0,0 +1,25 @@
+package editor
+
+import "testing"
+
+func TestParseRemote(t *testing.T) {
+	tests := []struct {
+		name string
+		rf   RemoteFile
+		ok   bool
+	}{
+		{"scp://host/notes.txt", RemoteFile{host: "host", path: "notes.txt"}, true},
+		{"scp://me@host:2222//etc/hosts", RemoteFile{user: "me", host: "host", port: "2222", path: "/etc/hosts"}, true},
+		{"scp://host/", RemoteFile{}, false},
+		{"scp:///notes.txt", RemoteFile{}, false},
+		// a host which looks like an option
+		{"scp://-oProxyCommand=cmd/p", RemoteFile{}, false},
+		{"notes.txt", RemoteFile{}, false},
+	}
+	for _, tt := range tests {
+		rf, ok := parseRemote(tt.name)
+		if ok != tt.ok || rf != tt.rf {
+			t.Errorf("parseRemote(%q) = %+v, %v, want %+v, %v", tt.name, rf, ok, tt.rf, tt.ok)
+		}
+	}
+}
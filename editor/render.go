@@ -0,0 +1,178 @@
+package editor
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/icholy/kilo/internal/ui"
+)
+
+func (e *Editor) scroll() {
+	if e.recenter {
+		e.centerCursor()
+		e.recenter = false
+	}
+	e.rx = 0
+	if e.cy < e.buf.NumRows() {
+		e.rx = e.buf.Rows[e.cy].CxToRx(e.cx)
+	}
+	// keep e.scrolloff rows of context around the cursor
+	so := e.scrolloff
+	if so > (e.screenrows-1)/2 {
+		so = (e.screenrows - 1) / 2
+	}
+	if e.cy-so < e.rowoff {
+		e.rowoff = e.cy - so
+	}
+	// don't scroll past the end of the file to make room for context
+	below := e.cy + so
+	if below >= e.buf.NumRows() {
+		below = e.buf.NumRows() - 1
+	}
+	if below < e.cy {
+		below = e.cy
+	}
+	if below >= e.rowoff+e.screenrows {
+		e.rowoff = below - e.screenrows + 1
+	}
+	if e.rowoff < 0 {
+		e.rowoff = 0
+	}
+	if e.rx < e.coloff {
+		e.coloff = e.rx
+	}
+	if cols := e.textCols(); e.rx >= e.coloff+cols {
+		e.coloff = e.rx - cols + 1
+	}
+}
+
+// centerCursor scrolls so that the cursor row is in the middle
+// of the screen.
+func (e *Editor) centerCursor() {
+	e.rowoff = e.cy - e.screenrows/2
+	if e.rowoff < 0 {
+		e.rowoff = 0
+	}
+}
+
+func (e *Editor) refreshScreen() {
+	if e.term == nil {
+		return
+	}
+	start := time.Now()
+	var b bytes.Buffer
+	e.render(&b)
+	if e.screenreader {
+		e.term.Write(e.drawChanged(b.Bytes()))
+	} else {
+		e.term.Write(b.Bytes())
+	}
+	e.recordFrame(start)
+	e.log(LogDebug, "render", "bytes", b.Len(), "time", e.timing.render)
+}
+
+func (e *Editor) render(b *bytes.Buffer) {
+	e.scroll()
+	if e.occword != "" && e.occword != e.cursorWord() {
+		e.occword = ""
+	}
+	b.WriteString("\x1b[?25l") // hide cursor
+	b.WriteString("\x1b[H")    // put cursor at top left
+	e.drawRows(b)
+	e.drawStatusBar(b)
+	e.drawScrollbar(b)
+	e.drawOutline(b)
+	e.drawPopup(b)
+	if e.prompting {
+		// the cursor is in the prompt on the message bar
+		col := e.promptcol
+		if col >= e.screencols {
+			col = e.screencols - 1
+		}
+		fmt.Fprintf(b, "\x1b[%d;%dH", e.totalrows, col+1)
+	} else if e.outlinefocus && e.outlineCols() > 0 {
+		// the cursor is on the symbol selected in the outline
+		row := e.outline.Selected - e.outline.Offset + 1
+		fmt.Fprintf(b, "\x1b[%d;%dH", row+1, e.screencols-e.outlineCols()+2)
+	} else if e.termfocus {
+		row, col, ok := e.terminalCursor()
+		if !ok {
+			return // the command hid the cursor
+		}
+		fmt.Fprintf(b, "\x1b[%d;%dH", row+1, col+1)
+	} else {
+		fmt.Fprintf(b, "\x1b[%d;%dH", e.cy-e.rowoff+1, e.cursorCol()+1) // move cursor to correct position
+	}
+	b.WriteString("\x1b[?25h") // show cursor
+}
+
+func (e *Editor) drawPopup(b *bytes.Buffer) {
+	if e.popup != nil {
+		e.popup.Draw(b, e.cy-e.rowoff, e.cursorCol(), e.screenrows, e.screencols)
+	}
+}
+
+// toggleCursorLine toggles the cursor line highlight, or the
+// cursor column highlight when args is "column".
+func (e *Editor) toggleCursorLine(args string) {
+	switch strings.TrimSpace(args) {
+	case "":
+		e.cursorline = !e.cursorline
+	case "column":
+		e.cursorcolumn = !e.cursorcolumn
+	default:
+		e.fail("cursorline: unknown option %q", args)
+	}
+}
+
+func (e *Editor) drawRows(b *bytes.Buffer) {
+	marks := e.gutterMarks()
+	diags := e.lineDiagnostics()
+	for y := 0; y < e.screenrows; y++ {
+		filerow := y + e.rowoff
+		if filerow >= e.buf.NumRows() {
+			// print welcome screen
+			if e.buf.NumRows() != 0 || e.filename != "" || !e.drawWelcome(b, y) {
+				b.WriteString("~")
+			}
+		} else {
+			row := e.buf.Rows[filerow]
+			style := ui.RowStyle{SelStart: -1, SelEnd: -1, CursorCol: -1}
+			// selected render columns on this row
+			if y0, x0, y1, x1, ok := e.selectionBounds(); ok && y0 <= filerow && filerow <= y1 {
+				style.SelStart, style.SelEnd = 0, len(row.Render())+1
+				if filerow == y0 {
+					style.SelStart = row.CxToRx(x0)
+				}
+				if filerow == y1 {
+					style.SelEnd = row.CxToRx(x1)
+				}
+			}
+			if e.cursorline && filerow == e.cy {
+				style.Background = ui.CursorBackground
+			}
+			if e.cursorcolumn {
+				style.CursorCol = e.rx - e.coloff
+			}
+			style.Marked = e.occurrenceMask(row.Render(), e.occword)
+			if e.colorPreview() {
+				style.Swatches = e.colorSwatches(row)
+			}
+			if d := diags[filerow]; len(d) > 0 {
+				style.VirtualText = d[0].message
+				style.VirtualColor = diagnosticColor(d[0].severity)
+			}
+			e.buf.Highlight(filerow)
+			if len(marks) > 0 || len(diags) > 0 {
+				drawGutter(b, marks, diags[filerow], filerow)
+			}
+			unmark := e.markMatches(row, filerow)
+			ui.DrawRow(b, row, e.coloff, e.textCols(), style)
+			unmark()
+		}
+		b.WriteString("\x1b[K") // clear one line
+		b.WriteString("\r\n")
+	}
+}
@@ -0,0 +1,190 @@
+package editor
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"golang.org/x/exp/slices"
+)
+
+// parseReplace splits sed style /old/new/ arguments. Any character can
+// be used as the delimiter and the trailing one is optional.
+func parseReplace(args string) (old, new string, ok bool) {
+	if len(args) < 2 {
+		return "", "", false
+	}
+	parts := strings.Split(args[1:], args[:1])
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || len(parts) == 3 && parts[2] != "" {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+// replaceText applies replace to the text of every row, or when text
+// is selected to the part of each row inside the selection. replace
+// returns the new text and how many replacements it made. The number
+// of replacements is shown in the status bar, and since it's a single
+// command it's undone in one step.
+func (e *Editor) replaceText(replace func(text []byte) ([]byte, int)) {
+	y0, x0, y1, x1, within := e.selectionBounds()
+	if !within {
+		y0, x0, y1, x1 = 0, 0, e.buf.NumRows()-1, -1
+	}
+	var n, lines int
+	for y := y0; y <= y1 && y < e.buf.NumRows(); y++ {
+		row := e.buf.Rows[y]
+		start, end := 0, row.Len()
+		if y == y0 {
+			start = x0
+		}
+		if y == y1 && x1 >= 0 {
+			end = x1
+		}
+		text, c := replace(row.Chars[start:end])
+		if c == 0 {
+			continue
+		}
+		chars := append(slices.Clone(row.Chars[:start]), text...)
+		row.Chars = append(chars, row.Chars[end:]...)
+		row.Update()
+		if y == y1 && within {
+			// keep the end of the selection after the replaced text
+			if e.cy == y1 && e.cx == x1 {
+				e.cx += len(text) - (end - start)
+			} else {
+				e.selection.cx += len(text) - (end - start)
+			}
+		}
+		n += c
+		lines++
+	}
+	if n > 0 {
+		e.dirty = true
+		e.moveTo(e.cx, e.cy)
+	}
+	where := ""
+	if within {
+		where = " in the selection"
+	}
+	if n == 0 {
+		e.fail("no occurrences found%s", where)
+		return
+	}
+	e.setStatus("replaced %d occurrences on %d lines%s", n, lines, where)
+}
+
+// replaceAll replaces every occurrence of the old text in the buffer,
+// or in the selection. The args are /old/new/.
+func (e *Editor) replaceAll(args string) {
+	old, new, ok := parseReplace(strings.TrimSpace(args))
+	if !ok {
+		e.fail("replace: expected /old/new/")
+		return
+	}
+	e.replaceText(func(text []byte) ([]byte, int) {
+		c := bytes.Count(text, []byte(old))
+		if c == 0 {
+			return text, 0
+		}
+		return bytes.ReplaceAll(text, []byte(old), []byte(new)), c
+	})
+}
+
+// replaceRegex replaces every match of a regular expression in the
+// buffer, or in the selection, one row at a time. The args are
+// /pattern/template/. In the template $1 or ${name} inserts a group and
+// $$ a dollar sign, \u and \l change the case of the next character,
+// and \U and \L change the case of everything up to \E.
+func (e *Editor) replaceRegex(args string) {
+	pattern, template, ok := parseReplace(strings.TrimSpace(args))
+	if !ok {
+		e.fail("replace-regex: expected /pattern/template/")
+		return
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		e.fail("replace-regex: %v", err)
+		return
+	}
+	e.replaceText(func(text []byte) ([]byte, int) {
+		matches := re.FindAllSubmatchIndex(text, -1)
+		if len(matches) == 0 {
+			return text, 0
+		}
+		var chars []byte
+		var last int
+		for _, m := range matches {
+			chars = append(chars, text[last:m[0]]...)
+			chars = expandTemplate(chars, template, re, text, m)
+			last = m[1]
+		}
+		return append(chars, text[last:]...), len(matches)
+	})
+}
+
+// expandTemplate appends the template to dst with the groups of the
+// match filled in and the case changes applied.
+func expandTemplate(dst []byte, template string, re *regexp.Regexp, src []byte, match []int) []byte {
+	var once, span byte // the case change of the next character, and up to \E
+	write := func(s []byte) {
+		for _, c := range s {
+			switch {
+			case once == 'u' || once == 0 && span == 'U':
+				c = byte(unicode.ToUpper(rune(c)))
+			case once == 'l' || once == 0 && span == 'L':
+				c = byte(unicode.ToLower(rune(c)))
+			}
+			once = 0
+			dst = append(dst, c)
+		}
+	}
+	for i := 0; i < len(template); i++ {
+		c := template[i]
+		switch {
+		case c == '\\' && i+1 < len(template):
+			i++
+			switch template[i] {
+			case 'u', 'l':
+				once = template[i]
+			case 'U', 'L':
+				span = template[i]
+			case 'E':
+				span = 0
+			default:
+				write([]byte{template[i]})
+			}
+		case c == '$' && i+1 < len(template) && template[i+1] == '$':
+			i++
+			write([]byte{'$'})
+		case c == '$':
+			// find the end of the group, unlike regexp.Expand a number
+			// ends at the first non-digit so that $1ID works
+			j := i + 1
+			switch {
+			case j < len(template) && template[j] == '{':
+				if end := strings.IndexByte(template[j:], '}'); end >= 0 {
+					j += end + 1
+				}
+			case j < len(template) && isDigit(template[j]):
+				for j < len(template) && isDigit(template[j]) {
+					j++
+				}
+			default:
+				for j < len(template) && (template[j] == '_' || unicode.IsLetter(rune(template[j])) || isDigit(template[j])) {
+					j++
+				}
+			}
+			write(re.Expand(nil, []byte(template[i:j]), src, match))
+			i = j - 1
+		default:
+			write([]byte{c})
+		}
+	}
+	return dst
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
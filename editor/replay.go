@@ -0,0 +1,106 @@
+package editor
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/icholy/kilo/internal/term"
+	"github.com/icholy/kilo/internal/vt"
+)
+
+// ParseKeys parses a key file: characters are typed as themselves, and
+// other keys are named in angle brackets the way bind names them, e.g.
+//
+//	ihello<enter>
+//	<ctrl-s><alt-x>set list<enter>
+//
+// <lt> types a <. Line breaks are ignored, so a key file can be split
+// into lines, and <enter> types Enter.
+func ParseKeys(s string) ([]int, error) {
+	var keys []int
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\n', '\r':
+		case '<':
+			end := strings.IndexByte(s[i:], '>')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated key name: %.20q", s[i:])
+			}
+			name := s[i+1 : i+end]
+			k, ok := parseKey(name)
+			if !ok {
+				return nil, fmt.Errorf("unknown key: <%s>", name)
+			}
+			keys = append(keys, k)
+			i += end
+		default:
+			keys = append(keys, int(c))
+		}
+	}
+	return keys, nil
+}
+
+// formatKey writes a key the way ParseKeys parses it. A line break
+// follows Enter, to keep the lines of a recorded key file short.
+func formatKey(k int) string {
+	switch {
+	case k == '\r':
+		return "<enter>\n"
+	case k >= ' ' && k != '<' && k < 256 && k != KeyBackspace:
+		return string([]byte{byte(k)})
+	}
+	return "<" + keyName(k) + ">"
+}
+
+// Record writes the keys typed from now on to a key file which
+// ParseKeys can read back, so that a session can be replayed. Pasted
+// text is recorded as typed, and mouse events aren't recorded.
+func (e *Editor) Record(name string) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	e.recording = f
+	return nil
+}
+
+// recordKey adds a key read from the terminal to the recording.
+func (e *Editor) recordKey(c int) {
+	switch {
+	case e.recording == nil, c == term.MouseEvent, c == term.ResizeEvent, c == term.UnknownKey:
+		return
+	case c == term.PasteEvent:
+		for _, b := range e.term.Paste {
+			if b == '\n' {
+				b = '\r'
+			}
+			io.WriteString(e.recording, formatKey(int(b)))
+		}
+		return
+	case c == term.TextEvent:
+		for _, b := range e.term.Text {
+			io.WriteString(e.recording, formatKey(int(b)))
+		}
+		return
+	}
+	io.WriteString(e.recording, formatKey(c))
+}
+
+// DumpScreen writes the screen as plain text, one line per row without
+// the trailing blanks, the way it looks after the keys typed so far.
+func (e *Editor) DumpScreen(w io.Writer) error {
+	var b bytes.Buffer
+	e.render(&b)
+	screen := vt.New(e.totalrows, e.screencols)
+	screen.Write(b.Bytes())
+	var out bytes.Buffer
+	for y := 0; y < screen.Rows; y++ {
+		out.WriteString(screen.Text(y))
+		out.WriteByte('\n')
+	}
+	_, err := w.Write(out.Bytes())
+	return err
+}
@@ -0,0 +1,30 @@
+package editor
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/icholy/kilo/internal/term"
+)
+
+func TestRecordText(t *testing.T) {
+	e := newTestEditor(t)
+	name := filepath.Join(t.TempDir(), "keys")
+	if err := e.Record(name); err != nil {
+		t.Fatal(err)
+	}
+	e.term = &term.Terminal{Text: []byte("é<x>")}
+	e.recordKey(term.TextEvent)
+	e.recording.Sync()
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := ParseKeys(string(data))
+	want := []int{0xc3, 0xa9, '<', 'x', '>'}
+	if err != nil || !reflect.DeepEqual(keys, want) {
+		t.Errorf("ParseKeys(%q) = %v, %v, want %v", data, keys, err, want)
+	}
+}
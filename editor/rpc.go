@@ -0,0 +1,220 @@
+package editor
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+// rpcCall is a request from an RPC client waiting to be handled by
+// the editor's goroutine.
+type rpcCall struct {
+	msg   *lspMessage
+	reply chan any
+}
+
+// Listen serves a JSON-RPC 2.0 API on a unix socket, one message per
+// line. The methods are the ones plugins can call, along with "open"
+// and "setText", see Plugin. Requests are handled while the editor
+// waits for input.
+func (e *Editor) Listen(path string) error {
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return fmt.Errorf("%s: another editor is listening", path)
+	}
+	os.Remove(path)
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		l.Close()
+		return err
+	}
+	e.listener = l
+	e.calls = make(chan rpcCall, 16)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go e.serveConn(conn)
+		}
+	}()
+	return nil
+}
+
+// ErrNotListening is returned by RemoteCall when there's no editor
+// listening on the socket.
+var ErrNotListening = errors.New("no editor is listening")
+
+// RemoteCall sends a request to the editor listening on the socket and
+// decodes the result into result, which may be nil.
+func RemoteCall(socket, method string, params, result any) error {
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return fmt.Errorf("%s: %w", socket, ErrNotListening)
+	}
+	defer conn.Close()
+	req := map[string]any{"jsonrpc": "2.0", "id": 1, "method": method, "params": params}
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return err
+	}
+	var msg lspMessage
+	if err := json.NewDecoder(conn).Decode(&msg); err != nil {
+		return err
+	}
+	if msg.Error != nil {
+		return msg.Error
+	}
+	if result == nil || len(msg.Result) == 0 {
+		return nil
+	}
+	return json.Unmarshal(msg.Result, result)
+}
+
+// serveConn passes the requests on a connection to the editor and
+// writes back the replies.
+func (e *Editor) serveConn(conn net.Conn) {
+	defer conn.Close()
+	sc := bufio.NewScanner(conn)
+	sc.Buffer(nil, 64<<20)
+	enc := json.NewEncoder(conn)
+	for sc.Scan() {
+		var msg lspMessage
+		if err := json.Unmarshal(sc.Bytes(), &msg); err != nil {
+			enc.Encode(map[string]any{"jsonrpc": "2.0", "id": nil, "error": lspError{Code: -32700, Message: err.Error()}})
+			continue
+		}
+		call := rpcCall{msg: &msg, reply: make(chan any, 1)}
+		e.calls <- call
+		e.wake()
+		if reply := <-call.reply; reply != nil {
+			enc.Encode(reply)
+		}
+	}
+}
+
+// pollRPC handles the waiting RPC requests without blocking, and
+// reports whether there were any.
+func (e *Editor) pollRPC() bool {
+	var handled bool
+	for {
+		select {
+		case call := <-e.calls:
+			handled = true
+			result, err := e.handleRequest(call.msg.Method, call.msg.Params)
+			if call.msg.ID == nil {
+				call.reply <- nil
+				continue
+			}
+			if err != nil {
+				call.reply <- map[string]any{"jsonrpc": "2.0", "id": call.msg.ID, "error": lspError{Code: -32602, Message: err.Error()}}
+			} else {
+				call.reply <- map[string]any{"jsonrpc": "2.0", "id": call.msg.ID, "result": result}
+			}
+		default:
+			return handled
+		}
+	}
+}
+
+// pollRequests handles the requests from plugins and RPC clients,
+// and redraws the screen if there were any.
+func (e *Editor) pollRequests() {
+	plugins := e.pollPlugins()
+	if e.pollRPC() || plugins {
+		e.refreshScreen()
+	}
+}
+
+// handleRequest runs a request from a plugin or an RPC client.
+func (e *Editor) handleRequest(method string, params json.RawMessage) (any, error) {
+	decode := func(v any) error {
+		if len(params) == 0 {
+			return fmt.Errorf("%s: missing params", method)
+		}
+		return json.Unmarshal(params, v)
+	}
+	switch method {
+	case "open":
+		var args struct {
+			Filename string `json:"filename"`
+			Line     int    `json:"line"`
+			Col      int    `json:"col"`
+		}
+		if err := decode(&args); err != nil {
+			return nil, err
+		}
+		if !e.switchFile(args.Filename) {
+			return nil, errors.New(e.status)
+		}
+		if args.Line > 0 {
+			e.Goto(args.Line, args.Col)
+		}
+	case "getText":
+		return map[string]any{"text": string(e.rowsToBytes())}, nil
+	case "setText":
+		var args struct {
+			Text string `json:"text"`
+		}
+		if err := decode(&args); err != nil {
+			return nil, err
+		}
+		e.replaceRange(0, 0, e.buf.NumRows(), 0, []byte(strings.TrimSuffix(args.Text, "\n")))
+		e.moveTo(e.cx, e.cy)
+	case "getCursor":
+		return pluginPosition{Line: e.cy, Col: e.cx}, nil
+	case "setCursor":
+		var pos pluginPosition
+		if err := decode(&pos); err != nil {
+			return nil, err
+		}
+		e.moveTo(pos.Col, pos.Line)
+	case "insert":
+		var args struct {
+			Text string `json:"text"`
+		}
+		if err := decode(&args); err != nil {
+			return nil, err
+		}
+		e.cy, e.cx = e.replaceRange(e.cy, e.cx, e.cy, e.cx, []byte(args.Text))
+	case "replace":
+		var args struct {
+			Start pluginPosition `json:"start"`
+			End   pluginPosition `json:"end"`
+			Text  string         `json:"text"`
+		}
+		if err := decode(&args); err != nil {
+			return nil, err
+		}
+		if err := e.checkRange(args.Start, args.End); err != nil {
+			return nil, err
+		}
+		e.cy, e.cx = e.replaceRange(args.Start.Line, args.Start.Col, args.End.Line, args.End.Col, []byte(args.Text))
+	case "status":
+		var args struct {
+			Message string `json:"message"`
+		}
+		if err := decode(&args); err != nil {
+			return nil, err
+		}
+		e.setStatus("%s", args.Message)
+	case "command":
+		var args struct {
+			Line string `json:"line"`
+		}
+		if err := decode(&args); err != nil {
+			return nil, err
+		}
+		e.runCommand(args.Line)
+	default:
+		return nil, fmt.Errorf("unknown method: %s", method)
+	}
+	return nil, nil
+}
@@ -0,0 +1,35 @@
+package editor
+
+import "strings"
+
+// runFile implements the run command, which saves the file and runs it
+// in the terminal pane with the command given as the argument or the
+// filetype's, e.g. go run %f. The exit status is shown in the status
+// bar.
+func (e *Editor) runFile(args string) {
+	command := strings.TrimSpace(args)
+	if command == "" && e.buf.Syntax != nil {
+		command = e.buf.Syntax.Run
+	}
+	if command == "" {
+		e.fail("run: no run command for this file type")
+		return
+	}
+	if e.dirty || e.filename == "" {
+		e.save()
+		if e.dirty || e.filename == "" {
+			return
+		}
+	}
+	if isRemote(e.filename) {
+		e.fail("run: %s is a remote file", e.filename)
+		return
+	}
+	command = strings.ReplaceAll(command, "%f", shellQuote(e.filename))
+	e.openTerminal(command)
+	if p := e.terminal; p != nil {
+		p.mu.Lock()
+		p.report = true
+		p.mu.Unlock()
+	}
+}
@@ -0,0 +1,177 @@
+package editor
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/icholy/kilo/internal/vt"
+)
+
+// defaultAnnounce shows the announcements of the screen reader mode in
+// the message bar.
+const defaultAnnounce = "status"
+
+// setScreenReader turns the screen reader mode on or off. Turning it on
+// turns off the decorations which change as the cursor moves, so that
+// a screen reader only hears about the text.
+func (e *Editor) setScreenReader() {
+	e.lastscreen = nil
+	if e.screenreader {
+		e.scrollbar = false
+		e.cursorline = false
+		e.cursorcolumn = false
+		e.occurrences = false
+		e.occword = ""
+	}
+}
+
+// drawChanged returns the escape sequences which draw the rows of a
+// frame which differ from the last frame drawn, instead of every row,
+// so that a screen reader following the changes isn't made to read the
+// whole screen on every key.
+func (e *Editor) drawChanged(frame []byte) []byte {
+	screen := vt.New(e.totalrows, e.screencols)
+	screen.Write(frame)
+	last := e.lastscreen
+	if last != nil && (last.Rows != screen.Rows || last.Cols != screen.Cols) {
+		last = nil
+	}
+	var b bytes.Buffer
+	b.WriteString("\x1b[?25l")
+	for y := 0; y < screen.Rows; y++ {
+		if last != nil && screen.SameRow(last, y) {
+			continue
+		}
+		fmt.Fprintf(&b, "\x1b[%d;1H", y+1)
+		screen.DrawRow(&b, y)
+	}
+	fmt.Fprintf(&b, "\x1b[%d;%dH", screen.Y+1, screen.X+1)
+	if !screen.HideCursor {
+		b.WriteString("\x1b[?25h")
+	}
+	e.lastscreen = screen
+	return b.Bytes()
+}
+
+// announceMove announces where a key moved the cursor: the line when it
+// moved to another line, the word when it moved by words, and the
+// character otherwise. Messages shown by the key are announced too.
+// Edits aren't announced, screen readers echo the keys typed.
+func (e *Editor) announceMove(c, cx, cy, changes int, statustime time.Time) {
+	if !e.screenreader || e.closed || e.termfocus {
+		return
+	}
+	var text string
+	switch {
+	case e.buf.Changes != changes:
+	case e.cy != cy:
+		text = e.describeLine(e.cy)
+	case e.cx != cx && c&(ModCtrl|ModAlt) != 0:
+		text = e.cursorWord()
+		if text == "" {
+			text = e.describeChar()
+		}
+	case e.cx != cx:
+		text = e.describeChar()
+	}
+	if e.status != "" && e.statustime != statustime {
+		if e.announceChannel() == defaultAnnounce {
+			// the message is in the message bar already
+			return
+		}
+		text = strings.TrimSpace(text + " " + e.status)
+	}
+	if text != "" {
+		e.announce(text)
+	}
+}
+
+// describeLine returns the text of a line, or says it's blank.
+func (e *Editor) describeLine(y int) string {
+	if y >= e.buf.NumRows() {
+		return "end of file"
+	}
+	line := strings.TrimSpace(string(e.buf.Rows[y].Chars))
+	if line == "" {
+		return "blank"
+	}
+	return line
+}
+
+// describeChar returns the character under the cursor, with names for
+// the ones which aren't spoken.
+func (e *Editor) describeChar() string {
+	if e.cy >= e.buf.NumRows() {
+		return "end of file"
+	}
+	chars := e.buf.Rows[e.cy].Chars
+	if e.cx >= len(chars) {
+		return "end of line"
+	}
+	r, _ := utf8.DecodeRune(chars[e.cx:])
+	switch {
+	case r == ' ':
+		return "space"
+	case r == '\t':
+		return "tab"
+	case unicode.IsUpper(r):
+		return "cap " + string(r)
+	case !unicode.IsGraphic(r):
+		return fmt.Sprintf("U+%04X", r)
+	}
+	return string(r)
+}
+
+// announceChannel returns the announce setting, or the message bar when
+// it's empty.
+func (e *Editor) announceChannel() string {
+	channel := strings.TrimSpace(e.announcer)
+	if channel == "" {
+		return defaultAnnounce
+	}
+	return channel
+}
+
+// announce sends text to the channel set by the announce setting: the
+// message bar, a file the lines are appended to when it starts with >,
+// or a command such as espeak which is given the text on its standard
+// input. A new announcement interrupts the command speaking the last one.
+func (e *Editor) announce(text string) {
+	e.log(LogDebug, "announce", "text", text)
+	switch channel := e.announceChannel(); {
+	case channel == defaultAnnounce:
+		e.showStatus("%s", text)
+	case strings.HasPrefix(channel, ">"):
+		name := strings.TrimSpace(channel[1:])
+		f, err := os.OpenFile(name, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+		if err != nil {
+			e.showStatus("announce: %v", err)
+			return
+		}
+		defer f.Close()
+		fmt.Fprintln(f, text)
+	default:
+		if e.speech != nil {
+			// the command runs in a process group of its own, which has
+			// the same id as the command
+			syscall.Kill(-e.speech.Process.Pid, syscall.SIGKILL)
+		}
+		cmd := exec.Command("sh", "-c", channel)
+		cmd.Stdin = strings.NewReader(text + "\n")
+		cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+		if err := cmd.Start(); err != nil {
+			e.speech = nil
+			e.showStatus("announce: %v", err)
+			return
+		}
+		e.speech = cmd
+		go cmd.Wait()
+	}
+}
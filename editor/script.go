@@ -0,0 +1,152 @@
+package editor
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// maxScriptDepth limits how deeply script commands can call each other.
+const maxScriptDepth = 50
+
+// runScript runs script lines. A def ... end block adds a command
+// which runs the lines in between, with $* replaced by its arguments:
+//
+//	def header
+//	  goto 1
+//	  insert // $*\n
+//	end
+//	bind ctrl-t header Copyright
+//	on open *.go find package
+func (e *Editor) runScript(name string, lines []string) error {
+	if e.scriptdepth >= maxScriptDepth {
+		return fmt.Errorf("%s: commands nested too deeply", name)
+	}
+	e.scriptdepth++
+	defer func() { e.scriptdepth-- }()
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		cmd, args, _ := strings.Cut(line, " ")
+		args = strings.TrimSpace(args)
+		if cmd == "def" {
+			start := i
+			for i++; i < len(lines) && strings.TrimSpace(lines[i]) != "end"; i++ {
+			}
+			if i == len(lines) {
+				return fmt.Errorf("%s:%d: def without end", name, start+1)
+			}
+			if args == "" || strings.ContainsAny(args, " \t") {
+				return fmt.Errorf("%s:%d: invalid command name: %q", name, start+1, args)
+			}
+			e.define(args, lines[start+1:i])
+			continue
+		}
+		c, ok := e.findCommand(cmd)
+		if !ok {
+			return fmt.Errorf("%s:%d: unknown command: %s", name, i+1, cmd)
+		}
+		// the script stops at the first command which fails
+		e.failure = nil
+		c.fn(e, args)
+		if err := e.failure; err != nil {
+			e.failure = nil
+			return fmt.Errorf("%s:%d: %w", name, i+1, err)
+		}
+	}
+	return nil
+}
+
+// define adds a command which runs the body.
+func (e *Editor) define(name string, body []string) {
+	e.addCommand(Command{
+		name: name,
+		help: "user command",
+		fn: func(e *Editor, args string) {
+			lines := make([]string, len(body))
+			for i, line := range body {
+				lines[i] = strings.ReplaceAll(line, "$*", args)
+			}
+			if err := e.runScript(name, lines); err != nil {
+				e.fail("%v", err)
+			}
+		},
+	})
+}
+
+// addCommand adds a command, replacing any existing command defined by
+// a script or plugin with the same name.
+func (e *Editor) addCommand(c Command) {
+	for i, uc := range e.usercmds {
+		if uc.name == c.name {
+			e.usercmds[i] = c
+			return
+		}
+	}
+	e.usercmds = append(e.usercmds, c)
+}
+
+// binding is what a key or hook runs: a command line, or a function
+// defined by a Lua script.
+type binding struct {
+	command string
+	fn      func()
+}
+
+// runBinding runs the command or calls the function of a binding.
+func (e *Editor) runBinding(b binding) {
+	if b.fn != nil {
+		b.fn()
+		return
+	}
+	e.runCommand(b.command)
+}
+
+// Hook is a command which runs when a matching file is opened or saved.
+type Hook struct {
+	event   string
+	pattern string
+	action  binding
+}
+
+// addHook parses "<event> <pattern> <command>". The pattern is matched
+// against the base name of the file.
+func (e *Editor) addHook(args string) {
+	fields := strings.SplitN(args, " ", 3)
+	if len(fields) != 3 || fields[0] != "open" && fields[0] != "save" {
+		e.fail("on: expected open|save <pattern> <command>")
+		return
+	}
+	if _, err := filepath.Match(fields[1], ""); err != nil {
+		e.fail("on: %v", err)
+		return
+	}
+	e.hooks = append(e.hooks, Hook{event: fields[0], pattern: fields[1], action: binding{command: strings.TrimSpace(fields[2])}})
+}
+
+// runHooks runs the commands hooked to the event for the current file.
+func (e *Editor) runHooks(event string) {
+	base := filepath.Base(e.filename)
+	for _, h := range e.hooks {
+		if ok, _ := filepath.Match(h.pattern, base); ok && h.event == event {
+			e.runBinding(h.action)
+		}
+	}
+}
+
+// bind parses "<key> <command>" and runs the command whenever the key
+// is pressed.
+func (e *Editor) bind(args string) {
+	name, command, _ := strings.Cut(args, " ")
+	k, ok := parseKey(name)
+	if !ok || strings.TrimSpace(command) == "" {
+		e.fail("bind: expected <key> <command>, e.g. ctrl-t or alt-x or f6")
+		return
+	}
+	if e.keymap == nil {
+		e.keymap = map[int]binding{}
+	}
+	e.keymap[k] = binding{command: strings.TrimSpace(command)}
+}
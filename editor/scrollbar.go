@@ -0,0 +1,38 @@
+package editor
+
+import (
+	"bytes"
+
+	"github.com/icholy/kilo/internal/ui"
+)
+
+// scrollbarRange returns the screen rows covered by the scrollbar
+// thumb. There's no scrollbar when it's disabled or the whole file
+// fits on the screen.
+func (e *Editor) scrollbarRange() (start, end int, ok bool) {
+	if !e.scrollbar {
+		return 0, 0, false
+	}
+	return ui.Scrollbar(e.rowoff, e.buf.NumRows(), e.screenrows)
+}
+
+// textCols returns the number of columns available for text.
+func (e *Editor) textCols() int {
+	cols := e.screencols - e.gutterCols() - e.outlineCols()
+	if _, _, ok := e.scrollbarRange(); ok {
+		cols--
+	}
+	return cols
+}
+
+// drawScrollbar draws the scrollbar thumb in the rightmost column of
+// the buffer, left of the outline sidebar.
+func (e *Editor) drawScrollbar(b *bytes.Buffer) {
+	if start, end, ok := e.scrollbarRange(); ok {
+		ui.DrawScrollbar(b, start, end, e.screencols-e.outlineCols()-1)
+	}
+}
+
+func (e *Editor) toggleScrollbar() {
+	e.scrollbar = !e.scrollbar
+}
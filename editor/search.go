@@ -0,0 +1,248 @@
+package editor
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/icholy/kilo/internal/buffer"
+	"github.com/icholy/kilo/internal/term"
+	"golang.org/x/exp/slices"
+)
+
+// maxSearchMatches caps the matches collected by a search, so searching
+// a huge file for common text stays responsive.
+const maxSearchMatches = 100000
+
+// matchCount formats the number of matches, with a + when there were
+// more than were collected.
+func matchCount(n int, capped bool) string {
+	if capped {
+		return strconv.Itoa(n) + "+"
+	}
+	return strconv.Itoa(n)
+}
+
+// matchRows returns the rows the matches are on.
+func matchRows(matches []SearchMatch) []int {
+	rows := []int{}
+	for _, m := range matches {
+		if len(rows) == 0 || rows[len(rows)-1] != m.cy {
+			rows = append(rows, m.cy)
+		}
+	}
+	return rows
+}
+
+// markMatches highlights the matches of the search in progress on row y
+// while it's drawn. Only the rows on the screen are marked, and the
+// returned function puts back their highlights afterwards.
+func (e *Editor) markMatches(row *buffer.Row, y int) (unmark func()) {
+	i := sort.Search(len(e.searchhl), func(i int) bool { return e.searchhl[i].cy >= y })
+	if i == len(e.searchhl) || e.searchhl[i].cy != y {
+		return func() {}
+	}
+	saved := slices.Clone(row.HL)
+	for ; i < len(e.searchhl) && e.searchhl[i].cy == y; i++ {
+		m := e.searchhl[i]
+		start, end := row.CxToRx(m.cx), row.CxToRx(m.cx+e.searchlen)
+		for x := start; x < end && x < len(row.HL); x++ {
+			row.HL[x] = buffer.HighlightMatch
+		}
+	}
+	return func() { copy(row.HL, saved) }
+}
+
+type SearchMatch struct {
+	cx, cy int
+}
+
+// searchMatches returns the positions of the occurrences of query in
+// the given rows, or in every row if rows is nil. At most
+// maxSearchMatches are collected, from row start on and wrapping around
+// the end of the buffer, so the ones after the cursor are kept. capped
+// reports whether there were more.
+func (e *Editor) searchMatches(query string, rows []int, start int) (matches []SearchMatch, capped bool) {
+	if query == "" {
+		return nil, false
+	}
+	f := buffer.NewFinder(query)
+	n, first := e.buf.NumRows(), start
+	if rows != nil {
+		n, first = len(rows), sort.SearchInts(rows, start)
+	}
+	// the matches above start, which come first
+	var before []SearchMatch
+	for k := 0; k < n; k++ {
+		y := (first + k) % n
+		if rows != nil {
+			y = rows[y]
+		}
+		chars := e.buf.Rows[y].Chars
+		for off := 0; off < len(chars); {
+			i := f.Index(chars[off:])
+			if i < 0 {
+				break
+			}
+			m := SearchMatch{cx: off + i, cy: y}
+			if y < start {
+				before = append(before, m)
+			} else {
+				matches = append(matches, m)
+			}
+			if len(before)+len(matches) == maxSearchMatches {
+				return append(before, matches...), true
+			}
+			off += i + 1
+		}
+	}
+	return append(before, matches...), false
+}
+
+// nextMatch returns the index of the first match at or after (cx, cy),
+// or when dir < 0 the last match before it. It wraps around the ends of
+// the buffer, which is reported by wrapped.
+func nextMatch(matches []SearchMatch, cx, cy, dir int) (idx int, wrapped bool) {
+	if dir > 0 {
+		for i, m := range matches {
+			if m.cy > cy || m.cy == cy && m.cx >= cx {
+				return i, false
+			}
+		}
+		return 0, true
+	}
+	for i := len(matches) - 1; i >= 0; i-- {
+		if m := matches[i]; m.cy < cy || m.cy == cy && m.cx < cx {
+			return i, false
+		}
+	}
+	return len(matches) - 1, true
+}
+
+// matchesWithin returns the matches of a query of length n which are
+// inside the bounds of a selection.
+func matchesWithin(matches []SearchMatch, n, y0, x0, y1, x1 int) []SearchMatch {
+	var within []SearchMatch
+	for _, m := range matches {
+		if (m.cy > y0 || m.cy == y0 && m.cx >= x0) && (m.cy < y1 || m.cy == y1 && m.cx+n <= x1) {
+			within = append(within, m)
+		}
+	}
+	return within
+}
+
+// find searches incrementally, forward from the cursor (dir > 0) or
+// backward from it (dir < 0). When text is selected, only the selection
+// is searched.
+func (e *Editor) find(dir int) {
+	// save the cursor state in case we cancel
+	cx, cy := e.cx, e.cy
+	rowoff, coloff := e.rowoff, e.coloff
+	y0, x0, y1, x1, within := e.selectionBounds()
+
+	// the search matches
+	var matchidx int
+	var matches []SearchMatch
+	var wrapped, capped bool
+	// searched is the query the matches are for
+	var searched string
+
+	query, ok := e.prompt("Search:", func(input string, c int) {
+		switch c {
+		case '\r', '\x1b':
+			return
+		case term.F3, term.ControlKey('n'):
+			matchidx++
+			wrapped = false
+		case term.ShiftModifier | term.F3, term.ControlKey('p'):
+			matchidx--
+			wrapped = false
+		case term.ArrowLeft, term.ArrowRight, term.HomeKey, term.EndKey, term.ControlKey('a'), term.ControlKey('e'):
+			// moving the cursor doesn't change the query
+			return
+		default:
+			// a longer query can only match the rows the shorter one did
+			var rows []int
+			if searched != "" && strings.HasPrefix(input, searched) && !capped {
+				rows = matchRows(matches)
+			}
+			matches, capped = e.searchMatches(input, rows, cy)
+			searched = input
+			if within {
+				matches = matchesWithin(matches, len(input), y0, x0, y1, x1)
+			}
+			e.searchhl, e.searchlen = matches, len(input)
+			// start over from where the search began
+			matchidx, wrapped = nextMatch(matches, cx, cy, dir)
+		}
+
+		e.promptinfo = ""
+		if len(matches) > 0 {
+			// fix the match index
+			if matchidx < 0 {
+				matchidx += len(matches)
+			} else {
+				matchidx = matchidx % len(matches)
+			}
+			m := matches[matchidx]
+			e.cy = m.cy
+			e.cx = m.cx
+			e.rowoff = e.buf.NumRows()
+			e.promptinfo = fmt.Sprintf("match %d/%s", matchidx+1, matchCount(len(matches), capped))
+			if wrapped {
+				e.promptinfo += ", wrapped"
+			}
+		} else if input != "" {
+			e.promptinfo = "no matches"
+		}
+		if within && e.promptinfo != "" {
+			e.promptinfo += ", in selection"
+		} else if within {
+			e.promptinfo = "in selection"
+		}
+	})
+	e.searchhl = nil
+	// restore cursor if user hit escape
+	if !ok {
+		e.cx = cx
+		e.cy = cy
+		e.rowoff = rowoff
+		e.coloff = coloff
+	} else {
+		e.searchquery = query
+		if cx != e.cx || cy != e.cy {
+			e.pushJump(Location{filename: e.filename, cx: cx, cy: cy})
+		}
+	}
+}
+
+// findNext moves to the next (dir > 0) or previous (dir < 0)
+// match of the last search, wrapping around the ends of the buffer.
+func (e *Editor) findNext(dir int) {
+	if e.searchquery == "" {
+		e.fail("no previous search")
+		return
+	}
+	matches, capped := e.searchMatches(e.searchquery, nil, e.cy)
+	if len(matches) == 0 {
+		e.fail("pattern not found: %s", e.searchquery)
+		return
+	}
+	// skip the match the cursor is on
+	cx := e.cx
+	if dir > 0 {
+		cx++
+	}
+	idx, wrapped := nextMatch(matches, cx, e.cy, dir)
+	m := matches[idx]
+	if m.cy != e.cy {
+		e.pushJump(e.location())
+	}
+	e.cx, e.cy = m.cx, m.cy
+	if wrapped {
+		e.setStatus("match %d/%s (search wrapped)", idx+1, matchCount(len(matches), capped))
+	} else {
+		e.setStatus("match %d/%s", idx+1, matchCount(len(matches), capped))
+	}
+}
@@ -0,0 +1,102 @@
+package editor
+
+import (
+	"reflect"
+	"testing"
+)
+
+// newTestEditor returns an editor with the lines in its buffer, which
+// keeps its state in a temporary directory.
+func newTestEditor(t *testing.T, lines ...string) *Editor {
+	t.Helper()
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	e := New(Options{})
+	t.Cleanup(e.Close)
+	for _, line := range lines {
+		e.buf.InsertRow(e.buf.NumRows(), []byte(line))
+	}
+	return e
+}
+
+func TestSearchMatches(t *testing.T) {
+	e := newTestEditor(t, "aa a", "b", "a ba", "aaa")
+	tests := []struct {
+		query string
+		rows  []int
+		start int
+		want  []SearchMatch
+	}{
+		{"", nil, 0, nil},
+		{"x", nil, 0, nil},
+		{"a", nil, 0, []SearchMatch{{0, 0}, {1, 0}, {3, 0}, {0, 2}, {3, 2}, {0, 3}, {1, 3}, {2, 3}}},
+		// overlapping matches are all found
+		{"aa", nil, 0, []SearchMatch{{0, 0}, {0, 3}, {1, 3}}},
+		{"ba", nil, 0, []SearchMatch{{2, 2}}},
+		// the rows before start come first
+		{"aa", nil, 2, []SearchMatch{{0, 0}, {0, 3}, {1, 3}}},
+		{"a", []int{1, 2}, 0, []SearchMatch{{0, 2}, {3, 2}}},
+	}
+	for _, tt := range tests {
+		got, capped := e.searchMatches(tt.query, tt.rows, tt.start)
+		if capped || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("searchMatches(%q, %v, %d) = %v, %v, want %v", tt.query, tt.rows, tt.start, got, capped, tt.want)
+		}
+	}
+}
+
+func TestSearchMatchesCapped(t *testing.T) {
+	e := newTestEditor(t)
+	for i := 0; i < maxSearchMatches/2+1; i++ {
+		e.buf.InsertRow(e.buf.NumRows(), []byte("xx"))
+	}
+	start := e.buf.NumRows() - 1
+	matches, capped := e.searchMatches("x", nil, start)
+	if !capped || len(matches) != maxSearchMatches {
+		t.Fatalf("got %d matches, capped %v, want %d, true", len(matches), capped, maxSearchMatches)
+	}
+	// the matches from start on are kept, and the ones before it which
+	// fit, in order
+	if first, last := matches[0], matches[len(matches)-1]; first != (SearchMatch{0, 0}) || last != (SearchMatch{1, start}) {
+		t.Errorf("matches run from %v to %v", first, last)
+	}
+}
+
+func TestNextMatch(t *testing.T) {
+	matches := []SearchMatch{{2, 0}, {0, 1}, {5, 1}, {1, 3}}
+	tests := []struct {
+		cx, cy, dir int
+		idx         int
+		wrapped     bool
+	}{
+		{0, 0, 1, 0, false},
+		{2, 0, 1, 0, false},
+		{3, 0, 1, 1, false},
+		{6, 1, 1, 3, false},
+		{2, 3, 1, 0, true},
+		{0, 4, 1, 0, true},
+		{0, 3, -1, 2, false},
+		{5, 1, -1, 1, false},
+		{2, 0, -1, 3, true},
+	}
+	for _, tt := range tests {
+		idx, wrapped := nextMatch(matches, tt.cx, tt.cy, tt.dir)
+		if idx != tt.idx || wrapped != tt.wrapped {
+			t.Errorf("nextMatch(%d, %d, %d) = %d, %v, want %d, %v", tt.cx, tt.cy, tt.dir, idx, wrapped, tt.idx, tt.wrapped)
+		}
+	}
+}
+
+func TestMatchesWithin(t *testing.T) {
+	matches := []SearchMatch{{2, 0}, {0, 1}, {5, 1}, {1, 3}}
+	// a match has to end inside the selection
+	got := matchesWithin(matches, 2, 0, 3, 3, 2)
+	want := []SearchMatch{{0, 1}, {5, 1}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("matchesWithin = %v, want %v", got, want)
+	}
+	got = matchesWithin(matches, 2, 0, 2, 3, 3)
+	want = matches
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("matchesWithin = %v, want %v", got, want)
+	}
+}
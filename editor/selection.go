@@ -0,0 +1,43 @@
+package editor
+
+// Selection is anchored at (cx, cy) and extends to the cursor.
+type Selection struct {
+	active bool
+	cx, cy int
+}
+
+// selectionBounds returns the ordered bounds of the selection.
+func (e *Editor) selectionBounds() (y0, x0, y1, x1 int, ok bool) {
+	s := e.selection
+	if !s.active || (s.cx == e.cx && s.cy == e.cy) {
+		return 0, 0, 0, 0, false
+	}
+	y0, x0, y1, x1 = s.cy, s.cx, e.cy, e.cx
+	if y0 > y1 || (y0 == y1 && x0 > x1) {
+		y0, x0, y1, x1 = y1, x1, y0, x0
+	}
+	return y0, x0, y1, x1, true
+}
+
+// selectedRows returns the rows covered by the selection, or the
+// cursor row if nothing is selected. A selection ending at the start of
+// a row doesn't include that row.
+func (e *Editor) selectedRows() (start, end int) {
+	y0, _, y1, x1, ok := e.selectionBounds()
+	if !ok {
+		return e.cy, e.cy
+	}
+	if x1 == 0 && y1 > y0 {
+		y1--
+	}
+	return y0, y1
+}
+
+// extendSelection moves the cursor while keeping the selection
+// anchored where it started.
+func (e *Editor) extendSelection(c int) {
+	if !e.selection.active {
+		e.selection = Selection{active: true, cx: e.cx, cy: e.cy}
+	}
+	e.moveCursor(c)
+}
@@ -0,0 +1,79 @@
+package editor
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseSequence parses the arguments of the sequence command: the first
+// number, the step, and a printf format, which can be quoted to keep
+// its blanks, e.g. 1 1 "%d. ".
+func parseSequence(args string) (start, step int, format string, err error) {
+	start, step, format = 1, 1, "%d"
+	args = strings.TrimLeft(args, " ")
+	for _, n := range []*int{&start, &step} {
+		arg, rest, _ := strings.Cut(args, " ")
+		if arg == "" {
+			break
+		}
+		v, err := strconv.Atoi(arg)
+		if err != nil {
+			break
+		}
+		*n = v
+		args = strings.TrimLeft(rest, " ")
+	}
+	if strings.HasPrefix(args, `"`) {
+		s, err := strconv.Unquote(args)
+		if err != nil {
+			return 0, 0, "", fmt.Errorf("invalid format: %s", args)
+		}
+		args = s
+	}
+	if args != "" {
+		format = args
+	}
+	if s := fmt.Sprintf(format, 0); strings.Contains(s, "%!") {
+		return 0, 0, "", fmt.Errorf("invalid format: %q", format)
+	}
+	return start, step, format, nil
+}
+
+// insertSequence inserts an incrementing number on each of the selected
+// lines, in the column where the selection starts. Lines which are too
+// short are padded with spaces.
+func (e *Editor) insertSequence(args string) {
+	start, step, format, err := parseSequence(args)
+	if err != nil {
+		e.fail("sequence: %v", err)
+		return
+	}
+	y0, y1 := e.selectedRows()
+	x0 := e.cx
+	if sy, sx, _, _, ok := e.selectionBounds(); ok {
+		y0, x0 = sy, sx
+	}
+	if y0 >= e.buf.NumRows() {
+		// only the line past the end is selected, the number starts it
+		e.insertRow(e.buf.NumRows(), nil)
+	}
+	// the line past the end isn't numbered when the selection ends on it
+	y1 = clamp(y1, y0, e.buf.NumRows()-1)
+	rx := e.buf.Rows[y0].CxToRx(x0)
+	n := start
+	for y := y0; y <= y1; y++ {
+		row := e.buf.Rows[y]
+		text := fmt.Sprintf(format, n)
+		if width := row.CxToRx(row.Len()); width < rx {
+			text = strings.Repeat(" ", rx-width) + text
+		}
+		x := row.RxToCx(rx)
+		e.replaceRange(y, x, y, x, []byte(text))
+		if y == e.cy {
+			e.cx = x + len(text)
+		}
+		n += step
+	}
+	e.setStatus("inserted %d numbers", y1-y0+1)
+}
@@ -0,0 +1,285 @@
+package editor
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/icholy/kilo/internal/buffer"
+)
+
+// configCheckInterval is how often the config file is checked for
+// changes while waiting for input.
+const configCheckInterval = time.Second
+
+// setting is an option which can be changed while the editor runs.
+// The names are the same as the command line flags, where there's one.
+type setting struct {
+	name  string
+	alias string
+	value any // *bool, *int, *string, or *time.Duration
+	// apply is called after the value changes
+	apply func(e *Editor)
+}
+
+func (e *Editor) settings() []setting {
+	rehighlight := func(e *Editor) { e.buf.Rehighlight() }
+	return []setting{
+		{name: "announce", value: &e.announcer},
+		{name: "autopairs", value: &e.autopairs},
+		{name: "build", value: &e.buildcmd},
+		{name: "color-preview", value: &e.colorpreview},
+		{name: "cursorcolumn", value: &e.cursorcolumn},
+		{name: "cursorline", value: &e.cursorline},
+		{name: "dict", value: &e.dictpath, apply: func(e *Editor) {
+			e.opts.Dict = nil
+			if e.opts.Spell {
+				if err := e.loadDictionary(); err != nil {
+					e.opts.Spell = false
+					e.fail("spell: %v", err)
+				}
+				e.buf.Rehighlight()
+			}
+		}},
+		{name: "esc-timeout", value: &e.esctimeout, apply: func(e *Editor) {
+			if e.term != nil {
+				e.term.EscTimeout = e.esctimeout
+			}
+		}},
+		{name: "diagnostics", value: &e.diagnostics},
+		{name: "expandtab", alias: "et", value: &e.expandtab, apply: func(e *Editor) {
+			e.config.ExpandTab = e.expandtab
+		}},
+		{name: "format-on-save", value: &e.formatonsave},
+		{name: "formatter", value: &e.formatter},
+		{name: "highlight-word", value: &e.occurrences},
+		{name: "lang", value: &e.lang, apply: (*Editor).loadCatalog},
+		{name: "list", value: &e.opts.List, apply: rehighlight},
+		{name: "listspaces", value: &e.opts.ListSpaces, apply: rehighlight},
+		{name: "modelines", value: &e.modelines},
+		{name: "readonly", alias: "ro", value: &e.readonly},
+		{name: "screen-reader", value: &e.screenreader, apply: (*Editor).setScreenReader},
+		{name: "scrollbar", value: &e.scrollbar},
+		{name: "scrolloff", value: &e.scrolloff},
+		{name: "shiftwidth", alias: "sw", value: &e.shiftwidth, apply: func(e *Editor) {
+			if e.shiftwidth <= 0 {
+				e.shiftwidth = 4
+			}
+			e.config.ShiftWidth = e.shiftwidth
+		}},
+		{name: "spell", value: &e.opts.Spell, apply: func(e *Editor) {
+			if e.opts.Spell && e.opts.Dict == nil {
+				if err := e.loadDictionary(); err != nil {
+					e.opts.Spell = false
+					e.fail("spell: %v", err)
+				}
+			}
+			e.buf.Rehighlight()
+		}},
+		{name: "statusline", value: &e.statusline},
+		{name: "tabstop", alias: "ts", value: &e.opts.TabStop, apply: func(e *Editor) {
+			if e.opts.TabStop <= 0 {
+				e.opts.TabStop = buffer.TabStop
+			}
+			e.buf.Rerender()
+		}},
+		{name: "templates", value: &e.templates},
+		{name: "textwidth", alias: "tw", value: &e.textwidth},
+		{name: "timestamp-format", value: &e.timefmt, apply: func(e *Editor) {
+			if e.timefmt == "" {
+				e.timefmt = defaultTimestampFormat
+			}
+		}},
+		{name: "timing", value: &e.showtiming},
+		{name: "todo-keywords", value: &e.todokeywords, apply: (*Editor).setTodoKeywords},
+		{name: "warnings", value: &e.opts.Warn, apply: rehighlight},
+		{name: "wordchars", value: &e.wordchars},
+	}
+}
+
+func (e *Editor) findSetting(name string) (setting, bool) {
+	for _, s := range e.settings() {
+		if s.name == name || s.alias != "" && s.alias == name {
+			return s, true
+		}
+	}
+	return setting{}, false
+}
+
+// Set changes an option by the name of its command line flag.
+func (e *Editor) Set(name, value string) error {
+	s, ok := e.findSetting(name)
+	if !ok {
+		return fmt.Errorf("unknown option: %s", name)
+	}
+	var err error
+	switch p := s.value.(type) {
+	case *bool:
+		*p, err = strconv.ParseBool(value)
+	case *int:
+		*p, err = strconv.Atoi(value)
+	case *string:
+		*p = value
+	case *time.Duration:
+		*p, err = time.ParseDuration(value)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: invalid value %q", s.name, value)
+	}
+	if s.apply != nil {
+		s.apply(e)
+	}
+	return nil
+}
+
+// formatSetting returns the current value of the setting.
+func formatSetting(s setting) string {
+	switch p := s.value.(type) {
+	case *bool:
+		return strconv.FormatBool(*p)
+	case *int:
+		return strconv.Itoa(*p)
+	case *string:
+		return *p
+	case *time.Duration:
+		return p.String()
+	}
+	return ""
+}
+
+// set implements the set command:
+//
+//	set tabstop=4    change an option
+//	set list         turn an option on
+//	set nolist       turn an option off
+//	set list!        toggle an option
+//	set tabstop?     show an option
+//	set              show every option
+func (e *Editor) set(args string) {
+	if args == "" {
+		var lines []string
+		for _, s := range e.settings() {
+			lines = append(lines, fmt.Sprintf("%-15s %s", s.name, formatSetting(s)))
+		}
+		e.showPopup(lines)
+		return
+	}
+	for _, arg := range strings.Fields(args) {
+		if err := e.setArg(arg); err != nil {
+			e.fail("set: %v", err)
+			return
+		}
+	}
+}
+
+func (e *Editor) setArg(arg string) error {
+	if name, value, ok := strings.Cut(arg, "="); ok {
+		return e.Set(name, value)
+	}
+	name := strings.TrimRight(arg, "?!")
+	s, ok := e.findSetting(name)
+	if !ok && strings.HasPrefix(name, "no") {
+		if _, ok := e.findSetting(name[2:]); ok {
+			return e.Set(name[2:], "false")
+		}
+	}
+	if !ok {
+		return fmt.Errorf("unknown option: %s", name)
+	}
+	b, isbool := s.value.(*bool)
+	switch {
+	case strings.HasSuffix(arg, "!") && isbool:
+		return e.Set(name, strconv.FormatBool(!*b))
+	case strings.HasSuffix(arg, "?") || !isbool:
+		e.setStatus("%s=%s", s.name, formatSetting(s))
+		return nil
+	}
+	return e.Set(name, "true")
+}
+
+// LoadConfig applies the settings in a kilorc file, one per line:
+//
+//	# comment
+//	expandtab = true
+//	statusline = %f %m%=%l:%c
+//
+// Lines which set a command line flag that can't be changed while the
+// editor runs are ignored.
+func (e *Editor) LoadConfig(name string) error {
+	return e.loadConfig(name, nil)
+}
+
+// loadConfig applies a config file, and records the values the
+// settings had before in prev if it isn't nil.
+func (e *Editor) loadConfig(name string, prev map[string]string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	var lineno int
+	for sc.Scan() {
+		lineno++
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			key, value = line, "true"
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		s, ok := e.findSetting(key)
+		if !ok {
+			continue
+		}
+		if _, ok := prev[s.name]; !ok && prev != nil {
+			prev[s.name] = formatSetting(s)
+		}
+		if err := e.Set(key, value); err != nil {
+			return fmt.Errorf("%s:%d: %v", name, lineno, err)
+		}
+	}
+	return sc.Err()
+}
+
+// reloadConfig applies the config file again.
+func (e *Editor) reloadConfig() {
+	if e.configfile == "" {
+		e.fail("no config file")
+		return
+	}
+	if err := e.LoadConfig(e.configfile); err != nil {
+		e.fail("%v", err)
+		return
+	}
+	// the project's settings still take precedence
+	if e.projectcfg != "" {
+		e.projectprev = map[string]string{}
+		if err := e.loadConfig(e.projectcfg, e.projectprev); err != nil {
+			e.fail("%v", err)
+			return
+		}
+	}
+	e.setStatus("reloaded %s", e.configfile)
+}
+
+// watchConfig reloads the config file when it's modified, and reports
+// whether it was.
+func (e *Editor) watchConfig() bool {
+	if e.configfile == "" || time.Since(e.configcheck) < configCheckInterval {
+		return false
+	}
+	e.configcheck = time.Now()
+	fi, err := os.Stat(e.configfile)
+	if err != nil || fi.ModTime().Equal(e.configtime) {
+		return false
+	}
+	e.configtime = fi.ModTime()
+	e.reloadConfig()
+	return true
+}
@@ -0,0 +1,86 @@
+package editor
+
+import (
+	"errors"
+	"os"
+
+	"github.com/icholy/kilo/internal/buffer"
+)
+
+var dictPaths = []string{
+	"/usr/share/hunspell/en_US.dic",
+	"/usr/share/myspell/en_US.dic",
+	"/usr/share/dict/words",
+}
+
+func (e *Editor) loadDictionary() error {
+	paths := dictPaths
+	if e.dictpath != "" {
+		paths = []string{e.dictpath}
+	}
+	for _, path := range paths {
+		d, err := buffer.LoadDictionary(path)
+		if errors.Is(err, os.ErrNotExist) && e.dictpath == "" {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		e.opts.Dict = d
+		return nil
+	}
+	return errors.New("no dictionary found")
+}
+
+func (e *Editor) toggleSpell() {
+	if e.opts.Dict == nil {
+		if err := e.loadDictionary(); err != nil {
+			e.fail("spell: %v", err)
+			return
+		}
+	}
+	e.opts.Spell = !e.opts.Spell
+	e.buf.Rehighlight()
+	if e.opts.Spell {
+		e.setStatus("spell checking on")
+	} else {
+		e.setStatus("spell checking off")
+	}
+}
+
+// spellSuggest offers corrections for the word under the cursor.
+func (e *Editor) spellSuggest() {
+	if e.opts.Dict == nil {
+		if err := e.loadDictionary(); err != nil {
+			e.fail("spell: %v", err)
+			return
+		}
+	}
+	if e.cy >= e.buf.NumRows() {
+		return
+	}
+	chars := e.buf.Rows[e.cy].Chars
+	start, end := e.cx, e.cx
+	for start > 0 && buffer.IsWordChar(chars[start-1]) {
+		start--
+	}
+	for end < len(chars) && buffer.IsWordChar(chars[end]) {
+		end++
+	}
+	word := string(chars[start:end])
+	if word == "" {
+		return
+	}
+	if e.opts.Dict.Check(word) {
+		e.setStatus("%q is spelled correctly", word)
+		return
+	}
+	suggestions := e.opts.Dict.Suggest(word)
+	if len(suggestions) == 0 {
+		e.setStatus("no suggestions for %q", word)
+		return
+	}
+	if idx := e.menu(suggestions); idx >= 0 {
+		e.cy, e.cx = e.replaceRange(e.cy, start, e.cy, end, []byte(suggestions[idx]))
+	}
+}
@@ -0,0 +1,135 @@
+package editor
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const maxPositions = 1000
+
+// stateDir is where kilo keeps data that persists between sessions.
+func stateDir() string {
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return filepath.Join(dir, "kilo")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".local", "state", "kilo")
+}
+
+type filePosition struct {
+	path   string
+	cx, cy int
+}
+
+func positionsFile() string {
+	return filepath.Join(stateDir(), "positions")
+}
+
+// readPositions reads the saved cursor positions, oldest first.
+// Each line has the format: line col path
+func readPositions() []filePosition {
+	f, err := os.Open(positionsFile())
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	var positions []filePosition
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		fields := strings.SplitN(sc.Text(), " ", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		var p filePosition
+		if _, err := fmt.Sscanf(fields[0]+" "+fields[1], "%d %d", &p.cy, &p.cx); err != nil {
+			continue
+		}
+		p.path = fields[2]
+		positions = append(positions, p)
+	}
+	return positions
+}
+
+// writeTemp writes data to a new temporary file with the permissions
+// perm, in the directory of name so that it can be renamed to it, and
+// returns its name.
+func writeTemp(name string, data []byte, perm os.FileMode) (string, error) {
+	f, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".*")
+	if err != nil {
+		return "", err
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Chmod(perm)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// writeFileAtomic replaces the file contents without leaving a partially
+// written file behind if something fails. The state files are private,
+// and each write goes to a temporary file of its own, so that editors
+// saving the same file at once don't write over each other's.
+func writeFileAtomic(name string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0700); err != nil {
+		return err
+	}
+	tmp, err := writeTemp(name, data, 0600)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, name); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// savePosition remembers the cursor position in the current file.
+func (e *Editor) savePosition() {
+	if e.filename == "" {
+		return
+	}
+	path, err := absPath(e.filename)
+	if err != nil {
+		return
+	}
+	var b strings.Builder
+	positions := readPositions()
+	if len(positions) >= maxPositions {
+		positions = positions[len(positions)-maxPositions+1:]
+	}
+	for _, p := range positions {
+		if p.path != path {
+			fmt.Fprintf(&b, "%d %d %s\n", p.cy, p.cx, p.path)
+		}
+	}
+	fmt.Fprintf(&b, "%d %d %s\n", e.cy, e.cx, path)
+	writeFileAtomic(positionsFile(), []byte(b.String()))
+}
+
+// restorePosition moves the cursor to where it was the last time
+// the current file was edited.
+func (e *Editor) restorePosition() {
+	path, err := absPath(e.filename)
+	if err != nil {
+		return
+	}
+	for _, p := range readPositions() {
+		if p.path == path {
+			e.moveTo(p.cx, p.cy)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package editor
+
+import (
+	"bytes"
+	"fmt"
+	"unicode/utf8"
+)
+
+// textStats counts the lines, words, characters, and bytes of text.
+func textStats(text []byte, lines int) string {
+	return fmt.Sprintf("%7d %7d %7d %7d", lines, len(bytes.Fields(text)), utf8.RuneCount(text), len(text))
+}
+
+// stats shows the statistics of the buffer, and of the selection if
+// there is one, in a popup.
+func (e *Editor) stats() {
+	lines := []string{
+		fmt.Sprintf("%-9s %7s %7s %7s %7s", "", "lines", "words", "chars", "bytes"),
+		fmt.Sprintf("%-9s %s", "buffer", textStats(e.rowsToBytes(), e.buf.NumRows())),
+	}
+	if y0, x0, y1, x1, ok := e.selectionBounds(); ok {
+		text := e.getRange(y0, x0, y1, x1)
+		lines = append(lines, fmt.Sprintf("%-9s %s", "selection", textStats(text, bytes.Count(text, []byte("\n"))+1)))
+	}
+	e.showPopup(lines)
+}
@@ -0,0 +1,58 @@
+package editor
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/icholy/kilo/internal/ui"
+)
+
+const maxMessages = 1000
+
+// setStatus shows a message in the message bar and records it in
+// the message log.
+func (e *Editor) setStatus(format string, args ...any) {
+	e.showStatus(format, args...)
+	if e.status == "" {
+		return
+	}
+	e.messages = append(e.messages, e.statustime.Format("15:04:05")+" "+e.status)
+	e.log(LogInfo, "status", "message", e.status)
+	if len(e.messages) > maxMessages {
+		e.messages = e.messages[1:]
+	}
+}
+
+// fail shows an error message like setStatus, and makes the script
+// running the command fail with it.
+func (e *Editor) fail(format string, args ...any) {
+	e.setStatus(format, args...)
+	e.failure = errors.New(e.status)
+}
+
+// showStatus shows a transient message without logging it. The format
+// is translated to the language of the messages.
+func (e *Editor) showStatus(format string, args ...any) {
+	e.status = fmt.Sprintf(e.tr(format), args...)
+	e.statustime = time.Now()
+}
+
+// showMessages opens the message log in a read-only view.
+func (e *Editor) showMessages() {
+	e.view("[Messages]", e.messages, nil)
+}
+
+func (e *Editor) drawStatusBar(b *bytes.Buffer) {
+	left, right := e.statusLine(e.statusline)
+	if e.showtiming {
+		right = e.timingReadout() + " | " + right
+	}
+	ui.DrawStatusBar(b, left, right, e.screencols)
+	e.drawTerminal(b)
+	if e.status != "" && time.Since(e.statustime) > 5*time.Second {
+		e.status = ""
+	}
+	ui.DrawMessage(b, e.status, e.screencols)
+}
@@ -0,0 +1,59 @@
+package editor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/icholy/kilo/internal/ui"
+)
+
+// gitBranch finds the branch checked out in the repository containing
+// filename, or the abbreviated commit for a detached HEAD.
+func gitBranch(filename string) string {
+	if isRemote(filename) {
+		return ""
+	}
+	dir, err := filepath.Abs(filepath.Dir(filename))
+	if err != nil {
+		return ""
+	}
+	for {
+		data, err := os.ReadFile(filepath.Join(dir, ".git", "HEAD"))
+		if err == nil {
+			head := strings.TrimSpace(string(data))
+			if strings.HasPrefix(head, "ref: refs/heads/") {
+				return strings.TrimPrefix(head, "ref: refs/heads/")
+			}
+			if len(head) > 7 {
+				head = head[:7]
+			}
+			return head
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// statusLine expands the statusline format, see ui.StatusLine.
+func (e *Editor) statusLine(format string) (left, right string) {
+	info := ui.StatusInfo{
+		Filename:  e.filename,
+		Branch:    e.gitbranch,
+		Line:      e.cy + 1,
+		Lines:     e.buf.NumRows(),
+		Col:       e.cx + 1,
+		RenderCol: e.rx + 1,
+		Modified:  e.dirty,
+		Selecting: e.selection.active,
+		CRLF:      e.crlf,
+		ReadOnly:  e.readonly,
+	}
+	if e.buf.Syntax != nil {
+		info.Filetype = e.buf.Syntax.Filetype
+	}
+	return ui.StatusLine(format, info)
+}
@@ -0,0 +1,139 @@
+package editor
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/icholy/kilo/internal/buffer"
+)
+
+const tagsFile = "tags"
+
+type Tag struct {
+	name     string
+	filename string
+	address  string
+}
+
+// readTags returns the entries in the tags file matching name.
+func readTags(name string) ([]Tag, error) {
+	f, err := os.Open(tagsFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var tags []Tag
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := sc.Text()
+		if strings.HasPrefix(line, "!_TAG_") {
+			continue
+		}
+		fields := strings.SplitN(line, "\t", 3)
+		if len(fields) < 3 || fields[0] != name {
+			continue
+		}
+		address, _, _ := strings.Cut(fields[2], ";\"")
+		tags = append(tags, Tag{
+			name:     fields[0],
+			filename: fields[1],
+			address:  address,
+		})
+	}
+	return tags, sc.Err()
+}
+
+// tagLocation resolves the tag address, which is either a line number
+// or a /^pattern$/ search.
+func (e *Editor) tagLocation(t Tag) Location {
+	loc := Location{filename: t.filename}
+	if n, err := strconv.Atoi(t.address); err == nil {
+		loc.cy = n - 1
+		return loc
+	}
+	pattern := t.address
+	if len(pattern) >= 2 && (pattern[0] == '/' || pattern[0] == '?') {
+		pattern = pattern[1 : len(pattern)-1]
+	}
+	pattern = strings.NewReplacer(`\/`, "/", `\\`, `\`).Replace(pattern)
+	prefix := strings.HasPrefix(pattern, "^")
+	suffix := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(strings.TrimPrefix(pattern, "^"), "$")
+	match := func(line string) bool {
+		switch {
+		case prefix && suffix:
+			return line == pattern
+		case prefix:
+			return strings.HasPrefix(line, pattern)
+		case suffix:
+			return strings.HasSuffix(line, pattern)
+		default:
+			return strings.Contains(line, pattern)
+		}
+	}
+	var lines []string
+	if t.filename == e.filename {
+		for _, r := range e.buf.Rows {
+			lines = append(lines, string(r.Chars))
+		}
+	} else if data, err := os.ReadFile(t.filename); err == nil {
+		lines = strings.Split(string(data), "\n")
+	}
+	for y, line := range lines {
+		if match(line) {
+			loc.cy = y
+			loc.cx = strings.Index(line, t.name)
+			if loc.cx < 0 {
+				loc.cx = 0
+			}
+			break
+		}
+	}
+	return loc
+}
+
+// wordUnderCursor returns the identifier the cursor is on.
+func (e *Editor) wordUnderCursor() string {
+	if e.cy >= e.buf.NumRows() {
+		return ""
+	}
+	chars := e.buf.Rows[e.cy].Chars
+	start, end := e.cx, e.cx
+	for start > 0 && !buffer.IsDelim(chars[start-1]) {
+		start--
+	}
+	for end < len(chars) && !buffer.IsDelim(chars[end]) {
+		end++
+	}
+	return string(chars[start:end])
+}
+
+// tagJump jumps to the tag matching the identifier under the cursor.
+func (e *Editor) tagJump() {
+	name := e.wordUnderCursor()
+	if name == "" {
+		return
+	}
+	tags, err := readTags(name)
+	if err != nil {
+		e.fail("tags: %v", err)
+		return
+	}
+	if len(tags) == 0 {
+		e.fail("tag not found: %s", name)
+		return
+	}
+	idx := 0
+	if len(tags) > 1 {
+		items := make([]string, len(tags))
+		for i, t := range tags {
+			items[i] = t.filename + ": " + t.address
+		}
+		if idx = e.menu(items); idx < 0 {
+			return
+		}
+	}
+	e.jump(e.tagLocation(tags[idx]))
+}
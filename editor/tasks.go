@@ -0,0 +1,279 @@
+package editor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"golang.org/x/exp/slices"
+)
+
+// tasksFileName is the name of a project's task file, relative to the
+// directory the project's files are in.
+var tasksFileName = filepath.Join(".kilo", "tasks.toml")
+
+// Task is a named command of a project, defined in its task file:
+//
+//	[test]
+//	command = "go test ./..."
+//	description = "run the tests"
+//	dir = "."
+//	errorformat = ["%f:%l:%c: %m", '^\s+(?P<file>\S+\.go):(?P<line>\d+): (?P<message>.*)']
+//
+// The command runs in dir, relative to the project, and the errors in
+// its output are put in the quickfix list. Each error format is a
+// regular expression in which %f, %l, %c, and %m match the file, line,
+// column, and message, or which has groups with those names. Without
+// one, the formats of compilers are recognized.
+type Task struct {
+	name        string
+	command     string
+	description string
+	dir         string
+	formats     []*regexp.Regexp
+}
+
+// errorFormatVerbs are the patterns of the % verbs in error formats.
+var errorFormatVerbs = strings.NewReplacer(
+	"%f", `(?P<file>[^:\s]+)`,
+	"%l", `(?P<line>\d+)`,
+	"%c", `(?P<col>\d+)`,
+	"%m", `(?P<message>.*)`,
+	"%%", "%",
+)
+
+// compileErrorFormat compiles an error format. Formats which don't start
+// with ^ match at the start of a line, after any indentation.
+func compileErrorFormat(format string) (*regexp.Regexp, error) {
+	pattern := errorFormatVerbs.Replace(format)
+	if !strings.HasPrefix(pattern, "^") {
+		pattern = `^\s*` + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(re.SubexpNames(), "file") || !slices.Contains(re.SubexpNames(), "line") {
+		return nil, fmt.Errorf("error format %q doesn't match a file and line", format)
+	}
+	return re, nil
+}
+
+// parseErrorFormats extracts the error locations from the output of a
+// task with the first format which matches each line. Relative file
+// names are relative to dir.
+func parseErrorFormats(output string, formats []*regexp.Regexp, dir string) []QuickfixEntry {
+	var entries []QuickfixEntry
+	for _, line := range strings.Split(output, "\n") {
+		for _, re := range formats {
+			m := re.FindStringSubmatch(line)
+			if m == nil {
+				continue
+			}
+			group := func(name string) string {
+				if i := re.SubexpIndex(name); i >= 0 {
+					return m[i]
+				}
+				return ""
+			}
+			e := QuickfixEntry{loc: Location{filename: group("file")}, message: strings.TrimSpace(group("message"))}
+			e.loc.cy, _ = strconv.Atoi(group("line"))
+			e.loc.cy--
+			if col, err := strconv.Atoi(group("col")); err == nil {
+				e.loc.cx = col - 1
+			}
+			entries = append(entries, e)
+			break
+		}
+	}
+	for i := range entries {
+		entries[i].loc.filename = relativeTo(dir, entries[i].loc.filename)
+	}
+	return entries
+}
+
+// relativeTo returns the name of a file which is relative to dir,
+// relative to the working directory instead.
+func relativeTo(dir, name string) string {
+	if dir == "" || filepath.IsAbs(name) {
+		return filepath.Clean(name)
+	}
+	path := filepath.Join(dir, name)
+	if wd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(wd, path); err == nil {
+			return rel
+		}
+	}
+	return path
+}
+
+// findTasksFile returns the task file of the project the current file,
+// or the working directory, is in.
+func (e *Editor) findTasksFile() string {
+	dir, err := os.Getwd()
+	if e.filename != "" && !isRemote(e.filename) {
+		var path string
+		if path, err = filepath.Abs(e.filename); err == nil {
+			dir = filepath.Dir(path)
+		}
+	}
+	if err != nil {
+		return ""
+	}
+	for ; ; dir = filepath.Dir(dir) {
+		path := filepath.Join(dir, tasksFileName)
+		if fi, err := os.Stat(path); err == nil && fi.Mode().IsRegular() {
+			return path
+		}
+		if dir == filepath.Dir(dir) {
+			return ""
+		}
+	}
+}
+
+// loadTasks reads a task file. Like the project config, it's only used
+// once the user trusts it, since it runs commands.
+func (e *Editor) loadTasks(path string) ([]Task, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	// the tables are the tasks, in the order of the file
+	var tables map[string]map[string]any
+	md, err := toml.Decode(string(data), &tables)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	var tasks []Task
+	for _, key := range md.Keys() {
+		if len(key) != 1 {
+			continue
+		}
+		name := key[0]
+		task := Task{name: name}
+		for key, value := range tables[name] {
+			var err error
+			switch key {
+			case "command":
+				task.command, err = tomlString(value)
+			case "description":
+				task.description, err = tomlString(value)
+			case "dir":
+				task.dir, err = tomlString(value)
+			case "errorformat":
+				var formats []string
+				if formats, err = tomlStrings(value); err != nil {
+					break
+				}
+				for _, format := range formats {
+					var re *regexp.Regexp
+					if re, err = compileErrorFormat(format); err != nil {
+						break
+					}
+					task.formats = append(task.formats, re)
+				}
+			default:
+				err = fmt.Errorf("unknown key")
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%s: task %s: %s: %w", path, name, key, err)
+			}
+		}
+		if task.command == "" {
+			return nil, fmt.Errorf("%s: task %s has no command", path, name)
+		}
+		tasks = append(tasks, task)
+	}
+	if len(tasks) == 0 {
+		return nil, fmt.Errorf("%s has no tasks", path)
+	}
+	hash := sha256.Sum256(data)
+	sum := hex.EncodeToString(hash[:])
+	if !isTrusted(path, sum) {
+		if !e.confirm("Run the tasks in %s?", path) {
+			return nil, fmt.Errorf("%s isn't trusted", path)
+		}
+		trust(path, sum)
+	}
+	return tasks, nil
+}
+
+// runTask implements the task command, which runs the task named by the
+// argument, or the one chosen from a menu, from the project's task file.
+func (e *Editor) runTask(name string) {
+	path := e.findTasksFile()
+	if path == "" {
+		e.fail("task: no %s found", tasksFileName)
+		return
+	}
+	tasks, err := e.loadTasks(path)
+	if err != nil {
+		e.fail("task: %v", err)
+		return
+	}
+	i := slices.IndexFunc(tasks, func(t Task) bool { return t.name == name })
+	if name == "" {
+		items := make([]string, len(tasks))
+		for i, t := range tasks {
+			items[i] = t.name
+			if t.description != "" {
+				items[i] += " - " + t.description
+			}
+		}
+		i = e.menu(items)
+		if i < 0 {
+			return
+		}
+	} else if i < 0 {
+		e.fail("task: no task named %q in %s", name, path)
+		return
+	}
+	task := tasks[i]
+	// the project is the directory which has the .kilo directory
+	dir := filepath.Join(filepath.Dir(filepath.Dir(path)), task.dir)
+	parse := func(output string) []QuickfixEntry {
+		if len(task.formats) == 0 {
+			entries := parseQuickfix(output)
+			for i := range entries {
+				entries[i].loc.filename = relativeTo(dir, entries[i].loc.filename)
+			}
+			return entries
+		}
+		return parseErrorFormats(output, task.formats, dir)
+	}
+	e.runQuickfix(task.command, dir, parse)
+}
+
+// tomlString accepts a string.
+func tomlString(v any) (string, error) {
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+	return "", fmt.Errorf("expected a string")
+}
+
+// tomlStrings accepts a string or an array of strings.
+func tomlStrings(v any) ([]string, error) {
+	if s, ok := v.(string); ok {
+		return []string{s}, nil
+	}
+	values, ok := v.([]any)
+	if !ok {
+		return nil, fmt.Errorf("expected strings")
+	}
+	strs := make([]string, len(values))
+	for i, v := range values {
+		s, err := tomlString(v)
+		if err != nil {
+			return nil, err
+		}
+		strs[i] = s
+	}
+	return strs, nil
+}
@@ -0,0 +1,51 @@
+package editor
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+)
+
+// templateCursor marks where the cursor goes in a template.
+const templateCursor = "${cursor}"
+
+// findTemplate returns the template for a new file. A template named
+// like the file, e.g. Makefile, is used before one with the same
+// extension, e.g. skeleton.go.
+func (e *Editor) findTemplate(filename string) []byte {
+	if e.templates == "" {
+		return nil
+	}
+	base := filepath.Base(filename)
+	if data, err := os.ReadFile(filepath.Join(e.templates, base)); err == nil {
+		return data
+	}
+	ext := filepath.Ext(base)
+	if ext == "" {
+		return nil
+	}
+	entries, err := os.ReadDir(e.templates)
+	if err != nil {
+		return nil
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() && filepath.Ext(entry.Name()) == ext {
+			if data, err := os.ReadFile(filepath.Join(e.templates, entry.Name())); err == nil {
+				return data
+			}
+		}
+	}
+	return nil
+}
+
+// cutTemplateCursor removes the cursor marker from the template and
+// returns its position, or 0, 0 if there's none.
+func cutTemplateCursor(data []byte) ([]byte, int, int) {
+	i := bytes.Index(data, []byte(templateCursor))
+	if i < 0 {
+		return data, 0, 0
+	}
+	cy := bytes.Count(data[:i], []byte("\n"))
+	cx := i - (bytes.LastIndexByte(data[:i], '\n') + 1)
+	return append(data[:i:i], data[i+len(templateCursor):]...), cx, cy
+}
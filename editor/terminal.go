@@ -0,0 +1,326 @@
+package editor
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"syscall"
+
+	"github.com/icholy/kilo/internal/term"
+	"github.com/icholy/kilo/internal/ui"
+	"github.com/icholy/kilo/internal/vt"
+)
+
+// terminalFocusKey moves the focus between the buffer and the terminal
+// pane, opening the pane if there isn't one.
+var terminalFocusKey = term.ControlKey('\\')
+
+// terminalPane is a shell, or another command, running in a pseudo
+// terminal in a pane below the buffer.
+type terminalPane struct {
+	name string
+	cmd  *exec.Cmd
+	pty  *os.File
+
+	// mu guards the fields below, which are updated as the output is read
+	mu      sync.Mutex
+	screen  *vt.Screen
+	changed bool
+	exited  bool
+	err     error
+	// report is set when the exit status is shown in the status bar
+	report bool
+}
+
+// terminalRows is the height of a terminal pane, without its status bar,
+// on a screen with the given number of rows.
+func terminalRows(rows int) int {
+	n := (rows - 2) / 3
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
+// startTerminal runs command, or the user's shell, in a pseudo terminal
+// of the given size.
+func startTerminal(command string, rows, cols int, wake func()) (*terminalPane, error) {
+	master, slave, err := term.OpenPTY()
+	if err != nil {
+		return nil, err
+	}
+	defer slave.Close()
+	shell := os.Getenv("SHELL")
+	if shell == "" {
+		shell = "/bin/sh"
+	}
+	name := filepath.Base(shell)
+	cmd := exec.Command(shell)
+	if command != "" {
+		name = command
+		cmd = exec.Command(shell, "-c", command)
+	}
+	cmd.Env = append(os.Environ(), "TERM=xterm-256color")
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = slave, slave, slave
+	// the terminal becomes the controlling terminal of a new session, so
+	// Ctrl-C interrupts the command instead of the editor
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true, Setctty: true}
+	if err := term.SetPTYSize(master, rows, cols); err != nil {
+		master.Close()
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		master.Close()
+		return nil, err
+	}
+	p := &terminalPane{name: name, cmd: cmd, pty: master, screen: vt.New(rows, cols)}
+	go p.read(wake)
+	return p, nil
+}
+
+// read copies the output of the command to the screen until it exits.
+func (p *terminalPane) read(wake func()) {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := p.pty.Read(buf)
+		if n > 0 {
+			p.mu.Lock()
+			p.screen.Write(buf[:n])
+			reply := p.screen.Reply()
+			p.changed = true
+			p.mu.Unlock()
+			if len(reply) > 0 {
+				p.pty.Write(reply)
+			}
+			wake()
+		}
+		if err != nil {
+			break
+		}
+	}
+	err := p.cmd.Wait()
+	p.mu.Lock()
+	p.exited, p.err, p.changed = true, err, true
+	p.mu.Unlock()
+	wake()
+}
+
+// done reports whether the command has exited.
+func (p *terminalPane) done() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.exited
+}
+
+// resize changes the size of the screen and of the pseudo terminal.
+func (p *terminalPane) resize(rows, cols int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.screen.Rows == rows && p.screen.Cols == cols {
+		return
+	}
+	p.screen.Resize(rows, cols)
+	if !p.exited {
+		term.SetPTYSize(p.pty, rows, cols)
+	}
+}
+
+// close kills the command if it's still running.
+func (p *terminalPane) close() {
+	if !p.done() {
+		// the command's session has the same id as the command
+		syscall.Kill(-p.cmd.Process.Pid, syscall.SIGHUP)
+	}
+	p.pty.Close()
+}
+
+// openTerminal implements the terminal command, which opens a pane
+// running the command given as the argument or a shell, and focuses it.
+// Without an argument, an open pane is focused instead.
+func (e *Editor) openTerminal(command string) {
+	if e.terminal != nil && command == "" && !e.terminal.done() {
+		e.termfocus = true
+		return
+	}
+	e.closeTerminal()
+	if e.totalrows < 3 {
+		e.fail("terminal: the screen is too small")
+		return
+	}
+	p, err := startTerminal(command, terminalRows(e.totalrows), e.screencols, e.wake)
+	if err != nil {
+		e.fail("terminal: %v", err)
+		return
+	}
+	e.log(LogInfo, "terminal", "command", p.name)
+	e.terminal = p
+	e.termfocus = true
+	e.Resize(e.totalrows, e.screencols)
+}
+
+// closeTerminal closes the terminal pane, killing its command.
+func (e *Editor) closeTerminal() {
+	if e.terminal == nil {
+		return
+	}
+	e.terminal.close()
+	e.terminal = nil
+	e.termfocus = false
+	e.Resize(e.totalrows, e.screencols)
+}
+
+// focusTerminal moves the focus to the terminal pane, opening one if
+// there isn't one.
+func (e *Editor) focusTerminal() {
+	if e.terminal == nil {
+		e.openTerminal("")
+		return
+	}
+	e.termfocus = true
+}
+
+// pollTerminal reports whether the terminal's screen changed since it
+// was last drawn.
+func (e *Editor) pollTerminal() bool {
+	if e.terminal == nil {
+		return false
+	}
+	p := e.terminal
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	changed := p.changed
+	p.changed = false
+	if p.exited && p.report {
+		p.report = false
+		if p.err != nil {
+			e.setStatus("%s: %v", p.name, p.err)
+		} else {
+			e.setStatus("%s: ok", p.name)
+		}
+	}
+	return changed
+}
+
+// terminalKey sends a key to the command in the focused terminal pane.
+// Once the command has exited, any key closes the pane.
+func (e *Editor) terminalKey(c int) {
+	p := e.terminal
+	p.mu.Lock()
+	exited, appcursor := p.exited, p.screen.AppCursor
+	p.mu.Unlock()
+	switch {
+	case c == terminalFocusKey:
+		e.termfocus = false
+		return
+	case exited:
+		e.closeTerminal()
+		return
+	case e.term == nil && (c == term.MouseEvent || c == term.PasteEvent || c == term.TextEvent):
+		return
+	case c == term.MouseEvent:
+		// clicking the buffer moves the focus back to it
+		if m := e.term.Mouse; m.Y < e.screenrows && !m.Release {
+			e.termfocus = false
+			e.mouse()
+		}
+		return
+	case c == term.PasteEvent:
+		p.pty.Write(e.term.Paste)
+		return
+	case c == term.TextEvent:
+		p.pty.Write(e.term.Text)
+		return
+	}
+	if seq := terminalInput(c, appcursor); seq != nil {
+		p.pty.Write(seq)
+	}
+}
+
+// terminalInput returns the bytes a terminal sends for a key, or nil
+// for keys it doesn't send.
+func terminalInput(c int, appcursor bool) []byte {
+	if c&term.AltModifier != 0 && c&^term.AltModifier < 128 {
+		return []byte{'\x1b', byte(c &^ term.AltModifier)}
+	}
+	if c < 128 {
+		return []byte{byte(c)}
+	}
+	arrows := "\x1b["
+	if appcursor {
+		arrows = "\x1bO"
+	}
+	switch c {
+	case term.ArrowUp:
+		return []byte(arrows + "A")
+	case term.ArrowDown:
+		return []byte(arrows + "B")
+	case term.ArrowRight:
+		return []byte(arrows + "C")
+	case term.ArrowLeft:
+		return []byte(arrows + "D")
+	case term.HomeKey:
+		return []byte(arrows + "H")
+	case term.EndKey:
+		return []byte(arrows + "F")
+	case term.InsertKey:
+		return []byte("\x1b[2~")
+	case term.DeleteKey:
+		return []byte("\x1b[3~")
+	case term.PageUp:
+		return []byte("\x1b[5~")
+	case term.PageDown:
+		return []byte("\x1b[6~")
+	}
+	if term.F1 <= c && c <= term.F4 {
+		return []byte("\x1bO" + string(rune('P'+c-term.F1)))
+	}
+	if term.F5 <= c && c <= term.F12 {
+		codes := []int{15, 17, 18, 19, 20, 21, 23, 24}
+		return []byte(fmt.Sprintf("\x1b[%d~", codes[c-term.F5]))
+	}
+	return nil
+}
+
+// drawTerminal draws the terminal pane and its status bar.
+func (e *Editor) drawTerminal(b *bytes.Buffer) {
+	p := e.terminal
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for y := 0; y < p.screen.Rows; y++ {
+		p.screen.DrawRow(b, y)
+		b.WriteString("\r\n")
+	}
+	left := "[terminal] " + p.name
+	if p.screen.Title != "" {
+		left = "[terminal] " + p.screen.Title
+	}
+	right := "Ctrl-\\ = terminal"
+	switch {
+	case p.exited && p.err != nil:
+		right = fmt.Sprintf("%v, press a key to close", p.err)
+	case p.exited:
+		right = "exited, press a key to close"
+	case e.termfocus:
+		right = "Ctrl-\\ = editor"
+	}
+	ui.DrawStatusBar(b, left, right, e.screencols)
+}
+
+// terminalCursor returns the screen position of the cursor in the
+// terminal pane, and whether it's shown.
+func (e *Editor) terminalCursor() (row, col int, ok bool) {
+	p := e.terminal
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.exited || p.screen.HideCursor {
+		return 0, 0, false
+	}
+	// below the buffer and its status bar
+	return e.screenrows + 1 + p.screen.Y, p.screen.X, true
+}
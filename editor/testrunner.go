@@ -0,0 +1,279 @@
+package editor
+
+import (
+	"bufio"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// defaultTestCommand runs the tests of filetypes without a test command.
+const defaultTestCommand = "go test ./..."
+
+var (
+	// testStart matches the lines go test -v prints when a test starts
+	// or continues running, and testResult the ones with its result.
+	testStart  = regexp.MustCompile(`^=== (?:RUN|CONT|PAUSE)\s+(\S+)`)
+	testResult = regexp.MustCompile(`^\s*--- (PASS|FAIL|SKIP): (\S+)`)
+	// testPackage matches the summary line of a package.
+	testPackage = regexp.MustCompile(`^(ok|FAIL|\?)\s+(\S+)`)
+	// testLog matches the messages of t.Error and the like.
+	testLog = regexp.MustCompile(`^\s+(\S+\.go):(\d+): (.*)$`)
+)
+
+// testFailure is a failed test: where its function is defined, and where
+// it failed.
+type testFailure struct {
+	name    string
+	pkg     string
+	def     Location
+	loc     Location
+	message string
+}
+
+// testReport is the result of running the tests.
+type testReport struct {
+	failures []*testFailure
+	passed   int
+	packages []string // the summary lines of the packages
+}
+
+// parseTestOutput parses the output of go test, with or without -v.
+func parseTestOutput(output string) testReport {
+	var r testReport
+	// logs holds the failures of the package being read by test name.
+	// go test prints the output of each package together followed by
+	// its summary line, which starts the next package's logs, so tests
+	// with the same name in different packages are kept apart.
+	logs := map[string]*testFailure{}
+	var current string
+	for _, line := range strings.Split(output, "\n") {
+		if m := testStart.FindStringSubmatch(line); m != nil {
+			current = m[1]
+			continue
+		}
+		if m := testResult.FindStringSubmatch(line); m != nil {
+			current = m[2]
+			switch m[1] {
+			case "PASS":
+				r.passed++
+			case "FAIL":
+				f := logs[current]
+				if f == nil {
+					f = &testFailure{}
+					logs[current] = f
+				}
+				f.name = current
+				r.failures = append(r.failures, f)
+			}
+			continue
+		}
+		if m := testPackage.FindStringSubmatch(line); m != nil {
+			r.packages = append(r.packages, strings.Join(strings.Fields(line), " "))
+			for _, f := range r.failures {
+				if f.pkg == "" {
+					f.pkg = m[2]
+				}
+			}
+			logs = map[string]*testFailure{}
+			current = ""
+			continue
+		}
+		if m := testLog.FindStringSubmatch(line); m != nil && current != "" {
+			// the first message of a test is where it failed
+			f := logs[current]
+			if f == nil {
+				f = &testFailure{}
+				logs[current] = f
+			}
+			if f.message == "" {
+				f.loc.filename = m[1]
+				f.loc.cy, _ = strconv.Atoi(m[2])
+				f.loc.cy--
+				f.message = m[3]
+			}
+		}
+	}
+	// a failed subtest fails its parents too, only keep the innermost
+	var failures []*testFailure
+	for _, f := range r.failures {
+		inner := false
+		for _, g := range r.failures {
+			if g.pkg == f.pkg && strings.HasPrefix(g.name, f.name+"/") {
+				inner = true
+			}
+		}
+		if !inner {
+			failures = append(failures, f)
+		}
+	}
+	r.failures = failures
+	return r
+}
+
+// findTestFunctions finds the definitions of the named top level test
+// functions in the _test.go files under the working directory.
+func findTestFunctions(names map[string]bool) map[string][]Location {
+	defs := map[string][]Location{}
+	filepath.WalkDir(".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if skipDir(path, d) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(path, "_test.go") {
+			return nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return nil
+		}
+		defer f.Close()
+		sc := bufio.NewScanner(f)
+		for y := 0; sc.Scan(); y++ {
+			line := sc.Text()
+			if !strings.HasPrefix(line, "func Test") && !strings.HasPrefix(line, "func Example") && !strings.HasPrefix(line, "func Fuzz") {
+				continue
+			}
+			name, _, _ := strings.Cut(strings.TrimPrefix(line, "func "), "(")
+			if names[name] {
+				defs[name] = append(defs[name], Location{filename: path, cy: y})
+			}
+		}
+		return nil
+	})
+	return defs
+}
+
+// locateFailures finds the test functions of the failures, and makes
+// the files they failed in relative to the working directory.
+func locateFailures(failures []*testFailure) {
+	names := map[string]bool{}
+	for _, f := range failures {
+		top, _, _ := strings.Cut(f.name, "/")
+		names[top] = true
+	}
+	defs := findTestFunctions(names)
+	for _, f := range failures {
+		top, _, _ := strings.Cut(f.name, "/")
+		candidates := defs[top]
+		if len(candidates) == 0 {
+			continue
+		}
+		// the package's import path ends with the directory of the file
+		f.def = candidates[0]
+		for _, def := range candidates {
+			if dir := filepath.ToSlash(filepath.Dir(def.filename)); dir == "." || strings.HasSuffix(f.pkg, "/"+dir) {
+				f.def = def
+				break
+			}
+		}
+		if f.loc.filename != "" && !filepath.IsAbs(f.loc.filename) {
+			f.loc.filename = filepath.Join(filepath.Dir(f.def.filename), f.loc.filename)
+		}
+	}
+}
+
+// testCommand returns the filetype's test command, or go test.
+func (e *Editor) testCommand() string {
+	if e.buf.Syntax != nil && e.buf.Syntax.Test != "" {
+		return e.buf.Syntax.Test
+	}
+	return defaultTestCommand
+}
+
+// runTests implements the test command, which runs the tests with the
+// command given, or the filetype's. The failed tests are marked in the
+// gutter and put in the quickfix list, and the results are shown in a
+// view where Enter jumps to a failure.
+func (e *Editor) runTests(args string) {
+	command := strings.TrimSpace(args)
+	if command == "" {
+		command = e.testCommand()
+	}
+	e.setStatus("running %s ...", command)
+	e.refreshScreen()
+	output, err := exec.Command("sh", "-c", command).CombinedOutput()
+	report := parseTestOutput(string(output))
+	locateFailures(report.failures)
+	e.testmarks = map[string][]int{}
+	e.quickfix = nil
+	e.qfidx = -1
+	for _, f := range report.failures {
+		if f.def.filename != "" {
+			if path, err := absPath(f.def.filename); err == nil {
+				e.testmarks[path] = append(e.testmarks[path], f.def.cy)
+			}
+		}
+		qf := QuickfixEntry{loc: f.loc, message: f.name + ": " + f.message}
+		if f.loc.filename == "" {
+			qf = QuickfixEntry{loc: f.def, message: f.name + " failed"}
+		}
+		if qf.loc.filename != "" {
+			e.quickfix = append(e.quickfix, qf)
+		}
+	}
+	if len(report.failures) == 0 {
+		if err == nil {
+			e.setStatus("%s: ok, %d packages", command, len(report.packages))
+			return
+		}
+		// a build failure
+		e.quickfix = parseQuickfix(string(output))
+		if len(e.quickfix) > 0 {
+			e.nextError(1)
+			return
+		}
+		msg, _, _ := strings.Cut(strings.TrimSpace(string(output)), "\n")
+		e.fail("%s: %v %s", command, err, msg)
+		return
+	}
+	e.showTestReport(report)
+}
+
+// showTestReport lists the failed tests and the package results. Enter
+// jumps to where the test failed, or to the test function.
+func (e *Editor) showTestReport(report testReport) {
+	var lines []string
+	var targets []*testFailure
+	for _, f := range report.failures {
+		line := "FAIL " + f.name
+		switch {
+		case f.loc.filename != "":
+			line += " at " + f.loc.filename + ":" + strconv.Itoa(f.loc.cy+1) + ": " + f.message
+		case f.def.filename != "":
+			line += " in " + f.def.filename + ":" + strconv.Itoa(f.def.cy+1)
+		}
+		lines = append(lines, line)
+		targets = append(targets, f)
+	}
+	lines = append(lines, "")
+	targets = append(targets, nil)
+	for _, pkg := range report.packages {
+		lines = append(lines, pkg)
+		targets = append(targets, nil)
+	}
+	title := strconv.Itoa(len(report.failures)) + " failed"
+	if report.passed > 0 {
+		title += ", " + strconv.Itoa(report.passed) + " passed"
+	}
+	e.view("tests: "+title, lines, func(i int) {
+		f := targets[i]
+		switch {
+		case f == nil:
+		case f.loc.filename != "":
+			e.jump(f.loc)
+		case f.def.filename != "":
+			e.jump(f.def)
+		}
+	})
+	e.fail("%d tests failed, F8 = next failure", len(report.failures))
+}
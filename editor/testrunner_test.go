@@ -0,0 +1,42 @@
+package editor
+
+import "testing"
+
+func TestParseTestOutput(t *testing.T) {
+	output := `=== RUN   TestA
+    a_test.go:10: a failed
+--- FAIL: TestA (0.00s)
+=== RUN   TestB
+=== RUN   TestB/sub
+    a_test.go:20: b failed
+--- FAIL: TestB (0.00s)
+    --- FAIL: TestB/sub (0.00s)
+FAIL
+FAIL	example.com/a	0.01s
+=== RUN   TestA
+--- PASS: TestA (0.00s)
+=== RUN   TestB
+    b_test.go:30: b failed too
+--- FAIL: TestB (0.00s)
+FAIL
+FAIL	example.com/b	0.01s
+`
+	r := parseTestOutput(output)
+	if r.passed != 1 || len(r.packages) != 2 {
+		t.Errorf("passed = %d, packages = %q", r.passed, r.packages)
+	}
+	want := []testFailure{
+		{name: "TestA", pkg: "example.com/a", loc: Location{filename: "a_test.go", cy: 9}, message: "a failed"},
+		{name: "TestB/sub", pkg: "example.com/a", loc: Location{filename: "a_test.go", cy: 19}, message: "b failed"},
+		// a test with the same name in another package has its own log
+		{name: "TestB", pkg: "example.com/b", loc: Location{filename: "b_test.go", cy: 29}, message: "b failed too"},
+	}
+	if len(r.failures) != len(want) {
+		t.Fatalf("got %d failures, want %d", len(r.failures), len(want))
+	}
+	for i, f := range r.failures {
+		if *f != want[i] {
+			t.Errorf("failure %d = %+v, want %+v", i, *f, want[i])
+		}
+	}
+}
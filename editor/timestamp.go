@@ -0,0 +1,133 @@
+package editor
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultTimestampFormat is the strftime format of the timestamp
+// command when none is configured.
+const defaultTimestampFormat = "%Y-%m-%d %H:%M"
+
+// strftimeLayouts are the Go time layouts of the strftime directives.
+var strftimeLayouts = map[byte]string{
+	'Y': "2006", 'y': "06", 'm': "01", 'd': "02", 'e': "_2",
+	'H': "15", 'I': "03", 'M': "04", 'S': "05", 'p': "PM",
+	'b': "Jan", 'B': "January", 'a': "Mon", 'A': "Monday",
+	'z': "-0700", 'Z': "MST", 'F': "2006-01-02", 'T': "15:04:05",
+}
+
+// strftime formats t with the directives of C's strftime, e.g.
+// "%Y-%m-%d %H:%M". Besides the ones in strftimeLayouts, %j is the day
+// of the year, %s the Unix time, and %% a percent sign.
+func strftime(format string, t time.Time) string {
+	var b strings.Builder
+	for i := 0; i < len(format); i++ {
+		c := format[i]
+		if c != '%' || i+1 == len(format) {
+			b.WriteByte(c)
+			continue
+		}
+		i++
+		if layout, ok := strftimeLayouts[format[i]]; ok {
+			b.WriteString(t.Format(layout))
+			continue
+		}
+		switch format[i] {
+		case 'j':
+			fmt.Fprintf(&b, "%03d", t.YearDay())
+		case 's':
+			b.WriteString(strconv.FormatInt(t.Unix(), 10))
+		case '%':
+			b.WriteByte('%')
+		default:
+			b.WriteByte('%')
+			b.WriteByte(format[i])
+		}
+	}
+	return b.String()
+}
+
+// username returns the login name of the user.
+func username() string {
+	if name := os.Getenv("USER"); name != "" {
+		return name
+	}
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return ""
+}
+
+// templateVariable matches ${name} and ${name:format}.
+var templateVariable = regexp.MustCompile(`\$\{(\w+)(?::([^}]*))?\}`)
+
+// expandVariables replaces the variables in a file template, or in text
+// inserted by the insert command, for the named file:
+//
+//	${filename}     the name of the file, e.g. main.go
+//	${name}         the name without its extension, e.g. main
+//	${dir}          the name of the directory the file is in
+//	${user}         the login name of the user
+//	${date}         the ISO 8601 date, e.g. 2024-01-31
+//	${time}         the time, e.g. 15:04
+//	${datetime}     the ISO 8601 date and time
+//	${year}         the year
+//	${timestamp}    the date and time in the timestamp-format setting
+//	${date:format}  the date and time in a strftime format, e.g. %d %b %Y
+//
+// Other variables, like ${cursor}, are left alone.
+func (e *Editor) expandVariables(text []byte, filename string) []byte {
+	now := time.Now()
+	return templateVariable.ReplaceAllFunc(text, func(m []byte) []byte {
+		sub := templateVariable.FindSubmatch(m)
+		name, format := string(sub[1]), string(sub[2])
+		var s string
+		switch {
+		case name == "date" && format != "":
+			s = strftime(format, now)
+		case format != "":
+			return m
+		case name == "filename":
+			s = filepath.Base(filename)
+		case name == "name":
+			s = strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+		case name == "dir":
+			if abs, err := filepath.Abs(filename); err == nil {
+				s = filepath.Base(filepath.Dir(abs))
+			}
+		case name == "user":
+			s = username()
+		case name == "date":
+			s = now.Format("2006-01-02")
+		case name == "time":
+			s = now.Format("15:04")
+		case name == "datetime":
+			s = now.Format(time.RFC3339)
+		case name == "year":
+			s = strconv.Itoa(now.Year())
+		case name == "timestamp":
+			s = strftime(e.timefmt, now)
+		default:
+			return m
+		}
+		return []byte(s)
+	})
+}
+
+// insertTimestamp inserts the current date and time at the cursor, in
+// the strftime format given as the argument or the timestamp-format
+// setting.
+func (e *Editor) insertTimestamp(format string) {
+	if format == "" {
+		format = e.timefmt
+	}
+	text := strftime(format, time.Now())
+	e.cy, e.cx = e.replaceRange(e.cy, e.cx, e.cy, e.cx, []byte(text))
+}
@@ -0,0 +1,46 @@
+package editor
+
+import (
+	"fmt"
+	"time"
+)
+
+// frameInterval is the least time between frames while there are keys
+// waiting to be handled.
+const frameInterval = time.Second / 60
+
+// timing measures how long drawing the screen and handling keys take,
+// for the readout in the status bar.
+type timing struct {
+	render  time.Duration // drawing the last frame
+	latency time.Duration // from reading the last key to drawing its result
+	keyed   bool          // a key was handled since the last frame
+	frame   time.Time     // when the last frame was drawn
+}
+
+// timingReadout is shown in the status bar when the timing option is
+// set.
+func (e *Editor) timingReadout() string {
+	return fmt.Sprintf("render %v key %v", e.timing.render.Round(time.Microsecond), e.timing.latency.Round(time.Microsecond))
+}
+
+// recordFrame records the time taken by a frame which started at start.
+func (e *Editor) recordFrame(start time.Time) {
+	e.timing.render = time.Since(start)
+	e.timing.frame = time.Now()
+	if e.timing.keyed {
+		e.timing.latency = time.Since(e.keytime)
+		e.timing.keyed = false
+	}
+}
+
+// skipFrame reports whether drawing the screen can wait, because there
+// are more keys to handle and a frame was drawn recently. A burst of
+// input, like a paste, is drawn at most 60 times a second instead of
+// after every key, so it isn't held up by the terminal.
+func (e *Editor) skipFrame() bool {
+	if time.Since(e.timing.frame) >= frameInterval {
+		return false
+	}
+	return len(e.pending) > 0 || e.term != nil && e.term.Buffered()
+}
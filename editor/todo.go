@@ -0,0 +1,127 @@
+package editor
+
+import (
+	"bytes"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/icholy/kilo/internal/buffer"
+)
+
+// maxTodoFileSize is the size of the largest file searched for todos.
+const maxTodoFileSize = 1 << 20
+
+// setTodoKeywords applies the todo-keywords setting.
+func (e *Editor) setTodoKeywords() {
+	e.opts.Todo = nil
+	for _, kw := range strings.Split(e.todokeywords, ",") {
+		if kw = strings.TrimSpace(kw); kw != "" {
+			e.opts.Todo = append(e.opts.Todo, kw)
+		}
+	}
+	e.buf.Rehighlight()
+}
+
+// findTodos returns where the todo keywords are in the comments of a
+// buffer, with the rest of the comment.
+func findTodos(b *buffer.Buffer, filename string) []QuickfixEntry {
+	var todos []QuickfixEntry
+	for y, row := range b.Rows {
+		if !containsTodo(row.Chars, b.Options.Todo) {
+			continue
+		}
+		b.Highlight(y)
+		render := row.Render()
+		for rx := 0; rx < len(render); rx++ {
+			if row.HL[rx] == buffer.HighlightTodo && (rx == 0 || row.HL[rx-1] != buffer.HighlightTodo) {
+				todos = append(todos, QuickfixEntry{
+					loc:      Location{filename: filename, cx: row.RxToCx(rx), cy: y},
+					message:  strings.TrimSpace(string(render[rx:])),
+					severity: severityHint,
+				})
+			}
+		}
+	}
+	return todos
+}
+
+// containsTodo reports whether a keyword appears in chars, which is
+// quicker to check than highlighting the row.
+func containsTodo(chars []byte, keywords []string) bool {
+	for _, kw := range keywords {
+		if bytes.Contains(chars, []byte(kw)) {
+			return true
+		}
+	}
+	return false
+}
+
+// projectTodos finds the todos in the files under the working
+// directory, other than the one being edited.
+func (e *Editor) projectTodos() []QuickfixEntry {
+	var todos []QuickfixEntry
+	for _, name := range projectFiles() {
+		syntax := buffer.SyntaxFor(name)
+		if syntax == nil || sameFile(name, e.filename) {
+			continue
+		}
+		if fi, err := os.Stat(name); err != nil || fi.Size() > maxTodoFileSize {
+			continue
+		}
+		data, err := os.ReadFile(name)
+		if err != nil || bytes.IndexByte(data, 0) >= 0 {
+			continue
+		}
+		b := buffer.New(&e.opts)
+		b.Syntax = syntax
+		lines := bytes.Split(bytes.TrimSuffix(data, []byte("\n")), []byte("\n"))
+		for i, line := range lines {
+			lines[i] = bytes.TrimSuffix(line, []byte("\r"))
+		}
+		b.AppendRows(lines)
+		todos = append(todos, findTodos(b, name)...)
+	}
+	return todos
+}
+
+// sameFile reports whether two names refer to the same file.
+func sameFile(a, b string) bool {
+	pa, err1 := absPath(a)
+	pb, err2 := absPath(b)
+	return err1 == nil && err2 == nil && pa == pb
+}
+
+// listTodos implements the todos command, which lists the todo keywords
+// in the comments of the buffer, or with "all" the project's files too,
+// in a view where Enter jumps to one. They're also put in the quickfix
+// list.
+func (e *Editor) listTodos(args string) {
+	todos := findTodos(e.buf, e.filename)
+	switch args {
+	case "":
+	case "all":
+		todos = append(todos, e.projectTodos()...)
+	default:
+		e.fail("todos: unknown argument %s, expected all", args)
+		return
+	}
+	if len(todos) == 0 {
+		e.setStatus("no %s found", strings.Join(e.opts.Todo, ", "))
+		return
+	}
+	lines := make([]string, len(todos))
+	for i, t := range todos {
+		name := t.loc.filename
+		if name == "" {
+			name = "[No Name]"
+		}
+		lines[i] = name + ":" + strconv.Itoa(t.loc.cy+1) + ": " + t.message
+	}
+	e.quickfix = todos
+	e.qfidx = -1
+	e.view("todos: "+strconv.Itoa(len(todos)), lines, func(i int) {
+		e.qfidx = i
+		e.jumpError()
+	})
+}
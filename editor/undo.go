@@ -0,0 +1,339 @@
+package editor
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/icholy/kilo/internal/buffer"
+	"golang.org/x/exp/slices"
+)
+
+const undoLimit = 1000
+
+// undoEdit replaces the rows before, starting at row y, with after.
+type undoEdit struct {
+	y             int
+	before, after [][]byte
+}
+
+// undoNode is a state of the buffer in the undo tree. Undo moves to the
+// parent, and redo to the child which was visited last. A change made
+// after undoing starts a new branch instead of discarding the redo
+// history, so every state the buffer was in can be returned to.
+type undoNode struct {
+	// edits change the state of the parent into this one
+	edits    []undoEdit
+	cx, cy   int
+	crlf     bool
+	seq      int
+	time     time.Time
+	parent   *undoNode
+	children []*undoNode
+	// last is the child redo moves to
+	last *undoNode
+}
+
+// undoLine is a row of the buffer in the state of the current node, and
+// its version then, which tell whether it was modified since.
+type undoLine struct {
+	row     *buffer.Row
+	version int
+}
+
+// cloneLines copies lines into a single array.
+func cloneLines(lines [][]byte) [][]byte {
+	var n int
+	for _, line := range lines {
+		n += len(line)
+	}
+	data := make([]byte, 0, n)
+	clone := make([][]byte, len(lines))
+	for i, line := range lines {
+		start := len(data)
+		data = append(data, line...)
+		clone[i] = data[start:len(data):len(data)]
+	}
+	return clone
+}
+
+// rowsText returns the contents of rows.
+func rowsText(rows []*buffer.Row) [][]byte {
+	lines := make([][]byte, len(rows))
+	for i, r := range rows {
+		lines[i] = r.Chars
+	}
+	return lines
+}
+
+// resetUndo discards the undo history, used when a file is opened.
+func (e *Editor) resetUndo() {
+	e.undoroot = &undoNode{cx: e.cx, cy: e.cy, crlf: e.crlf, time: time.Now()}
+	e.undocur = e.undoroot
+	e.undosaved = e.undoroot
+	e.undoseq = 0
+	e.undolines = make([]undoLine, len(e.buf.Rows))
+	for i, r := range e.buf.Rows {
+		e.undolines[i] = undoLine{row: r, version: r.Version()}
+	}
+	e.undotext = cloneLines(rowsText(e.buf.Rows))
+	e.undochanges = e.buf.Changes
+	e.indexWords()
+}
+
+// undoChanged finds the rows which changed since the state of the
+// current node, and returns the edit which changed them. The copy of
+// the rows in that state is brought up to date with it. Only the rows
+// whose text differs are part of the edit, so reloading the file edits
+// the lines which changed on disk.
+func (e *Editor) undoChanged() (undoEdit, bool) {
+	rows, lines, text := e.buf.Rows, e.undolines, e.undotext
+	same := func(i, y int) bool {
+		l, r := &lines[i], rows[y]
+		if l.row == r && l.version == r.Version() {
+			return true
+		}
+		if bytes.Equal(text[i], r.Chars) {
+			*l = undoLine{row: r, version: r.Version()}
+			return true
+		}
+		return false
+	}
+	y := 0
+	for y < len(rows) && y < len(lines) && same(y, y) {
+		y++
+	}
+	end, oldend := len(rows), len(lines)
+	for end > y && oldend > y && same(oldend-1, end-1) {
+		end--
+		oldend--
+	}
+	if end == y && oldend == y {
+		return undoEdit{}, false
+	}
+	edit := undoEdit{
+		y:      y,
+		before: slices.Clone(text[y:oldend]),
+		after:  cloneLines(rowsText(rows[y:end])),
+	}
+	changed := make([]undoLine, end-y)
+	for i, r := range rows[y:end] {
+		changed[i] = undoLine{row: r, version: r.Version()}
+	}
+	e.undolines = slices.Replace(lines, y, oldend, changed...)
+	// the word index may still be reading the old copy
+	newtext := make([][]byte, 0, len(rows))
+	newtext = append(newtext, text[:y]...)
+	newtext = append(newtext, edit.after...)
+	e.undotext = append(newtext, text[oldend:]...)
+	return edit, true
+}
+
+// commitUndo is called after every command. If the command changed the
+// buffer, a node with the edit it made is added below the current one.
+// Consecutive typed characters are grouped into a single node.
+func (e *Editor) commitUndo(c int) {
+	if e.buf.Changes == e.undochanges {
+		return
+	}
+	if e.undocur == nil {
+		e.resetUndo()
+		// the first state is the changed buffer
+		e.undosaved = nil
+		return
+	}
+	e.undochanges = e.buf.Changes
+	edit, changed := e.undoChanged()
+	if !changed && e.crlf == e.undocur.crlf {
+		return
+	}
+	typing := c < 128 && c != '\r' && (c == '\t' || c >= ' ')
+	n := e.undocur
+	if typing && e.lasttyped && n != e.undoroot && len(n.children) == 0 {
+		if changed {
+			n.addEdit(edit)
+		}
+	} else {
+		e.undoseq++
+		n = &undoNode{seq: e.undoseq, parent: e.undocur}
+		if changed {
+			n.edits = []undoEdit{edit}
+		}
+		e.undocur.children = append(e.undocur.children, n)
+		e.undocur.last = n
+		e.undocur = n
+		e.pruneUndo()
+	}
+	n.cx, n.cy, n.crlf, n.time = e.cx, e.cy, e.crlf, time.Now()
+	e.lasttyped = typing
+	e.indexWords()
+}
+
+// addEdit adds an edit to the node. An edit of the rows the last one
+// made is merged with it, so typing on a line keeps a single copy of it.
+func (n *undoNode) addEdit(edit undoEdit) {
+	if k := len(n.edits) - 1; k >= 0 {
+		last := &n.edits[k]
+		if last.y == edit.y && len(last.after) == len(edit.before) {
+			last.after = edit.after
+			return
+		}
+	}
+	n.edits = append(n.edits, edit)
+}
+
+// pruneUndo drops the oldest states once there are more than undoLimit,
+// along with the branches which split off before them.
+func (e *Editor) pruneUndo() {
+	for e.undoseq-e.undoroot.seq > undoLimit && e.undoroot != e.undocur {
+		// the child of the root which leads to the current state
+		next := e.undocur
+		for next.parent != e.undoroot {
+			next = next.parent
+		}
+		next.parent = nil
+		next.edits = nil
+		e.undoroot = next
+	}
+}
+
+// undoEdits reverts the edits of node n, which changes the buffer from
+// its state back to the state of its parent.
+func (e *Editor) undoEdits(n *undoNode) {
+	for i := len(n.edits) - 1; i >= 0; i-- {
+		edit := n.edits[i]
+		e.buf.ReplaceRows(edit.y, edit.y+len(edit.after), cloneLines(edit.before))
+	}
+}
+
+// redoEdits makes the edits of node n again.
+func (e *Editor) redoEdits(n *undoNode) {
+	for _, edit := range n.edits {
+		e.buf.ReplaceRows(edit.y, edit.y+len(edit.before), cloneLines(edit.after))
+	}
+}
+
+// moveUndo changes the buffer to the state of node n: the edits from the
+// current state up to the state both are below are undone, and the ones
+// from there down to n are made again.
+func (e *Editor) moveUndo(n *undoNode) {
+	above := map[*undoNode]bool{}
+	for a := n; a != nil; a = a.parent {
+		above[a] = true
+	}
+	cur := e.undocur
+	for ; !above[cur]; cur = cur.parent {
+		e.undoEdits(cur)
+	}
+	var path []*undoNode
+	for a := n; a != cur; a = a.parent {
+		path = append(path, a)
+	}
+	for i := len(path) - 1; i >= 0; i-- {
+		e.redoEdits(path[i])
+	}
+	e.undoChanged()
+	e.crlf = n.crlf
+	e.moveTo(n.cx, n.cy)
+	e.undocur = n
+	e.undochanges = e.buf.Changes
+	e.lasttyped = false
+	e.dirty = n != e.undosaved
+	e.indexWords()
+}
+
+// markSaved records the state of the buffer as the one in the file,
+// which undo and redo compare against to tell whether it's modified.
+// Typing after it starts a new state, so the saved one stays as it was.
+func (e *Editor) markSaved() {
+	e.commitUndo(0)
+	e.undosaved = e.undocur
+	e.lasttyped = false
+	e.dirty = false
+}
+
+func (e *Editor) undoChange() {
+	// changes made by the command running this one are undone first
+	e.commitUndo(0)
+	if e.undocur == nil || e.undocur.parent == nil {
+		e.setStatus("nothing to undo")
+		return
+	}
+	p := e.undocur.parent
+	p.last = e.undocur
+	e.moveUndo(p)
+}
+
+func (e *Editor) redoChange() {
+	e.commitUndo(0)
+	if e.undocur == nil || e.undocur.last == nil {
+		e.setStatus("nothing to redo")
+		return
+	}
+	e.moveUndo(e.undocur.last)
+	if len(e.undocur.parent.children) > 1 {
+		e.setStatus("redo to change %d, see undo-tree for the other branches", e.undocur.seq)
+	}
+}
+
+// undoSummary describes the change from the parent of n to n by the
+// first row it edits.
+func undoSummary(n *undoNode) string {
+	if n.parent == nil {
+		return "original"
+	}
+	if len(n.edits) == 0 {
+		return "line endings"
+	}
+	y, d := n.edits[0].y, 0
+	for _, edit := range n.edits {
+		if edit.y < y {
+			y = edit.y
+		}
+		d += len(edit.after) - len(edit.before)
+	}
+	switch {
+	case d > 0:
+		return fmt.Sprintf("line %d, %d lines added", y+1, d)
+	case d < 0:
+		return fmt.Sprintf("line %d, %d lines deleted", y+1, -d)
+	}
+	return fmt.Sprintf("line %d", y+1)
+}
+
+// undoTree lists the states in the undo tree, and moves to the one
+// chosen with Enter. Each later branch is listed indented below the
+// state it split off from, and the current state is marked with a >.
+func (e *Editor) undoTree() {
+	e.commitUndo(0)
+	if e.undoroot == nil {
+		e.setStatus("nothing to undo")
+		return
+	}
+	var nodes []*undoNode
+	var lines []string
+	var walk func(n *undoNode, depth int)
+	walk = func(n *undoNode, depth int) {
+		mark := " "
+		if n == e.undocur {
+			mark = ">"
+		}
+		lines = append(lines, fmt.Sprintf("%s %*s%-4d %s  %s", mark, depth*2, "", n.seq, n.time.Format("15:04:05"), undoSummary(n)))
+		nodes = append(nodes, n)
+		if len(n.children) == 0 {
+			return
+		}
+		// the branches which split off here, then the first one
+		for _, c := range n.children[1:] {
+			walk(c, depth+1)
+		}
+		walk(n.children[0], depth)
+	}
+	walk(e.undoroot, 0)
+	e.view("[Undo Tree]", lines, func(i int) {
+		if nodes[i] != e.undocur {
+			e.moveUndo(nodes[i])
+			e.setStatus("moved to change %d", nodes[i].seq)
+		}
+	})
+}
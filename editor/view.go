@@ -0,0 +1,93 @@
+package editor
+
+import (
+	"github.com/icholy/kilo/internal/buffer"
+	"github.com/icholy/kilo/internal/term"
+)
+
+// bufferState holds the parts of the editor state which belong to the
+// buffer being displayed.
+type bufferState struct {
+	buf            *buffer.Buffer
+	cx, cy         int
+	rowoff, coloff int
+	filename       string
+	dirty          bool
+	selection      Selection
+}
+
+func (e *Editor) saveBufferState() bufferState {
+	return bufferState{
+		buf:       e.buf,
+		cx:        e.cx,
+		cy:        e.cy,
+		rowoff:    e.rowoff,
+		coloff:    e.coloff,
+		filename:  e.filename,
+		dirty:     e.dirty,
+		selection: e.selection,
+	}
+}
+
+func (e *Editor) restoreBufferState(s bufferState) {
+	e.buf = s.buf
+	e.cx, e.cy = s.cx, s.cy
+	e.rowoff, e.coloff = s.rowoff, s.coloff
+	e.filename = s.filename
+	e.dirty = s.dirty
+	e.selection = s.selection
+}
+
+// view displays lines in a read-only buffer until q or Esc is
+// pressed. If choose is set, Enter closes the view and calls it with the
+// index of the line under the cursor.
+func (e *Editor) view(title string, lines []string, choose func(i int)) {
+	saved := e.saveBufferState()
+	e.buf = buffer.New(&e.opts)
+	for _, line := range lines {
+		e.buf.InsertRow(e.buf.NumRows(), []byte(line))
+	}
+	e.cx, e.cy = 0, 0
+	e.rowoff, e.coloff = 0, 0
+	e.filename = title
+	e.dirty = false
+	e.selection = Selection{}
+	chosen := -1
+	defer func() {
+		e.restoreBufferState(saved)
+		if chosen >= 0 {
+			choose(chosen)
+		}
+	}()
+	e.showStatus("q = close")
+	for {
+		e.refreshScreen()
+		switch c := e.readKey(); c {
+		case 'q', '\x1b', term.ControlKey('q'):
+			return
+		case '\r':
+			if choose != nil && e.cy < e.buf.NumRows() {
+				chosen = e.cy
+				return
+			}
+		case term.ArrowUp, term.ArrowDown, term.ArrowLeft, term.ArrowRight:
+			e.moveCursor(c)
+		case term.PageUp:
+			e.scrollBy(-e.screenrows)
+			e.moveTo(e.cx, e.rowoff)
+		case term.PageDown:
+			e.scrollBy(e.screenrows)
+			e.moveTo(e.cx, e.rowoff)
+		case term.HomeKey:
+			e.cx = 0
+		case term.EndKey:
+			if e.cy < e.buf.NumRows() {
+				e.cx = e.buf.Rows[e.cy].Len()
+			}
+		case term.ControlKey('f'):
+			e.find(1)
+		case term.MouseEvent:
+			e.mouse()
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package editor
+
+import "time"
+
+// wake makes the editor handle the requests from plugins and RPC
+// clients, and do the rest of its idle work, while it's waiting for
+// input. It's safe to call from any goroutine.
+func (e *Editor) wake() {
+	if t := e.waker.Load(); t != nil {
+		t.Wake()
+	}
+}
+
+// tick wakes the editor every interval until stop is called.
+func (e *Editor) tick(interval time.Duration) (stop func()) {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				e.wake()
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		ticker.Stop()
+		close(done)
+	}
+}
+
+// scheduleIdle wakes the editor once the user stops typing, so the
+// word under the cursor is highlighted, and the language server checks
+// the changes.
+func (e *Editor) scheduleIdle() {
+	if !e.occurrences && (e.lsp == nil || !e.diagnostics) {
+		return
+	}
+	if e.idletimer == nil {
+		e.idletimer = time.AfterFunc(occurrenceDelay, e.wake)
+		return
+	}
+	e.idletimer.Reset(occurrenceDelay)
+}
+
+// resizeTerminal fits the screen to the terminal, and redraws it.
+func (e *Editor) resizeTerminal() {
+	rows, cols, err := e.term.Size()
+	if err != nil {
+		e.fail("%v", err)
+	} else {
+		e.Resize(rows, cols)
+		e.log(LogInfo, "terminal", "rows", rows, "cols", cols)
+	}
+	e.refreshScreen()
+}
+
+// suspend stops the editor and returns to the shell.
+func (e *Editor) suspend() {
+	if e.term == nil {
+		e.fail("suspend: not running in a terminal")
+		return
+	}
+	if err := e.term.Suspend(); err != nil {
+		e.die("%v", err)
+	}
+	e.resizeTerminal()
+}
@@ -0,0 +1,158 @@
+package editor
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/icholy/kilo/internal/term"
+)
+
+const maxRecentFiles = 50
+
+func recentFile() string {
+	return filepath.Join(stateDir(), "recent")
+}
+
+// readRecentFiles returns the recently opened files, most recent first.
+func readRecentFiles() []string {
+	f, err := os.Open(recentFile())
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	var files []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if line := sc.Text(); line != "" {
+			files = append(files, line)
+		}
+	}
+	return files
+}
+
+// addRecentFile moves filename to the top of the recent files list.
+func addRecentFile(filename string) {
+	path, err := absPath(filename)
+	if err != nil {
+		return
+	}
+	files := []string{path}
+	for _, f := range readRecentFiles() {
+		if f != path && len(files) < maxRecentFiles {
+			files = append(files, f)
+		}
+	}
+	writeFileAtomic(recentFile(), []byte(strings.Join(files, "\n")+"\n"))
+}
+
+// displayPath shortens path for display.
+func displayPath(path string) string {
+	if wd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(wd, path); err == nil && !strings.HasPrefix(rel, "..") {
+			return rel
+		}
+	}
+	if home, err := os.UserHomeDir(); err == nil && strings.HasPrefix(path, home+"/") {
+		return "~" + path[len(home):]
+	}
+	return path
+}
+
+const welcomeShown = 9
+
+// initWelcome shows the recent files on the welcome screen.
+func (e *Editor) initWelcome() {
+	for _, f := range readRecentFiles() {
+		if _, err := os.Stat(f); err == nil {
+			e.recent = append(e.recent, f)
+		}
+		if len(e.recent) == welcomeShown {
+			break
+		}
+	}
+	e.welcome = true
+}
+
+// welcomeKey handles keys while the welcome screen is shown. Keys
+// which don't select a recent file close the welcome screen and are
+// processed normally.
+func (e *Editor) welcomeKey(c int) bool {
+	switch {
+	case c == term.ArrowUp && len(e.recent) > 0:
+		e.recentidx = (e.recentidx + len(e.recent) - 1) % len(e.recent)
+	case c == term.ArrowDown && len(e.recent) > 0:
+		e.recentidx = (e.recentidx + 1) % len(e.recent)
+	case c == '\r' && len(e.recent) > 0:
+		e.welcome = false
+		e.switchFile(displayPath(e.recent[e.recentidx]))
+	case c >= '1' && c <= '9' && int(c-'1') < len(e.recent):
+		e.welcome = false
+		e.switchFile(displayPath(e.recent[c-'1']))
+	default:
+		e.welcome = false
+		return false
+	}
+	return true
+}
+
+func (e *Editor) welcomeLines() []string {
+	lines := []string{
+		fmt.Sprintf("Kilo editor -- version %s", version),
+		"",
+	}
+	if len(e.recent) > 0 {
+		lines = append(lines, "Recent files:")
+		for i, f := range e.recent {
+			lines = append(lines, fmt.Sprintf(" %d  %s", i+1, displayPath(f)))
+		}
+		lines = append(lines, "")
+	}
+	return append(lines,
+		"Ctrl-S save     Ctrl-Q quit",
+		"Ctrl-F find     Ctrl-P commands",
+	)
+}
+
+// drawWelcome draws row y of the welcome screen.
+func (e *Editor) drawWelcome(b *bytes.Buffer, y int) bool {
+	lines := e.welcomeLines()
+	top := (e.screenrows - len(lines)) / 3
+	if top < 0 {
+		top = 0
+	}
+	i := y - top
+	if i < 0 || i >= len(lines) {
+		return false
+	}
+	var width int
+	for _, line := range lines {
+		if len(line) > width {
+			width = len(line)
+		}
+	}
+	line := lines[i]
+	padding := (e.screencols - width) / 2
+	if i == 0 {
+		// center the title on its own
+		padding = (e.screencols - len(line)) / 2
+	}
+	if padding < 0 {
+		padding = 0
+	}
+	if len(line) > e.screencols-padding {
+		line = line[:e.screencols-padding]
+	}
+	b.WriteString(strings.Repeat(" ", padding))
+	if recent := i - 3; e.welcome && recent == e.recentidx && len(e.recent) > 0 {
+		b.WriteString("\x1b[7m")
+		b.WriteString(line)
+		b.WriteString("\x1b[m")
+	} else {
+		b.WriteString(line)
+	}
+	return true
+}
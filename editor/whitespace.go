@@ -0,0 +1,11 @@
+package editor
+
+func (e *Editor) toggleWhitespace() {
+	e.opts.List = !e.opts.List
+	e.buf.Rehighlight()
+	if e.opts.List {
+		e.setStatus("showing whitespace")
+	} else {
+		e.setStatus("hiding whitespace")
+	}
+}
@@ -0,0 +1,238 @@
+package editor
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+
+	"github.com/icholy/kilo/internal/fuzzy"
+	"golang.org/x/exp/maps"
+)
+
+// maxWordCompletions is how many words are offered by complete-word.
+const maxWordCompletions = 100
+
+// wordIndex counts the words in the files edited during the session, to
+// complete words from. It's kept up to date on a background goroutine
+// with the copy of the rows the undo history keeps, so indexing a big
+// file doesn't hold up typing.
+type wordIndex struct {
+	mu    sync.Mutex
+	files map[string]*indexedFile
+	// pending holds the latest snapshot of each file which hasn't been
+	// indexed yet
+	pending map[string]wordSnapshot
+	signal  chan struct{}
+	done    chan struct{}
+}
+
+// wordSnapshot is the contents of a file to be indexed, and the
+// characters which are part of words besides letters and digits.
+type wordSnapshot struct {
+	rows      [][]byte
+	wordchars string
+}
+
+// indexedFile is the last snapshot of a file which was indexed, and the
+// number of times each word appears in it.
+type indexedFile struct {
+	wordSnapshot
+	words map[string]int
+}
+
+func newWordIndex() *wordIndex {
+	idx := &wordIndex{
+		files:   map[string]*indexedFile{},
+		pending: map[string]wordSnapshot{},
+		signal:  make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+	go idx.run()
+	return idx
+}
+
+// update queues a snapshot of a file to be indexed. The rows must not
+// be modified afterwards.
+func (idx *wordIndex) update(filename string, rows [][]byte, wordchars string) {
+	idx.mu.Lock()
+	idx.pending[filename] = wordSnapshot{rows: rows, wordchars: wordchars}
+	idx.mu.Unlock()
+	select {
+	case idx.signal <- struct{}{}:
+	default:
+	}
+}
+
+// close stops indexing.
+func (idx *wordIndex) close() {
+	close(idx.done)
+}
+
+// run indexes the queued snapshots until the index is closed. Only the
+// latest snapshot of a file is indexed when it changes faster than it
+// can be indexed.
+func (idx *wordIndex) run() {
+	for {
+		select {
+		case <-idx.signal:
+		case <-idx.done:
+			return
+		}
+		idx.mu.Lock()
+		pending := idx.pending
+		idx.pending = map[string]wordSnapshot{}
+		idx.mu.Unlock()
+		for name, s := range pending {
+			idx.index(name, s)
+		}
+	}
+}
+
+// index brings the word counts of a file up to date with a snapshot.
+// Only the rows between the parts which are the same as in the previous
+// snapshot are counted again.
+func (idx *wordIndex) index(name string, s wordSnapshot) {
+	idx.mu.Lock()
+	f := idx.files[name]
+	idx.mu.Unlock()
+	var old [][]byte
+	if f != nil && f.wordchars == s.wordchars {
+		old = f.rows
+	}
+	prefix := 0
+	for prefix < len(old) && prefix < len(s.rows) && bytes.Equal(old[prefix], s.rows[prefix]) {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(old)-prefix && suffix < len(s.rows)-prefix && bytes.Equal(old[len(old)-1-suffix], s.rows[len(s.rows)-1-suffix]) {
+		suffix++
+	}
+	delta := map[string]int{}
+	for _, row := range old[prefix : len(old)-suffix] {
+		forEachWord(row, s.wordchars, func(w []byte) { delta[string(w)]-- })
+	}
+	for _, row := range s.rows[prefix : len(s.rows)-suffix] {
+		forEachWord(row, s.wordchars, func(w []byte) { delta[string(w)]++ })
+	}
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if old == nil {
+		f = &indexedFile{words: map[string]int{}}
+		idx.files[name] = f
+	}
+	for w, n := range delta {
+		if f.words[w] += n; f.words[w] <= 0 {
+			delete(f.words, w)
+		}
+	}
+	f.wordSnapshot = s
+}
+
+// forEachWord calls fn with the words of a row. Numbers and words of a
+// single character aren't worth completing, so they're skipped.
+func forEachWord(row []byte, wordchars string, fn func(w []byte)) {
+	for i := 0; i < len(row); {
+		if !isWordByte(row[i], wordchars) {
+			i++
+			continue
+		}
+		start := i
+		for i < len(row) && isWordByte(row[i], wordchars) {
+			i++
+		}
+		if i-start > 1 && !isDigit(row[start]) {
+			fn(row[start:i])
+		}
+	}
+}
+
+// complete returns the words which fuzzy match the partial word, best
+// first. Among the ones which match as well, the words in the current
+// file come first, and then the most common.
+func (idx *wordIndex) complete(partial, current string) []string {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	counts := map[string]int{}
+	scores := map[string]int{}
+	local := map[string]bool{}
+	for name, f := range idx.files {
+		for w, n := range f.words {
+			if w == partial {
+				continue
+			}
+			if _, ok := scores[w]; !ok {
+				score, ok := fuzzy.Score(partial, w)
+				if !ok {
+					continue
+				}
+				scores[w] = score
+			}
+			counts[w] += n
+			if name == current {
+				local[w] = true
+			}
+		}
+	}
+	words := maps.Keys(counts)
+	sort.Slice(words, func(i, j int) bool {
+		a, b := words[i], words[j]
+		switch {
+		case scores[a] != scores[b]:
+			return scores[a] > scores[b]
+		case local[a] != local[b]:
+			return local[a]
+		case counts[a] != counts[b]:
+			return counts[a] > counts[b]
+		}
+		return a < b
+	})
+	return words
+}
+
+// indexWords queues the buffer to be indexed, using the copy of the
+// rows kept by the undo history.
+func (e *Editor) indexWords() {
+	if e.undocur != nil {
+		e.words.update(e.filename, e.undotext, e.wordchars)
+	}
+}
+
+// completeWord implements the complete-word command, which completes
+// the word before the cursor with the words in the files edited during
+// the session. The word is fuzzy matched, so fb completes to fooBar.
+func (e *Editor) completeWord() {
+	if e.cy >= e.buf.NumRows() {
+		return
+	}
+	chars := e.buf.Rows[e.cy].Chars
+	// the cursor may be left past the end of the row
+	end := clamp(e.cx, 0, len(chars))
+	start := end
+	for start > 0 && e.isWordByte(chars[start-1]) {
+		start--
+	}
+	if start == end {
+		e.setStatus("no word to complete")
+		return
+	}
+	partial := string(chars[start:end])
+	words := e.words.complete(partial, e.filename)
+	if len(words) > maxWordCompletions {
+		words = words[:maxWordCompletions]
+	}
+	var word string
+	switch len(words) {
+	case 0:
+		e.setStatus("no completions for %s", partial)
+		return
+	case 1:
+		word = words[0]
+	default:
+		i := e.menu(words)
+		if i < 0 {
+			return
+		}
+		word = words[i]
+	}
+	e.cy, e.cx = e.replaceRange(e.cy, start, e.cy, end, []byte(word))
+}
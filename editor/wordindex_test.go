@@ -0,0 +1,17 @@
+package editor
+
+import "testing"
+
+func TestCompleteWordPastEnd(t *testing.T) {
+	e := newTestEditor(t, "a longer line", "ab")
+	// moving down from the end of a longer line leaves the cursor past
+	// the end of the row
+	e.cy, e.cx = 1, 13
+	e.completeWord()
+	if e.status == "no word to complete" {
+		t.Errorf("status = %q, want the word before the end of the row completed", e.status)
+	}
+	if got, want := bufferText(e), "a longer line\nab"; got != want {
+		t.Errorf("buffer = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,99 @@
+package editor
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+func (e *Editor) isWordByte(c byte) bool {
+	return isWordByte(c, e.wordchars)
+}
+
+// isWordByte reports whether c is part of a word, which is made of
+// letters, digits and the extra wordchars.
+func isWordByte(c byte, wordchars string) bool {
+	// bytes of multi-byte utf-8 sequences are treated as letters
+	return c >= utf8.RuneSelf || unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c)) || strings.IndexByte(wordchars, c) >= 0
+}
+
+// wordForward returns the position after the end of the next word,
+// moving on to the following rows if necessary.
+func (e *Editor) wordForward(cx, cy int) (int, int) {
+	// skip separators, including line breaks
+	for cy < e.buf.NumRows() {
+		chars := e.buf.Rows[cy].Chars
+		for cx < len(chars) && !e.isWordByte(chars[cx]) {
+			cx++
+		}
+		if cx < len(chars) || cy == e.buf.NumRows()-1 {
+			break
+		}
+		cy++
+		cx = 0
+	}
+	if cy >= e.buf.NumRows() {
+		return cx, cy
+	}
+	chars := e.buf.Rows[cy].Chars
+	for cx < len(chars) && e.isWordByte(chars[cx]) {
+		cx++
+	}
+	return cx, cy
+}
+
+// wordBackward returns the position of the start of the previous word.
+func (e *Editor) wordBackward(cx, cy int) (int, int) {
+	if cy >= e.buf.NumRows() {
+		if cy == 0 {
+			return 0, 0
+		}
+		cy = e.buf.NumRows() - 1
+		cx = e.buf.Rows[cy].Len()
+	}
+	for {
+		chars := e.buf.Rows[cy].Chars
+		for cx > 0 && !e.isWordByte(chars[cx-1]) {
+			cx--
+		}
+		if cx > 0 || cy == 0 {
+			break
+		}
+		cy--
+		cx = e.buf.Rows[cy].Len()
+	}
+	chars := e.buf.Rows[cy].Chars
+	for cx > 0 && e.isWordByte(chars[cx-1]) {
+		cx--
+	}
+	return cx, cy
+}
+
+func (e *Editor) moveWordForward() {
+	e.cx, e.cy = e.wordForward(e.cx, e.cy)
+}
+
+func (e *Editor) moveWordBackward() {
+	e.cx, e.cy = e.wordBackward(e.cx, e.cy)
+}
+
+// deleteWordBackward deletes from the start of the previous word to the cursor.
+func (e *Editor) deleteWordBackward() {
+	cx, cy := e.wordBackward(e.cx, e.cy)
+	if cx == e.cx && cy == e.cy {
+		return
+	}
+	e.killRange(cy, cx, e.cy, e.cx, true)
+}
+
+// deleteWordForward deletes from the cursor to the end of the next word.
+func (e *Editor) deleteWordForward() {
+	if e.cy >= e.buf.NumRows() {
+		return
+	}
+	cx, cy := e.wordForward(e.cx, e.cy)
+	if cx == e.cx && cy == e.cy {
+		return
+	}
+	e.killRange(e.cy, e.cx, cy, cx, false)
+}
@@ -0,0 +1,229 @@
+package editor
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/icholy/kilo/internal/buffer"
+	"golang.org/x/exp/slices"
+)
+
+// reflowWidth is the width paragraphs are reflowed to when textwidth
+// isn't set.
+const reflowWidth = 79
+
+// listMarker matches the start of a list item in prose, e.g. "- " or
+// "1. ".
+var listMarker = regexp.MustCompile(`^([-*+]|[0-9]+[.)])[ \t]+`)
+
+// isProse reports whether the buffer holds prose rather than code.
+// Files without a filetype are treated as prose, like the spell checker
+// does.
+func (e *Editor) isProse() bool {
+	return e.buf.Syntax == nil || e.buf.Syntax.Prose
+}
+
+// lineLeader returns the prefix of a line which is kept when it's
+// wrapped: its indentation followed by a comment leader or, in prose,
+// block quote and list markers. next is the prefix of the lines the
+// rest is wrapped onto, which has spaces in place of the list marker.
+func (e *Editor) lineLeader(chars []byte) (leader, next []byte) {
+	n := skipBlanks(chars, 0)
+	text := chars[n:]
+	if syntax := e.buf.Syntax; syntax != nil {
+		comment := syntax.LineComment
+		// the stars down the side of a block comment
+		if syntax.BlockComment[0] == "/*" && bytes.HasPrefix(text, []byte("*")) && !bytes.HasPrefix(text, []byte("*/")) {
+			comment = "*"
+		}
+		if comment != "" && bytes.HasPrefix(text, []byte(comment)) {
+			n = skipBlanks(chars, n+len(comment))
+			return chars[:n], chars[:n]
+		}
+	}
+	if !e.isProse() {
+		return chars[:n], chars[:n]
+	}
+	for n < len(chars) && chars[n] == '>' {
+		n = skipBlanks(chars, n+1)
+	}
+	if m := listMarker.Find(chars[n:]); m != nil {
+		next = append(slices.Clone(chars[:n]), bytes.Repeat([]byte(" "), len(m))...)
+		return chars[:n+len(m)], next
+	}
+	return chars[:n], chars[:n]
+}
+
+// skipBlanks returns the index of the first character at or after i
+// which isn't a space or tab.
+func skipBlanks(chars []byte, i int) int {
+	for i < len(chars) && (chars[i] == ' ' || chars[i] == '\t') {
+		i++
+	}
+	return i
+}
+
+// removeBlanks returns b without its spaces and tabs, which is used to
+// compare leaders regardless of their indentation.
+func removeBlanks(b []byte) string {
+	return strings.Map(func(r rune) rune {
+		if r == ' ' || r == '\t' {
+			return -1
+		}
+		return r
+	}, string(b))
+}
+
+// columns returns the number of columns text takes up on the screen.
+func (e *Editor) columns(text []byte) int {
+	ts := e.buf.TabStop
+	if ts <= 0 {
+		ts = e.opts.TabStop
+	}
+	if ts <= 0 {
+		ts = buffer.TabStop
+	}
+	var n int
+	for _, c := range text {
+		switch {
+		case c == '\t':
+			n += ts - n%ts
+		case buffer.IsControl(c):
+			n += 2
+		default:
+			n++
+		}
+	}
+	return n
+}
+
+// autoWrap breaks the current line at the last blank before textwidth
+// once a word is typed past it. The new line starts with the leader of
+// the one it was broken off from. It only applies to prose.
+func (e *Editor) autoWrap() {
+	if e.textwidth <= 0 || !e.isProse() || e.cy >= e.buf.NumRows() || e.cx == 0 {
+		return
+	}
+	for {
+		row := e.buf.Rows[e.cy]
+		chars := row.Chars
+		if c := chars[e.cx-1]; c == ' ' || c == '\t' || row.CxToRx(e.cx) <= e.textwidth {
+			return
+		}
+		leader, next := e.lineLeader(chars)
+		// the last blank which leaves the line short enough, or the
+		// first one when a word is longer than textwidth
+		brk := -1
+		for x := len(leader) + 1; x < e.cx; x++ {
+			if chars[x] != ' ' && chars[x] != '\t' || chars[x-1] == ' ' || chars[x-1] == '\t' {
+				continue
+			}
+			if brk < 0 || row.CxToRx(x) <= e.textwidth {
+				brk = x
+			}
+		}
+		if brk < 0 {
+			return
+		}
+		start := skipBlanks(chars, brk)
+		line := append(slices.Clone(next), chars[start:]...)
+		e.cx = len(next) + e.cx - start
+		row.Truncate(brk)
+		e.insertRow(e.cy+1, line)
+		e.cy++
+		e.dirty = true
+	}
+}
+
+// continues reports whether row y continues the paragraph of the row
+// above it, which is when neither is blank and they have the same
+// leader. List items start a new paragraph.
+func (e *Editor) continues(y int) bool {
+	prev, chars := e.buf.Rows[y-1].Chars, e.buf.Rows[y].Chars
+	pleader, next := e.lineLeader(prev)
+	leader, _ := e.lineLeader(chars)
+	if len(pleader) == len(prev) || len(leader) == len(chars) {
+		return false
+	}
+	return removeBlanks(next) == removeBlanks(leader)
+}
+
+// reflow rewraps the paragraphs in the selection, or the one the cursor
+// is in, to the width given as the argument, textwidth, or reflowWidth.
+// The indentation and comment leaders of the paragraphs are kept.
+func (e *Editor) reflow(args string) {
+	width := e.textwidth
+	if args = strings.TrimSpace(args); args != "" {
+		n, err := strconv.Atoi(args)
+		if err != nil || n <= 0 {
+			e.fail("invalid width: %s", args)
+			return
+		}
+		width = n
+	}
+	if width <= 0 {
+		width = reflowWidth
+	}
+	if e.cy >= e.buf.NumRows() {
+		return
+	}
+	start, end := e.selectedRows()
+	end = clamp(end, 0, e.buf.NumRows()-1)
+	if !e.selection.active {
+		for start > 0 && e.continues(start) {
+			start--
+		}
+		for end < e.buf.NumRows()-1 && e.continues(end+1) {
+			end++
+		}
+	}
+	var lines [][]byte
+	for y := start; y <= end; {
+		n := y + 1
+		for n <= end && e.continues(n) {
+			n++
+		}
+		lines = append(lines, e.wrapParagraph(y, n, width)...)
+		y = n
+	}
+	text := bytes.Join(lines, []byte("\n"))
+	if bytes.Equal(text, e.getRange(start, 0, end, e.buf.Rows[end].Len())) {
+		return
+	}
+	e.cy, e.cx = e.replaceRange(start, 0, end, e.buf.Rows[end].Len(), text)
+	e.selection.active = false
+	e.setStatus("reflowed %d lines into %d", end-start+1, len(lines))
+}
+
+// wrapParagraph returns the words of the rows from start to end
+// exclusive filled into lines of at most width columns. Words longer
+// than width get a line of their own.
+func (e *Editor) wrapParagraph(start, end, width int) [][]byte {
+	leader, next := e.lineLeader(e.buf.Rows[start].Chars)
+	if len(leader) == e.buf.Rows[start].Len() {
+		return [][]byte{slices.Clone(e.buf.Rows[start].Chars)}
+	}
+	var words [][]byte
+	for y := start; y < end; y++ {
+		chars := e.buf.Rows[y].Chars
+		l, _ := e.lineLeader(chars)
+		words = append(words, bytes.Fields(chars[len(l):])...)
+	}
+	var lines [][]byte
+	line := slices.Clone(leader)
+	var n int
+	for _, w := range words {
+		if n > 0 && e.columns(line)+1+len(w) > width {
+			lines = append(lines, line)
+			line, n = slices.Clone(next), 0
+		}
+		if n > 0 {
+			line = append(line, ' ')
+		}
+		line = append(line, w...)
+		n++
+	}
+	return append(lines, line)
+}
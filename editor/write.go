@@ -0,0 +1,110 @@
+package editor
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// lineRange matches the line range of the write command, e.g. 10,20 or
+// a single line number.
+var lineRange = regexp.MustCompile(`^(\d+)(?:,(\d+))?$`)
+
+// parseWrite parses the arguments of the write command: an optional
+// line range, an optional >> to append, and the file name. The range is
+// zero based, and -1 without one.
+func parseWrite(args string) (start, end int, appending bool, name string, err error) {
+	start, end = -1, -1
+	args = strings.TrimSpace(args)
+	if arg, rest, _ := strings.Cut(args, " "); lineRange.MatchString(arg) {
+		m := lineRange.FindStringSubmatch(arg)
+		start, _ = strconv.Atoi(m[1])
+		end = start
+		if m[2] != "" {
+			end, _ = strconv.Atoi(m[2])
+		}
+		if start < 1 || end < start {
+			return 0, 0, false, "", fmt.Errorf("invalid range: %s", arg)
+		}
+		start, end = start-1, end-1
+		args = strings.TrimSpace(rest)
+	}
+	if strings.HasPrefix(args, ">>") {
+		appending = true
+		args = strings.TrimSpace(args[2:])
+	}
+	if args == "" {
+		return 0, 0, false, "", errors.New("expected [start,end] [>>] <file>")
+	}
+	return start, end, appending, args, nil
+}
+
+// writeRange implements the write command, which writes the selection,
+// the given range of lines, or the whole buffer to another file, or
+// appends it with >>. The buffer keeps its file name, and stays dirty if
+// it was.
+func (e *Editor) writeRange(args string) {
+	start, end, appending, name, err := parseWrite(args)
+	if err != nil {
+		e.fail("write: %v", err)
+		return
+	}
+	var text []byte
+	var lines int
+	y0, x0, y1, x1, selected := e.selectionBounds()
+	switch {
+	case start >= 0:
+		if start >= e.buf.NumRows() {
+			e.fail("write: the file only has %d lines", e.buf.NumRows())
+			return
+		}
+		end = clamp(end, 0, e.buf.NumRows()-1)
+		text = append(e.getRange(start, 0, end, e.buf.Rows[end].Len()), '\n')
+		lines = end - start + 1
+	case selected:
+		text = e.getRange(y0, x0, y1, x1)
+		if len(text) > 0 && text[len(text)-1] != '\n' {
+			text = append(text, '\n')
+		}
+		lines = strings.Count(string(text), "\n")
+	default:
+		text = e.rowsToBytes()
+		lines = e.buf.NumRows()
+	}
+	if err := writeOrAppend(name, text, appending); err != nil {
+		e.fail("write: %v", err)
+		return
+	}
+	if appending {
+		e.setStatus("appended %d lines to %s", lines, name)
+	} else {
+		e.setStatus("wrote %d lines to %s", lines, name)
+	}
+}
+
+// writeOrAppend writes data to the named file, replacing its contents
+// unless appending. Remote files can only be replaced.
+func writeOrAppend(name string, data []byte, appending bool) error {
+	if rf, ok := parseRemote(name); ok {
+		if appending {
+			return errors.New("can't append to remote files")
+		}
+		return rf.Write(data)
+	}
+	flag := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if appending {
+		flag = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+	f, err := os.OpenFile(name, flag, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -3,6 +3,10 @@ module github.com/icholy/kilo
 go 1.19
 
 require (
+	github.com/BurntSushi/toml v1.4.0
+	github.com/creack/pty v1.1.18
+	github.com/yuin/gopher-lua v1.1.1
+	golang.org/x/crypto v0.3.0
 	golang.org/x/exp v0.0.0-20221114191408-850992195362
 	golang.org/x/sys v0.2.0
 )
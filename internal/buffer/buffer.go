@@ -0,0 +1,168 @@
+// Package buffer holds the rows of text being edited and highlights them.
+package buffer
+
+import "golang.org/x/exp/slices"
+
+// Options control how rows are highlighted. They're shared by all the
+// buffers of an editor.
+type Options struct {
+	// Spell highlights the words missing from Dict.
+	Spell bool
+	Dict  Dictionary
+	// List shows tabs and trailing whitespace, and spaces as well when
+	// ListSpaces is set.
+	List       bool
+	ListSpaces bool
+	// TabStop is the number of columns between tab stops, the TabStop
+	// constant when zero.
+	TabStop int
+	// Todo are the keywords highlighted inside comments.
+	Todo []string
+	// Warn highlights indentation which mixes tabs and spaces, and
+	// invisible or confusable characters.
+	Warn bool
+}
+
+// Buffer is a list of rows.
+type Buffer struct {
+	Rows []*Row
+	// Changes is incremented by every modification, which lets callers
+	// detect whether an operation changed the buffer.
+	Changes int
+	Syntax  *Syntax
+	Options *Options
+	// TabStop overrides the tab stop of the options when it's set.
+	TabStop int
+	// scanned is the number of leading rows whose close states are
+	// known to be valid as of the change count scanchanges
+	scanned     int
+	scanchanges int
+}
+
+// New returns an empty buffer.
+func New(opts *Options) *Buffer {
+	return &Buffer{Options: opts}
+}
+
+// NumRows returns the number of rows.
+func (b *Buffer) NumRows() int {
+	return len(b.Rows)
+}
+
+// InsertRow inserts a row containing chars before row at.
+func (b *Buffer) InsertRow(at int, chars []byte) {
+	row := &Row{Chars: chars, buf: b}
+	row.Update()
+	b.Rows = insert(b.Rows, at, row)
+	b.Changes++
+}
+
+// AppendRows adds a row for each of lines to the end of the buffer,
+// which is quicker than inserting them one at a time. The rows use the
+// slices as their contents.
+func (b *Buffer) AppendRows(lines [][]byte) {
+	b.Rows = slices.Grow(b.Rows, len(lines))
+	for _, chars := range lines {
+		row := &Row{Chars: chars, buf: b, stale: true}
+		b.Rows = append(b.Rows, row)
+	}
+	b.Changes++
+}
+
+// ReplaceRows replaces rows [start, end) with a row for each of lines,
+// which use the slices as their contents.
+func (b *Buffer) ReplaceRows(start, end int, lines [][]byte) {
+	rows := make([]*Row, len(lines))
+	for i, chars := range lines {
+		rows[i] = &Row{Chars: chars, buf: b, stale: true}
+	}
+	b.Rows = slices.Replace(b.Rows, start, end, rows...)
+	b.Changes++
+}
+
+// insert inserts v into s at i. Unlike slices.Insert, the capacity
+// grows geometrically, so repeatedly inserting at the end is linear.
+func insert[S ~[]E, E any](s S, i int, v ...E) S {
+	n := len(s)
+	s = append(s, v...)
+	copy(s[i+len(v):], s[i:n])
+	copy(s[i:], v)
+	return s
+}
+
+// DeleteRows deletes rows [start, end).
+func (b *Buffer) DeleteRows(start, end int) {
+	b.Rows = slices.Delete(b.Rows, start, end)
+	b.Changes++
+}
+
+// Clear deletes every row.
+func (b *Buffer) Clear() {
+	b.Rows = b.Rows[:0]
+	b.Changes++
+}
+
+// Rehighlight marks every row to be highlighted again when it's next
+// drawn, used after changing the syntax or the options.
+func (b *Buffer) Rehighlight() {
+	for _, r := range b.Rows {
+		r.stale = true
+		r.scanned = false
+	}
+	b.scanned = 0
+}
+
+// SetTabStop changes the tab stop of the buffer.
+func (b *Buffer) SetTabStop(ts int) {
+	b.TabStop = ts
+	b.Rerender()
+}
+
+// Rerender marks every row to be rendered again, used after changing
+// the tab stop. This doesn't count as a change to the text.
+func (b *Buffer) Rerender() {
+	for _, r := range b.Rows {
+		r.invalidate()
+	}
+	b.scanned = 0
+}
+
+// Highlight brings the highlights of row y up to date. Block comments
+// and fenced code blocks span rows, so the rows above which changed
+// are scanned first to find the state row y starts in.
+func (b *Buffer) Highlight(y int) {
+	if b.Changes != b.scanchanges {
+		b.scanned, b.scanchanges = 0, b.Changes
+	}
+	for ; b.scanned <= y; b.scanned++ {
+		r := b.Rows[b.scanned]
+		var open state
+		if b.scanned > 0 {
+			open = b.Rows[b.scanned-1].close
+		}
+		if r.open != open {
+			r.open = open
+			r.stale = true
+			r.scanned = false
+		}
+		if !r.scanned {
+			r.close = r.lex(nil)
+			r.scanned = true
+		}
+	}
+	b.Rows[y].Highlight()
+}
+
+func (r *Row) syntax() *Syntax {
+	if r.buf == nil {
+		return nil
+	}
+	return r.buf.Syntax
+}
+
+func (r *Row) options() *Options {
+	if r.buf == nil || r.buf.Options == nil {
+		return &Options{}
+	}
+	return r.buf.Options
+}
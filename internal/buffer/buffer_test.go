@@ -0,0 +1,138 @@
+package buffer
+
+import (
+	"strings"
+	"testing"
+)
+
+// newBuffer returns a buffer of the lines.
+func newBuffer(lines ...string) *Buffer {
+	b := New(&Options{})
+	for _, line := range lines {
+		b.InsertRow(b.NumRows(), []byte(line))
+	}
+	return b
+}
+
+// text returns the lines of the buffer joined by newlines.
+func text(b *Buffer) string {
+	lines := make([]string, b.NumRows())
+	for i, r := range b.Rows {
+		lines[i] = string(r.Chars)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestRows(t *testing.T) {
+	b := newBuffer("one", "three")
+	b.InsertRow(1, []byte("two"))
+	b.InsertRow(0, []byte("zero"))
+	if got, want := text(b), "zero\none\ntwo\nthree"; got != want {
+		t.Fatalf("after InsertRow: %q, want %q", got, want)
+	}
+	changes := b.Changes
+	b.DeleteRows(1, 3)
+	if got, want := text(b), "zero\nthree"; got != want {
+		t.Fatalf("after DeleteRows: %q, want %q", got, want)
+	}
+	b.AppendRows([][]byte{[]byte("four"), []byte("five")})
+	b.ReplaceRows(0, 2, [][]byte{[]byte("a"), []byte("b"), []byte("c")})
+	if got, want := text(b), "a\nb\nc\nfour\nfive"; got != want {
+		t.Fatalf("after ReplaceRows: %q, want %q", got, want)
+	}
+	if b.Changes != changes+3 {
+		t.Errorf("Changes = %d, want %d", b.Changes, changes+3)
+	}
+	b.Clear()
+	if b.NumRows() != 0 {
+		t.Errorf("NumRows after Clear = %d, want 0", b.NumRows())
+	}
+}
+
+func TestRowEdits(t *testing.T) {
+	b := newBuffer("ac")
+	r := b.Rows[0]
+	v := r.Version()
+	r.InsertChar(1, 'b')
+	r.InsertChar(-1, 'd')
+	r.Append([]byte("ef"))
+	r.DeleteChar(0)
+	if got, want := string(r.Chars), "bcdef"; got != want {
+		t.Errorf("Chars = %q, want %q", got, want)
+	}
+	r.Truncate(2)
+	if got, want := string(r.Chars), "bc"; got != want {
+		t.Errorf("Chars after Truncate = %q, want %q", got, want)
+	}
+	if r.Version() == v {
+		t.Error("Version didn't change")
+	}
+}
+
+func TestRender(t *testing.T) {
+	b := newBuffer("\tx\ty", "ab\x01c")
+	if got, want := string(b.Rows[0].Render()), "        x       y"; got != want {
+		t.Errorf("Render = %q, want %q", got, want)
+	}
+	if got, want := string(b.Rows[1].Render()), "ab^Ac"; got != want {
+		t.Errorf("Render = %q, want %q", got, want)
+	}
+	b.TabStop = 4
+	b.Rerender()
+	if got, want := string(b.Rows[0].Render()), "    x   y"; got != want {
+		t.Errorf("Render with a tab stop of 4 = %q, want %q", got, want)
+	}
+}
+
+func TestColumns(t *testing.T) {
+	b := newBuffer("\tx\ty")
+	r := b.Rows[0]
+	for _, tt := range []struct{ cx, rx int }{{0, 0}, {1, 8}, {2, 9}, {3, 16}, {4, 17}} {
+		if rx := r.CxToRx(tt.cx); rx != tt.rx {
+			t.Errorf("CxToRx(%d) = %d, want %d", tt.cx, rx, tt.rx)
+		}
+		if cx := r.RxToCx(tt.rx); cx != tt.cx {
+			t.Errorf("RxToCx(%d) = %d, want %d", tt.rx, cx, tt.cx)
+		}
+	}
+	// the columns inside a tab are on the tab, and the ones past the end
+	// are at the end
+	for _, tt := range []struct{ rx, cx int }{{3, 0}, {12, 2}, {100, 4}} {
+		if cx := r.RxToCx(tt.rx); cx != tt.cx {
+			t.Errorf("RxToCx(%d) = %d, want %d", tt.rx, cx, tt.cx)
+		}
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	b := newBuffer(`func f() { return "s" } // c`, "/* a", "b */ x := 1")
+	b.Syntax = SyntaxFor("x.go")
+	b.Rehighlight()
+	for y := range b.Rows {
+		b.Highlight(y)
+	}
+	tests := []struct {
+		y    int
+		text string
+		want Highlight
+	}{
+		{0, "func", HighlightKeyword},
+		{0, "return", HighlightKeyword},
+		{0, `"s"`, HighlightString},
+		{0, "// c", HighlightComment},
+		{1, "/* a", HighlightComment},
+		{2, "b */", HighlightComment},
+		{2, "x", HighlightNormal},
+		{2, "1", HighlightNumber},
+	}
+	for _, tt := range tests {
+		r := b.Rows[tt.y]
+		i := strings.Index(string(r.Chars), tt.text)
+		for x := i; x < i+len(tt.text); x++ {
+			if r.HL[x] != tt.want {
+				t.Errorf("row %d: highlight of %q at %d = %d, want %d", tt.y, tt.text, x, r.HL[x], tt.want)
+				break
+			}
+		}
+	}
+}
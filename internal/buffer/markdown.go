@@ -0,0 +1,180 @@
+package buffer
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+)
+
+// markdownLeader matches the block quote and list markers at the start
+// of a line of markdown, including a task list checkbox.
+var markdownLeader = regexp.MustCompile(`^[ \t]*(>[ \t]?)*([-*+][ \t]+|[0-9]+[.)][ \t]+)?(\[[ xX]\][ \t]+)?`)
+
+// lexMarkdown highlights a line of markdown. Fenced code blocks are
+// highlighted with the syntax of the language named after the opening
+// fence, and HTML comments can span lines.
+func lexMarkdown(text []byte, st state, hl []Highlight) state {
+	rest := bytes.TrimLeft(text, " ")
+	if st.fence != "" {
+		if closesFence(rest, st.fence) {
+			mark(hl, 0, len(text), HighlightComment)
+			return state{}
+		}
+		if st.lang == nil {
+			mark(hl, 0, len(text), HighlightCode)
+			return st
+		}
+		return lexCode(st.lang, text, st, hl)
+	}
+	var i int
+	if st.comment {
+		end := bytes.Index(text, []byte("-->"))
+		if end < 0 {
+			mark(hl, 0, len(text), HighlightComment)
+			return st
+		}
+		mark(hl, 0, end+3, HighlightComment)
+		st.comment = false
+		i = end + 3
+	} else if fence := openFence(rest); fence != "" {
+		mark(hl, 0, len(text), HighlightComment)
+		info := strings.Fields(string(rest[len(fence):]))
+		st.fence = fence
+		if len(info) > 0 {
+			st.lang = fenceSyntax(info[0])
+		}
+		return st
+	} else if n := headingLevel(rest); n > 0 {
+		mark(hl, 0, len(text), HighlightHeading)
+		return st
+	} else {
+		i = len(markdownLeader.Find(text))
+		mark(hl, 0, i, HighlightKeyword)
+	}
+	return lexInline(text, i, st, hl)
+}
+
+// lexInline highlights the code spans, emphasis, and HTML comments in
+// text from i.
+func lexInline(text []byte, i int, st state, hl []Highlight) state {
+	for i < len(text) {
+		c := text[i]
+		switch {
+		case c == '\\':
+			i += 2
+		case bytes.HasPrefix(text[i:], []byte("<!--")):
+			end := bytes.Index(text[i+4:], []byte("-->"))
+			if end < 0 {
+				mark(hl, i, len(text), HighlightComment)
+				st.comment = true
+				return st
+			}
+			mark(hl, i, i+4+end+3, HighlightComment)
+			i += 4 + end + 3
+		case c == '`':
+			// a code span ends with as many backticks as it started
+			n := runLength(text[i:], '`')
+			delim := text[i : i+n]
+			end := bytes.Index(text[i+n:], delim)
+			if end < 0 {
+				i += n
+				continue
+			}
+			mark(hl, i, i+n+end+n, HighlightCode)
+			i += n + end + n
+		case c == '*' || c == '_':
+			n := runLength(text[i:], c)
+			if n > 3 {
+				n = 3
+			}
+			if end := emphasisEnd(text, i, n); end > 0 {
+				h := HighlightEmphasis
+				if n > 1 {
+					h = HighlightStrong
+				}
+				mark(hl, i, end, h)
+				i = end
+				continue
+			}
+			i += n
+		default:
+			i++
+		}
+	}
+	return st
+}
+
+// emphasisEnd returns the end of the emphasis which starts with n stars
+// or underscores at text[i], or -1 if it isn't emphasis. The opening
+// delimiter must be followed by text, and the closing one preceded by
+// it. Underscores inside words, as in snake_case, aren't emphasis.
+func emphasisEnd(text []byte, i, n int) int {
+	c := text[i]
+	start := i + n
+	if start >= len(text) || text[start] == ' ' || text[start] == '\t' {
+		return -1
+	}
+	if c == '_' && i > 0 && IsWordChar(text[i-1]) {
+		return -1
+	}
+	delim := text[i:start]
+	for j := start + 1; j+n <= len(text); j++ {
+		if !bytes.Equal(text[j:j+n], delim) || text[j-1] == ' ' || text[j-1] == '\t' {
+			continue
+		}
+		if c == '_' && j+n < len(text) && IsWordChar(text[j+n]) {
+			continue
+		}
+		return j + n
+	}
+	return -1
+}
+
+// runLength returns the number of times c repeats at the start of text.
+func runLength(text []byte, c byte) int {
+	var n int
+	for n < len(text) && text[n] == c {
+		n++
+	}
+	return n
+}
+
+// openFence returns the backticks or tildes which open a fenced code
+// block at the start of line, or "" if it doesn't open one.
+func openFence(line []byte) string {
+	if len(line) == 0 || line[0] != '`' && line[0] != '~' {
+		return ""
+	}
+	n := runLength(line, line[0])
+	if n < 3 || line[0] == '`' && bytes.IndexByte(line[n:], '`') >= 0 {
+		return ""
+	}
+	return string(line[:n])
+}
+
+// closesFence reports whether line closes the code block opened by
+// fence, which takes at least as many of the same character.
+func closesFence(line []byte, fence string) bool {
+	line = bytes.TrimRight(line, " \t")
+	return len(line) >= len(fence) && runLength(line, fence[0]) == len(line)
+}
+
+// headingLevel returns the level of an ATX heading, e.g. 2 for
+// "## Usage", or 0 if line isn't one.
+func headingLevel(line []byte) int {
+	n := runLength(line, '#')
+	if n == 0 || n > 6 || n < len(line) && line[n] != ' ' && line[n] != '\t' {
+		return 0
+	}
+	return n
+}
+
+// fenceSyntax finds the syntax of the language named after a fence,
+// either by filetype or by extension, e.g. go, js, or sh.
+func fenceSyntax(lang string) *Syntax {
+	lang = strings.ToLower(strings.Trim(lang, "{}."))
+	if s := SyntaxNamed(lang); s != nil {
+		return s
+	}
+	return SyntaxFor("file." + lang)
+}
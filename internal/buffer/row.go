@@ -0,0 +1,456 @@
+package buffer
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+	"unicode"
+
+	"golang.org/x/exp/slices"
+)
+
+// TabStop is the default number of columns between tab stops.
+const TabStop = 8
+
+type Highlight int
+
+const (
+	HighlightNormal Highlight = iota
+	HighlightNumber
+	HighlightMatch
+	HighlightKeyword
+	HighlightType
+	HighlightString
+	HighlightComment
+	HighlightSpell
+	HighlightTab
+	HighlightSpace
+	HighlightTrailing
+	HighlightTrailingTab
+	HighlightControl
+	HighlightHeading
+	HighlightEmphasis
+	HighlightStrong
+	HighlightCode
+	HighlightTodo
+	HighlightMixedIndent
+	HighlightSuspicious
+)
+
+// Row is a line of text. HL holds the highlight of each byte of the
+// rendered row once Highlight has been called.
+type Row struct {
+	Chars []byte
+	HL    []Highlight
+	buf   *Buffer
+	// render is Chars as it's displayed, nil until Render is called
+	render []byte
+	// wide are the bytes of Chars that take up other than one render
+	// column, in order, computed along with render
+	wide []wideChar
+	// stale is set when render changed since HL was computed
+	stale bool
+	// open is the state the row starts in, and close the one it ends
+	// in, which is only valid when scanned is set
+	open, close state
+	scanned     bool
+	// version counts the modifications of Chars
+	version int
+}
+
+// state is what a row starts or ends inside of, for the constructs
+// which span rows.
+type state struct {
+	// comment is set inside a block comment
+	comment bool
+	// quote is the quote of the raw string the row is inside of
+	quote byte
+	// fence is the line of backticks or tildes which opened a fenced
+	// code block in markdown, and lang is the syntax of its code, or
+	// nil when it's not known
+	fence string
+	lang  *Syntax
+}
+
+// wideChar is a tab or a control character. end is the render column
+// after it.
+type wideChar struct {
+	cx, end int
+}
+
+func (r *Row) Len() int {
+	return len(r.Chars)
+}
+
+func (r *Row) Truncate(n int) {
+	if r.Len() > n {
+		r.Chars = r.Chars[:n]
+		r.Update()
+	}
+}
+
+func (r *Row) InsertChar(at, c int) {
+	if at < 0 || at > r.Len() {
+		at = r.Len()
+	}
+	r.Chars = insert(r.Chars, at, byte(c))
+	r.Update()
+}
+
+func (r *Row) DeleteChar(at int) {
+	if at < 0 || at > r.Len() {
+		return
+	}
+	r.Chars = slices.Delete(r.Chars, at, at+1)
+	r.Update()
+}
+
+func (r *Row) Append(chars []byte) {
+	r.Chars = append(r.Chars, chars...)
+	r.Update()
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+// IsControl reports whether c is a control character other than tab.
+// These are rendered as a caret followed by a letter, e.g. ^A.
+func IsControl(c byte) bool {
+	return c < ' ' && c != '\t' || c == 0x7f
+}
+
+// tabStop returns the tab stop of the row's buffer.
+func (r *Row) tabStop() int {
+	switch {
+	case r.buf == nil:
+		return TabStop
+	case r.buf.TabStop > 0:
+		return r.buf.TabStop
+	case r.options().TabStop > 0:
+		return r.options().TabStop
+	}
+	return TabStop
+}
+
+// renderWidth returns the number of render columns taken up by c
+// when it starts at render column rx.
+func (r *Row) renderWidth(c byte, rx int) int {
+	switch {
+	case c == '\t':
+		ts := r.tabStop()
+		return ts - rx%ts
+	case IsControl(c):
+		return 2
+	default:
+		return 1
+	}
+}
+
+// IsDelim reports whether c separates tokens.
+func IsDelim(c byte) bool {
+	if unicode.IsSpace(rune(c)) || c == 0 {
+		return true
+	}
+	switch c {
+	case ',', '.', '(', ')', '+', '-', '/', '*', '=', '~', '%', '<', '>', '[', ']', '^', ':':
+		return true
+	default:
+		return false
+	}
+}
+
+// tokenHighlight returns the highlight of an identifier in code.
+func tokenHighlight(syntax *Syntax, token []byte) Highlight {
+	if syntax == nil || syntax.Keywords == nil {
+		switch {
+		case isKeyword(token):
+			return HighlightKeyword
+		case isType(token):
+			return HighlightType
+		}
+		return HighlightNormal
+	}
+	for _, k := range syntax.Keywords {
+		if string(token) == k {
+			return HighlightKeyword
+		}
+	}
+	for _, t := range syntax.Types {
+		if string(token) == t {
+			return HighlightType
+		}
+	}
+	return HighlightNormal
+}
+
+// isKeyword and isType are the generic lists of keywords and types
+// used by the filetypes which don't have their own.
+func isKeyword(token []byte) bool {
+	switch string(token) {
+	case "if", "else", "switch", "case", "func", "then", "for", "var", "type", "interface", "const", "range",
+		"return", "struct", "default", "iota", "nil", "package", "import", "map", "break", "continue":
+		return true
+	default:
+		return false
+	}
+}
+
+func isType(token []byte) bool {
+	switch string(token) {
+	case "int", "int8", "int16", "int32", "int64",
+		"uint", "uint8", "uint16", "uint32", "uint64",
+		"float32", "float64",
+		"byte", "rune", "bool", "string",
+		"complex64", "complex128",
+		"any", "error", "comparable":
+		return true
+	default:
+		return false
+	}
+}
+
+// Update marks the row to be rendered and highlighted again after
+// Chars was modified.
+func (r *Row) Update() {
+	if r.buf != nil {
+		r.buf.Changes++
+	}
+	r.version++
+	r.invalidate()
+}
+
+// Version returns a number which changes whenever the row is updated,
+// which tells whether a row kept since was modified.
+func (r *Row) Version() int {
+	return r.version
+}
+
+// invalidate drops the rendered row, so it's rendered and highlighted
+// again when it's next needed.
+func (r *Row) invalidate() {
+	r.render = nil
+	r.wide = nil
+	r.stale = true
+	r.scanned = false
+}
+
+// Render returns Chars as it's displayed, with tabs expanded and
+// control characters shown as ^X. It's computed the first time it's
+// needed after a change. Rows without tabs or control characters, which
+// are most of them, share the array of Chars instead of copying it.
+func (r *Row) Render() []byte {
+	if r.render != nil || r.Len() == 0 {
+		return r.render
+	}
+	plain := true
+	for _, c := range r.Chars {
+		if c == '\t' || IsControl(c) {
+			plain = false
+			break
+		}
+	}
+	if plain {
+		r.render = r.Chars[:r.Len():r.Len()]
+		return r.render
+	}
+	render := make([]byte, 0, r.Len())
+	ts := r.tabStop()
+	for cx, b := range r.Chars {
+		if b == '\t' {
+			render = append(render, ' ')
+			for len(render)%ts != 0 {
+				render = append(render, ' ')
+			}
+		} else if IsControl(b) {
+			render = append(render, '^', b^0x40)
+		} else {
+			render = append(render, b)
+			continue
+		}
+		r.wide = append(r.wide, wideChar{cx: cx, end: len(render)})
+	}
+	r.render = render
+	return render
+}
+
+// Highlight brings HL up to date with Render. Rows are highlighted
+// lazily, when they're drawn, so editing a row or changing the options
+// of a large buffer doesn't highlight the rows that aren't visible. The
+// row is assumed to start in the state it did last time, use
+// Buffer.Highlight to account for changes to the rows above.
+func (r *Row) Highlight() {
+	if r.stale || len(r.HL) != len(r.Render()) {
+		r.UpdateSyntax()
+	}
+}
+
+// UpdateSyntax highlights the row now.
+func (r *Row) UpdateSyntax() {
+	r.stale = false
+	render := r.Render()
+	// reallocate when the row shrank a lot, so HL doesn't keep the
+	// memory of the longest the row has ever been
+	if len(render) > cap(r.HL) || cap(r.HL) > 2*len(render)+16 {
+		r.HL = make([]Highlight, len(render))
+	}
+	r.HL = r.HL[:len(render)]
+	for i := range r.HL {
+		r.HL[i] = HighlightNormal
+	}
+	r.close = r.lex(r.HL)
+	r.scanned = true
+	r.markControl()
+	r.spellCheck()
+	r.markTodo()
+	r.markWhitespace()
+	r.markWarnings()
+}
+
+// lex highlights the row into hl starting in the open state, and
+// returns the state it ends in. When hl is nil, only the state is
+// computed.
+func (r *Row) lex(hl []Highlight) state {
+	syntax := r.syntax()
+	if syntax != nil && syntax.Filetype == "markdown" {
+		return lexMarkdown(r.Render(), r.open, hl)
+	}
+	return lexCode(syntax, r.Render(), r.open, hl)
+}
+
+// mark highlights text[start:end] as h, unless hl is nil.
+func mark(hl []Highlight, start, end int, h Highlight) {
+	if hl == nil {
+		return
+	}
+	for i := start; i < end; i++ {
+		hl[i] = h
+	}
+}
+
+// lexCode highlights a line of code with the comment syntax and
+// keywords of the filetype. Strings and runes end with the line, except
+// for raw strings, and backslashes escape the character after them.
+func lexCode(syntax *Syntax, text []byte, st state, hl []Highlight) state {
+	var comment, open, close []byte
+	var raw string
+	if syntax != nil {
+		comment = []byte(syntax.LineComment)
+		open, close = []byte(syntax.BlockComment[0]), []byte(syntax.BlockComment[1])
+		raw = syntax.RawQuotes
+	}
+	var quote byte
+	token := -1 // the start of the identifier
+	flush := func(end int) {
+		if token >= 0 {
+			mark(hl, token, end, tokenHighlight(syntax, text[token:end]))
+			token = -1
+		}
+	}
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case st.comment:
+			if len(close) > 0 && bytes.HasPrefix(text[i:], close) {
+				mark(hl, i, i+len(close), HighlightComment)
+				i += len(close) - 1
+				st.comment = false
+			} else {
+				mark(hl, i, i+1, HighlightComment)
+			}
+		case st.quote != 0:
+			mark(hl, i, i+1, HighlightString)
+			if c == st.quote {
+				st.quote = 0
+			}
+		case quote != 0:
+			mark(hl, i, i+1, HighlightString)
+			if c == '\\' && i+1 < len(text) {
+				i++
+				mark(hl, i, i+1, HighlightString)
+			} else if c == quote {
+				quote = 0
+			}
+		case token < 0 && strings.IndexByte(raw, c) >= 0:
+			mark(hl, i, i+1, HighlightString)
+			st.quote = c
+		case c == '"' || c == '\'':
+			flush(i)
+			mark(hl, i, i+1, HighlightString)
+			quote = c
+		case len(comment) > 0 && bytes.HasPrefix(text[i:], comment):
+			flush(i)
+			mark(hl, i, len(text), HighlightComment)
+			return st
+		case len(open) > 0 && bytes.HasPrefix(text[i:], open):
+			flush(i)
+			mark(hl, i, i+len(open), HighlightComment)
+			i += len(open) - 1
+			st.comment = true
+		case IsDelim(c):
+			flush(i)
+		case isDigit(c) && token < 0:
+			// the whole literal, e.g. 0x1F, 1_000, or 1.5e9
+			j := i + 1
+			for j < len(text) && (isDigit(text[j]) || IsWordChar(text[j]) || text[j] == '_' || text[j] == '.') {
+				j++
+			}
+			mark(hl, i, j, HighlightNumber)
+			i = j - 1
+		default:
+			if token < 0 {
+				token = i
+			}
+		}
+	}
+	flush(len(text))
+	return st
+}
+
+// markControl highlights the ^X sequences which control characters
+// are rendered as.
+func (r *Row) markControl() {
+	var rx int
+	for _, c := range r.Chars {
+		if IsControl(c) {
+			r.HL[rx] = HighlightControl
+			r.HL[rx+1] = HighlightControl
+		}
+		rx += r.renderWidth(c, rx)
+	}
+}
+
+// RxToCx converts a render column into an index into chars. A column
+// in the middle of a tab maps to the tab. It only searches the tabs and
+// control characters, so it's quick on long rows.
+func (r *Row) RxToCx(rx int) int {
+	r.Render()
+	// the wide chars which end at or before rx
+	n := sort.Search(len(r.wide), func(i int) bool { return r.wide[i].end > rx })
+	var cx int
+	if n > 0 {
+		w := r.wide[n-1]
+		cx = w.cx + 1 + rx - w.end
+	} else {
+		cx = rx
+	}
+	if n < len(r.wide) && cx > r.wide[n].cx {
+		cx = r.wide[n].cx
+	}
+	if cx > r.Len() {
+		return r.Len()
+	}
+	return cx
+}
+
+// CxToRx converts an index into chars into a render column.
+func (r *Row) CxToRx(cx int) int {
+	r.Render()
+	// the wide chars before cx
+	n := sort.Search(len(r.wide), func(i int) bool { return r.wide[i].cx >= cx })
+	if n == 0 {
+		return cx
+	}
+	w := r.wide[n-1]
+	return w.end + cx - w.cx - 1
+}
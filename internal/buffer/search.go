@@ -0,0 +1,52 @@
+package buffer
+
+import "bytes"
+
+// horspoolMin is the length from which patterns are searched with the
+// Boyer-Moore-Horspool algorithm. For shorter ones the vectorized
+// bytes.Index is faster.
+const horspoolMin = 16
+
+// Finder searches rows for a fixed pattern. The pattern is preprocessed
+// once, so a Finder should be reused for every row of a search.
+type Finder struct {
+	pattern []byte
+	// skip is how far the pattern can be shifted when the byte aligned
+	// with its last byte doesn't end a match
+	skip *[256]int
+}
+
+// NewFinder returns a Finder for pattern.
+func NewFinder(pattern string) *Finder {
+	f := &Finder{pattern: []byte(pattern)}
+	if n := len(pattern); n >= horspoolMin {
+		f.skip = new([256]int)
+		for i := range f.skip {
+			f.skip[i] = n
+		}
+		for i := 0; i < n-1; i++ {
+			f.skip[pattern[i]] = n - 1 - i
+		}
+	}
+	return f
+}
+
+// Len returns the length of the pattern.
+func (f *Finder) Len() int {
+	return len(f.pattern)
+}
+
+// Index returns the index of the first occurrence of the pattern in
+// text, or -1.
+func (f *Finder) Index(text []byte) int {
+	if f.skip == nil {
+		return bytes.Index(text, f.pattern)
+	}
+	last := len(f.pattern) - 1
+	for i := 0; i+last < len(text); i += f.skip[text[i+last]] {
+		if text[i+last] == f.pattern[last] && bytes.Equal(text[i:i+last], f.pattern[:last]) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,21 @@
+package buffer
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestFinder(t *testing.T) {
+	long := strings.Repeat("abcdefgh", 2) + "!"
+	text := []byte("xx " + long[:10] + " " + long + " yy abc")
+	for _, pattern := range []string{"a", "abc", "yy", "zz", long, long[1:], long + "?", "x", ""} {
+		f := NewFinder(pattern)
+		if f.Len() != len(pattern) {
+			t.Errorf("Len of %q = %d, want %d", pattern, f.Len(), len(pattern))
+		}
+		if got, want := f.Index(text), bytes.Index(text, []byte(pattern)); got != want {
+			t.Errorf("Index of %q = %d, want %d", pattern, got, want)
+		}
+	}
+}
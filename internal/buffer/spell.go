@@ -0,0 +1,132 @@
+package buffer
+
+import (
+	"bufio"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// Dictionary is a set of correctly spelled lower case words.
+type Dictionary map[string]bool
+
+// LoadDictionary reads a plain word list or a hunspell .dic file.
+// Hunspell affix rules aren't applied, only the stems are used.
+func LoadDictionary(path string) (Dictionary, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	d := Dictionary{}
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		word, _, _ := strings.Cut(sc.Text(), "/")
+		word = strings.TrimSpace(word)
+		if word != "" {
+			d[strings.ToLower(word)] = true
+		}
+	}
+	return d, sc.Err()
+}
+
+// Check reports whether word is spelled correctly.
+func (d Dictionary) Check(word string) bool {
+	word = strings.ToLower(word)
+	if d[word] {
+		return true
+	}
+	// possessives and contractions
+	if stem := strings.TrimSuffix(word, "'s"); stem != word && d[stem] {
+		return true
+	}
+	return false
+}
+
+// Suggest returns the dictionary words which are a single edit
+// (deletion, transposition, substitution, or insertion) away from word.
+func (d Dictionary) Suggest(word string) []string {
+	const letters = "abcdefghijklmnopqrstuvwxyz"
+	lower := strings.ToLower(word)
+	seen := map[string]bool{}
+	var suggestions []string
+	add := func(s string) {
+		if d[s] && !seen[s] {
+			seen[s] = true
+			suggestions = append(suggestions, matchCase(s, word))
+		}
+	}
+	for i := 0; i <= len(lower); i++ {
+		a, b := lower[:i], lower[i:]
+		if len(b) > 0 {
+			add(a + b[1:])
+		}
+		if len(b) > 1 {
+			add(a + string(b[1]) + string(b[0]) + b[2:])
+		}
+		for _, c := range letters {
+			if len(b) > 0 {
+				add(a + string(c) + b[1:])
+			}
+			add(a + string(c) + b)
+		}
+	}
+	return suggestions
+}
+
+// matchCase capitalizes s if the original word was capitalized.
+func matchCase(s, original string) string {
+	if original != "" && unicode.IsUpper(rune(original[0])) {
+		return strings.ToUpper(s[:1]) + s[1:]
+	}
+	return s
+}
+
+// IsWordChar reports whether c can be part of a word.
+func IsWordChar(c byte) bool {
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || c == '\''
+}
+
+func isIdentChar(c byte) bool {
+	return isDigit(c) || c == '_'
+}
+
+// spellCheck highlights the misspelled words in the row. Code is only
+// checked inside comments and strings, and code spans and fenced code
+// blocks in markdown aren't checked.
+func (r *Row) spellCheck() {
+	opts := r.options()
+	if !opts.Spell || opts.Dict == nil {
+		return
+	}
+	syntax := r.syntax()
+	prose := (syntax == nil || syntax.Prose) && r.open.fence == ""
+	render := r.Render()
+	for i := 0; i < len(render); {
+		if !IsWordChar(render[i]) {
+			i++
+			continue
+		}
+		start := i
+		for i < len(render) && IsWordChar(render[i]) {
+			i++
+		}
+		// words glued to digits or underscores are identifiers
+		if (start > 0 && isIdentChar(render[start-1])) || (i < len(render) && isIdentChar(render[i])) {
+			continue
+		}
+		word := strings.Trim(string(render[start:i]), "'")
+		if len(word) < 2 || r.HL[start] == HighlightCode || !prose && r.HL[start] != HighlightComment && r.HL[start] != HighlightString {
+			continue
+		}
+		// skip camelCase identifiers
+		if strings.IndexFunc(word[1:], unicode.IsUpper) >= 0 {
+			continue
+		}
+		if !opts.Dict.Check(word) {
+			for j := start; j < i; j++ {
+				r.HL[j] = HighlightSpell
+			}
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package buffer
+
+import (
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/exp/slices"
+)
+
+// Syntax describes a filetype.
+type Syntax struct {
+	Filetype   string
+	Extensions []string
+	// Interpreters are the programs named on #! lines of scripts
+	// without an extension, without any version number.
+	Interpreters []string
+	LineComment  string
+	BlockComment [2]string
+	// Prose filetypes are spell checked everywhere, code only
+	// inside comments and strings.
+	Prose bool
+	// Formatter reads the buffer on stdin and writes the formatted
+	// result to stdout. %f is replaced with the filename.
+	Formatter []string
+	// Keywords and Types are highlighted in code. Filetypes without
+	// their own lists use generic ones.
+	Keywords []string
+	Types    []string
+	// RawQuotes are the characters which delimit strings that have no
+	// escapes and can span lines, like ` in Go.
+	RawQuotes string
+	// Build is the command which builds a project of this filetype
+	// when no build command is configured.
+	Build string
+	// Test is the command which runs the tests of a project of this
+	// filetype.
+	Test string
+	// Run is the shell command which runs a file of this filetype. %f
+	// is replaced with the quoted filename.
+	Run string
+}
+
+// Syntaxes is the list of known filetypes.
+var Syntaxes = []*Syntax{
+	{
+		Filetype:     "go",
+		Extensions:   []string{".go"},
+		LineComment:  "//",
+		BlockComment: [2]string{"/*", "*/"},
+		Formatter:    []string{"gofmt"},
+		Keywords:     goKeywords,
+		Types:        goTypes,
+		RawQuotes:    "`",
+		Build:        "go build ./...",
+		Test:         "go test ./...",
+		Run:          "go run %f",
+	},
+	{Filetype: "c", Extensions: []string{".c", ".h", ".cc", ".cpp", ".hpp"}, LineComment: "//", BlockComment: [2]string{"/*", "*/"}},
+	{Filetype: "rust", Extensions: []string{".rs"}, LineComment: "//", BlockComment: [2]string{"/*", "*/"}, Formatter: []string{"rustfmt", "--emit", "stdout"}},
+	{Filetype: "javascript", Extensions: []string{".js", ".ts", ".jsx", ".tsx"}, Interpreters: []string{"node", "deno"}, Run: "node %f", LineComment: "//", BlockComment: [2]string{"/*", "*/"}, Formatter: []string{"prettier", "--stdin-filepath", "%f"}},
+	{Filetype: "java", Extensions: []string{".java"}, LineComment: "//", BlockComment: [2]string{"/*", "*/"}},
+	{Filetype: "python", Extensions: []string{".py"}, Interpreters: []string{"python"}, Run: "python3 %f", LineComment: "#", Formatter: []string{"black", "-q", "-"}},
+	{Filetype: "shell", Extensions: []string{".sh", ".bash"}, Interpreters: []string{"sh", "bash", "dash", "ksh", "zsh"}, Run: "bash %f", LineComment: "#"},
+	{Filetype: "ruby", Extensions: []string{".rb"}, Interpreters: []string{"ruby"}, Run: "ruby %f", LineComment: "#"},
+	{Filetype: "yaml", Extensions: []string{".yaml", ".yml"}, LineComment: "#"},
+	{Filetype: "toml", Extensions: []string{".toml"}, LineComment: "#"},
+	{Filetype: "make", Extensions: []string{".mk", "Makefile"}, LineComment: "#"},
+	{Filetype: "lua", Extensions: []string{".lua"}, Interpreters: []string{"lua"}, Run: "lua %f", LineComment: "--"},
+	{Filetype: "sql", Extensions: []string{".sql"}, LineComment: "--"},
+	{Filetype: "json", Extensions: []string{".json"}},
+	{Filetype: "css", Extensions: []string{".css"}, BlockComment: [2]string{"/*", "*/"}},
+	{Filetype: "html", Extensions: []string{".html", ".xml", ".svg"}, BlockComment: [2]string{"<!--", "-->"}},
+	{Filetype: "markdown", Extensions: []string{".md", ".markdown"}, BlockComment: [2]string{"<!--", "-->"}, Prose: true},
+	{Filetype: "text", Extensions: []string{".txt", "COMMIT_EDITMSG"}, Prose: true},
+}
+
+// goKeywords are Go's keywords and predeclared constants, and goTypes
+// its predeclared types.
+var (
+	goKeywords = []string{
+		"break", "case", "chan", "const", "continue", "default", "defer", "else", "fallthrough",
+		"for", "func", "go", "goto", "if", "import", "interface", "map", "package", "range",
+		"return", "select", "struct", "switch", "type", "var",
+		"true", "false", "iota", "nil",
+	}
+	goTypes = []string{
+		"bool", "byte", "complex64", "complex128", "error", "float32", "float64",
+		"int", "int8", "int16", "int32", "int64", "rune", "string",
+		"uint", "uint8", "uint16", "uint32", "uint64", "uintptr",
+		"any", "comparable",
+	}
+)
+
+// SyntaxFor finds the syntax by file extension, or by base name for
+// files like Makefile.
+func SyntaxFor(filename string) *Syntax {
+	ext := filepath.Ext(filename)
+	base := filepath.Base(filename)
+	for _, s := range Syntaxes {
+		if slices.Contains(s.Extensions, ext) || slices.Contains(s.Extensions, base) {
+			return s
+		}
+	}
+	return nil
+}
+
+// DetectSyntax finds the syntax from the first line of a file without
+// a known extension. Scripts are recognized by the interpreter on the
+// #! line, and markup and data files by how they start.
+func DetectSyntax(first []byte) *Syntax {
+	line := strings.TrimSpace(string(first))
+	if strings.HasPrefix(line, "#!") {
+		name := interpreter(line[2:])
+		for _, s := range Syntaxes {
+			if slices.Contains(s.Interpreters, name) {
+				return s
+			}
+		}
+		return nil
+	}
+	switch {
+	case strings.HasPrefix(line, "<?xml"), strings.HasPrefix(strings.ToLower(line), "<!doctype html"), strings.HasPrefix(line, "<html"):
+		return SyntaxNamed("html")
+	case strings.HasPrefix(line, "{"), strings.HasPrefix(line, "["):
+		return SyntaxNamed("json")
+	}
+	return nil
+}
+
+// interpreter returns the program run by a #! line, looking through
+// env, without any version number: "/usr/bin/env python3" is python.
+func interpreter(shebang string) string {
+	fields := strings.Fields(shebang)
+	if len(fields) == 0 {
+		return ""
+	}
+	name := filepath.Base(fields[0])
+	if name == "env" {
+		name = ""
+		for _, f := range fields[1:] {
+			if !strings.HasPrefix(f, "-") && !strings.Contains(f, "=") {
+				name = filepath.Base(f)
+				break
+			}
+		}
+	}
+	return strings.TrimRight(name, "0123456789.")
+}
+
+// SyntaxNamed returns the syntax for a filetype, or nil if there's none.
+func SyntaxNamed(filetype string) *Syntax {
+	for _, s := range Syntaxes {
+		if s.Filetype == filetype {
+			return s
+		}
+	}
+	return nil
+}
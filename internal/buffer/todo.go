@@ -0,0 +1,37 @@
+package buffer
+
+// DefaultTodo are the keywords highlighted in comments by default.
+var DefaultTodo = []string{"TODO", "FIXME", "HACK", "XXX"}
+
+// markTodo highlights the Todo keywords inside comments.
+func (r *Row) markTodo() {
+	opts := r.options()
+	if len(opts.Todo) == 0 {
+		return
+	}
+	render := r.Render()
+	for i := 0; i < len(render); {
+		if !isTodoChar(render[i]) {
+			i++
+			continue
+		}
+		start := i
+		for i < len(render) && isTodoChar(render[i]) {
+			i++
+		}
+		if r.HL[start] != HighlightComment && r.HL[start] != HighlightSpell {
+			continue
+		}
+		word := string(render[start:i])
+		for _, kw := range opts.Todo {
+			if word == kw {
+				mark(r.HL, start, i, HighlightTodo)
+				break
+			}
+		}
+	}
+}
+
+func isTodoChar(c byte) bool {
+	return IsWordChar(c) && c != '\'' || isIdentChar(c)
+}
@@ -0,0 +1,58 @@
+package buffer
+
+import (
+	"bytes"
+	"unicode/utf8"
+)
+
+// markWarnings highlights the indentation of a row which mixes tabs
+// and spaces, and the characters which are invisible or look like
+// others, like zero width spaces and bidi controls.
+func (r *Row) markWarnings() {
+	if !r.options().Warn {
+		return
+	}
+	indent := 0
+	for indent < len(r.Chars) && (r.Chars[indent] == ' ' || r.Chars[indent] == '\t') {
+		indent++
+	}
+	if bytes.IndexByte(r.Chars[:indent], '\t') >= 0 && bytes.IndexByte(r.Chars[:indent], ' ') >= 0 {
+		mark(r.HL, 0, r.CxToRx(indent), HighlightMixedIndent)
+	}
+	for cx := indent; cx < len(r.Chars); {
+		if r.Chars[cx] < utf8.RuneSelf {
+			cx++
+			continue
+		}
+		c, size := utf8.DecodeRune(r.Chars[cx:])
+		// a byte order mark only belongs at the start of the file
+		bom := c == '\ufeff' && cx == 0 && r.buf != nil && r.buf.Rows[0] == r
+		if IsSuspicious(c) && !bom {
+			rx := r.CxToRx(cx)
+			mark(r.HL, rx, rx+size, HighlightSuspicious)
+		}
+		cx += size
+	}
+}
+
+// IsSuspicious reports whether c is invisible, or looks like another
+// character, so it's likely to have been pasted in by mistake.
+func IsSuspicious(c rune) bool {
+	switch {
+	case c == '\u00a0', c == '\u00ad', c == '\u061c', c == '\u180e':
+		// no-break space, soft hyphen, and invisible marks
+		return true
+	case '\u2000' <= c && c <= '\u200f':
+		// unusual spaces, zero width characters, and direction marks
+		return true
+	case '\u2028' <= c && c <= '\u202f':
+		// line and paragraph separators, bidi embeddings and overrides
+		return true
+	case '\u205f' <= c && c <= '\u2069':
+		// word joiner, invisible operators, and bidi isolates
+		return true
+	case c == '\u3000', c == '\ufeff':
+		return true
+	}
+	return false
+}
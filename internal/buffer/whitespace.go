@@ -0,0 +1,33 @@
+package buffer
+
+// markWhitespace highlights tabs, trailing whitespace and, when
+// ListSpaces is set, spaces so that they can be rendered visibly. Only
+// the first render column of a tab is marked.
+func (r *Row) markWhitespace() {
+	opts := r.options()
+	if !opts.List {
+		return
+	}
+	trailing := len(r.Chars)
+	for trailing > 0 && (r.Chars[trailing-1] == ' ' || r.Chars[trailing-1] == '\t') {
+		trailing--
+	}
+	var rx int
+	for cx, c := range r.Chars {
+		start := rx
+		rx += r.renderWidth(c, rx)
+		switch {
+		case cx >= trailing:
+			for j := start; j < rx; j++ {
+				r.HL[j] = HighlightTrailing
+			}
+			if c == '\t' {
+				r.HL[start] = HighlightTrailingTab
+			}
+		case c == '\t':
+			r.HL[start] = HighlightTab
+		case c == ' ' && opts.ListSpaces:
+			r.HL[start] = HighlightSpace
+		}
+	}
+}
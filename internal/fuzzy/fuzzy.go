@@ -0,0 +1,158 @@
+// Package fuzzy matches patterns the way fuzzy finders do: the
+// characters of the pattern have to appear in order, and matches which
+// start words or run together score higher than scattered ones.
+package fuzzy
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	scoreMatch = 16
+	// bonusStart is for matching the first character of the string.
+	bonusStart = 10
+	// bonusBoundary is for matching the first character after a
+	// separator, and bonusCamel for the upper case letter or the digit
+	// starting a part of a camelCase or numbered name.
+	bonusBoundary = 8
+	bonusCamel    = 7
+	// bonusConsecutive is for matching right after the previous match.
+	bonusConsecutive = 5
+	// a gap between two matches costs penaltyGap and penaltyGapExtend
+	// for every character after the first
+	penaltyGap       = 3
+	penaltyGapExtend = 1
+)
+
+// Score reports whether pattern matches s, and how well. The match
+// ignores case unless the pattern has upper case letters.
+func Score(pattern, s string) (int, bool) {
+	if pattern == "" {
+		return 0, true
+	}
+	// the case of s tells where camelCase words start
+	bonus := boundaries(s)
+	if strings.IndexFunc(pattern, unicode.IsUpper) < 0 {
+		// only ascii is folded, so the bytes line up with the bonuses
+		s = lowerASCII(s)
+	}
+	if !isSubsequence(pattern, s) {
+		return 0, false
+	}
+	n, m := len(pattern), len(s)
+	// prev[j] and cur[j] are the best scores of the pattern up to the
+	// previous and the current character with that character matched at
+	// s[j], or noMatch
+	const noMatch = -1 << 30
+	prev, cur := make([]int, m), make([]int, m)
+	for j := 0; j < m; j++ {
+		prev[j] = noMatch
+		if s[j] == pattern[0] {
+			prev[j] = scoreMatch + bonus[j]
+		}
+	}
+	for i := 1; i < n; i++ {
+		// gap is the best score of the previous character matched
+		// before s[j-1], less the cost of the gap
+		gap := noMatch
+		for j := 0; j < m; j++ {
+			cur[j] = noMatch
+			if j >= 2 {
+				gap -= penaltyGapExtend
+				if g := prev[j-2] - penaltyGap; g > gap {
+					gap = g
+				}
+			}
+			if s[j] != pattern[i] || j == 0 {
+				continue
+			}
+			best := gap
+			if prev[j-1] != noMatch && prev[j-1]+bonusConsecutive > best {
+				best = prev[j-1] + bonusConsecutive
+			}
+			if best > noMatch/2 {
+				cur[j] = best + scoreMatch + bonus[j]
+			}
+		}
+		prev, cur = cur, prev
+	}
+	score := noMatch
+	for _, v := range prev {
+		if v > score {
+			score = v
+		}
+	}
+	return score, score > noMatch/2
+}
+
+// Rank returns the indexes of the items pattern matches, best first.
+// Shorter items come first among the ones which score the same, and the
+// order of the items is kept otherwise.
+func Rank(pattern string, items []string) []int {
+	var matched []int
+	scores := map[int]int{}
+	for i, item := range items {
+		if score, ok := Score(pattern, item); ok {
+			matched = append(matched, i)
+			scores[i] = score
+		}
+	}
+	sort.SliceStable(matched, func(a, b int) bool {
+		i, j := matched[a], matched[b]
+		if scores[i] != scores[j] {
+			return scores[i] > scores[j]
+		}
+		return len(items[i]) < len(items[j])
+	})
+	return matched
+}
+
+// isSubsequence reports whether the characters of pattern appear in s
+// in order, which is cheaper to check than scoring.
+func isSubsequence(pattern, s string) bool {
+	i := 0
+	for j := 0; j < len(s) && i < len(pattern); j++ {
+		if s[j] == pattern[i] {
+			i++
+		}
+	}
+	return i == len(pattern)
+}
+
+// boundaries returns the bonus for matching each character of s, by
+// whether it starts a word.
+func boundaries(s string) []int {
+	bonus := make([]int, len(s))
+	for j := 0; j < len(s); j++ {
+		switch c := s[j]; {
+		case j == 0:
+			bonus[j] = bonusStart
+		case isSeparator(s[j-1]) && !isSeparator(c):
+			bonus[j] = bonusBoundary
+		case isUpper(c) && isLower(s[j-1]), isDigit(c) && !isDigit(s[j-1]):
+			bonus[j] = bonusCamel
+		}
+	}
+	return bonus
+}
+
+func isSeparator(c byte) bool {
+	return strings.IndexByte(" /\\_-.:,;", c) >= 0
+}
+
+func isUpper(c byte) bool { return 'A' <= c && c <= 'Z' }
+func isLower(c byte) bool { return 'a' <= c && c <= 'z' }
+func isDigit(c byte) bool { return '0' <= c && c <= '9' }
+
+// lowerASCII returns s with the ascii letters in lower case.
+func lowerASCII(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		if isUpper(c) {
+			b[i] = c + 'a' - 'A'
+		}
+	}
+	return string(b)
+}
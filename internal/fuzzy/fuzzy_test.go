@@ -0,0 +1,71 @@
+package fuzzy
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestScoreMatches(t *testing.T) {
+	tests := []struct {
+		pattern, s string
+		ok         bool
+	}{
+		{"", "anything", true},
+		{"abc", "abc", true},
+		{"abc", "a_b_c", true},
+		{"abc", "acb", false},
+		{"abc", "ab", false},
+		{"fb", "FooBar", true},
+		// upper case in the pattern makes it case sensitive
+		{"FB", "foobar", false},
+		{"FB", "FooBar", true},
+	}
+	for _, tt := range tests {
+		if _, ok := Score(tt.pattern, tt.s); ok != tt.ok {
+			t.Errorf("Score(%q, %q) matched = %v, want %v", tt.pattern, tt.s, ok, tt.ok)
+		}
+	}
+}
+
+func TestScoreOrder(t *testing.T) {
+	// each pair is a better match followed by a worse one
+	tests := []struct {
+		pattern, better, worse string
+	}{
+		{"main", "main.go", "domain.go"},
+		{"fb", "foo_bar", "fxxxxb"},
+		{"fb", "fooBar", "foobar"},
+		{"abc", "abcxx", "axbxc"},
+		{"ed", "editor.go", "shared.go"},
+	}
+	for _, tt := range tests {
+		better, ok := Score(tt.pattern, tt.better)
+		if !ok {
+			t.Errorf("Score(%q, %q) didn't match", tt.pattern, tt.better)
+			continue
+		}
+		worse, ok := Score(tt.pattern, tt.worse)
+		if !ok {
+			t.Errorf("Score(%q, %q) didn't match", tt.pattern, tt.worse)
+			continue
+		}
+		if better <= worse {
+			t.Errorf("Score(%q): %q = %d, want more than %q = %d", tt.pattern, tt.better, better, tt.worse, worse)
+		}
+	}
+}
+
+func TestRank(t *testing.T) {
+	items := []string{"xbuffer.go", "README.md", "buffer.go", "internal/buffer/buffer.go", "bu.go"}
+	got := Rank("buf", items)
+	want := []int{2, 3, 0}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Rank = %v, want %v", got, want)
+	}
+	// items which score the same keep their order
+	got = Rank("", []string{"b", "a", "c"})
+	want = []int{0, 1, 2}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Rank of the empty pattern = %v, want %v", got, want)
+	}
+}
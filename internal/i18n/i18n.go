@@ -0,0 +1,146 @@
+// Package i18n translates the messages of the user interface. The
+// translations are kept in catalogs written in the part of the gettext
+// PO format without plurals and contexts, where the message ids are the
+// English messages, format verbs included:
+//
+//	# German
+//	msgid "saved %s"
+//	msgstr "%s gespeichert"
+package i18n
+
+import (
+	"bufio"
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// builtin are the catalogs which come with kilo, named by language.
+//
+//go:embed locales/*.po
+var builtin embed.FS
+
+// Catalog maps messages to their translations.
+type Catalog map[string]string
+
+// Translate returns the translation of msg, or msg when there is none.
+func (c Catalog) Translate(msg string) string {
+	if t, ok := c[msg]; ok {
+		return t
+	}
+	return msg
+}
+
+// Parse reads a catalog. Messages without a translation are left out.
+func Parse(r io.Reader) (Catalog, error) {
+	c := Catalog{}
+	var msgid, msgstr string
+	// field is what continued strings are added to
+	var field *string
+	add := func() {
+		if msgid != "" && msgstr != "" {
+			c[msgid] = msgstr
+		}
+		msgid, msgstr = "", ""
+	}
+	sc := bufio.NewScanner(r)
+	for lineno := 1; sc.Scan(); lineno++ {
+		line := strings.TrimSpace(sc.Text())
+		keyword, value, _ := strings.Cut(line, " ")
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+			continue
+		case keyword == "msgid":
+			add()
+			field = &msgid
+		case keyword == "msgstr":
+			field = &msgstr
+		case strings.HasPrefix(line, `"`) && field != nil:
+			value = line
+		default:
+			return nil, fmt.Errorf("line %d: unsupported: %s", lineno, line)
+		}
+		s, err := strconv.Unquote(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineno, err)
+		}
+		*field += s
+	}
+	add()
+	return c, sc.Err()
+}
+
+// Language returns the language the environment asks for messages in,
+// from LC_ALL, LC_MESSAGES, or LANG, without the encoding, e.g. de_DE.
+// It's empty for the C locale.
+func Language(getenv func(string) string) string {
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if v := getenv(name); v != "" {
+			lang, _, _ := strings.Cut(v, ".")
+			lang, _, _ = strings.Cut(lang, "@")
+			if lang == "C" || lang == "POSIX" {
+				return ""
+			}
+			return lang
+		}
+	}
+	return ""
+}
+
+// Load returns the catalog of a language, e.g. de_DE. The messages of
+// the catalog of the language without the region, de, are used where
+// the one of the region has none, and catalogs in dir, named like
+// de_DE.po, take precedence over the builtin ones. Languages without a
+// catalog have an empty one.
+func Load(lang, dir string) (Catalog, error) {
+	c := Catalog{}
+	if lang == "" {
+		return c, nil
+	}
+	names := []string{lang}
+	if base, _, ok := strings.Cut(lang, "_"); ok {
+		names = []string{base, lang}
+	}
+	if err := merge(c, builtin, "locales", names); err != nil {
+		return c, err
+	}
+	if dir != "" {
+		if err := merge(c, os.DirFS(dir), ".", names); err != nil {
+			return c, fmt.Errorf("%s: %w", dir, err)
+		}
+	}
+	return c, nil
+}
+
+// merge adds the messages of the named catalogs in a directory of fsys
+// to c.
+func merge(c Catalog, fsys fs.FS, dir string, names []string) error {
+	for _, name := range names {
+		file := path.Join(dir, name+".po")
+		if !fs.ValidPath(file) {
+			continue
+		}
+		data, err := fs.ReadFile(fsys, file)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		cat, err := Parse(bytes.NewReader(data))
+		if err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+		for msgid, msgstr := range cat {
+			c[msgid] = msgstr
+		}
+	}
+	return nil
+}
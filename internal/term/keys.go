@@ -0,0 +1,447 @@
+package term
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/sys/unix"
+)
+
+// ControlKey returns the key produced by pressing Ctrl with c.
+func ControlKey(c byte) int {
+	return int(c & 0b00011111)
+}
+
+// AltModifier is set on keys which were pressed while holding Alt/Meta.
+const (
+	AltModifier   = 1 << 16
+	ShiftModifier = 1 << 17
+	CtrlModifier  = 1 << 18
+)
+
+// AltKey returns the key produced by pressing Alt with c.
+func AltKey(c byte) int {
+	return AltModifier | int(c)
+}
+
+const (
+	BackspaceKey = 127
+	ArrowLeft    = iota + 1000
+	ArrowRight
+	ArrowUp
+	ArrowDown
+	PageUp
+	PageDown
+	HomeKey
+	EndKey
+	DeleteKey
+	MouseEvent
+	PasteEvent
+	ResizeEvent
+	InsertKey
+	F1
+	F2
+	F3
+	F4
+	F5
+	F6
+	F7
+	F8
+	F9
+	F10
+	F11
+	F12
+	UnknownKey
+	TextEvent
+)
+
+const (
+	MouseLeft      = 0
+	MouseDrag      = 32
+	MouseWheelUp   = 64
+	MouseWheelDown = 65
+)
+
+// Mouse is the most recent mouse event. The coordinates are 0 based
+// screen positions.
+type Mouse struct {
+	Button  int
+	X, Y    int
+	Release bool
+}
+
+// readPaste reads bracketed paste content up to the closing ESC[201~.
+func (t *Terminal) readPaste() bool {
+	end := []byte("\x1b[201~")
+	var buf []byte
+	for {
+		b, ok := t.readByte(pasteTimeout)
+		if !ok {
+			return false
+		}
+		buf = append(buf, b)
+		if bytes.HasSuffix(buf, end) {
+			t.Paste = buf[:len(buf)-len(end)]
+			return true
+		}
+	}
+}
+
+// parseMouse parses the parameters of an SGR mouse report: <b;x;y
+func (t *Terminal) parseMouse(params string, final byte) bool {
+	var m Mouse
+	if n, _ := fmt.Sscanf(params, "<%d;%d;%d", &m.Button, &m.X, &m.Y); n != 3 {
+		return false
+	}
+	m.X--
+	m.Y--
+	m.Release = final == 'm'
+	t.Mouse = m
+	return true
+}
+
+// csiModifiers decodes the xterm modifier parameter: 1 + (shift | alt<<1 | ctrl<<2).
+func csiModifiers(param string) int {
+	n, err := strconv.Atoi(param)
+	if err != nil || n < 2 {
+		return 0
+	}
+	n--
+	var mod int
+	if n&1 != 0 {
+		mod |= ShiftModifier
+	}
+	if n&2 != 0 {
+		mod |= AltModifier
+	}
+	if n&4 != 0 {
+		mod |= CtrlModifier
+	}
+	return mod
+}
+
+// parseCSI decodes a control sequence ESC [ params final into a key.
+// It returns -1 for unknown sequences.
+func (t *Terminal) parseCSI(params string, final byte) int {
+	if strings.HasPrefix(params, "<") && (final == 'M' || final == 'm') {
+		if t.parseMouse(params, final) {
+			return MouseEvent
+		}
+		return -1
+	}
+	args := strings.Split(params, ";")
+	var mod int
+	if len(args) > 1 {
+		mod = csiModifiers(args[1])
+	}
+	if final == '~' {
+		switch args[0] {
+		case "200":
+			if t.readPaste() {
+				return PasteEvent
+			}
+			return -1
+		case "1", "7":
+			return mod | HomeKey
+		case "2":
+			return mod | InsertKey
+		case "3":
+			return mod | DeleteKey
+		case "4", "8":
+			return mod | EndKey
+		case "5":
+			return mod | PageUp
+		case "6":
+			return mod | PageDown
+		case "11", "12", "13", "14", "15":
+			n, _ := strconv.Atoi(args[0])
+			return mod | (F1 + n - 11)
+		case "17", "18", "19", "20", "21":
+			n, _ := strconv.Atoi(args[0])
+			return mod | (F6 + n - 17)
+		case "23", "24":
+			n, _ := strconv.Atoi(args[0])
+			return mod | (F11 + n - 23)
+		}
+		return -1
+	}
+	// CSI u: codepoint;modifiers, lets terminals report Ctrl-I apart from Tab
+	if final == 'u' {
+		n, err := strconv.Atoi(args[0])
+		if err != nil {
+			return -1
+		}
+		return mod | n
+	}
+	if final == 'Z' {
+		return ShiftModifier | '\t'
+	}
+	if key := ss3Key(final); key >= 0 {
+		return mod | key
+	}
+	return -1
+}
+
+// ss3Key decodes the final byte shared by ESC [ and ESC O sequences.
+func ss3Key(final byte) int {
+	switch final {
+	case 'A':
+		return ArrowUp
+	case 'B':
+		return ArrowDown
+	case 'C':
+		return ArrowRight
+	case 'D':
+		return ArrowLeft
+	case 'H':
+		return HomeKey
+	case 'F':
+		return EndKey
+	case 'P', 'Q', 'R', 'S':
+		return F1 + int(final-'P')
+	}
+	return -1
+}
+
+// Unread pushes a key back so it's returned by the next call to ReadKey.
+func (t *Terminal) Unread(c int) {
+	t.queue = append(t.queue, c)
+}
+
+// Buffered reports whether a key can be read without waiting, e.g.
+// during a paste or when keys are typed faster than they're handled.
+func (t *Terminal) Buffered() bool {
+	if len(t.queue) > 0 {
+		return true
+	}
+	fds := []unix.PollFd{{Fd: int32(t.in), Events: unix.POLLIN}}
+	n, err := unix.Poll(fds, 0)
+	return err == nil && n > 0
+}
+
+// readByte reads a byte, waiting at most timeout for it to arrive.
+func (t *Terminal) readByte(timeout time.Duration) (byte, bool) {
+	fds := []unix.PollFd{{Fd: int32(t.in), Events: unix.POLLIN}}
+	if n, err := unix.Poll(fds, int(timeout/time.Millisecond)); err != nil || n == 0 {
+		return 0, false
+	}
+	var b [1]byte
+	if n, _ := unix.Read(t.in, b[:]); n != 1 {
+		return 0, false
+	}
+	return b[0], true
+}
+
+type keyState int
+
+const (
+	stateEscape keyState = iota // after ESC
+	stateCSI                    // after ESC [
+	stateSS3                    // after ESC O
+)
+
+const (
+	pasteTimeout = time.Second
+	maxCSIParams = 32
+)
+
+// DefaultEscTimeout is the default for Terminal.EscTimeout.
+const DefaultEscTimeout = 100 * time.Millisecond
+
+// ReadKey waits for a key press and decodes it. Keys which were
+// pushed back with Unread are returned first. ResizeEvent is returned
+// when the terminal was resized, or the process was continued after
+// being stopped, and the screen has to be redrawn.
+func (t *Terminal) ReadKey() (int, error) {
+	if len(t.queue) > 0 {
+		c := t.queue[0]
+		t.queue = t.queue[1:]
+		return c, nil
+	}
+	var c int
+	var b [1]byte
+	for {
+		if key, err := t.wait(); key != 0 || err != nil {
+			return key, err
+		}
+		n, err := unix.Read(t.in, b[:])
+		if n == 1 {
+			c = int(b[0])
+			break
+		}
+		if n == 0 {
+			return 0, fmt.Errorf("read: %w", io.EOF)
+		}
+		if err != unix.EAGAIN && err != unix.EINTR {
+			return 0, fmt.Errorf("read: %w", err)
+		}
+	}
+	if c >= 0xc0 && c < 0xf8 {
+		return t.readText(byte(c)), nil
+	}
+	if c != '\x1b' {
+		return c, nil
+	}
+	key, seq := t.readEscape()
+	if t.Trace != nil {
+		t.Trace(seq, key)
+	}
+	return key, nil
+}
+
+// readText reads the text starting with the first byte of a multi-byte
+// utf-8 character, and returns TextEvent with the text in t.Text, or the
+// byte as a key if it doesn't start a valid character. Input methods
+// commit text in bursts, so the characters which are ready to read
+// after it are added to the text, up to a key which isn't typed as
+// text, which is returned next.
+func (t *Terminal) readText(first byte) int {
+	text := []byte{first}
+	// the key after the text
+	var next []int
+	for {
+		// the rest of the character
+		for !utf8.FullRune(text[len(text)-1-lastRuneStart(text):]) {
+			b, ok := t.readByte(t.EscTimeout)
+			if !ok {
+				break
+			}
+			text = append(text, b)
+		}
+		b, ok := t.readByte(0)
+		if !ok {
+			break
+		}
+		if b == '\x1b' {
+			key, seq := t.readEscape()
+			if t.Trace != nil {
+				t.Trace(seq, key)
+			}
+			next = append(next, key)
+			break
+		}
+		if b < ' ' || b == 0x7f || b >= 0x80 && !utf8.RuneStart(b) {
+			next = append(next, int(b))
+			break
+		}
+		text = append(text, b)
+	}
+	if !utf8.Valid(text) {
+		// it's not utf-8, the bytes are keys of their own
+		for _, b := range text[1:] {
+			t.queue = append(t.queue, int(b))
+		}
+		t.queue = append(t.queue, next...)
+		return int(first)
+	}
+	t.queue = append(t.queue, next...)
+	t.Text = text
+	return TextEvent
+}
+
+// lastRuneStart returns how many bytes from the end of text the last
+// character starts.
+func lastRuneStart(text []byte) int {
+	n := 0
+	for n < len(text)-1 && n < utf8.UTFMax-1 && !utf8.RuneStart(text[len(text)-1-n]) {
+		n++
+	}
+	return n
+}
+
+// wait sleeps until there's input, calling Idle whenever the terminal
+// is woken. It returns ResizeEvent when a signal means the screen has
+// to be redrawn, and 0 once input is ready.
+func (t *Terminal) wait() (int, error) {
+	for {
+		fds := []unix.PollFd{
+			{Fd: int32(t.in), Events: unix.POLLIN},
+			{Fd: int32(t.wake[0]), Events: unix.POLLIN},
+		}
+		if _, err := unix.Poll(fds, -1); err != nil {
+			if err == unix.EINTR {
+				continue
+			}
+			return 0, fmt.Errorf("poll: %w", err)
+		}
+		if fds[1].Revents&unix.POLLIN != 0 {
+			if t.drainWake() {
+				return ResizeEvent, nil
+			}
+			if t.Idle != nil {
+				t.Idle()
+			}
+		}
+		if fds[0].Revents != 0 {
+			return 0, nil
+		}
+	}
+}
+
+// readEscape decodes the escape sequence following an ESC, and returns
+// the key along with the bytes of the sequence.
+func (t *Terminal) readEscape() (int, []byte) {
+	// Escape sequences are decoded with a state machine. Every byte of
+	// a sequence must arrive within t.EscTimeout of the previous one.
+	// Incomplete or unrecognized sequences are dropped instead of being
+	// inserted into the buffer as text.
+	state := stateEscape
+	var params []byte
+	seq := []byte{'\x1b'}
+	for {
+		b, ok := t.readByte(t.EscTimeout)
+		if !ok {
+			switch {
+			case state == stateEscape:
+				return '\x1b', seq
+			case state == stateCSI && len(params) == 0:
+				return AltKey('['), seq
+			case state == stateSS3:
+				return AltKey('O'), seq
+			default:
+				return UnknownKey, seq
+			}
+		}
+		seq = append(seq, b)
+		switch state {
+		case stateEscape:
+			switch {
+			case b == '[':
+				state = stateCSI
+			case b == 'O':
+				state = stateSS3
+			case b == '\x1b' || b < ' ' && b != '\r':
+				t.Unread(int(b))
+				return '\x1b', seq[:1]
+			default:
+				return AltKey(b), seq
+			}
+		case stateSS3:
+			if key := ss3Key(b); key >= 0 {
+				return key, seq
+			}
+			return UnknownKey, seq
+		case stateCSI:
+			switch {
+			case b == '\x1b':
+				// a new sequence started before this one finished
+				state = stateEscape
+				params = params[:0]
+			case b >= 0x40 && b <= 0x7e:
+				if key := t.parseCSI(string(params), b); key >= 0 {
+					return key, seq
+				}
+				return UnknownKey, seq
+			case b >= 0x20 && b <= 0x3f && len(params) < maxCSIParams:
+				params = append(params, b)
+			default:
+				return UnknownKey, seq
+			}
+		}
+	}
+}
@@ -0,0 +1,19 @@
+package term
+
+import (
+	"os"
+
+	"github.com/creack/pty"
+)
+
+// OpenPTY opens a pseudo terminal. The editor reads and writes the
+// master side, and the process running in it uses the slave side.
+func OpenPTY() (master, slave *os.File, err error) {
+	return pty.Open()
+}
+
+// SetPTYSize sets the size of the terminal whose master side is f, which
+// sends SIGWINCH to the processes running in it.
+func SetPTYSize(f *os.File, rows, cols int) error {
+	return pty.Setsize(f, &pty.Winsize{Rows: uint16(rows), Cols: uint16(cols)})
+}
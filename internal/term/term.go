@@ -0,0 +1,233 @@
+// Package term puts the terminal into raw mode and decodes key presses.
+package term
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// Terminal is the terminal the editor runs in.
+type Terminal struct {
+	in, out int
+	saved   *unix.Termios
+	raw     *unix.Termios
+	queue   []int
+	name    string
+	info    *Terminfo
+	// wake is a pipe which wakes up ReadKey. A zero byte is written by
+	// Wake, any other byte is the number of a signal which was caught.
+	wake    [2]int
+	signals chan os.Signal
+
+	// EscTimeout is the maximum delay between the bytes of an escape sequence.
+	EscTimeout time.Duration
+	// Idle is called when ReadKey is woken by Wake while it waits for
+	// input.
+	Idle func()
+	// Trace is called with every escape sequence read and the key it
+	// was decoded as, for debugging.
+	Trace func(seq []byte, key int)
+	// Mouse is the most recent mouse event.
+	Mouse Mouse
+	// Paste is the text of the most recent paste event.
+	Paste []byte
+	// Text is the text of the most recent text event: characters other
+	// than ascii, typed or committed by an input method.
+	Text []byte
+}
+
+// New returns a Terminal reading from stdin and writing to stdout. The
+// capabilities of the terminal are looked up in terminfo using $TERM,
+// if there's no entry an xterm compatible terminal is assumed.
+func New() *Terminal {
+	t := &Terminal{
+		in:         unix.Stdin,
+		out:        unix.Stdout,
+		name:       os.Getenv("TERM"),
+		wake:       [2]int{-1, -1},
+		EscTimeout: DefaultEscTimeout,
+	}
+	if err := unix.Pipe(t.wake[:]); err == nil {
+		for _, fd := range t.wake {
+			unix.SetNonblock(fd, true)
+			unix.CloseOnExec(fd)
+		}
+	}
+	t.signals = make(chan os.Signal, 1)
+	signal.Notify(t.signals, unix.SIGWINCH, unix.SIGTSTP, unix.SIGCONT)
+	go func() {
+		for sig := range t.signals {
+			t.notify(byte(sig.(unix.Signal)))
+		}
+	}()
+	if info, err := LoadTerminfo(t.name); err == nil {
+		if ct := os.Getenv("COLORTERM"); ct == "truecolor" || ct == "24bit" {
+			info.TrueColor = true
+			if info.Colors < 256 {
+				info.Colors = 256
+			}
+		}
+		t.info = info
+	}
+	return t
+}
+
+// Write writes p to the terminal. The ANSI escape sequences in p are
+// translated using the terminal's capabilities.
+func (t *Terminal) Write(p []byte) (int, error) {
+	if t.info != nil {
+		if _, err := unix.Write(t.out, t.info.translate(p)); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+	return unix.Write(t.out, p)
+}
+
+// EnableRawMode switches the terminal to raw mode and the alternate
+// screen, and enables mouse reporting and bracketed paste.
+func (t *Terminal) EnableRawMode() error {
+	if t.name == "dumb" || t.info != nil && t.info.cup == "" {
+		return fmt.Errorf("terminal %q can't position the cursor", t.name)
+	}
+	raw, err := unix.IoctlGetTermios(t.in, ioctlGetTermios)
+	if err != nil {
+		return fmt.Errorf("failed to get termios: %w", err)
+	}
+	saved := *raw
+	raw.Iflag &^= unix.BRKINT | unix.ICRNL | unix.INPCK | unix.ISTRIP | unix.IXON
+	raw.Oflag &^= unix.OPOST
+	raw.Cflag &^= unix.CS8
+	raw.Lflag &^= unix.ECHO | unix.ICANON | unix.IEXTEN | unix.ISIG
+	// reads block until there's a byte, ReadKey polls before reading
+	raw.Cc[unix.VMIN] = 1
+	raw.Cc[unix.VTIME] = 0
+	if err := unix.IoctlSetTermios(t.in, ioctlSetTermios, raw); err != nil {
+		return fmt.Errorf("failed to set termios: %w", err)
+	}
+	t.saved, t.raw = &saved, raw
+	// switch to the alternate screen so the shell's contents can be restored
+	t.Write([]byte("\x1b[?1049h"))
+	// report button presses, drags, and releases using the SGR encoding
+	t.Write([]byte("\x1b[?1002h\x1b[?1006h"))
+	// wrap pasted text in ESC[200~ ... ESC[201~
+	t.Write([]byte("\x1b[?2004h"))
+	return nil
+}
+
+// Restore undoes EnableRawMode. It does nothing if raw mode isn't enabled.
+func (t *Terminal) Restore() error {
+	if t.saved == nil {
+		return nil
+	}
+	t.Write([]byte("\x1b[?2004l\x1b[?1006l\x1b[?1002l"))
+	// leave the alternate screen, restoring the shell's contents
+	t.Write([]byte("\x1b[?1049l"))
+	if err := unix.IoctlSetTermios(t.in, ioctlSetTermios, t.saved); err != nil {
+		return fmt.Errorf("failed to restore termios: %w", err)
+	}
+	t.saved = nil
+	return nil
+}
+
+// Suspend restores the terminal and stops the process, like Ctrl-Z in
+// the shell. Raw mode is enabled again once the process is continued.
+func (t *Terminal) Suspend() error {
+	if err := t.Restore(); err != nil {
+		return err
+	}
+	// stop the whole process group, so the shell notices
+	if err := unix.Kill(0, unix.SIGSTOP); err != nil {
+		return fmt.Errorf("failed to stop: %w", err)
+	}
+	return t.EnableRawMode()
+}
+
+// resume puts the terminal back into raw mode after the process was
+// stopped by someone else, since the shell resets it.
+func (t *Terminal) resume() {
+	if t.saved != nil {
+		unix.IoctlSetTermios(t.in, ioctlSetTermios, t.raw)
+	}
+}
+
+// Wake makes ReadKey call Idle if it's waiting for input. It's used by
+// other goroutines to hand work to the one running the editor.
+func (t *Terminal) Wake() {
+	t.notify(0)
+}
+
+func (t *Terminal) notify(b byte) {
+	if t.wake[1] >= 0 {
+		// when the pipe is full a wake up is already pending
+		unix.Write(t.wake[1], []byte{b})
+	}
+}
+
+// drainWake reads the bytes written to the wake pipe, and handles the
+// signals among them. It reports whether the screen has to be redrawn.
+func (t *Terminal) drainWake() bool {
+	var buf [64]byte
+	var redraw bool
+	for {
+		n, _ := unix.Read(t.wake[0], buf[:])
+		if n <= 0 {
+			return redraw
+		}
+		for _, b := range buf[:n] {
+			switch unix.Signal(b) {
+			case unix.SIGWINCH:
+				redraw = true
+			case unix.SIGTSTP:
+				t.Suspend()
+				redraw = true
+			case unix.SIGCONT:
+				t.resume()
+				redraw = true
+			}
+		}
+	}
+}
+
+// Size returns the number of rows and columns of the terminal.
+func (t *Terminal) Size() (rows, cols int, err error) {
+	ws, err := unix.IoctlGetWinsize(t.out, unix.TIOCGWINSZ)
+	if err != nil {
+		// fallback mechanism
+		if _, err := t.Write([]byte("\x1b[999C\x1b[999B")); err != nil {
+			return 0, 0, fmt.Errorf("failed to get window size: %w", err)
+		}
+		return t.cursorPosition()
+	}
+	return int(ws.Row), int(ws.Col), nil
+}
+
+func (t *Terminal) cursorPosition() (row, col int, err error) {
+	if _, err := t.Write([]byte("\x1b[6n")); err != nil {
+		return 0, 0, fmt.Errorf("getCursorPosition: %w", err)
+	}
+	var buf [32]byte
+	var i int
+	for i < len(buf)-1 {
+		b, ok := t.readByte(time.Second)
+		if !ok {
+			break
+		}
+		if buf[i] = b; b == 'R' {
+			break
+		}
+		i++
+	}
+	if buf[0] != '\x1b' || buf[1] != '[' {
+		return 0, 0, errors.New("invalid escape sequence")
+	}
+	if n, err := fmt.Sscanf(string(buf[2:i]), "%d;%d", &row, &col); n != 2 {
+		return 0, 0, fmt.Errorf("failed to scan cursor pos: %w", err)
+	}
+	return row, col, nil
+}
@@ -0,0 +1,375 @@
+package term
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Terminfo holds the capabilities the editor uses from a compiled
+// terminfo entry.
+type Terminfo struct {
+	Colors int
+	// TrueColor is set when the terminal supports 24 bit colors, which
+	// terminfo doesn't say.
+	TrueColor bool
+
+	clear string
+	el    string
+	cup   string
+	home  string
+	civis string
+	cnorm string
+	rev   string
+	sgr0  string
+	smcup string
+	rmcup string
+}
+
+// indexes of the capabilities in the compiled format, see term(5)
+const (
+	numColors = 13
+
+	strClear = 5
+	strEl    = 6
+	strCup   = 10
+	strHome  = 12
+	strCivis = 13
+	strCnorm = 16
+	strSmcup = 28
+	strRev   = 34
+	strSgr0  = 39
+	strRmcup = 40
+)
+
+// terminfoDirs returns the directories searched for terminfo entries.
+func terminfoDirs() []string {
+	var dirs []string
+	if dir := os.Getenv("TERMINFO"); dir != "" {
+		dirs = append(dirs, dir)
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs, filepath.Join(home, ".terminfo"))
+	}
+	for _, dir := range strings.Split(os.Getenv("TERMINFO_DIRS"), ":") {
+		if dir != "" {
+			dirs = append(dirs, dir)
+		}
+	}
+	return append(dirs, "/etc/terminfo", "/lib/terminfo", "/usr/share/terminfo", "/usr/lib/terminfo")
+}
+
+// LoadTerminfo reads the terminfo entry for the named terminal.
+func LoadTerminfo(name string) (*Terminfo, error) {
+	if name == "" {
+		return nil, errors.New("TERM is not set")
+	}
+	for _, dir := range terminfoDirs() {
+		// macOS uses the hex value of the first letter for the directory
+		for _, sub := range []string{name[:1], fmt.Sprintf("%x", name[0])} {
+			data, err := os.ReadFile(filepath.Join(dir, sub, name))
+			if err == nil {
+				return parseTerminfo(data)
+			}
+		}
+	}
+	return nil, fmt.Errorf("no terminfo entry for %q", name)
+}
+
+func parseTerminfo(data []byte) (*Terminfo, error) {
+	var header [6]int16
+	if err := binary.Read(bytes.NewReader(data), binary.LittleEndian, &header); err != nil {
+		return nil, fmt.Errorf("invalid terminfo: %w", err)
+	}
+	numsize := 2
+	switch header[0] {
+	case 0432:
+	case 01036:
+		numsize = 4
+	default:
+		return nil, errors.New("invalid terminfo: bad magic number")
+	}
+	names, bools, nums, strs, table := int(header[1]), int(header[2]), int(header[3]), int(header[4]), int(header[5])
+	off := 12 + names + bools
+	if off%2 != 0 {
+		off++
+	}
+	numoff := off
+	stroff := numoff + nums*numsize
+	tableoff := stroff + strs*2
+	if names < 0 || bools < 0 || nums < 0 || strs < 0 || table < 0 || tableoff+table > len(data) {
+		return nil, errors.New("invalid terminfo: truncated")
+	}
+	num := func(i int) int {
+		if i >= nums {
+			return -1
+		}
+		p := data[numoff+i*numsize:]
+		if numsize == 4 {
+			return int(int32(binary.LittleEndian.Uint32(p)))
+		}
+		return int(int16(binary.LittleEndian.Uint16(p)))
+	}
+	str := func(i int) string {
+		if i >= strs {
+			return ""
+		}
+		n := int(int16(binary.LittleEndian.Uint16(data[stroff+i*2:])))
+		if n < 0 || n >= table {
+			return ""
+		}
+		s := data[tableoff+n : tableoff+table]
+		if end := bytes.IndexByte(s, 0); end >= 0 {
+			s = s[:end]
+		}
+		return stripPadding(string(s))
+	}
+	return &Terminfo{
+		Colors: num(numColors),
+		clear:  str(strClear),
+		el:     str(strEl),
+		cup:    str(strCup),
+		home:   str(strHome),
+		civis:  str(strCivis),
+		cnorm:  str(strCnorm),
+		rev:    str(strRev),
+		sgr0:   str(strSgr0),
+		smcup:  str(strSmcup),
+		rmcup:  str(strRmcup),
+	}, nil
+}
+
+// stripPadding removes $<n> delays.
+func stripPadding(s string) string {
+	for {
+		i := strings.Index(s, "$<")
+		if i < 0 {
+			return s
+		}
+		j := strings.IndexByte(s[i:], '>')
+		if j < 0 {
+			return s
+		}
+		s = s[:i] + s[i+j+1:]
+	}
+}
+
+// tparm expands the parameters of a capability. Only the operations
+// used for cursor addressing are supported.
+func tparm(s string, params ...int) (string, error) {
+	var b strings.Builder
+	var stack []int
+	pop := func() int {
+		if len(stack) == 0 {
+			return 0
+		}
+		v := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		return v
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' || i == len(s)-1 {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch c := s[i]; c {
+		case '%':
+			b.WriteByte('%')
+		case 'i':
+			for j := 0; j < 2 && j < len(params); j++ {
+				params[j]++
+			}
+		case 'p':
+			if i+1 < len(s) && s[i+1] >= '1' && s[i+1] <= '9' {
+				i++
+				n := int(s[i] - '1')
+				if n < len(params) {
+					stack = append(stack, params[n])
+				} else {
+					stack = append(stack, 0)
+				}
+			}
+		case 'd':
+			b.WriteString(strconv.Itoa(pop()))
+		case 'c':
+			b.WriteByte(byte(pop()))
+		case '{':
+			end := strings.IndexByte(s[i:], '}')
+			if end < 0 {
+				return "", errors.New("unterminated constant")
+			}
+			n, _ := strconv.Atoi(s[i+1 : i+end])
+			stack = append(stack, n)
+			i += end
+		case '+', '-', '*', '/':
+			y, x := pop(), pop()
+			switch c {
+			case '+':
+				stack = append(stack, x+y)
+			case '-':
+				stack = append(stack, x-y)
+			case '*':
+				stack = append(stack, x*y)
+			case '/':
+				if y != 0 {
+					stack = append(stack, x/y)
+				} else {
+					stack = append(stack, 0)
+				}
+			}
+		default:
+			return "", fmt.Errorf("unsupported operation %%%c", c)
+		}
+	}
+	return b.String(), nil
+}
+
+// translate rewrites the ANSI sequences the editor writes into the
+// terminal's own, and drops the ones it doesn't support.
+func (ti *Terminfo) translate(p []byte) []byte {
+	out := make([]byte, 0, len(p))
+	for i := 0; i < len(p); i++ {
+		if p[i] != '\x1b' || i+1 >= len(p) || p[i+1] != '[' {
+			out = append(out, p[i])
+			continue
+		}
+		// find the final byte of the control sequence
+		j := i + 2
+		for j < len(p) && (p[j] < 0x40 || p[j] > 0x7e) {
+			j++
+		}
+		if j == len(p) {
+			out = append(out, p[i:]...)
+			break
+		}
+		out = append(out, ti.sequence(string(p[i+2:j]), p[j], p[i:j+1])...)
+		i = j
+	}
+	return out
+}
+
+// sequence translates a single control sequence with the parameters
+// and final byte.
+func (ti *Terminfo) sequence(params string, final byte, orig []byte) []byte {
+	switch seq := params + string(final); {
+	case final == 'H':
+		if params == "" && ti.home != "" {
+			return []byte(ti.home)
+		}
+		row, col := 1, 1
+		if r, c, ok := strings.Cut(params, ";"); ok {
+			row, _ = strconv.Atoi(r)
+			col, _ = strconv.Atoi(c)
+		}
+		if s, err := tparm(ti.cup, row-1, col-1); err == nil {
+			return []byte(s)
+		}
+	case final == 'K' && params == "":
+		return []byte(ti.el)
+	case final == 'J' && params == "2":
+		return []byte(ti.clear)
+	case final == 'm':
+		return ti.sgr(params)
+	case seq == "?25l":
+		return []byte(ti.civis)
+	case seq == "?25h":
+		return []byte(ti.cnorm)
+	case seq == "?1049h" && ti.smcup != "":
+		return []byte(ti.smcup)
+	case seq == "?1049l" && ti.rmcup != "":
+		return []byte(ti.rmcup)
+	}
+	return orig
+}
+
+// color256 returns the color of the 6x6x6 cube of the 256 color
+// palette closest to a 24 bit color.
+func color256(r, g, b int) int {
+	// the cube's levels are 0, 95, 135, 175, 215 and 255
+	level := func(v int) int {
+		if v < 48 {
+			return 0
+		}
+		if v < 115 {
+			return 1
+		}
+		return (v - 35) / 40
+	}
+	return 16 + 36*level(r) + 6*level(g) + level(b)
+}
+
+// sgr filters the graphic rendition parameters down to the ones the
+// terminal supports.
+func (ti *Terminfo) sgr(params string) []byte {
+	if ti.sgr0 == "" && ti.rev == "" {
+		return nil
+	}
+	var keep []string
+	fields := strings.Split(params, ";")
+	for i := 0; i < len(fields); i++ {
+		f := fields[i]
+		n, _ := strconv.Atoi(f)
+		switch {
+		case n == 38 || n == 48:
+			// 256 colors: 38;5;n
+			if i+2 < len(fields) && fields[i+1] == "5" {
+				if ti.Colors >= 256 {
+					keep = append(keep, fields[i:i+3]...)
+				}
+				i += 2
+			}
+			// 24 bit colors: 38;2;r;g;b, the closest of the 256 colors
+			// without support for them
+			if i+4 < len(fields) && fields[i+1] == "2" {
+				switch {
+				case ti.TrueColor:
+					keep = append(keep, fields[i:i+5]...)
+				case ti.Colors >= 256:
+					r, _ := strconv.Atoi(fields[i+2])
+					g, _ := strconv.Atoi(fields[i+3])
+					b, _ := strconv.Atoi(fields[i+4])
+					keep = append(keep, f, "5", strconv.Itoa(color256(r, g, b)))
+				}
+				i += 4
+			}
+		case 30 <= n && n <= 49:
+			if ti.Colors >= 8 {
+				keep = append(keep, f)
+			}
+		case 90 <= n && n <= 107:
+			switch {
+			case ti.Colors >= 16:
+				keep = append(keep, f)
+			case ti.Colors >= 8 && n == 90:
+				keep = append(keep, "37")
+			case ti.Colors >= 8:
+				keep = append(keep, strconv.Itoa(n-60))
+			}
+		default:
+			keep = append(keep, f)
+		}
+	}
+	if len(keep) == 0 {
+		return nil
+	}
+	if ti.Colors < 8 && ti.rev != "" {
+		// no colors, but inverse video and resets still work
+		var b []byte
+		for _, f := range keep {
+			switch f {
+			case "", "0", "27":
+				b = append(b, ti.sgr0...)
+			case "7":
+				b = append(b, ti.rev...)
+			}
+		}
+		return b
+	}
+	return []byte("\x1b[" + strings.Join(keep, ";") + "m")
+}
@@ -0,0 +1,70 @@
+package ui
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+
+	"github.com/icholy/kilo/internal/buffer"
+)
+
+// htmlColors are the CSS colors of the foreground color parameters
+// returned by SyntaxToColor.
+var htmlColors = map[int]string{
+	31: "#c23621",
+	32: "#25bc24",
+	33: "#adad27",
+	34: "#492ee1",
+	35: "#d338d3",
+	36: "#33bbc8",
+	90: "#808080",
+	91: "#fc391f",
+	93: "#fce94f",
+}
+
+// ExportANSI writes the rows with their highlighting as ANSI colored
+// text.
+func ExportANSI(w io.Writer, rows []*buffer.Row) error {
+	bw := bufio.NewWriter(w)
+	var b bytes.Buffer
+	for _, row := range rows {
+		b.Reset()
+		DrawRow(&b, row, 0, len(row.Render()), RowStyle{SelStart: -1, SelEnd: -1, CursorCol: -1})
+		b.WriteString("\x1b[m\n")
+		bw.Write(b.Bytes())
+	}
+	return bw.Flush()
+}
+
+// ExportHTML writes the rows with their highlighting as a standalone
+// HTML document.
+func ExportHTML(w io.Writer, title string, rows []*buffer.Row) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>%s</title>\n", html.EscapeString(title))
+	bw.WriteString("<style>body { background: #1e1e1e; color: #d4d4d4; } pre { font-family: monospace; }</style>\n")
+	bw.WriteString("</head>\n<body>\n<pre>")
+	for _, row := range rows {
+		row.Highlight()
+		render := row.Render()
+		for i := 0; i < len(render); {
+			// write a run of bytes with the same color
+			color := SyntaxToColor(row.HL[i])
+			j := i + 1
+			for j < len(render) && SyntaxToColor(row.HL[j]) == color {
+				j++
+			}
+			text := html.EscapeString(string(render[i:j]))
+			if css, ok := htmlColors[color]; ok {
+				fmt.Fprintf(bw, "<span style=\"color: %s\">%s</span>", css, text)
+			} else {
+				bw.WriteString(text)
+			}
+			i = j
+		}
+		bw.WriteByte('\n')
+	}
+	bw.WriteString("</pre>\n</body>\n</html>\n")
+	return bw.Flush()
+}
@@ -0,0 +1,63 @@
+package ui
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// PopupMaxHeight is the maximum number of visible popup lines.
+const PopupMaxHeight = 10
+
+// Popup is a list of lines displayed next to the cursor.
+type Popup struct {
+	Lines []string
+	// Selected is the index of the highlighted line, or -1.
+	Selected int
+	// Offset is the index of the first visible line.
+	Offset int
+}
+
+// Draw draws the popup below the screen position y, x of the cursor,
+// or above it if there's no room. The screen has rows by cols cells.
+func (p *Popup) Draw(b *bytes.Buffer, y, x, rows, cols int) {
+	if len(p.Lines) == 0 {
+		return
+	}
+	lines := p.Lines[p.Offset:]
+	if len(lines) > PopupMaxHeight {
+		lines = lines[:PopupMaxHeight]
+	}
+	var width int
+	for _, line := range lines {
+		if len(line) > width {
+			width = len(line)
+		}
+	}
+	if width > cols-2 {
+		width = cols - 2
+	}
+	// place the popup below the cursor, or above it if there's no room
+	y++
+	if y+len(lines) > rows && y-1-len(lines) >= 0 {
+		y -= len(lines) + 1
+	}
+	if x+width+2 > cols {
+		x = cols - width - 2
+	}
+	for i, line := range lines {
+		if y+i >= rows {
+			break
+		}
+		if len(line) > width {
+			line = line[:width]
+		}
+		fmt.Fprintf(b, "\x1b[%d;%dH", y+i+1, x+1)
+		if p.Offset+i == p.Selected {
+			b.WriteString("\x1b[7m")
+		} else {
+			b.WriteString("\x1b[100m")
+		}
+		fmt.Fprintf(b, " %-*s ", width, line)
+		b.WriteString("\x1b[m")
+	}
+}
@@ -0,0 +1,287 @@
+// Package ui renders rows, popups, and the status bar as terminal
+// escape sequences.
+package ui
+
+import (
+	"bytes"
+	"fmt"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/icholy/kilo/internal/buffer"
+)
+
+// Background color parameters.
+const (
+	// CursorBackground is used for the cursor line and column.
+	CursorBackground = "48;5;236"
+	// MarkBackground is used for marked columns, e.g. the occurrences
+	// of the word under the cursor.
+	MarkBackground = "48;5;239"
+	// defaultBackground is the terminal's background.
+	defaultBackground = "49"
+	// trailingBackground is used for trailing whitespace, and for
+	// invisible or confusable characters.
+	trailingBackground = "41"
+	// mixedBackground is used for indentation mixing tabs and spaces.
+	mixedBackground = "43"
+)
+
+// SyntaxToColor returns the foreground color parameter of hl.
+func SyntaxToColor(hl buffer.Highlight) int {
+	switch hl {
+	case buffer.HighlightNumber:
+		return 31
+	case buffer.HighlightString, buffer.HighlightCode:
+		return 33
+	case buffer.HighlightComment:
+		return 32
+	case buffer.HighlightSpell:
+		return 31
+	case buffer.HighlightMatch:
+		return 34
+	case buffer.HighlightKeyword, buffer.HighlightHeading:
+		return 35
+	case buffer.HighlightType:
+		return 36
+	case buffer.HighlightControl:
+		return 91
+	case buffer.HighlightTodo:
+		return 93
+	case buffer.HighlightTab, buffer.HighlightSpace, buffer.HighlightTrailing, buffer.HighlightTrailingTab:
+		return 90
+	default:
+		return 37
+	}
+}
+
+// textAttribute returns the bold or italic attribute parameter of hl,
+// or "" for neither.
+func textAttribute(hl buffer.Highlight) string {
+	switch hl {
+	case buffer.HighlightHeading, buffer.HighlightStrong, buffer.HighlightTodo:
+		return "1"
+	case buffer.HighlightEmphasis:
+		return "3"
+	default:
+		return ""
+	}
+}
+
+// whitespaceGlyph returns the text drawn in place of a render byte
+// highlighted as whitespace, or "" to draw the byte itself.
+func whitespaceGlyph(hl buffer.Highlight) string {
+	switch hl {
+	case buffer.HighlightTab, buffer.HighlightTrailingTab:
+		return "→"
+	case buffer.HighlightSpace:
+		return "·"
+	default:
+		return ""
+	}
+}
+
+// suspiciousGlyph returns what's drawn for line[i], a byte of an
+// invisible or confusable character. Spaces are drawn as they are, and
+// the other characters as a question mark.
+func suspiciousGlyph(line []byte, i int) []byte {
+	start := i
+	for start > 0 && i-start < utf8.UTFMax-1 && !utf8.RuneStart(line[start]) {
+		start--
+	}
+	if c, _ := utf8.DecodeRune(line[start:]); unicode.Is(unicode.Zs, c) {
+		return line[i : i+1]
+	}
+	if start == i {
+		return []byte("?")
+	}
+	return nil
+}
+
+// RowStyle holds the decorations of a row which don't come from its
+// highlights.
+type RowStyle struct {
+	// SelStart and SelEnd are the selected render columns.
+	SelStart, SelEnd int
+	// Background is the background color parameter of the whole row,
+	// or "" for the default.
+	Background string
+	// CursorCol is the screen column of the cursor column highlight,
+	// or -1 for none.
+	CursorCol int
+	// Marked render columns are drawn with MarkBackground.
+	Marked []bool
+	// Swatches are drawn on the background of the color they spell.
+	Swatches []Swatch
+	// VirtualText is drawn dimmed after the text, in the foreground
+	// color parameter VirtualColor, e.g. a diagnostic of the line.
+	VirtualText  string
+	VirtualColor int
+}
+
+// Swatch is a color literal in a row, from render column Start up to
+// End.
+type Swatch struct {
+	Start, End int
+	R, G, B    uint8
+}
+
+// background returns the truecolor background parameter of the swatch.
+func (s *Swatch) background() string {
+	return fmt.Sprintf("48;2;%d;%d;%d", s.R, s.G, s.B)
+}
+
+// foreground returns black or white, whichever is easier to read on
+// the swatch's color.
+func (s *Swatch) foreground() int {
+	// the perceived brightness, from 0 to 255
+	if (299*int(s.R)+587*int(s.G)+114*int(s.B))/1000 > 140 {
+		return 30
+	}
+	return 97
+}
+
+// swatchAt returns the swatch covering render column x, or nil.
+func swatchAt(swatches []Swatch, x int) *Swatch {
+	for i := range swatches {
+		if swatches[i].Start <= x && x < swatches[i].End {
+			return &swatches[i]
+		}
+	}
+	return nil
+}
+
+// DrawRow draws at most cols columns of row starting at render column
+// coloff. The line isn't cleared or terminated.
+func DrawRow(b *bytes.Buffer, row *buffer.Row, coloff, cols int, style RowStyle) {
+	row.Highlight()
+	line := row.Render()
+	if coloff >= len(line) {
+		coloff = 0
+	}
+	line = line[coloff:]
+	if len(line) > cols {
+		// don't cut a character in two
+		n := cols
+		for n > 0 && !utf8.RuneStart(line[n]) {
+			n--
+		}
+		line = line[:n]
+	}
+	// the rest of a character scrolled off the left edge is blank
+	var partial int
+	for partial < len(line) && !utf8.RuneStart(line[partial]) {
+		partial++
+	}
+	rowbg := style.Background
+	if rowbg == "" {
+		rowbg = defaultBackground
+	}
+	var prevcolor int
+	var prevhl buffer.Highlight
+	var attr string
+	var selected bool
+	bg := defaultBackground
+	for i, c := range line {
+		if i < partial {
+			b.WriteByte(' ')
+			continue
+		}
+		if !utf8.RuneStart(c) {
+			// escape sequences in the middle of a character would
+			// break it, the rest of it is drawn like its first byte
+			if row.HL[i+coloff] != buffer.HighlightSuspicious {
+				b.WriteByte(c)
+			} else {
+				b.Write(suspiciousGlyph(line, i))
+			}
+			continue
+		}
+		if sel := i+coloff >= style.SelStart && i+coloff < style.SelEnd; sel != selected {
+			selected = sel
+			if sel {
+				b.WriteString("\x1b[7m")
+			} else {
+				b.WriteString("\x1b[27m")
+			}
+		}
+		hl := row.HL[i+coloff]
+		if hl == buffer.HighlightSpell && prevhl != buffer.HighlightSpell {
+			b.WriteString("\x1b[4m")
+		} else if hl != buffer.HighlightSpell && prevhl == buffer.HighlightSpell {
+			b.WriteString("\x1b[24m")
+		}
+		if a := textAttribute(hl); a != attr {
+			b.WriteString("\x1b[22;23m")
+			if a != "" {
+				fmt.Fprintf(b, "\x1b[%sm", a)
+			}
+			attr = a
+		}
+		cellbg := rowbg
+		if i == style.CursorCol {
+			cellbg = CursorBackground
+		}
+		if style.Marked != nil && style.Marked[i+coloff] {
+			cellbg = MarkBackground
+		}
+		switch hl {
+		case buffer.HighlightTrailing, buffer.HighlightTrailingTab, buffer.HighlightSuspicious:
+			cellbg = trailingBackground
+		case buffer.HighlightMixedIndent:
+			cellbg = mixedBackground
+		}
+		swatch := swatchAt(style.Swatches, i+coloff)
+		if swatch != nil {
+			cellbg = swatch.background()
+		}
+		if cellbg != bg {
+			fmt.Fprintf(b, "\x1b[%sm", cellbg)
+			bg = cellbg
+		}
+		prevhl = hl
+		if swatch != nil {
+			if color := swatch.foreground(); color != prevcolor {
+				fmt.Fprintf(b, "\x1b[%dm", color)
+				prevcolor = color
+			}
+		} else if hl == buffer.HighlightNormal {
+			b.WriteString("\x1b[39m")
+			prevcolor = -1
+		} else {
+			if color := SyntaxToColor(hl); color != prevcolor {
+				fmt.Fprintf(b, "\x1b[%dm", color)
+				prevcolor = color
+			}
+		}
+		if glyph := whitespaceGlyph(hl); glyph != "" {
+			b.WriteString(glyph)
+		} else if hl == buffer.HighlightSuspicious {
+			b.Write(suspiciousGlyph(line, i))
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	if attr != "" {
+		b.WriteString("\x1b[22;23m")
+	}
+	b.WriteString("\x1b[39;24;27m")
+	used := Width(line)
+	// extend the cursor column past the end of short lines
+	if style.CursorCol >= len(line) && style.CursorCol < cols {
+		fmt.Fprintf(b, "\x1b[%sm%*s\x1b[%sm ", rowbg, style.CursorCol-len(line), "", CursorBackground)
+		bg = ""
+		used = style.CursorCol + 1
+	}
+	if text := cutWidth(style.VirtualText, cols-used-2); text != "" {
+		fmt.Fprintf(b, "\x1b[%sm  \x1b[2;%dm%s\x1b[22;39m", rowbg, style.VirtualColor, text)
+		bg = rowbg
+	}
+	if rowbg != defaultBackground {
+		fmt.Fprintf(b, "\x1b[%sm\x1b[K", rowbg)
+		bg = ""
+	}
+	if bg != defaultBackground {
+		fmt.Fprintf(b, "\x1b[%sm", defaultBackground)
+	}
+}
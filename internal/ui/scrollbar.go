@@ -0,0 +1,36 @@
+package ui
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// scrollbarBackground is the background color parameter of the
+// scrollbar thumb.
+const scrollbarBackground = "48;5;242"
+
+// Scrollbar returns the screen rows covered by the scrollbar thumb
+// when rowoff is the first of rows visible rows out of numrows. There's
+// no scrollbar when the whole file fits on the screen.
+func Scrollbar(rowoff, numrows, rows int) (start, end int, ok bool) {
+	if numrows <= rows {
+		return 0, 0, false
+	}
+	size := rows * rows / numrows
+	if size < 1 {
+		size = 1
+	}
+	start = rowoff * rows / numrows
+	if start+size > rows {
+		start = rows - size
+	}
+	return start, start + size, true
+}
+
+// DrawScrollbar draws the scrollbar thumb covering screen rows
+// [start, end) in column col.
+func DrawScrollbar(b *bytes.Buffer, start, end, col int) {
+	for y := start; y < end; y++ {
+		fmt.Fprintf(b, "\x1b[%d;%dH\x1b[%sm \x1b[49m", y+1, col+1, scrollbarBackground)
+	}
+}
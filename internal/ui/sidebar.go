@@ -0,0 +1,76 @@
+package ui
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Sidebar is a list of lines displayed below a title in the columns at
+// the right of the screen.
+type Sidebar struct {
+	Title string
+	Lines []string
+	// Selected is the index of the highlighted line, or -1.
+	Selected int
+	// Offset is the index of the first visible line.
+	Offset int
+}
+
+// Scroll moves the offset so that the selected line is visible when
+// the sidebar is rows rows high, the title included.
+func (s *Sidebar) Scroll(rows int) {
+	visible := rows - 1
+	if s.Selected >= 0 {
+		if s.Selected < s.Offset {
+			s.Offset = s.Selected
+		}
+		if visible > 0 && s.Selected >= s.Offset+visible {
+			s.Offset = s.Selected - visible + 1
+		}
+	}
+	if s.Offset > len(s.Lines)-visible {
+		s.Offset = len(s.Lines) - visible
+	}
+	if s.Offset < 0 {
+		s.Offset = 0
+	}
+}
+
+// Draw draws the sidebar rows rows high and width columns wide,
+// starting at column col. It's separated from the text on its left by
+// a line. The selected line is shown in reverse video when the sidebar
+// has the focus, and highlighted otherwise.
+func (s *Sidebar) Draw(b *bytes.Buffer, col, rows, width int, focused bool) {
+	if width < 2 {
+		return
+	}
+	for y := 0; y < rows; y++ {
+		fmt.Fprintf(b, "\x1b[%d;%dH\x1b[90m│\x1b[m", y+1, col+1)
+		i := s.Offset + y - 1
+		var text string
+		switch {
+		case y == 0:
+			text = s.Title
+			b.WriteString("\x1b[1m")
+		case i >= len(s.Lines):
+		case i == s.Selected && focused:
+			text = s.Lines[i]
+			b.WriteString("\x1b[7m")
+		case i == s.Selected:
+			text = s.Lines[i]
+			b.WriteString("\x1b[100m")
+		default:
+			text = s.Lines[i]
+		}
+		b.WriteString(fitWidth(" "+text, width-1))
+		b.WriteString("\x1b[m")
+	}
+}
+
+// fitWidth cuts line to width screen columns, or pads it with blanks
+// to width columns.
+func fitWidth(line string, width int) string {
+	line = cutWidth(line, width)
+	return line + strings.Repeat(" ", width-Width([]byte(line)))
+}
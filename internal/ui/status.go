@@ -0,0 +1,138 @@
+package ui
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// DefaultStatusLine is the default status bar format.
+const DefaultStatusLine = "%f - line %l/%L%m%r%=col %c (%v) %p "
+
+// StatusInfo holds the values which can be shown in the status bar.
+type StatusInfo struct {
+	Filename  string
+	Filetype  string
+	Branch    string
+	Line      int
+	Lines     int
+	Col       int
+	RenderCol int
+	Modified  bool
+	Selecting bool
+	CRLF      bool
+	ReadOnly  bool
+}
+
+// StatusLine expands the statusline format:
+//
+//	%f  file name         %t  file type
+//	%e  encoding          %b  git branch
+//	%l  line              %L  number of lines
+//	%c  column            %v  render column
+//	%p  percentage        %m  modified flag
+//	%M  mode              %n  line endings
+//	%r  read-only flag    %%  literal %
+//	%=  separates the left and right aligned parts
+func StatusLine(format string, s StatusInfo) (left, right string) {
+	var b strings.Builder
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' || i == len(format)-1 {
+			b.WriteByte(format[i])
+			continue
+		}
+		i++
+		switch format[i] {
+		case 'f':
+			filename := s.Filename
+			if filename == "" {
+				filename = "[No Name]"
+			}
+			fmt.Fprintf(&b, "%.20s", filename)
+		case 't':
+			if s.Filetype != "" {
+				b.WriteString(s.Filetype)
+			} else {
+				b.WriteString("none")
+			}
+		case 'e':
+			b.WriteString("utf-8")
+		case 'n':
+			if s.CRLF {
+				b.WriteString("crlf")
+			} else {
+				b.WriteString("lf")
+			}
+		case 'b':
+			b.WriteString(s.Branch)
+		case 'l':
+			fmt.Fprint(&b, s.Line)
+		case 'L':
+			fmt.Fprint(&b, s.Lines)
+		case 'c':
+			fmt.Fprint(&b, s.Col)
+		case 'v':
+			fmt.Fprint(&b, s.RenderCol)
+		case 'p':
+			pct := 100
+			if s.Lines > 0 && s.Line <= s.Lines {
+				pct = s.Line * 100 / s.Lines
+			}
+			fmt.Fprintf(&b, "%d%%", pct)
+		case 'm':
+			if s.Modified {
+				b.WriteString(" (modified)")
+			}
+		case 'r':
+			if s.ReadOnly {
+				b.WriteString(" (read-only)")
+			}
+		case 'M':
+			if s.Selecting {
+				b.WriteString("SELECT")
+			} else {
+				b.WriteString("EDIT")
+			}
+		case '=':
+			left = b.String()
+			b.Reset()
+			right = "\x00" // marks that there is a right part
+		case '%':
+			b.WriteByte('%')
+		default:
+			b.WriteByte('%')
+			b.WriteByte(format[i])
+		}
+	}
+	if right == "" {
+		return b.String(), ""
+	}
+	return left, b.String()
+}
+
+// DrawStatusBar draws the left and right parts of the status bar in
+// reverse video, followed by a line break.
+func DrawStatusBar(b *bytes.Buffer, left, right string, cols int) {
+	b.WriteString("\x1b[7m")
+	if len(left) > cols {
+		left = left[:cols]
+	}
+	b.WriteString(left)
+	for i := len(left); i < cols-len(right); i++ {
+		b.WriteString(" ")
+	}
+	if len(left)+len(right) <= cols {
+		b.WriteString(right)
+	}
+	b.WriteString("\x1b[m")
+	b.WriteString("\r\n")
+}
+
+// DrawMessage draws the message bar.
+func DrawMessage(b *bytes.Buffer, message string, cols int) {
+	b.WriteString("\x1b[K")
+	if len(message) > cols {
+		message = message[:cols]
+	}
+	b.WriteString(message)
+}
@@ -0,0 +1,79 @@
+package ui
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// wideRanges are the characters which take up two columns of the
+// screen: the East Asian wide and fullwidth ones, and emoji.
+var wideRanges = [][2]rune{
+	{0x1100, 0x115f},   // Hangul Jamo
+	{0x2e80, 0x303e},   // CJK radicals and punctuation
+	{0x3041, 0x33ff},   // kana, Bopomofo, CJK compatibility
+	{0x3400, 0x4dbf},   // CJK extension A
+	{0x4e00, 0x9fff},   // CJK unified ideographs
+	{0xa000, 0xa4cf},   // Yi
+	{0xac00, 0xd7a3},   // Hangul syllables
+	{0xf900, 0xfaff},   // CJK compatibility ideographs
+	{0xfe30, 0xfe4f},   // CJK compatibility forms
+	{0xff00, 0xff60},   // fullwidth forms
+	{0xffe0, 0xffe6},   // fullwidth signs
+	{0x1f300, 0x1f64f}, // symbols and emoticons
+	{0x1f900, 0x1f9ff}, // supplemental symbols
+	{0x20000, 0x3fffd}, // CJK extensions B and later
+}
+
+// RuneWidth returns the number of screen columns c takes up.
+func RuneWidth(c rune) int {
+	if unicode.In(c, unicode.Mn, unicode.Me) || c == '\u200b' {
+		return 0
+	}
+	for _, r := range wideRanges {
+		if c < r[0] {
+			break
+		}
+		if c <= r[1] {
+			return 2
+		}
+	}
+	return 1
+}
+
+// Width returns the number of screen columns the rendered text takes
+// up. Bytes which aren't utf-8 take up a column each, like the
+// characters they're drawn as.
+func Width(text []byte) int {
+	var n int
+	for len(text) > 0 {
+		c, size := utf8.DecodeRune(text)
+		if c == utf8.RuneError {
+			n += size
+		} else {
+			n += RuneWidth(c)
+		}
+		text = text[size:]
+	}
+	return n
+}
+
+// cutWidth returns the start of text which takes up at most width
+// screen columns, with tabs and other control characters replaced by
+// blanks.
+func cutWidth(text string, width int) string {
+	var b strings.Builder
+	var n int
+	for _, c := range text {
+		if unicode.IsControl(c) {
+			c = ' '
+		}
+		w := RuneWidth(c)
+		if n+w > width {
+			break
+		}
+		b.WriteRune(c)
+		n += w
+	}
+	return b.String()
+}
@@ -0,0 +1,613 @@
+// Package vt emulates the subset of an xterm which shells and the
+// programs run from them use, so their output can be drawn in a pane.
+package vt
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Style is how a cell is drawn. The colors are 0 for the default, and
+// otherwise one more than the 256 color palette index.
+type Style struct {
+	FG, BG    int
+	Bold      bool
+	Underline bool
+	Reverse   bool
+}
+
+// sgr returns the SGR parameters which select the style.
+func (s Style) sgr() string {
+	params := []string{"0"}
+	if s.Bold {
+		params = append(params, "1")
+	}
+	if s.Underline {
+		params = append(params, "4")
+	}
+	if s.Reverse {
+		params = append(params, "7")
+	}
+	if s.FG > 0 {
+		params = append(params, fmt.Sprintf("38;5;%d", s.FG-1))
+	}
+	if s.BG > 0 {
+		params = append(params, fmt.Sprintf("48;5;%d", s.BG-1))
+	}
+	return strings.Join(params, ";")
+}
+
+// Cell is a character on the screen. The zero Cell is a blank.
+type Cell struct {
+	Ch    rune
+	Style Style
+}
+
+// parser states
+const (
+	stateGround = iota
+	stateEscape
+	stateCSI
+	stateOSC
+	stateOSCEscape
+	stateCharset
+)
+
+// Screen is the state of an emulated terminal: its cells, the cursor,
+// and the modes set by the program running in it.
+type Screen struct {
+	Rows, Cols int
+	// X and Y are the position of the cursor.
+	X, Y int
+	// AppCursor is set when the arrow keys send application sequences.
+	AppCursor bool
+	// HideCursor is set when the program hid the cursor.
+	HideCursor bool
+	// Title is set by the program, usually to the running command.
+	Title string
+
+	cells    [][]Cell
+	main     [][]Cell // the main screen while the alternate one is shown
+	style    Style
+	wrapnext bool
+	// top and bottom are the scrolling region
+	top, bottom int
+	saved       struct {
+		x, y  int
+		style Style
+	}
+	state  int
+	params []byte
+	osc    []byte
+	utf8   []byte
+	reply  []byte
+}
+
+// New returns a blank screen.
+func New(rows, cols int) *Screen {
+	s := &Screen{}
+	s.Resize(rows, cols)
+	return s
+}
+
+func blankRows(rows, cols int) [][]Cell {
+	cells := make([][]Cell, rows)
+	for i := range cells {
+		cells[i] = make([]Cell, cols)
+	}
+	return cells
+}
+
+// resizeRows copies cells into rows of the new size, starting from the
+// row shift.
+func resizeRows(cells [][]Cell, rows, cols, shift int) [][]Cell {
+	resized := blankRows(rows, cols)
+	for y := range resized {
+		if y+shift < len(cells) {
+			copy(resized[y], cells[y+shift])
+		}
+	}
+	return resized
+}
+
+// Resize changes the size of the screen. When there are fewer rows, the
+// ones at the top are dropped to keep the cursor on the screen.
+func (s *Screen) Resize(rows, cols int) {
+	if rows < 1 {
+		rows = 1
+	}
+	if cols < 1 {
+		cols = 1
+	}
+	shift := 0
+	if s.Y >= rows {
+		shift = s.Y - rows + 1
+	}
+	s.cells = resizeRows(s.cells, rows, cols, shift)
+	if s.main != nil {
+		s.main = resizeRows(s.main, rows, cols, shift)
+	}
+	s.Rows, s.Cols = rows, cols
+	s.Y -= shift
+	s.X = clamp(s.X, 0, cols-1)
+	s.top, s.bottom = 0, rows-1
+	s.wrapnext = false
+}
+
+// Reply returns the responses to the queries made by the program, which
+// have to be written back to it, e.g. the cursor position.
+func (s *Screen) Reply() []byte {
+	r := s.reply
+	s.reply = nil
+	return r
+}
+
+// DrawRow writes the escape sequences which draw row y.
+func (s *Screen) DrawRow(b *bytes.Buffer, y int) {
+	var style Style
+	b.WriteString("\x1b[m")
+	for _, c := range s.cells[y] {
+		if c.Style != style {
+			style = c.Style
+			fmt.Fprintf(b, "\x1b[%sm", style.sgr())
+		}
+		if c.Ch == 0 {
+			b.WriteByte(' ')
+		} else {
+			b.WriteRune(c.Ch)
+		}
+	}
+	b.WriteString("\x1b[m")
+}
+
+// SameRow reports whether row y of s and of t have the same cells.
+func (s *Screen) SameRow(t *Screen, y int) bool {
+	if y >= t.Rows || len(s.cells[y]) != len(t.cells[y]) {
+		return false
+	}
+	for x, c := range s.cells[y] {
+		if t.cells[y][x] != c {
+			return false
+		}
+	}
+	return true
+}
+
+// Text returns the characters of row y, without the trailing blanks.
+func (s *Screen) Text(y int) string {
+	var b strings.Builder
+	for _, c := range s.cells[y] {
+		if c.Ch == 0 {
+			b.WriteByte(' ')
+		} else {
+			b.WriteRune(c.Ch)
+		}
+	}
+	return strings.TrimRight(b.String(), " ")
+}
+
+// Write interprets the output of the program.
+func (s *Screen) Write(p []byte) (int, error) {
+	for _, c := range p {
+		s.feed(c)
+	}
+	return len(p), nil
+}
+
+func (s *Screen) feed(c byte) {
+	switch s.state {
+	case stateGround:
+		s.ground(c)
+	case stateEscape:
+		s.escape(c)
+	case stateCSI:
+		switch {
+		case c == '\x1b':
+			s.state = stateEscape
+		case c < ' ':
+			s.control(c)
+		case c < '@':
+			s.params = append(s.params, c)
+		default:
+			s.csi(string(s.params), c)
+			s.state = stateGround
+		}
+	case stateOSC:
+		switch c {
+		case '\a':
+			s.command(string(s.osc))
+			s.state = stateGround
+		case '\x1b':
+			s.state = stateOSCEscape
+		default:
+			if len(s.osc) < 1024 {
+				s.osc = append(s.osc, c)
+			}
+		}
+	case stateOSCEscape:
+		// ESC \ terminates the string
+		s.command(string(s.osc))
+		s.state = stateGround
+		if c != '\\' {
+			s.feed(c)
+		}
+	case stateCharset:
+		s.state = stateGround
+	}
+}
+
+// ground handles the text and control characters outside of escape
+// sequences.
+func (s *Screen) ground(c byte) {
+	if c < ' ' || c == 0x7f {
+		s.utf8 = s.utf8[:0]
+		s.control(c)
+		return
+	}
+	if c < utf8.RuneSelf && len(s.utf8) == 0 {
+		s.put(rune(c))
+		return
+	}
+	s.utf8 = append(s.utf8, c)
+	if utf8.FullRune(s.utf8) {
+		r, _ := utf8.DecodeRune(s.utf8)
+		s.utf8 = s.utf8[:0]
+		s.put(r)
+	}
+}
+
+func (s *Screen) control(c byte) {
+	switch c {
+	case '\x1b':
+		s.state = stateEscape
+	case '\r':
+		s.X = 0
+		s.wrapnext = false
+	case '\n', '\v', '\f':
+		s.linefeed()
+	case '\b':
+		if s.X > 0 {
+			s.X--
+		}
+		s.wrapnext = false
+	case '\t':
+		s.X = clamp((s.X/8+1)*8, 0, s.Cols-1)
+	}
+}
+
+func (s *Screen) escape(c byte) {
+	s.state = stateGround
+	switch c {
+	case '[':
+		s.params = s.params[:0]
+		s.state = stateCSI
+	case ']':
+		s.osc = s.osc[:0]
+		s.state = stateOSC
+	case '(', ')', '*', '+':
+		s.state = stateCharset
+	case '7':
+		s.saveCursor()
+	case '8':
+		s.restoreCursor()
+	case 'D':
+		s.linefeed()
+	case 'E':
+		s.X = 0
+		s.linefeed()
+	case 'M':
+		if s.Y == s.top {
+			s.scrollDown(1)
+		} else if s.Y > 0 {
+			s.Y--
+		}
+	case 'c':
+		rows, cols := s.Rows, s.Cols
+		*s = Screen{}
+		s.Resize(rows, cols)
+	}
+}
+
+// command handles an operating system command, of which only setting
+// the title is supported.
+func (s *Screen) command(osc string) {
+	if code, title, ok := strings.Cut(osc, ";"); ok && (code == "0" || code == "2") {
+		s.Title = title
+	}
+}
+
+func (s *Screen) put(r rune) {
+	if s.wrapnext {
+		s.X = 0
+		s.linefeed()
+		s.wrapnext = false
+	}
+	s.cells[s.Y][s.X] = Cell{Ch: r, Style: s.style}
+	if s.X == s.Cols-1 {
+		s.wrapnext = true
+	} else {
+		s.X++
+	}
+}
+
+func (s *Screen) linefeed() {
+	s.wrapnext = false
+	switch {
+	case s.Y == s.bottom:
+		s.scrollUp(1)
+	case s.Y < s.Rows-1:
+		s.Y++
+	}
+}
+
+// scrollUp moves the lines of the scrolling region up by n.
+func (s *Screen) scrollUp(n int) {
+	s.shiftLines(s.top, n)
+}
+
+// scrollDown moves the lines of the scrolling region down by n.
+func (s *Screen) scrollDown(n int) {
+	s.shiftLines(s.top, -n)
+}
+
+// shiftLines moves the lines from y to the bottom of the scrolling region
+// up by n, or down when n is negative, and blanks the lines left behind.
+func (s *Screen) shiftLines(y, n int) {
+	region := s.cells[y : s.bottom+1]
+	if n > len(region) {
+		n = len(region)
+	} else if n < -len(region) {
+		n = -len(region)
+	}
+	if n > 0 {
+		copy(region, region[n:])
+		region = region[len(region)-n:]
+	} else {
+		copy(region[-n:], region)
+		region = region[:-n]
+	}
+	for i := range region {
+		region[i] = make([]Cell, s.Cols)
+	}
+}
+
+func (s *Screen) saveCursor() {
+	s.saved.x, s.saved.y, s.saved.style = s.X, s.Y, s.style
+}
+
+func (s *Screen) restoreCursor() {
+	s.X = clamp(s.saved.x, 0, s.Cols-1)
+	s.Y = clamp(s.saved.y, 0, s.Rows-1)
+	s.style = s.saved.style
+	s.wrapnext = false
+}
+
+// erase blanks the cells from x0 to x1 of row y.
+func (s *Screen) erase(y, x0, x1 int) {
+	row := s.cells[y]
+	for x := clamp(x0, 0, s.Cols); x < clamp(x1, 0, s.Cols); x++ {
+		row[x] = Cell{Style: Style{BG: s.style.BG}}
+	}
+}
+
+func (s *Screen) csi(params string, final byte) {
+	private := strings.HasPrefix(params, "?")
+	args := parseParams(strings.TrimLeft(params, "?>="))
+	arg := func(i, def int) int {
+		if i < len(args) && args[i] > 0 {
+			return args[i]
+		}
+		return def
+	}
+	n := arg(0, 1)
+	if final != 'm' && final != 'h' && final != 'l' {
+		s.wrapnext = false
+	}
+	switch final {
+	case 'A':
+		s.Y = clamp(s.Y-n, 0, s.Rows-1)
+	case 'B':
+		s.Y = clamp(s.Y+n, 0, s.Rows-1)
+	case 'C':
+		s.X = clamp(s.X+n, 0, s.Cols-1)
+	case 'D':
+		s.X = clamp(s.X-n, 0, s.Cols-1)
+	case 'E':
+		s.X, s.Y = 0, clamp(s.Y+n, 0, s.Rows-1)
+	case 'F':
+		s.X, s.Y = 0, clamp(s.Y-n, 0, s.Rows-1)
+	case 'G', '`':
+		s.X = clamp(n-1, 0, s.Cols-1)
+	case 'd':
+		s.Y = clamp(n-1, 0, s.Rows-1)
+	case 'H', 'f':
+		s.Y = clamp(arg(0, 1)-1, 0, s.Rows-1)
+		s.X = clamp(arg(1, 1)-1, 0, s.Cols-1)
+	case 'J':
+		switch arg(0, 0) {
+		case 0:
+			s.erase(s.Y, s.X, s.Cols)
+			for y := s.Y + 1; y < s.Rows; y++ {
+				s.erase(y, 0, s.Cols)
+			}
+		case 1:
+			for y := 0; y < s.Y; y++ {
+				s.erase(y, 0, s.Cols)
+			}
+			s.erase(s.Y, 0, s.X+1)
+		case 2, 3:
+			for y := 0; y < s.Rows; y++ {
+				s.erase(y, 0, s.Cols)
+			}
+		}
+	case 'K':
+		switch arg(0, 0) {
+		case 0:
+			s.erase(s.Y, s.X, s.Cols)
+		case 1:
+			s.erase(s.Y, 0, s.X+1)
+		case 2:
+			s.erase(s.Y, 0, s.Cols)
+		}
+	case 'L':
+		if s.top <= s.Y && s.Y <= s.bottom {
+			s.shiftLines(s.Y, -n)
+		}
+	case 'M':
+		if s.top <= s.Y && s.Y <= s.bottom {
+			s.shiftLines(s.Y, n)
+		}
+	case '@':
+		row := s.cells[s.Y]
+		n = clamp(n, 0, s.Cols-s.X)
+		copy(row[s.X+n:], row[s.X:])
+		s.erase(s.Y, s.X, s.X+n)
+	case 'P':
+		row := s.cells[s.Y]
+		n = clamp(n, 0, s.Cols-s.X)
+		copy(row[s.X:], row[s.X+n:])
+		s.erase(s.Y, s.Cols-n, s.Cols)
+	case 'X':
+		s.erase(s.Y, s.X, s.X+n)
+	case 'S':
+		s.scrollUp(n)
+	case 'T':
+		s.scrollDown(n)
+	case 'r':
+		top, bottom := arg(0, 1)-1, arg(1, s.Rows)-1
+		if top < bottom && bottom < s.Rows {
+			s.top, s.bottom = top, bottom
+			s.X, s.Y = 0, 0
+		}
+	case 's':
+		s.saveCursor()
+	case 'u':
+		s.restoreCursor()
+	case 'm':
+		s.sgr(args)
+	case 'h', 'l':
+		if private {
+			s.setModes(args, final == 'h')
+		}
+	case 'n':
+		if arg(0, 0) == 6 {
+			s.reply = append(s.reply, fmt.Sprintf("\x1b[%d;%dR", s.Y+1, s.X+1)...)
+		}
+	case 'c':
+		if !strings.HasPrefix(params, ">") {
+			s.reply = append(s.reply, "\x1b[?1;2c"...)
+		}
+	}
+}
+
+func parseParams(params string) []int {
+	if params == "" {
+		return nil
+	}
+	var args []int
+	for _, p := range strings.FieldsFunc(params, func(r rune) bool { return r == ';' || r == ':' }) {
+		n, _ := strconv.Atoi(p)
+		args = append(args, n)
+	}
+	return args
+}
+
+// setModes sets or resets the private modes.
+func (s *Screen) setModes(modes []int, set bool) {
+	for _, mode := range modes {
+		switch mode {
+		case 1:
+			s.AppCursor = set
+		case 25:
+			s.HideCursor = !set
+		case 47, 1047, 1049:
+			if set == (s.main != nil) {
+				continue
+			}
+			if set {
+				if mode == 1049 {
+					s.saveCursor()
+				}
+				s.main = s.cells
+				s.cells = blankRows(s.Rows, s.Cols)
+			} else {
+				s.cells, s.main = s.main, nil
+				if mode == 1049 {
+					s.restoreCursor()
+				}
+			}
+		}
+	}
+}
+
+// sgr sets the style of the following text.
+func (s *Screen) sgr(args []int) {
+	if len(args) == 0 {
+		args = []int{0}
+	}
+	for i := 0; i < len(args); i++ {
+		switch a := args[i]; {
+		case a == 0:
+			s.style = Style{}
+		case a == 1:
+			s.style.Bold = true
+		case a == 4:
+			s.style.Underline = true
+		case a == 7:
+			s.style.Reverse = true
+		case a == 22:
+			s.style.Bold = false
+		case a == 24:
+			s.style.Underline = false
+		case a == 27:
+			s.style.Reverse = false
+		case 30 <= a && a <= 37:
+			s.style.FG = a - 30 + 1
+		case 40 <= a && a <= 47:
+			s.style.BG = a - 40 + 1
+		case 90 <= a && a <= 97:
+			s.style.FG = a - 90 + 8 + 1
+		case 100 <= a && a <= 107:
+			s.style.BG = a - 100 + 8 + 1
+		case a == 39:
+			s.style.FG = 0
+		case a == 49:
+			s.style.BG = 0
+		case a == 38 || a == 48:
+			color := &s.style.FG
+			if a == 48 {
+				color = &s.style.BG
+			}
+			switch {
+			case i+2 < len(args) && args[i+1] == 5:
+				*color = clamp(args[i+2], 0, 255) + 1
+				i += 2
+			case i+4 < len(args) && args[i+1] == 2:
+				*color = rgbColor(args[i+2], args[i+3], args[i+4]) + 1
+				i += 4
+			}
+		}
+	}
+}
+
+// rgbColor returns the closest color of the 6x6x6 cube in the 256
+// color palette.
+func rgbColor(r, g, b int) int {
+	level := func(v int) int {
+		return (clamp(v, 0, 255)*5 + 127) / 255
+	}
+	return 16 + 36*level(r) + 6*level(g) + level(b)
+}
+
+func clamp(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
@@ -1,837 +1,251 @@
 package main
 
 import (
-	"bufio"
-	"bytes"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
-	"time"
-	"unicode"
 
-	"golang.org/x/exp/slices"
-	"golang.org/x/sys/unix"
+	"github.com/icholy/kilo/editor"
 )
 
-const version = "0.0.1"
-const tabstop = 8
-
-type Highlight int
-
-const (
-	HighlightNormal Highlight = iota
-	HighlightNumber
-	HighlightMatch
-	HighlightKeyword
-	HighlightType
-	HighlightString
-)
-
-func editorSyntaxToColor(hl Highlight) int {
-	switch hl {
-	case HighlightNumber:
-		return 31
-	case HighlightString:
-		return 33
-	case HighlightMatch:
-		return 34
-	case HighlightKeyword:
-		return 35
-	case HighlightType:
-		return 36
-	default:
-		return 37
-	}
-}
-
-type Row struct {
-	chars  []byte
-	render []byte
-	hl     []Highlight
-}
-
-func (r *Row) Len() int {
-	return len(r.chars)
-}
-
-func (r *Row) Truncate(n int) {
-	if r.Len() > n {
-		r.chars = r.chars[:n]
-		r.Update()
-	}
-}
-
-func (r *Row) InsertChar(at, c int) {
-	if at < 0 || at > r.Len() {
-		at = r.Len()
-	}
-	r.chars = slices.Insert(r.chars, at, byte(c))
-	r.Update()
-}
-
-func (r *Row) DeleteChar(at int) {
-	if at < 0 || at > r.Len() {
-		return
-	}
-	r.chars = slices.Delete(r.chars, at, at+1)
-	r.Update()
-}
-
-func (r *Row) Append(chars []byte) {
-	r.chars = append(r.chars, chars...)
-	r.Update()
-}
-
-func isDigit(c byte) bool {
-	return '0' <= c && c <= '9'
-}
-
-func isDelim(c byte) bool {
-	if unicode.IsSpace(rune(c)) || c == 0 {
-		return true
-	}
-	switch c {
-	case ',', '.', '(', ')', '+', '-', '/', '*', '=', '~', '%', '<', '>', '[', ']', '^', ':':
-		return true
-	default:
-		return false
-	}
-}
-
-func isKeyword(token []byte) bool {
-	switch string(token) {
-	case "if", "else", "switch", "case", "func", "then", "for", "var", "type", "interface", "const", "range",
-		"return", "struct", "default", "iota", "nil", "package", "import", "map", "break", "continue":
-		return true
-	default:
-		return false
-	}
-}
-
-func isType(token []byte) bool {
-	switch string(token) {
-	case "int", "int8", "int16", "int32", "int64",
-		"uint", "uint8", "uint16", "uint32", "uint64",
-		"float32", "float64",
-		"byte", "rune", "bool", "string",
-		"complex64", "complex128",
-		"any", "error", "comparable":
-		return true
-	default:
-		return false
-	}
-}
-
-func (r *Row) Update() {
-	if r.render == nil {
-		r.render = make([]byte, 0, r.Len())
-	} else {
-		r.render = r.render[:0]
-	}
-	for _, b := range r.chars {
-		if b == '\t' {
-			r.render = append(r.render, ' ')
-			for len(r.render)%tabstop != 0 {
-				r.render = append(r.render, ' ')
-			}
-		} else {
-			r.render = append(r.render, b)
-		}
-	}
-	r.UpdateSyntax()
-}
-
-func (r *Row) UpdateSyntax() {
-	if len(r.hl) < len(r.render) {
-		r.hl = make([]Highlight, len(r.render))
-	}
-	var quote byte
-	var token []byte
-	var tokenidx int
-	for i, c := range r.render {
-		r.hl[i] = HighlightNormal
-		switch {
-		case quote != 0 || c == '"' || c == '\'':
-			r.hl[i] = HighlightString
-			if quote == 0 {
-				quote = c
-			} else if quote == c {
-				quote = 0
-			}
-		case isDelim(c):
-			if len(token) > 0 {
-				hl := HighlightNormal
-				if isKeyword(token) {
-					hl = HighlightKeyword
-				}
-				if isType(token) {
-					hl = HighlightType
-				}
-				for j := 0; j < len(token); j++ {
-					r.hl[tokenidx+j] = hl
-				}
-			}
-			token = token[:0]
-		case isDigit(c):
-			if len(token) > 0 {
-				token = append(token, c)
-			} else {
-				r.hl[i] = HighlightNumber
-			}
-		default:
-			if len(token) == 0 {
-				tokenidx = i
-			}
-			token = append(token, c)
-		}
+func main() {
+	opts := editor.DefaultOptions()
+	opts.Templates = filepath.Join(configDir(), "templates")
+	opts.Config = configFile()
+	opts.Locales = filepath.Join(configDir(), "locale")
+	flag.BoolVar(&opts.AutoPairs, "autopairs", opts.AutoPairs, "automatically close brackets and quotes")
+	flag.StringVar(&opts.Dict, "dict", opts.Dict, "spell checking dictionary (hunspell .dic or word list)")
+	flag.BoolVar(&opts.FormatOnSave, "format-on-save", opts.FormatOnSave, "run the filetype's formatter when saving")
+	flag.StringVar(&opts.Build, "build", opts.Build, "command used to build the project (default go build ./... for Go, make otherwise)")
+	flag.DurationVar(&opts.EscTimeout, "esc-timeout", opts.EscTimeout, "maximum delay between the bytes of an escape sequence")
+	flag.StringVar(&opts.WordChars, "wordchars", opts.WordChars, "characters other than letters and digits which are part of words")
+	flag.BoolVar(&opts.ExpandTab, "expandtab", opts.ExpandTab, "indent with spaces instead of tabs")
+	flag.IntVar(&opts.ShiftWidth, "shiftwidth", opts.ShiftWidth, "number of spaces per indent level when expandtab is set")
+	flag.BoolVar(&opts.List, "list", opts.List, "show tabs and trailing whitespace")
+	flag.BoolVar(&opts.ListSpaces, "listspaces", opts.ListSpaces, "show spaces as middle dots when whitespace is shown")
+	flag.BoolVar(&opts.CursorLine, "cursorline", opts.CursorLine, "highlight the line the cursor is on")
+	flag.BoolVar(&opts.CursorColumn, "cursorcolumn", opts.CursorColumn, "highlight the column the cursor is on")
+	flag.BoolVar(&opts.HighlightWord, "highlight-word", opts.HighlightWord, "highlight occurrences of the word under the cursor")
+	flag.IntVar(&opts.ScrollOff, "scrolloff", opts.ScrollOff, "minimum number of rows kept visible above and below the cursor")
+	flag.BoolVar(&opts.Scrollbar, "scrollbar", opts.Scrollbar, "show a scrollbar in the rightmost column")
+	flag.BoolVar(&opts.Diagnostics, "diagnostics", opts.Diagnostics, "show the diagnostics of the language server and the build errors dimmed after their lines, and their number in the gutter")
+	flag.StringVar(&opts.StatusLine, "statusline", opts.StatusLine, "status bar format: %f file, %m modified, %l line, %c column, %= align right")
+	flag.BoolVar(&opts.Modelines, "modelines", opts.Modelines, "apply the tab stop, indentation, and filetype set by vim and emacs modelines")
+	flag.BoolVar(&opts.ScreenReader, "screen-reader", opts.ScreenReader, "draw only the rows which change, without decorations, and announce the line, word, or character the cursor moves to")
+	flag.StringVar(&opts.Announce, "announce", opts.Announce, "where the screen reader mode's announcements go: status for the message bar, >file to append them to a file, or a command such as espeak which reads them")
+	flag.StringVar(&opts.Language, "lang", opts.Language, "language of the messages, e.g. de, from LC_ALL, LC_MESSAGES, or LANG by default, with catalogs like de.po in ~/.config/kilo/locale")
+	flag.BoolVar(&opts.Timing, "timing", opts.Timing, "show how long drawing the screen and handling keys take in the status bar")
+	flag.Var(&opts.LogLevel, "log", "how much is written to ~/.local/state/kilo/kilo.log: off, error, info, or debug")
+	flag.StringVar(&opts.Formatter, "formatter", opts.Formatter, "command which formats the buffer instead of the filetype's formatter, %f is the file name")
+	flag.IntVar(&opts.TabStop, "tabstop", opts.TabStop, "number of columns between tab stops")
+	flag.IntVar(&opts.TextWidth, "textwidth", opts.TextWidth, "column past which prose is wrapped while typing, 0 to turn it off")
+	flag.StringVar(&opts.Timestamp, "timestamp-format", opts.Timestamp, "strftime format of the timestamp command")
+	flag.StringVar(&opts.Templates, "templates", opts.Templates, "directory of templates for new files, ${cursor} marks where the cursor goes, and ${filename}, ${user}, ${date} and the like are expanded")
+	batch := flag.String("batch", "", "apply the commands in a script, or a .lua script, to the file and exit")
+	exportHTML := flag.Bool("export-html", false, "write the file with its syntax highlighting as HTML to stdout and exit")
+	exportANSI := flag.Bool("export-ansi", false, "write the file with its syntax highlighting as ANSI colored text to stdout and exit")
+	keys := flag.String("keys", "", "type the keys in a file, or - for stdin, without a terminal and exit, e.g. ihello<enter><ctrl-s>")
+	dumpScreen := flag.String("dump-screen", "", "write the screen as plain text to a file, or - for stdout, once the keys are typed")
+	record := flag.String("record", "", "write the keys typed to a file which --keys can replay")
+	listen := flag.String("listen", "", "serve the JSON-RPC API on a unix socket, which --remote uses too (default $XDG_RUNTIME_DIR/kilo-UID.sock with --remote)")
+	profile := flag.String("profile", "", "write a CPU profile to `prefix`.cpu while running, and a heap profile to prefix.heap on exit")
+	remote := flag.Bool("remote", false, "open the file in the editor listening on the socket, or start a new one if there is none")
+	if err := loadConfig(configFile()); err != nil {
+		log.Fatal(err)
 	}
-	if len(token) > 0 {
-		hl := HighlightNormal
-		if isKeyword(token) {
-			hl = HighlightKeyword
-		}
-		if isType(token) {
-			hl = HighlightType
+	flag.Parse()
+	filename, line, col := parseFileArgs(flag.Args())
+	socket := *listen
+	if *remote && socket == "" {
+		var err error
+		if socket, err = socketPath(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+	if *remote && filename != "" {
+		err := remoteOpen(socket, filename, line, col)
+		if err == nil {
+			return
 		}
-		for j := 0; j < len(token); j++ {
-			r.hl[tokenidx+j] = hl
+		if !errors.Is(err, editor.ErrNotListening) {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
 		}
 	}
-}
-
-func (r Row) CxToRx(cx int) int {
-	var rx int
-	for _, c := range r.chars[:cx] {
-		if c == '\t' {
-			rx += (tabstop - 1) - rx%tabstop
+	if *profile != "" {
+		stop, err := startProfile(*profile)
+		if err != nil {
+			log.Fatal(err)
 		}
-		rx++
-	}
-	return rx
-}
-
-var E struct {
-	termios    unix.Termios
-	screenrows int
-	screencols int
-	cx         int
-	cy         int
-	rx         int
-	numrows    int
-	rowoff     int
-	coloff     int
-	rows       []*Row
-	debug      string
-	status     string
-	statustime time.Time
-	filename   string
-	dirty      bool
-}
-
-func enableRawMode() {
-	raw, err := unix.IoctlGetTermios(0, unix.TCGETS)
-	if err != nil {
-		log.Fatalf("failed to get termios: %v", err)
-	}
-	E.termios = *raw
-	raw.Iflag &^= unix.BRKINT | unix.ICRNL | unix.INPCK | unix.ISTRIP | unix.IXON
-	raw.Oflag &^= unix.OPOST
-	raw.Cflag &^= unix.CS8
-	raw.Lflag &^= unix.ECHO | unix.ICANON | unix.IEXTEN | unix.ISIG
-	raw.Cc[unix.VMIN] = 0
-	raw.Cc[unix.VTIME] = 1
-	if err := unix.IoctlSetTermios(unix.Stdin, unix.TCSETS, raw); err != nil {
-		log.Fatalf("failed to set termios: %v", err)
-	}
-}
-
-func restoreMode() {
-	if err := unix.IoctlSetTermios(unix.Stdin, unix.TCSETS, &E.termios); err != nil {
-		log.Fatalf("failed to restore termios: %v", err)
-	}
-}
-
-func die(format string, args ...any) {
-	editorRefreshScreen()
-	msg := fmt.Sprintf(format, args...)
-	unix.Write(unix.Stdout, []byte(msg))
-	unix.Exit(0)
-}
-
-func initEditor() {
-	E.screenrows, E.screencols = getWindowSize()
-	E.screenrows -= 2 // room for status bar & message
-}
-
-func editorOpen(filename string) {
-	E.filename = filename
-	f, err := os.Open(filename)
-	if err != nil {
-		die("failed to open file: %s", err)
-	}
-	defer f.Close()
-	sc := bufio.NewScanner(f)
-	for sc.Scan() {
-		editorInsertRow(E.numrows, slices.Clone(sc.Bytes()))
+		defer func() {
+			if err := stop(); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			}
+		}()
 	}
-	if err := sc.Err(); err != nil {
-		die("failed to read file: %s", err)
+	e := editor.New(opts)
+	if err := loadInitScript(e); err != nil {
+		log.Fatal(err)
 	}
-}
-
-func editorSave() {
-	if E.filename == "" {
-		name, ok := editorPrompt("Save as:", nil)
-		if !ok {
-			return
+	if filename != "" {
+		if err := e.Open(filename); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
 		}
-		E.filename = name
-	}
-	f, err := os.OpenFile(E.filename, os.O_RDWR|os.O_CREATE, 0644)
-	if err != nil {
-		die("save failed: %v", err)
-	}
-	defer f.Close()
-	if err := f.Truncate(0); err != nil {
-		die("save failed: %v", err)
-	}
-	if err := writeRowsTo(f); err != nil {
-		die("save failed: %v", err)
-	}
-	if err := f.Close(); err != nil {
-		die("save failed: %v", err)
-	}
-	E.dirty = false
-	editorSetStatus("saved %s", E.filename)
-}
-
-func getWindowSize() (rows, cols int) {
-	ws, err := unix.IoctlGetWinsize(unix.Stdout, unix.TIOCGWINSZ)
-	if err != nil {
-		// fallback mechanism
-		if _, err := unix.Write(unix.Stdout, []byte("\x1b[999C\x1b[999B")); err != nil {
-			die("failed to get window size: %v", err)
+		if line > 0 {
+			e.Goto(line, col)
 		}
-		return getCursorPosition()
-
-	}
-	return int(ws.Row), int(ws.Col)
-}
-
-func getCursorPosition() (row, col int) {
-	if _, err := unix.Write(unix.Stdout, []byte("\x1b[6n")); err != nil {
-		die("getCursorPosition: %v", err)
 	}
-	var buf [32]byte
-	var i int
-	for i < len(buf)-1 {
-		if n, _ := unix.Read(unix.Stdin, buf[i:i+1]); n != 1 {
-			break
+	if *exportHTML || *exportANSI {
+		format := "html"
+		if *exportANSI {
+			format = "ansi"
 		}
-		if buf[i] == 'R' {
-			break
+		err := e.Export(os.Stdout, format)
+		e.Close()
+		if err != nil {
+			log.Fatal(err)
 		}
-		i++
-	}
-	if buf[0] != '\x1b' || buf[1] != '[' {
-		die("invalid escape sequence")
-	}
-	if n, err := fmt.Sscanf(string(buf[2:i]), "%d;%d", &row, &col); n != 2 {
-		die("failed to scan cursor pos: %v", err)
+		return
 	}
-	return row, col
-}
-
-func controlKey(c byte) int {
-	return int(c & 0b00011111)
-}
-
-const (
-	BackspaceKey = 127
-	ArrowLeft    = iota + 1000
-	ArrowRight
-	ArrowUp
-	ArrowDown
-	PageUp
-	PageDown
-	HomeKey
-	EndKey
-	DeleteKey
-)
-
-func editorReadKey() int {
-	var c int
-	var b [1]byte
-	for {
-		n, err := unix.Read(unix.Stdin, b[:])
-		if n == 1 {
-			c = int(b[0])
-			break
-		}
-		if n == -1 && err != unix.EAGAIN {
-			die("read: %v", err)
+	if *batch != "" {
+		if err := runBatch(e, *batch); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
 		}
+		return
 	}
-	// handle escale sequences
-	if c == '\x1b' {
-		var seq [3]byte
-		if n, _ := unix.Read(unix.Stdin, seq[:1]); n != 1 {
-			return c
-		}
-		if n, _ := unix.Read(unix.Stdin, seq[1:2]); n != 1 {
-			return c
-		}
-		if seq[0] == '[' {
-			// page up/page down
-			if seq[1] >= '0' && seq[1] <= '9' {
-				if n, _ := unix.Read(unix.Stdin, seq[2:]); n != 1 {
-					return c
-				}
-				if seq[2] == '~' {
-					switch seq[1] {
-					case '3':
-						return DeleteKey
-					case '5':
-						return PageUp
-					case '6':
-						return PageDown
-					case '1', '7':
-						return HomeKey
-					case '4', '8':
-						return EndKey
-					}
-				}
-			}
-			// arrow keys
-			switch seq[1] {
-			case 'A':
-				return ArrowUp
-			case 'B':
-				return ArrowDown
-			case 'C':
-				return ArrowRight
-			case 'D':
-				return ArrowLeft
-			case 'H':
-				return HomeKey
-			case 'F':
-				return EndKey
-			}
-		} else {
-			if seq[0] == 'O' {
-				switch seq[1] {
-				case 'H':
-					return HomeKey
-				case 'F':
-					return EndKey
-				}
-			}
+	if *keys != "" || *dumpScreen != "" {
+		if err := replayKeys(e, *keys, *dumpScreen); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
 		}
+		return
 	}
-	return c
-}
-
-func editorPrompt(prompt string, callback func(input string, key int)) (string, bool) {
-	var input []byte
-	for {
-		editorSetStatus("%s %s (ESC to cancel)", prompt, input)
-		editorRefreshScreen()
-		c := editorReadKey()
-		if c == DeleteKey || c == controlKey('h') || c == BackspaceKey {
-			if len(input) > 0 {
-				input = input[:len(input)-1]
-			}
-		} else if c == '\x1b' || c == controlKey('q') {
-			editorSetStatus("")
-			return "", false
-		} else if c == '\r' {
-			if len(input) != 0 {
-				editorSetStatus("")
-				if callback != nil {
-					callback(string(input), c)
-				}
-				return string(input), true
-			}
-		} else if unicode.IsPrint(rune(c)) && c < 128 {
-			input = append(input, byte(c))
-		}
-		if callback != nil {
-			callback(string(input), c)
+	if *record != "" {
+		if err := e.Record(*record); err != nil {
+			log.Fatal(err)
 		}
 	}
-}
-
-type SearchMatch struct {
-	cx, cy int
-}
-
-func editorFind() {
-	// save the cursor state in case we cancel
-	cx, cy := E.cx, E.cy
-	rowoff, coloff := E.rowoff, E.coloff
-
-	// the search matches
-	var matchidx int
-	var matches []SearchMatch
-
-	_, ok := editorPrompt("Search:", func(input string, c int) {
-		switch c {
-		case '\r', '\x1b':
-			return
-		case ArrowUp, ArrowLeft:
-			matchidx--
-		case ArrowDown, ArrowRight:
-			matchidx++
-		default:
-			if len(input) == 0 {
-				return
-			}
-			matches = matches[:0]
-			query := []byte(input)
-			for y, r := range E.rows {
-				r.UpdateSyntax() // clear highlight
-				var off int
-				for off < len(r.chars) {
-					i := bytes.Index(r.chars[off:], query)
-					if i < 0 {
-						break
-					}
-					m := SearchMatch{cx: off + i, cy: y}
-					matches = append(matches, m)
-					off += i + 1
-
-					// highlight
-					rx := r.CxToRx(m.cx)
-					for x := rx; x < rx+len(query); x++ {
-						r.hl[x] = HighlightMatch
-					}
-				}
-			}
-		}
-
-		if len(matches) > 0 {
-			// fix the match index
-			if matchidx < 0 {
-				matchidx += len(matches)
-			} else {
-				matchidx = matchidx % len(matches)
-			}
-			m := matches[matchidx]
-			E.cy = m.cy
-			E.cx = m.cx
-			E.rowoff = E.numrows
+	// the API is only served when asked for, it can run commands
+	if socket != "" {
+		if err := e.Listen(socket); err != nil {
+			log.Fatal(err)
 		}
-	})
-	// restore cursor if user hit escape
-	if !ok {
-		E.cx = cx
-		E.cy = cy
-		E.rowoff = rowoff
-		E.coloff = coloff
 	}
-	// clear the status line
-	E.debug = ""
-	// clear highlights
-	for _, r := range E.rows {
-		r.UpdateSyntax()
+	if err := e.Run(); err != nil {
+		log.Fatal(err)
 	}
 }
 
-func editorSetStatus(format string, args ...any) {
-	E.status = fmt.Sprintf(format, args...)
-	E.statustime = time.Now()
-}
-
-func editorDrawStatusBar(b *bytes.Buffer) {
-	// status bar
-	b.WriteString("\x1b[7m")
-	filename := E.filename
-	if filename == "" {
-		filename = "[No Name]"
-	}
-	status := fmt.Sprintf("%.20s - line %d/%d", filename, E.cy+1, E.numrows)
-	if E.dirty {
-		status += " (modified)"
-	}
-	if E.debug != "" {
-		status += " " + E.debug
-	}
-	if len(status) > E.screencols {
-		status = status[:E.screencols]
-	}
-	b.WriteString(status)
-	for i := len(status); i < E.screencols; i++ {
-		b.WriteString(" ")
+// runBatch applies a script of editor commands, or a Lua script, without
+// a terminal.
+func runBatch(e *editor.Editor, script string) error {
+	defer e.Close()
+	f, err := os.Open(script)
+	if err != nil {
+		return err
 	}
-	b.WriteString("\x1b[m")
-	b.WriteString("\r\n")
-	// status message
-	b.WriteString("\x1b[K")
-	if E.status != "" {
-		if time.Since(E.statustime) > 5*time.Second {
-			E.status = ""
-			return
+	defer f.Close()
+	if filepath.Ext(script) == ".lua" {
+		return e.RunLua(script, f)
+	}
+	return e.RunScript(script, f)
+}
+
+// replayKeys types the keys in a key file without a terminal, and
+// writes the screen to dump if it's set. Both can be - for stdin and
+// stdout.
+func replayKeys(e *editor.Editor, keyfile, dump string) error {
+	defer e.Close()
+	var keys []int
+	if keyfile != "" {
+		var data []byte
+		var err error
+		if keyfile == "-" {
+			data, err = io.ReadAll(os.Stdin)
+		} else {
+			data, err = os.ReadFile(keyfile)
 		}
-		message := E.status
-		if len(status) > E.screencols {
-			message = message[:E.screencols]
+		if err != nil {
+			return err
+		}
+		if keys, err = editor.ParseKeys(string(data)); err != nil {
+			return fmt.Errorf("%s: %w", keyfile, err)
 		}
-		b.WriteString(message)
-	}
-}
-
-func editorInsertRow(at int, chars []byte) {
-	row := &Row{chars: chars}
-	row.Update()
-	E.rows = slices.Insert(E.rows, at, row)
-	E.numrows++
-	E.dirty = true
-}
-
-func editorDeleteRow(at int) {
-	if at < 0 || at >= E.numrows {
-		return
-	}
-	if E.cx == 0 && E.cy == 0 {
-		return
 	}
-	E.rows = slices.Delete(E.rows, at, at+1)
-	E.numrows--
-	E.dirty = true
-}
-
-func editorInsertChar(c int) {
-	if E.cy == E.numrows {
-		editorInsertRow(E.numrows, nil)
+	for _, k := range keys {
+		if e.Closed() {
+			break
+		}
+		e.HandleKey(k)
 	}
-	E.rows[E.cy].InsertChar(E.cx, c)
-	E.cx++
-	E.dirty = true
-}
-
-func editorDeleteChar() {
-	if E.cy == E.numrows {
-		return
+	if dump == "" {
+		return nil
 	}
-	if E.cx == 0 && E.cy == 0 {
-		return
+	if dump == "-" {
+		return e.DumpScreen(os.Stdout)
 	}
-	row := E.rows[E.cy]
-	if E.cx > 0 {
-		row.DeleteChar(E.cx - 1)
-		E.cx--
-	} else {
-		E.cx = E.rows[E.cy-1].Len()
-		E.rows[E.cy-1].Append(row.chars)
-		editorDeleteRow(E.cy)
-		E.cy--
+	f, err := os.Create(dump)
+	if err != nil {
+		return err
 	}
-}
-
-func editorInsertNewline() {
-	if E.cx == 0 {
-		editorInsertRow(E.cy, nil)
-	} else {
-		editorInsertRow(E.cy+1, E.rows[E.cy].chars[E.cx:])
-		E.rows[E.cy].Truncate(E.cx)
+	if err := e.DumpScreen(f); err != nil {
+		f.Close()
+		return err
 	}
-	E.cy++
-	E.cx = 0
+	return f.Close()
 }
 
-func editorProcessKeypress() {
-	c := editorReadKey()
-	switch c {
-	case controlKey('q'):
-		editorRefreshScreen()
-		restoreMode()
-		unix.Exit(0)
-	case controlKey('s'):
-		editorSave()
-	case controlKey('f'):
-		editorFind()
-	case ArrowUp, ArrowDown, ArrowLeft, ArrowRight:
-		editorMoveCursor(c)
-	case PageUp:
-		E.cy = E.rowoff
-		for i := 0; i < E.screenrows; i++ {
-			editorMoveCursor(ArrowUp)
-		}
-	case PageDown:
-		E.cy = E.rowoff + E.screenrows - 1
-		if E.cy > E.numrows {
-			E.cy = E.numrows
-		}
-		for i := 0; i < E.screenrows; i++ {
-			editorMoveCursor(ArrowDown)
-		}
-	case HomeKey:
-		E.cx = 0
-	case EndKey:
-		if E.cy < E.numrows {
-			E.cx = E.rows[E.cy].Len()
-		}
-	case '\r':
-		editorInsertNewline()
-	case DeleteKey:
-		editorMoveCursor(ArrowRight)
-		editorDeleteChar()
-	case controlKey('h'), BackspaceKey:
-		editorDeleteChar()
-	case controlKey('l'), '\x1b':
-		// ignore
-	default:
-		editorInsertChar(c)
+// remoteOpen asks the editor listening on the socket to open the file.
+func remoteOpen(socket, filename string, line, col int) error {
+	if abs, err := filepath.Abs(filename); err == nil && !strings.HasPrefix(filename, "scp://") {
+		filename = abs
 	}
+	params := map[string]any{"filename": filename, "line": line, "col": col}
+	return editor.RemoteCall(socket, "open", params, nil)
 }
 
-func editorMoveCursor(c int) {
-	var row *Row
-	if E.cy < E.numrows {
-		row = E.rows[E.cy]
+// parseFileArgs returns the file named on the command line and the
+// position to open it at. The position is given either as +line
+// before the name, or as a file:line:col suffix like in compiler
+// output. A line of 0 means no position was given.
+func parseFileArgs(args []string) (filename string, line, col int) {
+	if len(args) == 0 {
+		return "", 0, 0
 	}
-	switch c {
-	case ArrowUp:
-		if E.cy > 0 {
-			E.cy--
-		}
-	case ArrowDown:
-		if E.cy < E.numrows {
-			E.cy++
-		}
-	case ArrowLeft:
-		if E.cx > 0 {
-			E.cx--
-		} else if E.cy > 0 {
-			E.cy--
-			E.cx = E.rows[E.cy].Len()
-		}
-	case ArrowRight:
-		if row.chars != nil && E.cx < row.Len() {
-			E.cx++
-		} else if row.chars != nil && E.cx == row.Len() {
-			E.cy++
-			E.cx = 0
+	if len(args) > 1 && strings.HasPrefix(args[0], "+") {
+		if n, err := strconv.Atoi(args[0][1:]); err == nil {
+			return args[1], n, 0
 		}
 	}
-
-	if E.cy < E.numrows {
-		row := E.rows[E.cy]
-		if E.cx > row.Len() {
-			E.cx = row.Len()
-		}
+	filename = args[0]
+	// names can contain colons, so prefer an existing file
+	if _, err := os.Stat(filename); err == nil {
+		return filename, 0, 0
 	}
-}
-
-func writeRowsTo(w io.Writer) error {
-	for _, r := range E.rows {
-		if _, err := w.Write(r.chars); err != nil {
-			return err
-		}
-		if _, err := w.Write([]byte("\n")); err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
-func editorScroll() {
-	E.rx = 0
-	if E.cy < E.numrows {
-		E.rx = E.rows[E.cy].CxToRx(E.cx)
-	}
-	if E.cy < E.rowoff {
-		E.rowoff = E.cy
-	}
-	if E.cy >= E.rowoff+E.screenrows {
-		E.rowoff = E.cy - E.screenrows + 1
-	}
-	if E.rx < E.coloff {
-		E.coloff = E.rx
-	}
-	if E.rx >= E.coloff+E.screencols {
-		E.coloff = E.rx - E.screencols + 1
-	}
-}
-
-func editorRefreshScreen() {
-	editorScroll()
-	var b bytes.Buffer
-	b.WriteString("\x1b[?25l") // hide cursor
-	b.WriteString("\x1b[H")    // put cursor at top left
-	editorDrawRows(&b)
-	editorDrawStatusBar(&b)
-	fmt.Fprintf(&b, "\x1b[%d;%dH", E.cy-E.rowoff+1, E.rx-E.coloff+1) // move cursor to correct position
-	b.WriteString("\x1b[?25h")                                       // show cursor
-	unix.Write(unix.Stdout, b.Bytes())
-}
-
-func editorDrawRows(b *bytes.Buffer) {
-	for y := 0; y < E.screenrows; y++ {
-		filerow := y + E.rowoff
-		if filerow >= E.numrows {
-			// print welcome screen
-			if E.numrows == 0 && y == E.screenrows/3 {
-				welcome := fmt.Sprintf("Kilo editor -- version %s", version)
-				if len(welcome) > E.screencols {
-					welcome = welcome[:E.screencols]
-				}
-				padding := (E.screencols - len(welcome)) / 2
-				b.WriteString(strings.Repeat(" ", padding))
-				b.WriteString(welcome)
-			} else {
-				b.WriteString("~")
-			}
-		} else {
-			row := E.rows[filerow]
-			line := row.render
-			coloff := E.coloff
-			if coloff >= len(line) {
-				coloff = 0
-			}
-			line = line[coloff:]
-			if len(line) > E.screencols {
-				line = line[:E.screencols]
-			}
-			var prevcolor int
-			for i, c := range line {
-				hl := row.hl[i+coloff]
-				if hl == HighlightNormal {
-					b.WriteString("\x1b[39m")
-					prevcolor = -1
-				} else {
-					if color := editorSyntaxToColor(hl); color != prevcolor {
-						fmt.Fprintf(b, "\x1b[%dm", color)
-						prevcolor = color
-					}
-				}
-				b.WriteByte(c)
-			}
-			b.WriteString("\x1b[39m")
+	for i := 0; i < 2; i++ {
+		rest, suffix, ok := cutLast(filename, ":")
+		n, err := strconv.Atoi(suffix)
+		if !ok || err != nil || rest == "" {
+			break
 		}
-		b.WriteString("\x1b[K") // clear one line
-		b.WriteString("\r\n")
+		filename, line, col = rest, n, line
 	}
+	return filename, line, col
 }
 
-func main() {
-	flag.Parse()
-	// raw mode
-	enableRawMode()
-	defer restoreMode()
-	// setup
-	initEditor()
-	if flag.NArg() > 0 {
-		editorOpen(flag.Arg(0))
-	}
-	// show help message
-	editorSetStatus("HELP: Ctrl-S = save | Ctrl-Q = quit | Ctrl-F = find")
-	// byte reader loop
-	for {
-		editorRefreshScreen()
-		editorProcessKeypress()
+// cutLast slices s around the last instance of sep.
+func cutLast(s, sep string) (before, after string, found bool) {
+	if i := strings.LastIndex(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
 	}
+	return s, "", false
 }
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"os"
+	"runtime"
+	"runtime/pprof"
+)
+
+// startProfile writes a CPU profile to prefix.cpu until the returned
+// function is called, which also writes a heap profile to prefix.heap.
+func startProfile(prefix string) (func() error, error) {
+	cpu, err := os.Create(prefix + ".cpu")
+	if err != nil {
+		return nil, err
+	}
+	if err := pprof.StartCPUProfile(cpu); err != nil {
+		cpu.Close()
+		return nil, err
+	}
+	return func() error {
+		pprof.StopCPUProfile()
+		if err := cpu.Close(); err != nil {
+			return err
+		}
+		heap, err := os.Create(prefix + ".heap")
+		if err != nil {
+			return err
+		}
+		defer heap.Close()
+		runtime.GC() // get up to date statistics
+		return pprof.WriteHeapProfile(heap)
+	}, nil
+}